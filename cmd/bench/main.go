@@ -0,0 +1,73 @@
+// Command bench measures the token throughput of a running go-copilot-api proxy by
+// sending concurrent chat completion requests for a fixed time.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"copilot-api/internal/bench"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the benchmark and prints its JSON summary, followed by a table if asked. It
+// returns the process exit code: 0 on success, 1 if the benchmark fails and 2 on usage
+// errors.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", "localhost:9191", "address or base URL of the proxy")
+	token := fs.String("token", os.Getenv("COPILOT_TOKEN"), "access token (default: $COPILOT_TOKEN)")
+	model := fs.String("model", "", "model to request (default: the proxy's default model)")
+	concurrency := fs.Int("concurrency", 4, "number of requests in flight at once")
+	duration := fs.Duration("duration", 30*time.Second, "how long to send requests for")
+	stream := fs.Bool("stream", false, "request streamed responses and measure time to first token")
+	table := fs.Bool("table", false, "also print the results as a table")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *concurrency < 1 || *duration <= 0 || fs.NArg() > 0 {
+		fmt.Fprintln(stderr, "usage: bench [--addr host:port] [--token <token>] [--model <model>] [--concurrency n] [--duration 30s] [--stream] [--table]")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	res, err := bench.Run(ctx, bench.Options{
+		Addr:        *addr,
+		Token:       *token,
+		Model:       *model,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Stream:      *stream,
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(res); err != nil {
+		fmt.Fprintf(stderr, "bench: %v\n", err)
+		return 1
+	}
+	if *table {
+		fmt.Fprintln(stdout)
+		if err := res.WriteTable(stdout); err != nil {
+			fmt.Fprintf(stderr, "bench: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
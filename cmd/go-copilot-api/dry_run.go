@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/joho/godotenv"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// secretFields are the Config fields masked in the dry run summary.
+var secretFields = map[string]bool{
+	"CopilotOAuthToken": true,
+	"CopilotToken":      true,
+	"AdminToken":        true,
+	"AuditSigningKey":   true,
+}
+
+// dryRun handles --dry-run and --validate-config: it loads and checks the configuration
+// and prints a summary of it with secrets masked, without starting the server,
+// refreshing the Copilot token or starting background work. It returns the process exit
+// code: 0 if the configuration is valid and 1 otherwise.
+func dryRun(stdout, stderr io.Writer) int {
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(stderr, "Errors:")
+		for _, err := range unjoin(err) {
+			fmt.Fprintf(stderr, "  - %v\n", err)
+		}
+		fmt.Fprintln(stderr, "configuration is invalid")
+		return 1
+	}
+
+	writeConfigSummary(stdout, cfg)
+	warnings, errs := runValidation(cfg)
+	if len(warnings) > 0 {
+		fmt.Fprintln(stdout, "Warnings:")
+		for _, w := range warnings {
+			fmt.Fprintf(stdout, "  - %s\n", w)
+		}
+	}
+	if len(errs) > 0 {
+		fmt.Fprintln(stderr, "Errors:")
+		for _, err := range errs {
+			fmt.Fprintf(stderr, "  - %v\n", err)
+		}
+		fmt.Fprintln(stderr, "configuration is invalid")
+		return 1
+	}
+	fmt.Fprintln(stdout, "configuration is valid")
+	return 0
+}
+
+// runValidation checks what config.Load cannot: that the Copilot OAuth token was found
+// and that token.json can be written. Settings the server accepts but that are likely
+// mistakes are returned as warnings.
+func runValidation(cfg *config.Config) ([]string, []error) {
+	var warnings []string
+	var errs []error
+	if cfg.CopilotTokenGenerated {
+		warnings = append(warnings, "COPILOT_TOKEN is not set; a random token is generated at every start")
+	}
+	if cfg.AdminToken == "" {
+		warnings = append(warnings, "ADMIN_TOKEN is not set; admin endpoints are disabled")
+	}
+	if cfg.CopilotOAuthToken == "" {
+		errs = append(errs, errors.New("no Copilot OAuth token found in OAUTH_TOKEN_SEARCH_PATHS"))
+	}
+	tokenFile := copilot.TokenFilePath()
+	if err := checkWritableDir(filepath.Dir(tokenFile)); err != nil {
+		errs = append(errs, fmt.Errorf("token file %s cannot be written: %w", tokenFile, err))
+	}
+	return warnings, errs
+}
+
+// checkWritableDir reports whether files can be created in dir, or for a missing dir in
+// its closest existing ancestor, by creating and removing a temporary file.
+func checkWritableDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".dry-run-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// writeConfigSummary prints every Config field, masking secrets and API key tokens.
+func writeConfigSummary(w io.Writer, cfg *config.Config) {
+	fmt.Fprintln(w, "Configuration:")
+	v := reflect.ValueOf(*cfg)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		var value interface{} = v.Field(i).Interface()
+		switch {
+		case secretFields[name]:
+			value = mask(v.Field(i).String())
+		case name == "APIKeys":
+			labels := make([]string, len(cfg.APIKeys))
+			for i, k := range cfg.APIKeys {
+				labels[i] = k.Label
+			}
+			value = labels
+		}
+		fmt.Fprintf(w, "  %-30s %v\n", name, value)
+	}
+}
+
+// mask hides a secret, keeping the last four characters of long ones to tell them apart.
+func mask(secret string) string {
+	switch {
+	case secret == "":
+		return ""
+	case len(secret) < 12:
+		return "****"
+	default:
+		return "****" + secret[len(secret)-4:]
+	}
+}
+
+// unjoin returns the errors joined in err, or err itself.
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
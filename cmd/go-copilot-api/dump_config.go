@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"copilot-api/pkg/config"
+)
+
+// dumpConfig runs the dump-config subcommand, which prints the resolved configuration
+// in .env format, or writes it to the --output file. An existing file is only
+// overwritten with --force. It returns the process exit code: 0 on success, 1 if the
+// configuration cannot be loaded or written and 2 on usage errors.
+func dumpConfig(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("dump-config", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	output := fs.String("output", "", "write the configuration to this file instead of stdout")
+	force := fs.Bool("force", false, "overwrite the --output file if it exists")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(stderr, "usage: go-copilot-api dump-config [--output <file> [--force]]")
+		return 2
+	}
+
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "dump-config: failed to load config: %v\n", err)
+		return 1
+	}
+	env := config.Marshal(cfg)
+	if *output == "" {
+		fmt.Fprint(stdout, env)
+		return 0
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if *force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	// The file may hold a generated COPILOT_TOKEN, so only the owner may read it
+	f, err := os.OpenFile(*output, flags, 0600)
+	if errors.Is(err, os.ErrExist) {
+		fmt.Fprintf(stderr, "dump-config: %s already exists; use --force to overwrite it\n", *output)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "dump-config: %v\n", err)
+		return 1
+	}
+	if _, err := io.WriteString(f, env); err != nil {
+		f.Close()
+		fmt.Fprintf(stderr, "dump-config: %v\n", err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(stderr, "dump-config: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stderr, "Wrote the configuration to %s\n", *output)
+	return 0
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// loginTimeout is how long the login subcommand waits for the user to authorize it.
+const loginTimeout = 5 * time.Minute
+
+// login runs the login subcommand, which signs in with GitHub's OAuth device flow, saves
+// the OAuth token to the Copilot apps.json and fetches a first Copilot token. It returns
+// the process exit code: 0 on success, 1 if signing in fails and 2 on usage errors.
+func login(args []string, stdout, stderr io.Writer) int {
+	_ = godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "login: failed to load config: %v\n", err)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("login", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	clientID := fs.String("client-id", cfg.GitHubOAuthClientID, "GitHub OAuth app client ID (default: $GITHUB_OAUTH_CLIENT_ID)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *clientID == "" || fs.NArg() > 0 {
+		fmt.Fprintln(stderr, "usage: go-copilot-api login [--client-id <id>]")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, loginTimeout)
+	defer cancel()
+
+	githubURL := "https://" + cfg.GitHubHost()
+	code, err := copilot.RequestDeviceCode(ctx, githubURL, *clientID)
+	if err != nil {
+		fmt.Fprintf(stderr, "login: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Open %s and enter the code %s\n", code.VerificationURI, code.UserCode)
+	fmt.Fprintln(stdout, "Waiting for authorization...")
+	oauthToken, err := copilot.PollDeviceToken(ctx, githubURL, *clientID, code)
+	if err != nil {
+		fmt.Fprintf(stderr, "login: %v\n", err)
+		return 1
+	}
+
+	tokenOpts := []copilot.TokenManagerOption{
+		copilot.WithGitHubHost(cfg.GitHubHost()),
+		copilot.WithUserAgent(cfg.UserAgent),
+		copilot.WithOAuthToken(oauthToken),
+	}
+	if cfg.GitHubAuthURL != "" {
+		tokenOpts = append(tokenOpts, copilot.WithAuthURL(cfg.GitHubAuthURL))
+	}
+	tokenManager, err := copilot.NewTokenManager(ctx, tokenOpts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "login: %v\n", err)
+		return 1
+	}
+	defer tokenManager.Close()
+	if err := tokenManager.SaveToAppsJSON("", *clientID); err != nil {
+		fmt.Fprintf(stderr, "login: failed to save the OAuth token: %v\n", err)
+		return 1
+	}
+	// Replace any Copilot token cached for a previous account
+	if err := tokenManager.ForceRefresh(ctx); err != nil {
+		fmt.Fprintf(stderr, "login: signed in, but fetching a Copilot token failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "Signed in; the OAuth token was saved to the Copilot apps.json")
+	return 0
+}
@@ -4,20 +4,39 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"copilot-api/internal/api"
+	"copilot-api/internal/audit"
 	"copilot-api/internal/copilot"
+	"copilot-api/internal/pidfile"
 	"copilot-api/pkg/config"
 	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify-audit":
+			os.Exit(verifyAudit(os.Args[2:], os.Stdout, os.Stderr))
+		case "login":
+			os.Exit(login(os.Args[2:], os.Stdout, os.Stderr))
+		case "dump-config":
+			os.Exit(dumpConfig(os.Args[2:], os.Stdout, os.Stderr))
+		case "--dry-run", "--validate-config":
+			os.Exit(dryRun(os.Stdout, os.Stderr))
+		}
+	}
+
 	// Load environment variables from .env if present
 	_ = godotenv.Load()
 
@@ -27,7 +46,7 @@ func main() {
 		log.Fatalf("failed to load config: %v", err)
 	}
 	// If COPILOT_TOKEN was randomly generated, print it for the user
-	if os.Getenv("COPILOT_TOKEN") == "" {
+	if cfg.CopilotTokenGenerated {
 		log.Printf("COPILOT_TOKEN was not set. Generated random token: %s", cfg.CopilotToken)
 	}
 
@@ -35,19 +54,94 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	// Set up ModelsCache (fetch models at startup, refresh every 6 hours)
-	var modelsCache *copilot.ModelsCache
-	modelsCache, err = copilot.NewModelsCache(ctx, cfg.CopilotToken, 6*time.Hour)
-	if err != nil {
-		log.Printf("Warning: failed to fetch models list at startup: %v", err)
+	// Set up Copilot TokenManager (handles token refresh, concurrency, etc.)
+	tokenOpts := []copilot.TokenManagerOption{
+		copilot.WithPrewarm(time.Duration(cfg.TokenPrewarmSeconds) * time.Second),
+		copilot.WithExpiryGrace(time.Duration(cfg.TokenExpiryGraceSecs) * time.Second),
+		copilot.WithGitHubHost(cfg.GitHubHost()),
+		copilot.WithUserAgent(cfg.UserAgent),
+		copilot.WithOAuthToken(cfg.CopilotOAuthToken),
+		copilot.WithMigrateOldConfig(cfg.MigrateOldConfig),
+	}
+	if cfg.GitHubAuthURL != "" {
+		tokenOpts = append(tokenOpts, copilot.WithAuthURL(cfg.GitHubAuthURL))
+	}
+	if cfg.WriteHostsJSON {
+		tokenOpts = append(tokenOpts, copilot.WithHostsJSON(""))
+	}
+	var registry *prometheus.Registry
+	if cfg.EnableMetrics {
+		registry = prometheus.NewRegistry()
+		tokenOpts = append(tokenOpts, copilot.WithMetrics(registry))
+	}
+	// Several OAuth tokens spread requests over their Copilot seats
+	var tokenManager copilot.TokenSource
+	if len(cfg.CopilotOAuthTokens) > 1 {
+		pool, err := copilot.NewTokenPool(ctx, cfg.CopilotOAuthTokens, tokenOpts...)
+		if err != nil {
+			log.Fatalf("failed to initialize Copilot token pool: %v", err)
+		}
+		log.Printf("Rotating Copilot tokens over %d OAuth tokens", pool.Seats())
+		if cfg.GCPSecretName != "" {
+			log.Printf("Warning: GCP_SECRET_NAME is not reloaded on SIGHUP while COPILOT_OAUTH_TOKENS rotates over several OAuth tokens")
+		}
+		tokenManager = pool
+	} else {
+		tm, err := copilot.NewTokenManager(ctx, tokenOpts...)
+		if err != nil {
+			log.Fatalf("failed to initialize Copilot token manager: %v", err)
+		}
+		if cfg.GCPSecretName != "" {
+			go reloadGCPSecretOnSIGHUP(ctx, cfg.GCPSecretName, tm)
+		}
+		tokenManager = tm
 	}
 
-	// Set up Copilot TokenManager (handles token refresh, concurrency, etc.)
-	tokenManager, err := copilot.NewTokenManager(ctx)
+	// Model aliases are reloaded whenever MODEL_ALIASES_FILE changes
+	aliases := copilot.NewAtomicAliasMap(cfg.ModelAliases)
+	if cfg.ModelAliasFile != "" {
+		go copilot.WatchAliasFile(ctx, cfg.ModelAliasFile, aliases)
+	}
+
+	// Set up ModelsCache (fetch models at startup, refresh every MODELS_CACHE_TTL)
+	modelsOpts := []copilot.ModelsCacheOption{
+		copilot.WithModelsUserAgent(cfg.UserAgent),
+		copilot.WithAliasMap(aliases),
+		copilot.WithModelsConfig(copilot.ModelsConfig{
+			FetchTimeout:   cfg.ModelsFetchTimeout,
+			RefreshTimeout: cfg.ModelsRefreshTimeout,
+		}),
+	}
+	if cfg.UsesCopilotModelsEndpoint {
+		modelsOpts = append(modelsOpts, copilot.WithCopilotModelsEndpoint(cfg.CopilotBaseURL+"/models", tokenManager.GetToken, cfg.MergeModelLists))
+	}
+	// With the redis backend the in-memory cache sits in front of the list shared in Redis
+	var redisModels *copilot.RedisModelsCache
+	if cfg.ModelsCacheBackend == "redis" {
+		var err error
+		redisModels, err = copilot.NewRedisModelsCache(ctx, cfg.RedisAddr, cfg.CopilotToken, cfg.ModelsCacheTTL, modelsOpts...)
+		switch {
+		case errors.Is(err, copilot.ErrRedisUnreachable):
+			// Run like the memory backend rather than not at all
+			log.Printf("Warning: %v; caching the models list in memory only", err)
+		case err != nil:
+			log.Fatalf("failed to initialize the Redis models cache: %v", err)
+		default:
+			modelsOpts = append(modelsOpts, copilot.WithRedisCache(redisModels))
+		}
+	}
+	modelsCache, err := copilot.NewModelsCache(ctx, cfg.CopilotToken, cfg.ModelsCacheTTL, modelsOpts...)
 	if err != nil {
-		log.Fatalf("failed to initialize Copilot token manager: %v", err)
+		// Fall back to fetching the list on the first /v1/models request
+		log.Printf("Warning: failed to fetch models list at startup: %v", err)
+		modelsCache = copilot.NewEmptyModelsCache(cfg.CopilotToken, cfg.ModelsCacheTTL, modelsOpts...)
+	}
+	if cfg.StrictModelAliases {
+		// Warnings for unknown alias targets are logged by every refresh; here they are fatal
+		if errs := copilot.ValidateModelAliases(ctx, cfg.ModelAliases, modelsCache); len(errs) > 0 {
+			log.Fatalf("invalid model aliases: %v", errors.Join(errs...))
+		}
 	}
-	defer tokenManager.Close()
 
 	// Set up root context with cancellation
 	// Use COPILOT_SERVER_PORT for listening address if set, otherwise fallback to ServerAddr
@@ -56,33 +150,134 @@ func main() {
 		addr = ":" + cfg.ServerPort
 	}
 
-	// Set up HTTP server, inject TokenManager and ModelsCache into API router
+	// Set up HTTP server, inject TokenManager and ModelsCache into API router.
+	// The tracker limits concurrent requests and lets shutdown wait for in-flight ones.
+	tracker := api.NewRequestTracker(cfg.MaxConcurrentRequests)
+	usage := api.NewUpstreamUsage(cfg, tokenManager)
+	usage.Start(ctx)
+	// isShuttingDown fails /readyz from the shutdown signal on
+	var isShuttingDown atomic.Bool
+	routerOpts := []api.RouterOption{api.WithModelAliases(aliases), api.WithUpstreamUsage(usage), api.WithShutdownFlag(&isShuttingDown)}
+	if cfg.AuditLogFile != "" {
+		auditLog, err := audit.Open(cfg.AuditLogFile, cfg.AuditSigningKey)
+		if err != nil {
+			log.Fatalf("failed to open audit log: %v", err)
+		}
+		defer auditLog.Close()
+		routerOpts = append(routerOpts, api.WithAuditLog(auditLog))
+	}
+	if registry != nil {
+		routerOpts = append(routerOpts, api.WithMetrics(registry))
+	}
+	keys, err := api.NewKeyStore(cfg.APIKeys, cfg.APIKeysStateFile)
+	if err != nil {
+		log.Fatalf("failed to load API keys: %v", err)
+	}
+	routerOpts = append(routerOpts, api.WithKeyStore(keys))
+	handler := api.NewRouter(cfg, tokenManager, modelsCache, routerOpts...)
+	if registry != nil {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		mux.Handle("/", handler)
+		handler = mux
+	}
 	server := &http.Server{
 		Addr:         addr,
-		Handler:      api.NewRouter(cfg, tokenManager, modelsCache),
+		Handler:      tracker.Middleware(handler),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %v", addr, err)
+	}
+
 	// Start server in a goroutine
+	logStartupSummary(cfg, addr)
 	go func() {
-		log.Printf("Starting server on %s", addr)
-		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		var err error
+		if cfg.TLSCertFile != "" {
+			err = server.ServeTLS(ln, cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			err = server.Serve(ln)
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("server error: %v", err)
 		}
 	}()
 
+	// Write the PID file once the server is listening, and remove it on shutdown
+	if cfg.PIDFile != "" {
+		if err := pidfile.Write(cfg.PIDFile); err != nil {
+			log.Printf("Warning: failed to write PID file %s: %v", cfg.PIDFile, err)
+		} else {
+			defer func() {
+				if err := pidfile.Remove(cfg.PIDFile); err != nil {
+					log.Printf("Warning: failed to remove PID file %s: %v", cfg.PIDFile, err)
+				}
+			}()
+		}
+	}
+
 	// Wait for shutdown signal
 	<-ctx.Done()
 	log.Println("Shutdown signal received")
+	isShuttingDown.Store(true)
+	if cfg.PreShutdownDelay > 0 {
+		// Keep serving while load balancers notice /readyz failing
+		log.Printf("waiting %s before draining connections", cfg.PreShutdownDelay)
+		time.Sleep(cfg.PreShutdownDelay)
+	}
 
 	// Graceful shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
-	if err := server.Shutdown(shutdownCtx); err != nil {
+	if err := tracker.Shutdown(shutdownCtx, server); err != nil {
 		log.Printf("graceful shutdown failed: %v", err)
 	} else {
 		log.Println("server shut down gracefully")
 	}
+
+	// Stop background refreshes once no handler can use them anymore
+	tokenManager.Close()
+	modelsCache.Close()
+	if redisModels != nil {
+		redisModels.Close()
+	}
+	if err := keys.Save(); err != nil {
+		log.Printf("Warning: failed to save API keys: %v", err)
+	}
+}
+
+// reloadGCPSecretOnSIGHUP fetches the latest version of the Secret Manager secret name
+// whenever the process receives SIGHUP and makes it the OAuth token of tokenManager,
+// until ctx is done. A failed fetch keeps the current token.
+func reloadGCPSecretOnSIGHUP(ctx context.Context, name string, tokenManager *copilot.TokenManager) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		token, err := config.FetchGCPSecret(ctx, name)
+		if err != nil {
+			log.Printf("Warning: keeping the current OAuth token: %v", err)
+			continue
+		}
+		if token == "" {
+			log.Printf("Warning: keeping the current OAuth token: GCP secret %s is empty", name)
+			continue
+		}
+		tokenManager.SetOAuthToken(token)
+		if err := tokenManager.ForceRefresh(ctx); err != nil {
+			log.Printf("Warning: failed to refresh the Copilot token with the reloaded OAuth token: %v", err)
+			continue
+		}
+		log.Printf("Reloaded the OAuth token from GCP secret %s", name)
+	}
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"copilot-api/pkg/config"
+)
+
+// logStartupSummary logs the configuration in effect as a single structured record, so
+// operators can check which settings the server runs with. Secrets are only reported
+// as set or not set.
+func logStartupSummary(cfg *config.Config, addr string) {
+	authMode := "single"
+	if len(cfg.APIKeys) > 0 {
+		authMode = "multi"
+	}
+	slog.LogAttrs(context.Background(), slog.LevelInfo, "Starting server",
+		slog.String("addr", addr),
+		slog.Bool("tls", cfg.TLSCertFile != ""),
+		slog.String("default_model", orNotSet(cfg.DefaultModel)),
+		slog.String("cors_origins", cfg.CORSAllowedOrigins),
+		slog.String("auth_mode", authMode),
+		slog.Int("api_keys", len(cfg.APIKeys)),
+		slog.String("copilot_token", secretState(cfg.CopilotToken != "" && !cfg.CopilotTokenGenerated)),
+		slog.String("admin_token", secretState(cfg.AdminToken != "")),
+		slog.String("oauth_token", secretState(cfg.CopilotOAuthToken != "")),
+		slog.String("token_source", tokenSource(cfg.OAuthTokenSource)),
+		slog.String("copilot_base_url", cfg.CopilotBaseURL),
+		slog.Duration("models_cache_ttl", cfg.ModelsCacheTTL),
+		slog.String("models_cache_backend", cfg.ModelsCacheBackend),
+		slog.Int("upstream_max_attempts", cfg.UpstreamMaxAttempts),
+		slog.Duration("upstream_tls_handshake_timeout", cfg.UpstreamTLSHandshakeTimeout),
+		slog.Int("max_upstream_concurrency", cfg.MaxUpstreamConcurrency),
+		slog.Int("max_concurrent_requests", cfg.MaxConcurrentRequests),
+		slog.Int("rate_limit_per_minute", cfg.RateLimitPerMinute),
+		slog.Int("response_cache_size", cfg.ResponseCacheSize),
+		slog.Duration("shutdown_timeout", cfg.ShutdownTimeout),
+		slog.Duration("pre_shutdown_delay", cfg.PreShutdownDelay),
+		slog.Group("features",
+			slog.Bool("debug", cfg.Debug),
+			slog.Bool("request_dedup", cfg.EnableRequestDedup),
+			slog.Bool("emulate_multiple_n", cfg.EmulateMultipleN),
+			slog.Bool("smart_routing", cfg.EnableSmartRouting),
+			slog.Bool("metrics", cfg.EnableMetrics),
+			slog.Bool("tunnel", cfg.EnableTunnel),
+			slog.Bool("copilot_models_endpoint", cfg.UsesCopilotModelsEndpoint),
+			slog.Bool("strict_model_aliases", cfg.StrictModelAliases),
+			slog.Bool("forward_rate_limit_headers", cfg.ForwardRateLimitHeaders),
+			slog.Bool("write_hosts_json", cfg.WriteHostsJSON),
+			slog.Bool("migrate_old_config", cfg.MigrateOldConfig),
+		),
+	)
+}
+
+// orNotSet returns s, or "not set" if it is empty.
+func orNotSet(s string) string {
+	if s == "" {
+		return "not set"
+	}
+	return s
+}
+
+// secretState describes a secret without revealing it.
+func secretState(set bool) string {
+	if set {
+		return "<set>"
+	}
+	return "<not set>"
+}
+
+// tokenSource names the kind of OAuth token search path entry the token came from.
+func tokenSource(entry string) string {
+	switch {
+	case entry == "":
+		return "not found"
+	case strings.HasPrefix(entry, "$"):
+		return "env"
+	case entry == config.SearchGHCLI:
+		return "gh-cli"
+	case entry == config.SearchKeychain:
+		return "keychain"
+	case entry == config.SearchGCPSecret:
+		return "gcp-secret"
+	default:
+		return "file"
+	}
+}
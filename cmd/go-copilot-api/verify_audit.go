@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"copilot-api/internal/audit"
+)
+
+// verifyAudit runs the verify-audit subcommand, which checks the signature of every
+// entry of an audit log. It returns the process exit code: 0 if all entries are valid,
+// 1 if any is not and 2 on usage errors. The key is never printed.
+func verifyAudit(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("verify-audit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	file := fs.String("file", "", "audit log file to verify")
+	key := fs.String("key", "", "signing key (default: $AUDIT_SIGNING_KEY)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *key == "" {
+		*key = os.Getenv("AUDIT_SIGNING_KEY")
+	}
+	if *file == "" || *key == "" {
+		fmt.Fprintln(stderr, "usage: go-copilot-api verify-audit --file audit.jsonl --key <key>")
+		return 2
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fmt.Fprintf(stderr, "verify-audit: %v\n", err)
+		return 2
+	}
+	defer f.Close()
+	invalid, err := audit.Verify(f, *key)
+	if err != nil {
+		fmt.Fprintf(stderr, "verify-audit: %s: %v\n", *file, err)
+		return 2
+	}
+	for _, line := range invalid {
+		fmt.Fprintf(stdout, "%s:%d: invalid signature\n", *file, line)
+	}
+	if len(invalid) > 0 {
+		fmt.Fprintf(stdout, "%d invalid entries\n", len(invalid))
+		return 1
+	}
+	fmt.Fprintln(stdout, "all entries valid")
+	return 0
+}
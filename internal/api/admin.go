@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+// adminOnly restricts next to requests authenticated with ADMIN_TOKEN.
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if keyLabelFromContext(r.Context()) != adminKeyLabel {
+			http.Error(w, "Forbidden: admin access token required", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// modelsCacheRefreshHandler handles DELETE /v1/models/cache by refetching the models list.
+// With ?background=true the refresh runs asynchronously and 202 is returned immediately.
+func modelsCacheRefreshHandler(modelsCache *copilot.ModelsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if modelsCache == nil {
+			http.Error(w, "Models cache unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("background") == "true" {
+			go func() {
+				_, _ = modelsCache.ForceRefresh(context.Background())
+			}()
+			w.WriteHeader(http.StatusAccepted)
+			_ = json.NewEncoder(w).Encode(map[string]string{"status": "refreshing"})
+			return
+		}
+
+		count, err := modelsCache.ForceRefresh(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "Failed to refresh models: " + err.Error()})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"models_count": count,
+			"last_fetch":   modelsCache.LastFetch().UTC().Format(time.RFC3339),
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"copilot-api/pkg/config"
+)
+
+// anthropicHeaders are the Anthropic API headers sent with a /v1/messages request.
+type anthropicHeaders struct {
+	Version string   // anthropic-version, "" if not sent
+	Betas   []string // anthropic-beta feature flags
+}
+
+// anthropicHeadersKey is the context key of the anthropicHeaders of a /v1/messages
+// request. The beta flags are recorded for features that may later be gated on them.
+type anthropicHeadersKey struct{}
+
+// withAnthropicHeaders validates the anthropic-version header of r against
+// cfg.SupportedAnthropicVersions (config.DefaultSupportedAnthropicVersions if nil) and
+// returns r with the Anthropic headers stored in its context. The header is optional.
+func withAnthropicHeaders(cfg *config.Config, r *http.Request) (*http.Request, error) {
+	h := anthropicHeaders{Version: strings.TrimSpace(r.Header.Get("anthropic-version"))}
+	for _, v := range r.Header.Values("anthropic-beta") {
+		for _, beta := range strings.Split(v, ",") {
+			if beta = strings.TrimSpace(beta); beta != "" {
+				h.Betas = append(h.Betas, beta)
+			}
+		}
+	}
+
+	supported := cfg.SupportedAnthropicVersions
+	if supported == nil {
+		supported = config.DefaultSupportedAnthropicVersions
+	}
+	switch {
+	case h.Version == "":
+		if cfg.Debug {
+			log.Printf("Warning: /v1/messages request without an anthropic-version header")
+		}
+	case !slices.Contains(supported, h.Version):
+		return nil, fmt.Errorf("anthropic-version %q is not supported; supported versions: %s", h.Version, strings.Join(supported, ", "))
+	}
+	return r.WithContext(context.WithValue(r.Context(), anthropicHeadersKey{}, h)), nil
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/sync/semaphore"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// assistantsBetaHeader is the OpenAI-Beta value selecting version 2 of the Assistants API.
+const assistantsBetaHeader = "assistants=v2"
+
+// assistantsProxyHandler proxies the OpenAI Assistants API, every path under
+// /v1/assistants and /v1/threads, to cfg.AssistantsAPIURL. The path after /v1 and the
+// query string are kept, and the response, including run event streams, is streamed
+// back. Since the API is not Copilot's, the request carries neither the Copilot token
+// nor the client's headers: only its Content-Type and Accept, OpenAI-Beta:
+// assistants=v2 and, if set, cfg.AssistantsAPIKey as bearer token. Without
+// AssistantsAPIURL every path answers 501 Not Implemented.
+func assistantsProxyHandler(cfg *config.Config, streamer *streamCopier, sem *semaphore.Weighted) http.HandlerFunc {
+	client := &http.Client{Transport: concurrencyTransport{next: copilot.NewUpstreamTransport(cfg), sem: sem}}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.AssistantsAPIURL == "" {
+			writeOpenAIError(w, http.StatusNotImplemented, "api_error", "",
+				"The Assistants API is not available: set ASSISTANTS_API_URL to the base URL of an OpenAI Assistants API")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		url := strings.TrimRight(cfg.AssistantsAPIURL, "/") + strings.TrimPrefix(r.URL.Path, "/v1")
+		if r.URL.RawQuery != "" {
+			url += "?" + r.URL.RawQuery
+		}
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, url, bytes.NewReader(body))
+		if err != nil {
+			http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(body) > 0 {
+			req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+		}
+		if accept := r.Header.Get("Accept"); accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if cfg.AssistantsAPIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+cfg.AssistantsAPIKey)
+		}
+		if cfg.UserAgent != "" {
+			req.Header.Set("User-Agent", cfg.UserAgent)
+		}
+		req.Header.Set("OpenAI-Beta", assistantsBetaHeader)
+		resp, err := client.Do(req)
+		if err != nil {
+			http.Error(w, "Failed to contact Assistants API: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		// Propagate status code and headers
+		for k, v := range resp.Header {
+			for _, vv := range v {
+				w.Header().Add(k, vv)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		streamer.copy(w, resp.Body)
+	}
+}
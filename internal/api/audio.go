@@ -0,0 +1,66 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+
+	"golang.org/x/sync/semaphore"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// audioTranscriptionsHandler proxies /v1/audio/transcriptions to cfg.AudioAPIURL, a
+// Whisper-compatible endpoint, since Copilot has no audio API. The multipart body is
+// forwarded unchanged and the response is streamed back. Without AudioAPIURL the
+// endpoint answers 501 Not Implemented.
+func audioTranscriptionsHandler(cfg *config.Config, streamer *streamCopier, sem *semaphore.Weighted) http.HandlerFunc {
+	client := &http.Client{Transport: concurrencyTransport{next: copilot.NewUpstreamTransport(cfg), sem: sem}}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cfg.AudioAPIURL == "" {
+			writeOpenAIError(w, http.StatusNotImplemented, "api_error", "",
+				"Audio transcription is not available: set AUDIO_API_URL to a Whisper-compatible transcription endpoint")
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+			return
+		}
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "multipart/form-data" {
+			writeOpenAIError(w, http.StatusUnsupportedMediaType, "invalid_request_error", "",
+				"Unsupported Content-Type: audio must be uploaded as multipart/form-data")
+			return
+		}
+		body := r.Body
+		if cfg.MaxFileUploadBytes > 0 {
+			body = http.MaxBytesReader(w, r.Body, cfg.MaxFileUploadBytes)
+		}
+
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, cfg.AudioAPIURL, body)
+		if err != nil {
+			http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		req.ContentLength = r.ContentLength
+		req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+		if cfg.UserAgent != "" {
+			req.Header.Set("User-Agent", cfg.UserAgent)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			http.Error(w, "Failed to contact audio API: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		// Propagate status code and headers
+		for k, v := range resp.Header {
+			for _, vv := range v {
+				w.Header().Add(k, vv)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+		streamer.copy(w, resp.Body)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"copilot-api/internal/audit"
+	"copilot-api/pkg/config"
+)
+
+// auditUserKey is the context key of the end user recorded for an audited request.
+type auditUserKey struct{}
+
+// recordAuditUser records the end user named by the user field of a request body, as
+// sent by OpenAI clients or converted from Anthropic's metadata.user_id, for the audit
+// log entry of the request. It does nothing when the audit log is disabled.
+func recordAuditUser(ctx context.Context, body map[string]interface{}) {
+	user, ok := ctx.Value(auditUserKey{}).(*atomic.Pointer[string])
+	if !ok {
+		return
+	}
+	if u, _ := body["user"].(string); u != "" {
+		user.Store(&u)
+	}
+}
+
+// auditMiddleware writes an audit log entry for every request let through by
+// AuthMiddleware once it has been served, with the end user recorded by
+// recordAuditUser. A nil auditLog disables the middleware.
+func auditMiddleware(cfg *config.Config, auditLog *audit.Logger, next http.Handler) http.Handler {
+	if auditLog == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		user := new(atomic.Pointer[string])
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), auditUserKey{}, user)))
+
+		entry := audit.Entry{
+			Time:       start.UTC(),
+			Key:        keyLabelFromContext(r.Context()),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     rec.status,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if u := user.Load(); u != nil {
+			entry.User = *u
+		}
+		if ip := realClientIP(r, cfg.TrustedProxyCount); ip != nil {
+			entry.ClientIP = ip.String()
+		}
+		if err := auditLog.Log(entry); err != nil {
+			log.Printf("Warning: failed to write audit log entry: %v", err)
+		}
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush lets streamed responses through the recorder.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
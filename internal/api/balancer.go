@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/sync/semaphore"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// errNoHealthyEndpoint is returned when every upstream endpoint has an open circuit.
+var errNoHealthyEndpoint = errors.New("all Copilot API endpoints are unavailable")
+
+// endpoint is a Copilot API base URL with its own circuit breaker.
+type endpoint struct {
+	url     string
+	breaker circuitBreaker
+}
+
+// endpointPool distributes upstream requests round-robin across Copilot API endpoints.
+// It is shared by all proxy handlers.
+type endpointPool struct {
+	endpoints []*endpoint
+	next      atomic.Uint64
+	client    *http.Client
+	tokens    copilot.TokenSource // refreshes rejected Copilot tokens; may be nil
+	sem       *semaphore.Weighted // bounds the requests in flight; may be nil
+}
+
+// newEndpointPool builds the pool from COPILOT_ENDPOINTS, falling back to the single CopilotBaseURL.
+// Rate limit headers of upstream responses are recorded in remaining unless it is nil.
+// Requests hold a slot of sem, if not nil, while in flight.
+func newEndpointPool(cfg *config.Config, tokens copilot.TokenSource, remaining *remainingTracker, sem *semaphore.Weighted) *endpointPool {
+	urls := cfg.CopilotEndpoints
+	if len(urls) == 0 {
+		urls = []string{cfg.CopilotBaseURL}
+	}
+	transport := rateLimitTransport{
+		next:      breakerTransport{next: copilot.NewUpstreamTransport(cfg)},
+		forward:   cfg.ForwardRateLimitHeaders,
+		remaining: remaining,
+	}
+	p := &endpointPool{client: &http.Client{Transport: concurrencyTransport{next: transport, sem: sem}}, tokens: tokens, sem: sem}
+	for _, u := range urls {
+		p.endpoints = append(p.endpoints, &endpoint{url: strings.TrimRight(u, "/")})
+	}
+	return p
+}
+
+// pick returns the next endpoint whose circuit is closed, skipping open ones.
+func (p *endpointPool) pick() (*endpoint, error) {
+	n := uint64(len(p.endpoints))
+	start := p.next.Add(1) - 1
+	for i := uint64(0); i < n; i++ {
+		ep := p.endpoints[(start+i)%n]
+		if ep.breaker.allow() {
+			return ep, nil
+		}
+	}
+	return nil, errNoHealthyEndpoint
+}
+
+// send forwards a JSON request for path to the next healthy endpoint. Failed attempts are
+// retried up to cfg.UpstreamMaxAttempts times, each on the next healthy endpoint.
+func (p *endpointPool) send(r *http.Request, cfg *config.Config, method, path string, body []byte, copilotToken string) (*http.Response, error) {
+	return p.sendAs(r, cfg, method, path, "application/json", body, copilotToken)
+}
+
+// sendAs is send for a body of the given content type. An empty contentType sends no
+// Content-Type header. If Copilot rejects the token with 401, the token is refreshed and
+// the request sent once more with a new one. With a TokenPool only the seat that issued
+// the token is refreshed.
+func (p *endpointPool) sendAs(r *http.Request, cfg *config.Config, method, path, contentType string, body []byte, copilotToken string) (*http.Response, error) {
+	resp, err := p.sendOnce(r, cfg, method, path, contentType, body, copilotToken)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || p.tokens == nil {
+		return resp, err
+	}
+	if err := p.tokens.RefreshToken(r.Context(), copilotToken); err != nil {
+		log.Printf("Warning: Copilot rejected the token and refreshing it failed: %v", err)
+		return resp, nil
+	}
+	newToken, err := p.tokens.GetToken(r.Context())
+	if err != nil || newToken == copilotToken {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return p.sendOnce(r, cfg, method, path, contentType, body, newToken)
+}
+
+// sendOnce sends the request with copilotToken, retrying transient failures.
+func (p *endpointPool) sendOnce(r *http.Request, cfg *config.Config, method, path, contentType string, body []byte, copilotToken string) (*http.Response, error) {
+	return doWithRetry(r.Context(), p.client, func() (*http.Request, error) {
+		ep, err := p.pick()
+		if err != nil {
+			return nil, err
+		}
+		req, err := newUpstreamRequest(r, cfg, method, ep.url+path, contentType, body, copilotToken)
+		if err != nil {
+			return nil, err
+		}
+		return req.WithContext(context.WithValue(req.Context(), endpointKey{}, ep)), nil
+	}, cfg.UpstreamMaxAttempts)
+}
+
+// endpointKey is the context key of the endpoint an upstream request is sent to.
+type endpointKey struct{}
+
+// breakerTransport records the outcome of every upstream request on the circuit breaker
+// of its endpoint. Server errors count as failures.
+type breakerTransport struct {
+	next http.RoundTripper
+}
+
+func (t breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if ep, ok := req.Context().Value(endpointKey{}).(*endpoint); ok {
+		ep.breaker.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+	}
+	return resp, err
+}
+
+// writeUpstreamError reports a failure to reach the Copilot API.
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNoHealthyEndpoint) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, "Failed to contact Copilot API: "+err.Error(), http.StatusBadGateway)
+}
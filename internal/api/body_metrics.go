@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// bodySizeBuckets are the histogram buckets of the body size metrics, from small
+// prompts to requests carrying whole documents or images.
+var bodySizeBuckets = []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20}
+
+// unmatchedPath is the path label of requests no route matched.
+const unmatchedPath = "unmatched"
+
+// bodyMetrics holds the copilot_request_body_bytes and copilot_response_body_bytes
+// histograms.
+type bodyMetrics struct {
+	request  *prometheus.HistogramVec
+	response *prometheus.HistogramVec
+}
+
+// newBodyMetrics registers the body size metrics with registry.
+func newBodyMetrics(registry *prometheus.Registry) *bodyMetrics {
+	m := &bodyMetrics{
+		request: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "copilot_request_body_bytes",
+			Help:    "Size of request bodies by route and model, as sent upstream for model requests.",
+			Buckets: bodySizeBuckets,
+		}, []string{"path", "model"}),
+		response: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "copilot_response_body_bytes",
+			Help:    "Size of response bodies by route and model, streamed responses included.",
+			Buckets: bodySizeBuckets,
+		}, []string{"path", "model"}),
+	}
+	registry.MustRegister(m.request, m.response)
+	return m
+}
+
+// bodySizesKey is the context key of the bodySizes of a request.
+type bodySizesKey struct{}
+
+// bodySizes collects the sizes of a request while it is served. Handlers that know the
+// model record it with the request size through recordRequestBody.
+type bodySizes struct {
+	model        atomic.Pointer[string]
+	requestBytes atomic.Int64 // size of the body sent upstream, or -1 until recorded
+	readBytes    atomic.Int64 // bytes read from the client
+}
+
+// recordRequestBody records the model of a request and the size of the body sent
+// upstream for it. It does nothing when body metrics are disabled.
+func recordRequestBody(ctx context.Context, model string, size int) {
+	sizes, ok := ctx.Value(bodySizesKey{}).(*bodySizes)
+	if !ok {
+		return
+	}
+	sizes.model.Store(&model)
+	sizes.requestBytes.Store(int64(size))
+}
+
+// requestModel returns the model of a request body, or "" if it names none.
+func requestModel(body map[string]interface{}) string {
+	model, _ := body["model"].(string)
+	return model
+}
+
+// bodyMetricsMiddleware observes the request and response body sizes of every request
+// served by next, labelled by the route pattern and the recorded model. Requests for
+// which no size is recorded count the bytes read from the client, and those without a
+// body are not observed. A nil m disables the middleware.
+func bodyMetricsMiddleware(m *bodyMetrics, next http.Handler) http.Handler {
+	if m == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sizes := &bodySizes{}
+		sizes.requestBytes.Store(-1)
+		r = r.WithContext(context.WithValue(r.Context(), bodySizesKey{}, sizes))
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &countingReader{ReadCloser: r.Body, n: &sizes.readBytes}
+		}
+		cw := &countingWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		// The mux sets the pattern on the request it was given
+		path := r.Pattern
+		if path == "" {
+			path = unmatchedPath
+		}
+		var model string
+		if p := sizes.model.Load(); p != nil {
+			model = *p
+		}
+		requestBytes := sizes.requestBytes.Load()
+		if requestBytes < 0 {
+			requestBytes = sizes.readBytes.Load()
+		}
+		if requestBytes > 0 {
+			m.request.WithLabelValues(path, model).Observe(float64(requestBytes))
+		}
+		m.response.WithLabelValues(path, model).Observe(float64(cw.n.Load()))
+	})
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// countingWriter counts the bytes of a response body, including streamed ones.
+type countingWriter struct {
+	http.ResponseWriter
+	n atomic.Int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.n.Add(int64(n))
+	return n, err
+}
+
+// Flush lets streamed responses through the writer.
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the underlying ResponseWriter.
+func (w *countingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold is the number of consecutive failures that opens a circuit.
+	breakerThreshold = 5
+	// breakerCooldown is how long an open circuit rejects requests before a retry is allowed.
+	breakerCooldown = 30 * time.Second
+)
+
+// circuitBreaker tracks consecutive upstream failures for a single endpoint.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probeSent time.Time // when the half-open circuit let its probe through
+}
+
+// allow reports whether requests may be sent. Once the cooldown has passed the
+// circuit is half-open: a single probe request is let through and its outcome decides
+// the state. Further requests are rejected until then, or until the probe has not
+// reported for another cooldown, in case its outcome was never recorded.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		return false
+	}
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if !b.probeSent.IsZero() && now.Sub(b.probeSent) < breakerCooldown {
+		return false
+	}
+	b.probeSent = now
+	return true
+}
+
+// record registers the outcome of a request, opening the circuit after too many failures.
+func (b *circuitBreaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		b.probeSent = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.openUntil = time.Now().Add(breakerCooldown)
+		b.probeSent = time.Time{}
+	}
+}
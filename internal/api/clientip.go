@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// realClientIP returns the IP address of the client that sent r. When the server runs
+// behind trustedProxies reverse proxies, the proxy chain (X-Forwarded-For followed by
+// the connecting address) is walked from the right, skipping one entry per trusted
+// proxy. With a single trusted proxy, X-Real-IP is used directly when present.
+func realClientIP(r *http.Request, trustedProxies int) net.IP {
+	remote := parseIP(r.RemoteAddr)
+	if trustedProxies <= 0 {
+		return remote
+	}
+	if trustedProxies == 1 {
+		if ip := parseIP(r.Header.Get("X-Real-IP")); ip != nil {
+			return ip
+		}
+	}
+
+	var chain []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		for _, part := range strings.Split(h, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				chain = append(chain, part)
+			}
+		}
+	}
+	if len(chain) == 0 {
+		return remote
+	}
+	chain = append(chain, r.RemoteAddr)
+
+	idx := len(chain) - 1 - trustedProxies
+	if idx < 0 {
+		idx = 0
+	}
+	if ip := parseIP(chain[idx]); ip != nil {
+		return ip
+	}
+	return remote
+}
+
+// parseIP parses an IP address with an optional port.
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	return net.ParseIP(s)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// contentTypeExemptPaths lists API paths whose request bodies are not JSON.
+var contentTypeExemptPaths = map[string]bool{
+	"/v1/models/cache":         true,
+	"/v1/files":                true,
+	"/v1/audio/transcriptions": true,
+}
+
+// bodyLimitExemptPaths lists paths that enforce their own request body limit.
+var bodyLimitExemptPaths = map[string]bool{
+	"/v1/files":                true,
+	"/v1/audio/transcriptions": true,
+}
+
+// ContentTypeMiddleware rejects POST and PUT requests to the API endpoints whose body is
+// not declared as application/json with 415 Unsupported Media Type.
+func ContentTypeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requiresJSONBody(r) || isJSONContentType(r.Header.Get("Content-Type")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeOpenAIError(w, http.StatusUnsupportedMediaType, "invalid_request_error", "",
+			"Unsupported Content-Type: requests must be sent as application/json")
+	})
+}
+
+// requiresJSONBody reports whether r carries a body that must be JSON.
+func requiresJSONBody(r *http.Request) bool {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		return false
+	}
+	if r.ContentLength == 0 {
+		return false
+	}
+	return strings.HasPrefix(r.URL.Path, "/v1/") && !contentTypeExemptPaths[r.URL.Path]
+}
+
+// isJSONContentType reports whether contentType is application/json, ignoring parameters.
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+// maxBodyMiddleware rejects request bodies larger than limit with 413 Request Entity Too
+// Large. A limit <= 0 disables the check.
+func maxBodyMiddleware(limit int64, next http.Handler) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if bodyLimitExemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > limit {
+			writeOpenAIError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "",
+				"Request body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"copilot-api/pkg/config"
+)
+
+// decodeCountTokensRequest decodes a completions request body and returns it with a
+// token counter for its model, falling back to endpointDefault and then the global default
+// model. It writes an error response and returns false on failure.
+func decodeCountTokensRequest(w http.ResponseWriter, r *http.Request, cfg *config.Config, endpointDefault string) (map[string]interface{}, tokenCounter, bool) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "", "Invalid JSON: "+err.Error())
+		return nil, tokenCounter{}, false
+	}
+	if _, ok := body["messages"].([]interface{}); !ok {
+		writeValidationError(w, invalidParam("messages", "messages must be an array"))
+		return nil, tokenCounter{}, false
+	}
+	model, _ := body["model"].(string)
+	return body, newTokenCounter(resolveDefaultModel(model, endpointDefault, cfg.DefaultModel)), true
+}
+
+// anthropicCountTokensHandler handles /v1/messages/count_tokens without calling upstream.
+func anthropicCountTokensHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, counter, ok := decodeCountTokensRequest(w, r, cfg, cfg.DefaultAnthropicModel)
+		if !ok {
+			return
+		}
+		n := counter.messages(body["messages"].([]interface{}))
+		if system, ok := body["system"]; ok {
+			n += counter.content(system)
+		}
+
+		resp := map[string]interface{}{"input_tokens": n}
+		if counter.estimated() {
+			resp["estimated"] = true
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// chatCountTokensHandler handles /v1/chat/completions/count_tokens without calling upstream.
+func chatCountTokensHandler(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, counter, ok := decodeCountTokensRequest(w, r, cfg, cfg.DefaultChatModel)
+		if !ok {
+			return
+		}
+		n := counter.messages(body["messages"].([]interface{}))
+
+		resp := map[string]interface{}{
+			"usage": map[string]int{"prompt_tokens": n},
+		}
+		if counter.estimated() {
+			resp["estimated"] = true
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
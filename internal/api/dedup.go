@@ -0,0 +1,74 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// capturedResponse is an upstream response buffered so it can be replayed to several clients.
+type capturedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// requestDeduper shares one upstream call between identical in-flight requests.
+type requestDeduper struct {
+	group singleflight.Group
+}
+
+// dedupKey identifies a chat completion request by the label of the API key it was
+// made with and its marshaled body, so that only identical requests are shared.
+// Requests of different keys are not shared, since their integration IDs may differ.
+func dedupKey(label string, body []byte) string {
+	sum := sha256.Sum256(append([]byte(label+"\x00"), body...))
+	return hex.EncodeToString(sum[:])
+}
+
+// do runs send once for all concurrent callers with the same key and returns a buffered
+// copy of the response to each of them. send must not depend on the first caller's
+// cancellation, or a client disconnecting would fail the others.
+func (d *requestDeduper) do(key string, send func() (*http.Response, error)) (*capturedResponse, error) {
+	v, err, _ := d.group.Do(key, func() (interface{}, error) {
+		return captureResponse(send())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*capturedResponse), nil
+}
+
+// captureResponse buffers resp and closes its body.
+func captureResponse(resp *http.Response, err error) (*capturedResponse, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, resp.Body); err != nil {
+		return nil, err
+	}
+	return &capturedResponse{status: resp.StatusCode, header: resp.Header.Clone(), body: buf.Bytes()}, nil
+}
+
+// writeTo replays the captured response to w.
+func (c *capturedResponse) writeTo(w http.ResponseWriter) {
+	for k, v := range c.header {
+		if k == "Vary" {
+			// Keep the Vary fields set by middleware, such as Origin
+			for _, field := range strings.Split(strings.Join(v, ","), ",") {
+				appendVary(w, strings.TrimSpace(field))
+			}
+			continue
+		}
+		w.Header()[k] = append([]string(nil), v...)
+	}
+	w.WriteHeader(c.status)
+	_, _ = w.Write(c.body)
+}
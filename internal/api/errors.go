@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// openAIError is the error object returned in OpenAI-compatible error responses.
+type openAIError struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// writeOpenAIError writes an OpenAI-format error response. param may be empty.
+func writeOpenAIError(w http.ResponseWriter, status int, errType, param, message string) {
+	body := openAIError{Message: message, Type: errType}
+	if param != "" {
+		body.Param = &param
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]openAIError{"error": body})
+}
+
+// methodGuard restricts handler to requests with the given method. Others get a 405
+// OpenAI-format error listing the method in the Allow header.
+func methodGuard(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path+"; use "+method)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// anthropicError is the error object returned in Anthropic-compatible error responses.
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// writeAnthropicError writes an Anthropic-format error response.
+func writeAnthropicError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(struct {
+		Type  string         `json:"type"`
+		Error anthropicError `json:"error"`
+	}{Type: "error", Error: anthropicError{Type: errType, Message: message}})
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// filesHandler proxies the OpenAI files API: GET and POST on /v1/files list and upload
+// files, GET and DELETE on /v1/files/{id} retrieve and delete one. Uploads are rebuilt
+// as a new multipart body holding the file and the other form fields, such as purpose.
+// Upstream responses are passed through as-is.
+func filesHandler(cfg *config.Config, tokenManager copilot.TokenSource, pool *endpointPool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/files"), "/")
+		if strings.Contains(id, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		allowed := r.Method == http.MethodGet || (id == "" && r.Method == http.MethodPost) || (id != "" && r.Method == http.MethodDelete)
+		if !allowed {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+			return
+		}
+
+		ctx := r.Context()
+		copilotToken, err := tokenManager.GetToken(ctx)
+		if err != nil {
+			http.Error(w, "Failed to get Copilot token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		path := "/files"
+		if id != "" {
+			path += "/" + id
+		}
+		if r.URL.RawQuery != "" {
+			path += "?" + r.URL.RawQuery
+		}
+		var contentType string
+		var body []byte
+		if r.Method == http.MethodPost {
+			contentType, body, err = rebuildFileUpload(w, r, cfg.MaxFileUploadBytes)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					writeOpenAIError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "file", "File too large")
+					return
+				}
+				writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "file", err.Error())
+				return
+			}
+		}
+
+		// Send request to the next healthy Copilot API endpoint
+		resp, err := pool.sendAs(r, cfg, r.Method, path, contentType, body, copilotToken)
+		if err != nil {
+			writeUpstreamError(w, err)
+			return
+		}
+		defer resp.Body.Close()
+
+		// Propagate status code and headers
+		for k, v := range resp.Header {
+			for _, vv := range v {
+				w.Header().Add(k, vv)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+
+		// Copy the full response
+		_, _ = io.Copy(w, resp.Body)
+	}
+}
+
+// rebuildFileUpload reads the multipart upload in r, limited to maxBytes, and returns
+// the content type and body of an equivalent multipart request for the Copilot API.
+func rebuildFileUpload(w http.ResponseWriter, r *http.Request, maxBytes int64) (string, []byte, error) {
+	if maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return "", nil, err
+		}
+		return "", nil, errors.New("file is required as a multipart/form-data field")
+	}
+	defer file.Close()
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for key, values := range r.MultipartForm.Value {
+		for _, v := range values {
+			if err := mw.WriteField(key, v); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	// Reuse the part header to keep the file name and content type
+	part, err := mw.CreatePart(header.Header)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return "", nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return "", nil, err
+	}
+	return mw.FormDataContentType(), buf.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+// Health statuses reported by /healthz.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthError    = "error"
+)
+
+// Readiness statuses reported by /readyz, besides the health statuses degraded and error.
+const (
+	readyOK           = "ready"
+	readyShuttingDown = "shutting_down"
+)
+
+// healthResponse is the body of /healthz.
+type healthResponse struct {
+	Status         string `json:"status"`
+	TokenExpiresAt string `json:"token_expires_at,omitempty"`
+	TokenValid     bool   `json:"token_valid"`
+	ModelsCount    int    `json:"models_count"`
+	ModelsAgeSecs  *int64 `json:"models_age_secs"`
+	UptimeSecs     int64  `json:"uptime_secs"`
+}
+
+// healthHandler reports the Copilot token and models cache state. It answers 200 while
+// the server can still recover on its own (ok or degraded) and 503 on error, so it can
+// back a liveness probe.
+func healthHandler(tokenManager copilot.TokenSource, modelsCache *copilot.ModelsCache, started time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := health(tokenManager, modelsCache, started)
+		status := http.StatusOK
+		if resp.Status == healthError {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// health computes the /healthz response.
+func health(tokenManager copilot.TokenSource, modelsCache *copilot.ModelsCache, started time.Time) healthResponse {
+	resp := healthResponse{
+		Status:     healthOK,
+		UptimeSecs: int64(time.Since(started).Seconds()),
+	}
+
+	var expiry time.Time
+	if tokenManager != nil {
+		expiry = tokenManager.TokenExpiry()
+	}
+	switch {
+	case expiry.IsZero():
+		// No token manager or no token was ever obtained
+		resp.Status = healthError
+	case !time.Now().Before(expiry):
+		resp.Status = healthDegraded
+		resp.TokenExpiresAt = expiry.UTC().Format(time.RFC3339)
+	default:
+		resp.TokenValid = true
+		resp.TokenExpiresAt = expiry.UTC().Format(time.RFC3339)
+	}
+
+	if modelsCache != nil {
+		resp.ModelsCount = modelsCache.ModelCount()
+		if last := modelsCache.LastFetch(); !last.IsZero() {
+			age := int64(time.Since(last).Seconds())
+			resp.ModelsAgeSecs = &age
+		}
+	}
+	return resp
+}
+
+// readyHandler reports whether the server accepts new requests. It answers 503 once
+// shuttingDown is set, so a readiness probe takes the server out of rotation before
+// connections are drained, and while the /healthz status is degraded or error, with that
+// status, since requests would fail without a valid Copilot token. Otherwise it answers
+// 200.
+func readyHandler(tokenManager copilot.TokenSource, shuttingDown *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, code := readyOK, http.StatusOK
+		if shuttingDown != nil && shuttingDown.Load() {
+			status, code = readyShuttingDown, http.StatusServiceUnavailable
+		} else if h := health(tokenManager, nil, time.Now()); h.Status != healthOK {
+			status, code = h.Status, http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": status})
+	}
+}
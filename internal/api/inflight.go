@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// RequestTracker counts in-flight requests so shutdown can report and wait for them.
+// If a concurrency limit is set it also caps how many requests are served at once.
+type RequestTracker struct {
+	sem    chan struct{} // nil when the number of concurrent requests is unlimited
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	active map[uint64]string
+	nextID uint64
+}
+
+// NewRequestTracker creates a RequestTracker serving at most maxConcurrent requests at
+// once. maxConcurrent <= 0 means unlimited.
+func NewRequestTracker(maxConcurrent int) *RequestTracker {
+	t := &RequestTracker{active: make(map[uint64]string)}
+	if maxConcurrent > 0 {
+		t.sem = make(chan struct{}, maxConcurrent)
+	}
+	return t
+}
+
+// Middleware tracks each request for the duration of next. When the concurrency limit
+// is reached requests wait for a free slot until the client gives up.
+func (t *RequestTracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if t.sem != nil {
+			select {
+			case t.sem <- struct{}{}:
+				defer func() { <-t.sem }()
+			case <-r.Context().Done():
+				http.Error(w, "Server busy", http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		t.wg.Add(1)
+		t.mu.Lock()
+		id := t.nextID
+		t.nextID++
+		t.active[id] = r.URL.Path
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			delete(t.active, id)
+			t.mu.Unlock()
+			t.wg.Done()
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Count returns the number of in-flight requests.
+func (t *RequestTracker) Count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.active)
+}
+
+// ActivePaths returns the sorted paths of the in-flight requests.
+func (t *RequestTracker) ActivePaths() []string {
+	t.mu.Lock()
+	paths := make([]string, 0, len(t.active))
+	for _, p := range t.active {
+		paths = append(paths, p)
+	}
+	t.mu.Unlock()
+	sort.Strings(paths)
+	return paths
+}
+
+// Wait blocks until all in-flight requests have finished or ctx is done.
+func (t *RequestTracker) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown gracefully shuts down server, waiting for in-flight requests until ctx is
+// done. If the deadline is hit the paths of the requests still running are logged.
+func (t *RequestTracker) Shutdown(ctx context.Context, server *http.Server) error {
+	if n := t.Count(); n > 0 {
+		log.Printf("waiting for %d in-flight requests to complete", n)
+	}
+	err := server.Shutdown(ctx)
+	if err == nil {
+		err = t.Wait(ctx)
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		log.Printf("shutdown timed out with %d requests still active: %v", t.Count(), t.ActivePaths())
+		return err
+	}
+	if err == nil {
+		log.Println("all in-flight requests completed")
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"copilot-api/internal/copilot"
+)
+
+// jsonModeCapability is the models catalog capability of models that honour
+// response_format json_object natively.
+const jsonModeCapability = "json-mode"
+
+// jsonModeInstruction is added to the system prompt of JSON mode requests for models
+// without native JSON mode.
+const jsonModeInstruction = "Respond with valid JSON only"
+
+// applyJSONMode asks for JSON in the system prompt when a request uses response_format
+// json_object and the model is not known to support JSON mode. The instruction is
+// appended to the first system message, or sent as a new one if there is none.
+func applyJSONMode(reqBody map[string]interface{}, modelsCache *copilot.ModelsCache) {
+	format, _ := reqBody["response_format"].(map[string]interface{})
+	if format == nil || format["type"] != "json_object" {
+		return
+	}
+	if model, _ := reqBody["model"].(string); model != "" && modelsCache != nil {
+		if m, ok := modelsCache.Lookup(model); ok && m.HasCapability(jsonModeCapability) {
+			return
+		}
+	}
+
+	messages, _ := reqBody["messages"].([]interface{})
+	for _, raw := range messages {
+		msg, ok := raw.(map[string]interface{})
+		if !ok || msg["role"] != "system" {
+			continue
+		}
+		if content, ok := msg["content"].(string); ok {
+			msg["content"] = content + "\n\n" + jsonModeInstruction
+			return
+		}
+	}
+	system := map[string]interface{}{"role": "system", "content": jsonModeInstruction}
+	reqBody["messages"] = append([]interface{}{system}, messages...)
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"copilot-api/pkg/config"
+)
+
+var (
+	// errKeyExists is returned by KeyStore.Add for a label or token already in use.
+	errKeyExists = errors.New("key already exists")
+	// errDailyLimit is returned by KeyStore.authenticate for a key over its daily limit.
+	errDailyLimit = errors.New("daily request limit reached")
+)
+
+// storedKey is an API key of a KeyStore with its usage.
+type storedKey struct {
+	config.APIKey
+	runtime  bool // added through /admin/keys rather than configured
+	lastUsed time.Time
+	requests int64
+	day      string // UTC date dayCount counts requests for
+	dayCount int
+}
+
+// keyStoreState is the content of the API_KEYS_STATE_FILE: the keys added at runtime
+// and the labels revoked at runtime.
+type keyStoreState struct {
+	Keys    []config.APIKey `json:"keys"`
+	Revoked []string        `json:"revoked,omitempty"`
+}
+
+// KeyStore holds the API keys accepted besides COPILOT_TOKEN and ADMIN_TOKEN. Keys can
+// be added and revoked at runtime through /admin/keys; with a state file these changes
+// survive restarts.
+type KeyStore struct {
+	mu      sync.RWMutex
+	keys    map[string]*storedKey // by label
+	revoked map[string]bool       // labels of configured keys revoked at runtime
+	path    string
+}
+
+// NewKeyStore returns a KeyStore holding keys, usually Config.APIKeys. If path is set and
+// the file exists, the keys added and revoked when it was saved are applied on top.
+// keys stay authoritative for their labels: a saved key with the label of a configured
+// key is ignored, so rotating a token in API_KEYS takes effect.
+func NewKeyStore(keys []config.APIKey, path string) (*KeyStore, error) {
+	ks := &KeyStore{keys: make(map[string]*storedKey, len(keys)), revoked: make(map[string]bool), path: path}
+	for _, k := range keys {
+		if isReservedLabel(k.Label) {
+			return nil, fmt.Errorf("API key label %q is reserved", k.Label)
+		}
+		ks.keys[k.Label] = &storedKey{APIKey: k}
+	}
+	if path == "" {
+		return ks, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state keyStoreState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, label := range state.Revoked {
+		delete(ks.keys, label)
+		ks.revoked[label] = true
+	}
+	for _, k := range state.Keys {
+		if isReservedLabel(k.Label) {
+			return nil, fmt.Errorf("%s: API key label %q is reserved", path, k.Label)
+		}
+		if slices.ContainsFunc(keys, func(c config.APIKey) bool { return c.Label == k.Label }) {
+			continue
+		}
+		ks.keys[k.Label] = &storedKey{APIKey: k, runtime: true}
+	}
+	return ks, nil
+}
+
+// Add adds key. Labels and tokens must be unique.
+func (ks *KeyStore) Add(key config.APIKey) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for label, k := range ks.keys {
+		if label == key.Label || k.Token == key.Token {
+			return errKeyExists
+		}
+	}
+	ks.keys[key.Label] = &storedKey{APIKey: key, runtime: true}
+	delete(ks.revoked, key.Label)
+	return nil
+}
+
+// Revoke removes the key labeled label. It reports whether the key existed.
+func (ks *KeyStore) Revoke(label string) bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if _, ok := ks.keys[label]; !ok {
+		return false
+	}
+	delete(ks.keys, label)
+	ks.revoked[label] = true
+	return true
+}
+
+// Save writes the keys added and the labels revoked at runtime to the state file
+// atomically. It does nothing without a state file.
+func (ks *KeyStore) Save() error {
+	if ks.path == "" {
+		return nil
+	}
+	ks.mu.RLock()
+	state := keyStoreState{Keys: make([]config.APIKey, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		if k.runtime {
+			state.Keys = append(state.Keys, k.APIKey)
+		}
+	}
+	for label := range ks.revoked {
+		state.Revoked = append(state.Revoked, label)
+	}
+	ks.mu.RUnlock()
+	sort.Slice(state.Keys, func(i, j int) bool { return state.Keys[i].Label < state.Keys[j].Label })
+	sort.Strings(state.Revoked)
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(ks.path), 0700); err != nil {
+		return err
+	}
+	tempFile := ks.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, ks.path)
+}
+
+// authenticate returns the label of the key matching token and records its use, or ""
+// if no key matches. A key over its daily limit returns errDailyLimit.
+func (ks *KeyStore) authenticate(token string) (string, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, k := range ks.keys {
+		if k.Token != token {
+			continue
+		}
+		now := time.Now().UTC()
+		if day := now.Format(time.DateOnly); day != k.day {
+			k.day, k.dayCount = day, 0
+		}
+		if k.DailyLimit > 0 && k.dayCount >= k.DailyLimit {
+			return k.Label, errDailyLimit
+		}
+		k.dayCount++
+		k.requests++
+		k.lastUsed = now
+		return k.Label, nil
+	}
+	return "", nil
+}
+
+// keyInfo is an entry of the GET /admin/keys response. The token is never shown.
+type keyInfo struct {
+	Label        string     `json:"label"`
+	DailyLimit   int        `json:"daily_limit"`
+	RequestCount int64      `json:"request_count"`
+	LastUsed     *time.Time `json:"last_used"`
+}
+
+// list returns the keys sorted by label.
+func (ks *KeyStore) list() []keyInfo {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make([]keyInfo, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		info := keyInfo{Label: k.Label, DailyLimit: k.DailyLimit, RequestCount: k.requests}
+		if !k.lastUsed.IsZero() {
+			lastUsed := k.lastUsed
+			info.LastUsed = &lastUsed
+		}
+		keys = append(keys, info)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Label < keys[j].Label })
+	return keys
+}
+
+// keysHandler serves the admin key endpoints: GET /admin/keys lists the keys, POST
+// /admin/keys adds one from a {"label", "token", "daily_limit"} body and DELETE
+// /admin/keys/{label} revokes one. Changes are saved to the state file right away.
+func keysHandler(keys *KeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		label := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/admin/keys"), "/")
+		switch {
+		case label == "" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string][]keyInfo{"keys": keys.list()})
+		case label == "" && r.Method == http.MethodPost:
+			var key config.APIKey
+			if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+				writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "", "Invalid JSON body: "+err.Error())
+				return
+			}
+			key.Label, key.Token = strings.TrimSpace(key.Label), strings.TrimSpace(key.Token)
+			if err := validateNewKey(key); err != nil {
+				writeValidationError(w, err)
+				return
+			}
+			if err := keys.Add(key); err != nil {
+				writeOpenAIError(w, http.StatusConflict, "invalid_request_error", "", "A key with this label or token already exists")
+				return
+			}
+			saveKeys(keys)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(keyInfo{Label: key.Label, DailyLimit: key.DailyLimit})
+		case label != "" && r.Method == http.MethodDelete:
+			if !keys.Revoke(label) {
+				writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "", "Unknown key: "+label)
+				return
+			}
+			saveKeys(keys)
+			w.WriteHeader(http.StatusNoContent)
+		case label == "":
+			w.Header().Set("Allow", "GET, POST")
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+		default:
+			w.Header().Set("Allow", http.MethodDelete)
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+		}
+	}
+}
+
+// validateNewKey checks a key sent to POST /admin/keys.
+func validateNewKey(key config.APIKey) error {
+	switch {
+	case key.Label == "":
+		return invalidParam("label", "label is required")
+	case isReservedLabel(key.Label):
+		return invalidParam("label", "label %q is reserved", key.Label)
+	case strings.Contains(key.Label, "/"):
+		return invalidParam("label", "label must not contain '/'")
+	case key.Token == "":
+		return invalidParam("token", "token is required")
+	case key.DailyLimit < 0:
+		return invalidParam("daily_limit", "daily_limit must not be negative, got %d", key.DailyLimit)
+	}
+	return nil
+}
+
+// isReservedLabel reports whether label is given to requests authenticated with
+// COPILOT_TOKEN or ADMIN_TOKEN; admin access is granted by label, so keys cannot use it.
+func isReservedLabel(label string) bool {
+	return label == defaultKeyLabel || label == adminKeyLabel
+}
+
+// saveKeys saves keys after a change, logging rather than failing the request if the
+// state file cannot be written.
+func saveKeys(keys *KeyStore) {
+	if err := keys.Save(); err != nil {
+		log.Printf("Warning: failed to save API keys: %v", err)
+	}
+}
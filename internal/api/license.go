@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// checkLicenseTier refuses requests for a model in EnterpriseOnlyModels when the
+// Copilot license is an individual plan. An unknown license tier is let through.
+func checkLicenseTier(cfg *config.Config, tokenManager copilot.TokenSource, body map[string]interface{}) error {
+	model, _ := body["model"].(string)
+	if model == "" || len(cfg.EnterpriseOnlyModels) == 0 || tokenManager == nil {
+		return nil
+	}
+	if tokenManager.LicenseTier() != copilot.LicenseIndividual {
+		return nil
+	}
+	for _, m := range cfg.EnterpriseOnlyModels {
+		if strings.EqualFold(m, model) {
+			return invalidParam("model", "model %s requires a Copilot Business or Enterprise license, but this Copilot token belongs to an individual plan", model)
+		}
+	}
+	return nil
+}
+
+// tokenStatusHandler handles GET /admin/token/status, reporting the expiry and license
+// tier of the current Copilot token.
+func tokenStatusHandler(tokenManager copilot.TokenSource) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if tokenManager == nil {
+			http.Error(w, "No Copilot token manager", http.StatusServiceUnavailable)
+			return
+		}
+		expiry := tokenManager.TokenExpiry()
+		status := map[string]interface{}{
+			"valid":        expiry.After(time.Now()),
+			"license_tier": tokenManager.LicenseTier(),
+		}
+		if !expiry.IsZero() {
+			status["expires_at"] = expiry.UTC().Format(time.RFC3339)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(status)
+	}
+}
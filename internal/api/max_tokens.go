@@ -0,0 +1,72 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// applyMaxTokensLimit clamps the max_tokens of a request body to the limit configured
+// for the authenticated key, setting it when the request has none, and reports the
+// limit in the X-Max-Tokens-Limit response header.
+func applyMaxTokensLimit(w http.ResponseWriter, r *http.Request, cfg *config.Config, body map[string]interface{}) {
+	limit, ok := cfg.MaxTokensPerKey[keyLabelFromContext(r.Context())]
+	if !ok || limit <= 0 {
+		return
+	}
+	if n, ok := body["max_tokens"].(float64); !ok || n > float64(limit) {
+		body["max_tokens"] = limit
+	}
+	w.Header().Set("X-Max-Tokens-Limit", strconv.Itoa(limit))
+}
+
+// applyModelMaxTokens clamps the max_tokens of a request body to the output token limit
+// of the requested model in modelsCache, reporting the requested value in the
+// X-Max-Tokens-Clamped response header. Models missing from the cache or without a
+// known limit are left alone.
+func applyModelMaxTokens(w http.ResponseWriter, cfg *config.Config, modelsCache *copilot.ModelsCache, body map[string]interface{}) {
+	m := lookupModel(modelsCache, body)
+	if m == nil {
+		return
+	}
+	original := body["max_tokens"]
+	clamps := enforceModelLimits(body, m)
+	if len(clamps) == 0 {
+		return
+	}
+	w.Header().Set("X-Max-Tokens-Clamped", strconv.FormatFloat(original.(float64), 'f', -1, 64))
+	if cfg.Debug {
+		log.Printf("Clamped request for %s: %s", m.ID, strings.Join(clamps, ", "))
+	}
+}
+
+// lookupModel returns the model requested by body from modelsCache, or nil if it is not
+// cached.
+func lookupModel(modelsCache *copilot.ModelsCache, body map[string]interface{}) *copilot.Model {
+	if modelsCache == nil {
+		return nil
+	}
+	id, _ := body["model"].(string)
+	m, ok := modelsCache.Lookup(id)
+	if !ok {
+		return nil
+	}
+	return &m
+}
+
+// enforceModelLimits lowers the max_tokens of body to the MaxOutputTokens of model and
+// returns a description of each change made.
+func enforceModelLimits(body map[string]interface{}, model *copilot.Model) []string {
+	limit := model.Limits.MaxOutputTokens
+	n, ok := body["max_tokens"].(float64)
+	if limit <= 0 || !ok || n <= float64(limit) {
+		return nil
+	}
+	body["max_tokens"] = limit
+	return []string{fmt.Sprintf("max_tokens %v -> %d", n, limit)}
+}
@@ -0,0 +1,12 @@
+package api
+
+import "copilot-api/internal/copilot"
+
+// applyModelAlias replaces the model of a request body with its alias target, if the
+// model is an alias.
+func applyModelAlias(body map[string]interface{}, aliases *copilot.AtomicAliasMap) {
+	model, _ := body["model"].(string)
+	if target := aliases.Get(model); target != "" && model != "" {
+		body["model"] = target
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"copilot-api/internal/copilot"
+)
+
+// modelCompatibility is the GET /v1/models/{id}/compatibility response: the features a
+// client can use with a model through the proxy.
+type modelCompatibility struct {
+	ModelID                 string `json:"model_id"`
+	SupportsStreaming       bool   `json:"supports_streaming"`
+	SupportsFunctionCalling bool   `json:"supports_function_calling"`
+	SupportsVision          bool   `json:"supports_vision"`
+	SupportsJSONMode        bool   `json:"supports_json_mode"`
+	AnthropicCompatible     bool   `json:"anthropic_compatible"`
+	MaxContextTokens        int    `json:"max_context_tokens"`
+	MaxOutputTokens         int    `json:"max_output_tokens"`
+}
+
+// compatibilityOf returns the compatibility document of m. JSON mode and /v1/messages
+// work for every chat model, that is every model producing text: the proxy adds a JSON
+// instruction for models without the json-mode capability and converts Anthropic
+// requests to chat completions.
+func compatibilityOf(m copilot.Model) modelCompatibility {
+	chat := len(m.SupportedOutputModalities) == 0 || slices.Contains(m.SupportedOutputModalities, "text")
+	return modelCompatibility{
+		ModelID:                 m.ID,
+		SupportsStreaming:       searchCapabilities["streaming"](m),
+		SupportsFunctionCalling: searchCapabilities["function_calling"](m),
+		SupportsVision:          searchCapabilities["vision"](m),
+		SupportsJSONMode:        chat,
+		AnthropicCompatible:     chat,
+		MaxContextTokens:        m.Limits.MaxInputTokens,
+		MaxOutputTokens:         m.Limits.MaxOutputTokens,
+	}
+}
+
+// modelCompatibilityHandler serves GET /v1/models/{id}/compatibility. The ID is matched
+// like request models, so the publisher prefix is optional; unknown models get 404.
+func modelCompatibilityHandler(modelsCache *copilot.ModelsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/v1/models/"), "/compatibility")
+		if !ok || id == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+			return
+		}
+		if modelsCache == nil {
+			writeOpenAIError(w, http.StatusServiceUnavailable, "api_error", "", "models unavailable at startup")
+			return
+		}
+		// Fill an empty cache before looking the model up
+		if _, err := modelsCache.ListModels(r.Context()); err != nil {
+			http.Error(w, "Failed to fetch models: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		m, ok := modelsCache.Lookup(id)
+		if !ok {
+			writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "model", "The model "+id+" does not exist")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(compatibilityOf(m))
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// applyModelFallback checks the requested model against the models list. A model that
+// is not listed is replaced by cfg.FallbackModel, which is reported in the X-Model-Fallback
+// and X-Original-Model response headers; without a fallback an error naming the available
+// models is returned. Requests without a model, and an empty or missing models list, are
+// left alone.
+func applyModelFallback(w http.ResponseWriter, r *http.Request, cfg *config.Config, modelsCache *copilot.ModelsCache, body map[string]interface{}) error {
+	model, _ := body["model"].(string)
+	if model == "" || modelsCache == nil || modelsCache.ModelCount() == 0 || modelsCache.HasModel(model) {
+		return nil
+	}
+	if cfg.FallbackModel == "" {
+		return invalidParam("model", "model %q is not available; available models: %s", model, availableModels(r, modelsCache))
+	}
+	body["model"] = cfg.FallbackModel
+	w.Header().Set("X-Model-Fallback", "true")
+	w.Header().Set("X-Original-Model", model)
+	return nil
+}
+
+// availableModels lists the IDs of the cached models, separated by commas.
+func availableModels(r *http.Request, modelsCache *copilot.ModelsCache) string {
+	models, _ := modelsCache.ListModels(r.Context())
+	ids := make([]string, len(models))
+	for i, m := range models {
+		ids[i] = m.ID
+	}
+	return strings.Join(ids, ", ")
+}
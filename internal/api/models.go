@@ -0,0 +1,66 @@
+package api
+
+import (
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+// anthropicMediaType selects the Anthropic model list format on /v1/models.
+const anthropicMediaType = "application/vnd.anthropic+json"
+
+// openAIModel is a model entry in the OpenAI /v1/models format.
+type openAIModel struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// openAIModels is the OpenAI /v1/models list response.
+type openAIModels struct {
+	Object string        `json:"object"`
+	Data   []openAIModel `json:"data"`
+}
+
+// anthropicModel is a model entry in the Anthropic model list format.
+type anthropicModel struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// anthropicModels is the Anthropic model list response.
+type anthropicModels struct {
+	Models []anthropicModel `json:"models"`
+}
+
+// openAIModelList converts catalog models to the OpenAI list format. The catalog has no
+// creation dates, so every model reports the time the catalog was fetched.
+func openAIModelList(models []copilot.Model, fetched time.Time) openAIModels {
+	list := openAIModels{Object: "list", Data: make([]openAIModel, 0, len(models))}
+	for _, m := range models {
+		list.Data = append(list.Data, openAIModel{
+			ID:      m.ID,
+			Object:  "model",
+			Created: fetched.Unix(),
+			OwnedBy: m.Publisher,
+		})
+	}
+	return list
+}
+
+// anthropicModelList converts catalog models to the Anthropic list format.
+func anthropicModelList(models []copilot.Model, fetched time.Time) anthropicModels {
+	list := anthropicModels{Models: make([]anthropicModel, 0, len(models))}
+	for _, m := range models {
+		list.Models = append(list.Models, anthropicModel{
+			Type:        "model",
+			ID:          m.ID,
+			DisplayName: m.Name,
+			CreatedAt:   fetched.UTC().Format(time.RFC3339),
+		})
+	}
+	return list
+}
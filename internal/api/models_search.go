@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"copilot-api/internal/copilot"
+)
+
+// searchCapabilities maps the capability values accepted by /v1/models/search to a test
+// of a catalog model.
+var searchCapabilities = map[string]func(copilot.Model) bool{
+	"vision":           func(m copilot.Model) bool { return m.Supports(copilot.CapabilityVision) },
+	"function_calling": func(m copilot.Model) bool { return m.Supports(copilot.CapabilityFunctionCalling) },
+	"streaming":        func(m copilot.Model) bool { return m.Supports(copilot.CapabilityStreaming) },
+}
+
+// modelSearch holds the filters of a /v1/models/search request. Empty fields match any
+// model.
+type modelSearch struct {
+	query      string
+	capability func(copilot.Model) bool
+	vendor     string
+}
+
+// matches reports whether m passes every filter of s.
+func (s modelSearch) matches(m copilot.Model) bool {
+	if s.query != "" && !strings.Contains(strings.ToLower(m.ID), s.query) && !strings.Contains(strings.ToLower(m.Name), s.query) {
+		return false
+	}
+	if s.capability != nil && !s.capability(m) {
+		return false
+	}
+	if s.vendor != "" {
+		vendor := m.Publisher
+		if vendor == "" {
+			vendor, _, _ = strings.Cut(m.ID, "/")
+		}
+		if !strings.EqualFold(vendor, s.vendor) {
+			return false
+		}
+	}
+	return true
+}
+
+// modelsSearchHandler serves GET /v1/models/search, the cached models filtered by the q
+// (case-insensitive substring of the ID or name), capability and vendor query
+// parameters, in the /v1/models OpenAI list format.
+func modelsSearchHandler(modelsCache *copilot.ModelsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+			return
+		}
+		params := r.URL.Query()
+		search := modelSearch{
+			query:  strings.ToLower(strings.TrimSpace(params.Get("q"))),
+			vendor: strings.TrimSpace(params.Get("vendor")),
+		}
+		if c := strings.TrimSpace(params.Get("capability")); c != "" {
+			if search.capability = searchCapabilities[strings.ToLower(c)]; search.capability == nil {
+				writeValidationError(w, invalidParam("capability", "capability must be one of vision, function_calling or streaming, got %q", c))
+				return
+			}
+		}
+		if modelsCache == nil {
+			writeOpenAIError(w, http.StatusServiceUnavailable, "api_error", "", "models unavailable at startup")
+			return
+		}
+		models, err := modelsCache.ListModels(r.Context())
+		if err != nil {
+			http.Error(w, "Failed to fetch models: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		matched := make([]copilot.Model, 0, len(models))
+		for _, m := range models {
+			if search.matches(m) {
+				matched = append(matched, m)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(openAIModelList(matched, modelsCache.LastFetch()))
+	}
+}
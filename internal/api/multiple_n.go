@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	"copilot-api/internal/sse"
+	"copilot-api/pkg/config"
+)
+
+// completionCount returns the n parameter of a chat completion request, defaulting to 1.
+// It assumes the request has been validated.
+func completionCount(body map[string]interface{}) int {
+	if n, ok := body["n"].(float64); ok {
+		return int(n)
+	}
+	return 1
+}
+
+// emulateMultipleN serves a chat completion request for n > 1 choices with n parallel
+// single-choice upstream requests. Non-streaming responses are merged into one
+// completion holding all choices, indexed in request order, with summed completion
+// tokens. Streaming responses are interleaved event by event, each choice carrying its
+// own index, and end with a single [DONE]. The streams are only read once all have
+// started, so their upstream concurrency slots are reserved together beforehand. If any
+// upstream request fails, the first failure is returned instead.
+func emulateMultipleN(w http.ResponseWriter, r *http.Request, cfg *config.Config, pool *endpointPool, reqBody map[string]interface{}, n int, copilotToken string) {
+	single := make(map[string]interface{}, len(reqBody))
+	for k, v := range reqBody {
+		single[k] = v
+	}
+	delete(single, "n")
+	bodyBytes, err := json.Marshal(single)
+	if err != nil {
+		http.Error(w, "Failed to marshal request: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if single["stream"] == true {
+		ctx, release, err := reserveSlots(r.Context(), pool.sem, n)
+		if err != nil {
+			writeUpstreamError(w, err)
+			return
+		}
+		defer release()
+		r = r.WithContext(ctx)
+	}
+
+	resps := make([]*http.Response, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resps[i], errs[i] = pool.send(r, cfg, r.Method, "/chat/completions", bodyBytes, copilotToken)
+		}(i)
+	}
+	wg.Wait()
+	defer func() {
+		for _, resp := range resps {
+			if resp != nil {
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	for i, resp := range resps {
+		if errs[i] != nil {
+			writeUpstreamError(w, errs[i])
+			return
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			captured, err := captureResponse(resp, nil)
+			if err != nil {
+				writeUpstreamError(w, err)
+				return
+			}
+			captured.writeTo(w)
+			return
+		}
+	}
+
+	if isSSEResponse(resps[0]) {
+		interleaveStreams(w, resps)
+		return
+	}
+	mergeCompletions(w, resps)
+}
+
+// mergeCompletions writes one completion holding the first choice of every response.
+func mergeCompletions(w http.ResponseWriter, resps []*http.Response) {
+	var merged map[string]interface{}
+	var choices []interface{}
+	var completionTokens float64
+	for i, resp := range resps {
+		var completion map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+			http.Error(w, "Failed to decode Copilot response: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		if merged == nil {
+			merged = completion
+		}
+		if list, _ := completion["choices"].([]interface{}); len(list) > 0 {
+			if choice, ok := list[0].(map[string]interface{}); ok {
+				choice["index"] = i
+				choices = append(choices, choice)
+			}
+		}
+		if usage, ok := completion["usage"].(map[string]interface{}); ok {
+			tokens, _ := usage["completion_tokens"].(float64)
+			completionTokens += tokens
+		}
+	}
+	merged["choices"] = choices
+	if usage, ok := merged["usage"].(map[string]interface{}); ok {
+		promptTokens, _ := usage["prompt_tokens"].(float64)
+		usage["completion_tokens"] = completionTokens
+		usage["total_tokens"] = promptTokens + completionTokens
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(merged)
+}
+
+// interleaveStreams copies the events of all streams to w as they arrive, setting the
+// choice index of each event to the position of its stream. Every event is written
+// whole, and a single [DONE] ends the merged stream.
+func interleaveStreams(w http.ResponseWriter, resps []*http.Response) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	events := sse.NewWriter(w)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, resp := range resps {
+		wg.Add(1)
+		go func(i int, body io.Reader) {
+			defer wg.Done()
+			scanner := sse.NewScanner(body)
+			for scanner.Scan() {
+				event := scanner.Event()
+				if event.Data == "[DONE]" {
+					continue
+				}
+				event.Data = string(reindexChunk([]byte(event.Data), i))
+				mu.Lock()
+				_ = events.WriteEvent(event)
+				mu.Unlock()
+			}
+		}(i, resp.Body)
+	}
+	wg.Wait()
+
+	_ = events.WriteEvent(sse.Event{Data: "[DONE]"})
+}
+
+// reindexChunk sets the index of every choice in a streamed completion chunk. Chunks
+// that are not JSON objects are returned unchanged.
+func reindexChunk(data []byte, index int) []byte {
+	var chunk map[string]interface{}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return data
+	}
+	choices, _ := chunk["choices"].([]interface{})
+	for _, c := range choices {
+		if choice, ok := c.(map[string]interface{}); ok {
+			choice["index"] = index
+		}
+	}
+	out, err := json.Marshal(chunk)
+	if err != nil {
+		return data
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"copilot-api/internal/copilot"
+)
+
+// provider is an entry of the /v1/providers response.
+type provider struct {
+	Name   string   `json:"name"`
+	Models []string `json:"models"`
+}
+
+// providersHandler serves the cached models grouped by provider: GET /v1/providers lists
+// every provider with its model IDs, and GET /v1/providers/{name}/models lists the models
+// of one provider, matched case-insensitively, in the OpenAI list format.
+func providersHandler(modelsCache *copilot.ModelsCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/providers"), "/")
+		if name != "" {
+			var ok bool
+			if name, ok = strings.CutSuffix(name, "/models"); !ok || name == "" || strings.Contains(name, "/") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		if r.Method != http.MethodGet {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+			return
+		}
+		if modelsCache == nil {
+			writeOpenAIError(w, http.StatusServiceUnavailable, "api_error", "", "models unavailable at startup")
+			return
+		}
+		// ListModels fills an empty or expired cache before grouping
+		if _, err := modelsCache.ListModels(r.Context()); err != nil {
+			http.Error(w, "Failed to fetch models: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		byProvider := modelsCache.GetByProvider()
+
+		var body interface{}
+		if name == "" {
+			providers := make([]provider, 0, len(byProvider))
+			for p, models := range byProvider {
+				ids := make([]string, 0, len(models))
+				for _, m := range models {
+					ids = append(ids, m.ID)
+				}
+				providers = append(providers, provider{Name: p, Models: ids})
+			}
+			sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+			body = map[string][]provider{"providers": providers}
+		} else {
+			var models []copilot.Model
+			found := false
+			for p, m := range byProvider {
+				if strings.EqualFold(p, name) {
+					models, found = m, true
+					break
+				}
+			}
+			if !found {
+				writeOpenAIError(w, http.StatusNotFound, "invalid_request_error", "", "Unknown provider: "+name)
+				return
+			}
+			body = openAIModelList(models, modelsCache.LastFetch())
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"copilot-api/pkg/config"
+)
+
+// defaultRateLimiterMaxIPs bounds the tracked clients when none is configured.
+const defaultRateLimiterMaxIPs = 10000
+
+// ipLimiters is an LRU cache of per-client rate limiters. Tracking at most maxSize
+// clients keeps memory bounded when requests arrive from many distinct addresses.
+type ipLimiters struct {
+	mu      sync.Mutex
+	maxSize int
+	limit   rate.Limit
+	burst   int
+	order   *list.List // front is most recently seen
+	entries map[string]*list.Element
+}
+
+// ipLimiterEntry is the rate limiter of a single client IP.
+type ipLimiterEntry struct {
+	ip      string
+	limiter *rate.Limiter
+}
+
+// newIPLimiters returns a cache of limiters allowing limit requests per second with the
+// given burst, tracking up to maxSize clients.
+func newIPLimiters(maxSize int, limit rate.Limit, burst int) *ipLimiters {
+	if maxSize <= 0 {
+		maxSize = defaultRateLimiterMaxIPs
+	}
+	return &ipLimiters{
+		maxSize: maxSize,
+		limit:   limit,
+		burst:   burst,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the limiter for ip, creating it and evicting the least recently seen
+// client if the cache is full.
+func (c *ipLimiters) get(ip string) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[ip]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*ipLimiterEntry).limiter
+	}
+	entry := &ipLimiterEntry{ip: ip, limiter: rate.NewLimiter(c.limit, c.burst)}
+	c.entries[ip] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxSize {
+		el := c.order.Back()
+		c.order.Remove(el)
+		delete(c.entries, el.Value.(*ipLimiterEntry).ip)
+	}
+	return entry.limiter
+}
+
+// rateLimitMiddleware rejects clients exceeding cfg.RateLimitPerMinute requests with
+// 429 Too Many Requests. A limit <= 0 disables rate limiting.
+func rateLimitMiddleware(cfg *config.Config, next http.Handler) http.Handler {
+	if cfg.RateLimitPerMinute <= 0 {
+		return next
+	}
+	burst := cfg.RateLimitBurst
+	if burst <= 0 {
+		burst = 1
+	}
+	limiters := newIPLimiters(cfg.RateLimiterMaxIPs, rate.Limit(float64(cfg.RateLimitPerMinute)/60), burst)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := realClientIP(r, cfg.TrustedProxyCount).String()
+		if !limiters.get(ip).Allow() {
+			writeOpenAIError(w, http.StatusTooManyRequests, "rate_limit_error", "", "Rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
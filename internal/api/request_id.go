@@ -0,0 +1,46 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"copilot-api/internal/ctxkey"
+)
+
+// maxRequestIDLength is the longest client X-Request-Id reused as the request ID.
+const maxRequestIDLength = 128
+
+// requestIDMiddleware gives every request an ID, stored in its context. A client supplied
+// X-Request-Id is kept if it is short printable ASCII; otherwise a random ID is
+// generated. The response header is left alone, as Copilot's own X-Request-Id is passed
+// through there.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		next.ServeHTTP(w, r.WithContext(ctxkey.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether id can be used as a request ID as is.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 32 character hex request ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// responseCache is an LRU cache of upstream responses with a per-entry TTL.
+type responseCache struct {
+	mu      sync.Mutex
+	maxSize int
+	ttl     time.Duration
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+}
+
+// responseCacheEntry is a cached response and the time it was stored.
+type responseCacheEntry struct {
+	key      string
+	resp     *capturedResponse
+	storedAt time.Time
+}
+
+// newResponseCache returns a cache holding up to maxSize responses, or nil if maxSize <= 0.
+func newResponseCache(maxSize int, ttl time.Duration) *responseCache {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &responseCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached entry for key, evicting it if its TTL has expired.
+func (c *responseCache) get(key string) (*responseCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*responseCacheEntry)
+	if time.Since(entry.storedAt) > c.ttl {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return entry, true
+}
+
+// add stores resp under key, evicting expired and least recently used entries.
+func (c *responseCache) add(key string, resp *capturedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = &responseCacheEntry{key: key, resp: resp, storedAt: time.Now()}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&responseCacheEntry{key: key, resp: resp, storedAt: time.Now()})
+	for el := c.order.Back(); el != nil; el = c.order.Back() {
+		entry := el.Value.(*responseCacheEntry)
+		if c.order.Len() <= c.maxSize && time.Since(entry.storedAt) <= c.ttl {
+			break
+		}
+		c.order.Remove(el)
+		delete(c.entries, entry.key)
+	}
+}
+
+// writeTo replays the cached response with X-Cache and Age headers.
+func (e *responseCacheEntry) writeTo(w http.ResponseWriter) {
+	w.Header().Set("X-Cache", "HIT")
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(e.storedAt).Seconds())))
+	e.resp.writeTo(w)
+}
+
+// isCacheableRequest reports whether a chat completion request has a deterministic
+// output: non-streaming with temperature 0.
+func isCacheableRequest(body map[string]interface{}) bool {
+	if body["stream"] == true {
+		return false
+	}
+	temperature, ok := body["temperature"].(float64)
+	return ok && temperature == 0
+}
+
+// responseCacheKey identifies a cacheable request by its marshaled body, so that every
+// field, including tools, response_format and stop, must match for a hit. Maps marshal
+// with sorted keys, so equal requests give equal keys.
+func responseCacheKey(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// retryBaseDelay is the wait before the second attempt; it doubles for every further attempt.
+const retryBaseDelay = 100 * time.Millisecond
+
+// doWithRetry sends the request returned by buildReq up to maxAttempts times, retrying
+// on transport errors and 502, 503 and 504 responses. buildReq is called for every
+// attempt so each one gets a fresh body reader. maxAttempts < 1 means a single attempt.
+func doWithRetry(ctx context.Context, client *http.Client, buildReq func() (*http.Request, error), maxAttempts int) (*http.Response, error) {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		req, err := buildReq()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if attempt == maxAttempts || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// shouldRetry reports whether an upstream attempt failed in a way worth retrying.
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
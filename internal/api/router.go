@@ -1,49 +1,195 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"log"
+	"math/rand"
+	"mime"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
+	"copilot-api/internal/audit"
 	"copilot-api/internal/copilot"
+	"copilot-api/internal/ctxkey"
+	"copilot-api/internal/sse"
 	"copilot-api/pkg/config"
 )
 
 // NewRouter creates and returns the main HTTP handler (router) for the API.
-// Accepts a TokenManager for Copilot token management and a ModelsCache for model listing.
-func NewRouter(cfg *config.Config, tokenManager *copilot.TokenManager, modelsCache *copilot.ModelsCache) http.Handler {
+// Accepts a TokenManager or TokenPool for Copilot token management and a ModelsCache for model listing.
+func NewRouter(cfg *config.Config, tokenManager copilot.TokenSource, modelsCache *copilot.ModelsCache, opts ...RouterOption) http.Handler {
+	// A nil *TokenManager means no token manager, like a nil TokenSource
+	if tm, ok := tokenManager.(*copilot.TokenManager); ok && tm == nil {
+		tokenManager = nil
+	}
+	var o routerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var remaining *remainingTracker
+	var sizes *bodyMetrics
+	if o.registry != nil {
+		remaining = &remainingTracker{}
+		registerRateLimitMetrics(o.registry, remaining)
+		sizes = newBodyMetrics(o.registry)
+	}
+	if o.aliases == nil {
+		o.aliases = copilot.NewAtomicAliasMap(cfg.ModelAliases)
+	}
+	if o.usage == nil {
+		o.usage = NewUpstreamUsage(cfg, tokenManager)
+	}
+	if o.keys == nil {
+		o.keys, _ = NewKeyStore(cfg.APIKeys, "")
+	}
+	// One semaphore bounds the requests in flight to every upstream
+	upstreamSem := newUpstreamSemaphore(cfg.MaxUpstreamConcurrency)
+	pool := newEndpointPool(cfg, tokenManager, remaining, upstreamSem)
+	stats := newStatsTracker()
 	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", healthHandler)
-	mux.HandleFunc("/v1/chat/completions", chatCompletionsHandler(cfg, tokenManager))
-	mux.HandleFunc("/v1/embeddings", embeddingsHandler(cfg, tokenManager))
-	mux.HandleFunc("/v1/messages", anthropicHandler(cfg, tokenManager))
+	mux.HandleFunc("/healthz", healthHandler(tokenManager, modelsCache, time.Now()))
+	mux.HandleFunc("/readyz", readyHandler(tokenManager, o.shuttingDown))
+	streamer := newStreamCopier(cfg.StreamBufferSize, cfg.StreamFlushInterval)
+	mux.HandleFunc("/v1/chat/completions", methodGuard(http.MethodPost, chatCompletionsHandler(cfg, tokenManager, modelsCache, o.aliases, pool, stats, &requestDeduper{}, newResponseCache(cfg.ResponseCacheSize, cfg.ResponseCacheTTL), streamer)))
+	mux.HandleFunc("/v1/chat/completions/count_tokens", methodGuard(http.MethodPost, chatCountTokensHandler(cfg)))
+	mux.HandleFunc("/v1/embeddings", methodGuard(http.MethodPost, embeddingsHandler(cfg, tokenManager, modelsCache, o.aliases, pool, stats)))
+	mux.HandleFunc("/v1/messages", methodGuard(http.MethodPost, anthropicHandler(cfg, tokenManager, modelsCache, o.aliases, pool, stats)))
+	mux.HandleFunc("/v1/messages/count_tokens", methodGuard(http.MethodPost, anthropicCountTokensHandler(cfg)))
+	mux.HandleFunc("/v1/audio/transcriptions", audioTranscriptionsHandler(cfg, streamer, upstreamSem))
+	mux.HandleFunc("/v1/files", filesHandler(cfg, tokenManager, pool))
+	mux.HandleFunc("/v1/files/", filesHandler(cfg, tokenManager, pool))
+	assistants := assistantsProxyHandler(cfg, streamer, upstreamSem)
+	for _, prefix := range []string{"/v1/assistants", "/v1/threads"} {
+		mux.HandleFunc(prefix, assistants)
+		mux.HandleFunc(prefix+"/", assistants)
+	}
 	mux.HandleFunc("/v1/models", modelsHandler(modelsCache))
+	mux.HandleFunc("/v1/providers", providersHandler(modelsCache))
+	mux.HandleFunc("/v1/providers/", providersHandler(modelsCache))
+	mux.HandleFunc("/v1/models/search", modelsSearchHandler(modelsCache))
+	mux.HandleFunc("/v1/models/", modelCompatibilityHandler(modelsCache))
+	mux.HandleFunc("/v1/models/cache", adminOnly(modelsCacheRefreshHandler(modelsCache)))
+	mux.HandleFunc("/admin/stats", adminOnly(statsHandler(stats)))
+	mux.HandleFunc("/admin/token/status", adminOnly(tokenStatusHandler(tokenManager)))
+	mux.HandleFunc("/admin/upstream-usage", adminOnly(upstreamUsageHandler(cfg, o.usage)))
+	mux.HandleFunc("/admin/keys", adminOnly(keysHandler(o.keys)))
+	mux.HandleFunc("/admin/keys/", adminOnly(keysHandler(o.keys)))
+	if cfg.EnableTunnel {
+		mux.HandleFunc("/tunnel/", tunnelHandler(cfg))
+	}
 
-	handler := loggingMiddleware(AuthMiddleware(cfg, CORS(cfg, mux)))
+	handler := SecurityHeadersMiddleware(cfg, requestIDMiddleware(loggingMiddleware(cfg, rateLimitMiddleware(cfg, ContentTypeMiddleware(maxBodyMiddleware(cfg.MaxRequestBodyBytes, authMiddleware(cfg, o.keys, auditMiddleware(cfg, o.auditLog, CORS(cfg, bodyMetricsMiddleware(sizes, mux))))))))))
 	return handler
 }
 
-// healthHandler provides a simple health check endpoint.
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	resp := map[string]string{"status": "ok"}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(resp)
+// RouterOption configures optional NewRouter behavior.
+type RouterOption func(*routerOptions)
+
+// routerOptions holds the settings applied by RouterOptions.
+type routerOptions struct {
+	auditLog *audit.Logger
+	registry *prometheus.Registry
+	keys     *KeyStore
+	aliases  *copilot.AtomicAliasMap
+	usage    *UpstreamUsage
+	// shuttingDown is set once the server is shutting down
+	shuttingDown *atomic.Bool
+}
+
+// WithAuditLog writes an entry to auditLog for every authenticated request.
+func WithAuditLog(auditLog *audit.Logger) RouterOption {
+	return func(o *routerOptions) {
+		o.auditLog = auditLog
+	}
 }
 
-// loggingMiddleware is a simple request logger for demonstration.
-func loggingMiddleware(next http.Handler) http.Handler {
+// WithKeyStore takes the API keys from keys instead of cfg.APIKeys, so that keys added
+// and revoked through /admin/keys can be saved by the caller.
+func WithKeyStore(keys *KeyStore) RouterOption {
+	return func(o *routerOptions) {
+		o.keys = keys
+	}
+}
+
+// WithModelAliases resolves model aliases from aliases instead of cfg.ModelAliases, so
+// that they can be replaced while the server runs.
+func WithModelAliases(aliases *copilot.AtomicAliasMap) RouterOption {
+	return func(o *routerOptions) {
+		o.aliases = aliases
+	}
+}
+
+// WithUpstreamUsage serves /admin/upstream-usage from usage, which the caller may keep
+// fresh with Start.
+func WithUpstreamUsage(usage *UpstreamUsage) RouterOption {
+	return func(o *routerOptions) {
+		o.usage = usage
+	}
+}
+
+// WithShutdownFlag makes /readyz report the server as shutting down once shuttingDown is
+// set.
+func WithShutdownFlag(shuttingDown *atomic.Bool) RouterOption {
+	return func(o *routerOptions) {
+		o.shuttingDown = shuttingDown
+	}
+}
+
+// WithMetrics registers the API's Prometheus metrics with registry.
+func WithMetrics(registry *prometheus.Registry) RouterOption {
+	return func(o *routerOptions) {
+		o.registry = registry
+	}
+}
+
+// loggingMiddleware is a simple request logger, enabled in debug mode. Only a
+// LogSampleRate fraction of successful requests is logged; error responses and requests
+// slower than SlowRequestThreshold always are.
+func loggingMiddleware(cfg *config.Config, next http.Handler) http.Handler {
+	if !cfg.Debug {
+		return next
+	}
+	// A rand.Rand of its own keeps the middleware off the locked global source
+	var mu sync.Mutex
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	sampled := func() bool {
+		if cfg.LogSampleRate >= 1 {
+			return true
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return rng.Float64() < cfg.LogSampleRate
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		keep := sampled()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		elapsed := time.Since(start)
+		slow := cfg.SlowRequestThreshold > 0 && elapsed > cfg.SlowRequestThreshold
+		if !keep && !slow && rec.status < http.StatusBadRequest {
+			return
+		}
 		// In production, use a structured logger (e.g., slog, zap, zerolog)
 		// Here, we use the standard library for simplicity.
-		// log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
+		log.Printf("%s %s %s %d %dms %s", realClientIP(r, cfg.TrustedProxyCount), r.Method, r.URL.Path, rec.status, elapsed.Milliseconds(), ctxkey.MustRequestIDFrom(r.Context()))
 	})
 }
 
 // chatCompletionsHandler handles /v1/chat/completions requests (proxy to Copilot, streaming support).
-func chatCompletionsHandler(cfg *config.Config, tokenManager *copilot.TokenManager) http.HandlerFunc {
+func chatCompletionsHandler(cfg *config.Config, tokenManager copilot.TokenSource, modelsCache *copilot.ModelsCache, aliases *copilot.AtomicAliasMap, pool *endpointPool, stats *statsTracker, dedup *requestDeduper, respCache *responseCache, streamer *streamCopier) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		copilotToken, err := tokenManager.GetToken(ctx)
@@ -58,79 +204,162 @@ func chatCompletionsHandler(cfg *config.Config, tokenManager *copilot.TokenManag
 			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		if reqBody["model"] == nil || reqBody["model"] == "" {
-			if cfg.DefaultModel != "" {
-				reqBody["model"] = cfg.DefaultModel
-			} else {
-				delete(reqBody, "model")
+		if err := validateChatCompletionsRequest(reqBody); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		applyDefaultModel(reqBody, cfg.DefaultChatModel, cfg.DefaultModel)
+		applyModelAlias(reqBody, aliases)
+		if err := applyModelFallback(w, r, cfg, modelsCache, reqBody); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		if err := applySmartRouting(w, r, cfg, modelsCache, reqBody); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		if err := checkLicenseTier(cfg, tokenManager, reqBody); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		if err := sanitizeRequestBody(reqBody, cfg, lookupModel(modelsCache, reqBody)); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+		applyModelMaxTokens(w, cfg, modelsCache, reqBody)
+		applyMaxTokensLimit(w, r, cfg, reqBody)
+		applyJSONMode(reqBody, modelsCache)
+		if n := completionCount(reqBody); n > 1 {
+			if !cfg.EmulateMultipleN {
+				writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "n", "n > 1 not supported")
+				return
+			}
+			// Every stream holds an upstream slot until all of them have started
+			if reqBody["stream"] == true && cfg.MaxUpstreamConcurrency > 0 && n > cfg.MaxUpstreamConcurrency {
+				writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "n",
+					"n must be at most "+strconv.Itoa(cfg.MaxUpstreamConcurrency)+" for streamed requests (MAX_UPSTREAM_CONCURRENCY)")
+				return
 			}
+			timing := stats.begin(reqBody)
+			if b, err := json.Marshal(reqBody); err == nil {
+				recordRequestBody(ctx, requestModel(reqBody), len(b))
+			}
+			recordAuditUser(ctx, reqBody)
+			emulateMultipleN(w, r, cfg, pool, reqBody, n, copilotToken)
+			timing.done(false)
+			return
 		}
 		bodyBytes, err := json.Marshal(reqBody)
 		if err != nil {
 			http.Error(w, "Failed to marshal request: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		recordRequestBody(ctx, requestModel(reqBody), len(bodyBytes))
+		recordAuditUser(ctx, reqBody)
 
-		// Prepare request to Copilot API
-		req, err := http.NewRequestWithContext(ctx, r.Method, "https://api.githubcopilot.com/chat/completions", strings.NewReader(string(bodyBytes)))
-		if err != nil {
-			http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
-			return
+		// Serve deterministic requests from the response cache
+		var cacheKey string
+		if respCache != nil && isCacheableRequest(reqBody) {
+			key := responseCacheKey(bodyBytes)
+			if entry, ok := respCache.get(key); ok {
+				entry.writeTo(w)
+				return
+			}
+			cacheKey = key
 		}
-		// Copy headers except for hop-by-hop and auth
-		for k, v := range r.Header {
-			if strings.ToLower(k) == "authorization" || strings.ToLower(k) == "host" || strings.ToLower(k) == "connection" || strings.ToLower(k) == "content-length" {
-				continue
+
+		// Buffer the response when it is shared between identical requests or cached
+		dedupEnabled := cfg.EnableRequestDedup && reqBody["stream"] != true
+		if dedupEnabled || cacheKey != "" {
+			send := func() (*http.Response, error) {
+				return pool.send(r, cfg, r.Method, "/chat/completions", bodyBytes, copilotToken)
 			}
-			for _, vv := range v {
-				req.Header.Add(k, vv)
+			var captured *capturedResponse
+			timing := stats.begin(reqBody)
+			var err error
+			if dedupEnabled {
+				// The shared call outlives the first client if it disconnects
+				shared := r.WithContext(context.WithoutCancel(r.Context()))
+				captured, err = dedup.do(dedupKey(keyLabelFromContext(ctx), bodyBytes), func() (*http.Response, error) {
+					return pool.send(shared, cfg, r.Method, "/chat/completions", bodyBytes, copilotToken)
+				})
+			} else {
+				captured, err = captureResponse(send())
 			}
+			if err != nil {
+				timing.done(true)
+				writeUpstreamError(w, err)
+				return
+			}
+			if cacheKey != "" && captured.status == http.StatusOK {
+				respCache.add(cacheKey, captured)
+				w.Header().Set("X-Cache", "MISS")
+			}
+			captured.writeTo(w)
+			timing.done(captured.status >= http.StatusBadRequest)
+			return
 		}
-		req.Header.Set("Authorization", "Bearer "+copilotToken)
-		req.Header.Set("Copilot-Integration-Id", "vscode-chat")
-		req.Header.Set("Editor-Version", "Go/1.21+")
-		req.Header.Set("Content-Type", "application/json")
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		// Send request to the next healthy Copilot API endpoint
+		timing := stats.begin(reqBody)
+		resp, err := pool.send(r, cfg, r.Method, "/chat/completions", bodyBytes, copilotToken)
 		if err != nil {
-			http.Error(w, "Failed to contact Copilot API: "+err.Error(), http.StatusBadGateway)
+			timing.done(true)
+			writeUpstreamError(w, err)
 			return
 		}
 		defer resp.Body.Close()
+		stream := isSSEResponse(resp)
+		if stream && resp.StatusCode < http.StatusBadRequest {
+			// Copilot may reject an expiring token inside the stream instead of with a 401
+			first := resp
+			if resp, err = resendOnStreamAuthError(r, cfg, tokenManager, pool, bodyBytes, resp); err != nil {
+				timing.done(true)
+				writeUpstreamError(w, err)
+				return
+			}
+			if resp != first {
+				defer resp.Body.Close()
+				stream = isSSEResponse(resp)
+			}
+		}
 
 		// Propagate status code and headers
+		failed := resp.StatusCode >= http.StatusBadRequest
+		addUsage := stream && !failed && includeUsageRequested(reqBody)
 		for k, v := range resp.Header {
 			for _, vv := range v {
 				w.Header().Add(k, vv)
 			}
 		}
+		if stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		if addUsage {
+			// The added usage chunk makes the upstream length wrong
+			w.Header().Del("Content-Length")
+		}
 		w.WriteHeader(resp.StatusCode)
 
 		// If streaming, copy as stream
-		if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
-			// Stream response
-			buf := make([]byte, 4096)
-			for {
-				n, err := resp.Body.Read(buf)
-				if n > 0 {
-					_, _ = w.Write(buf[:n])
-					w.(http.Flusher).Flush()
-				}
-				if err != nil {
-					break
-				}
+		if stream {
+			body := timing.stream(resp.Body, failed)
+			if addUsage {
+				// Copilot may leave out the usage chunk OpenAI clients asked for
+				body = newUsageStream(body, reqBody)
 			}
+			streamer.copy(w, body)
 			return
 		}
 
 		// Otherwise, copy the full response
 		_, _ = io.Copy(w, resp.Body)
+		timing.done(failed)
 	}
 }
 
 // embeddingsHandler handles /v1/embeddings requests (proxy to Copilot).
-func embeddingsHandler(cfg *config.Config, tokenManager *copilot.TokenManager) http.HandlerFunc {
+func embeddingsHandler(cfg *config.Config, tokenManager copilot.TokenSource, modelsCache *copilot.ModelsCache, aliases *copilot.AtomicAliasMap, pool *endpointPool, stats *statsTracker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		copilotToken, err := tokenManager.GetToken(ctx)
@@ -145,43 +374,26 @@ func embeddingsHandler(cfg *config.Config, tokenManager *copilot.TokenManager) h
 			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		if reqBody["model"] == nil || reqBody["model"] == "" {
-			if cfg.DefaultModel != "" {
-				reqBody["model"] = cfg.DefaultModel
-			} else {
-				delete(reqBody, "model")
-			}
+		applyDefaultModel(reqBody, cfg.DefaultEmbeddingModel, cfg.DefaultModel)
+		applyModelAlias(reqBody, aliases)
+		if err := applyModelFallback(w, r, cfg, modelsCache, reqBody); err != nil {
+			writeValidationError(w, err)
+			return
 		}
 		bodyBytes, err := json.Marshal(reqBody)
 		if err != nil {
 			http.Error(w, "Failed to marshal request: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		recordRequestBody(ctx, requestModel(reqBody), len(bodyBytes))
+		recordAuditUser(ctx, reqBody)
 
-		// Prepare request to Copilot API
-		req, err := http.NewRequestWithContext(ctx, r.Method, "https://api.githubcopilot.com/embeddings", strings.NewReader(string(bodyBytes)))
+		// Send request to the next healthy Copilot API endpoint
+		timing := stats.begin(reqBody)
+		resp, err := pool.send(r, cfg, r.Method, "/embeddings", bodyBytes, copilotToken)
 		if err != nil {
-			http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		// Copy headers except for hop-by-hop and auth
-		for k, v := range r.Header {
-			if strings.ToLower(k) == "authorization" || strings.ToLower(k) == "host" || strings.ToLower(k) == "connection" || strings.ToLower(k) == "content-length" {
-				continue
-			}
-			for _, vv := range v {
-				req.Header.Add(k, vv)
-			}
-		}
-		req.Header.Set("Authorization", "Bearer "+copilotToken)
-		req.Header.Set("Copilot-Integration-Id", "vscode-chat")
-		req.Header.Set("Editor-Version", "Go/1.21+")
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			http.Error(w, "Failed to contact Copilot API: "+err.Error(), http.StatusBadGateway)
+			timing.done(true)
+			writeUpstreamError(w, err)
 			return
 		}
 		defer resp.Body.Close()
@@ -196,12 +408,18 @@ func embeddingsHandler(cfg *config.Config, tokenManager *copilot.TokenManager) h
 
 		// Copy the full response
 		_, _ = io.Copy(w, resp.Body)
+		timing.done(resp.StatusCode >= http.StatusBadRequest)
 	}
 }
 
 // anthropicHandler handles /v1/messages requests (Anthropic compatibility).
-func anthropicHandler(cfg *config.Config, tokenManager *copilot.TokenManager) http.HandlerFunc {
+func anthropicHandler(cfg *config.Config, tokenManager copilot.TokenSource, modelsCache *copilot.ModelsCache, aliases *copilot.AtomicAliasMap, pool *endpointPool, stats *statsTracker) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		r, err := withAnthropicHeaders(cfg, r)
+		if err != nil {
+			writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
 		ctx := r.Context()
 		copilotToken, err := tokenManager.GetToken(ctx)
 		if err != nil {
@@ -216,11 +434,21 @@ func anthropicHandler(cfg *config.Config, tokenManager *copilot.TokenManager) ht
 			return
 		}
 		// Inject default model if missing
-		if anthropicReq["model"] == nil || anthropicReq["model"] == "" {
-			if cfg.DefaultModel != "" {
-				anthropicReq["model"] = cfg.DefaultModel
-			} else {
-				delete(anthropicReq, "model")
+		applyDefaultModel(anthropicReq, cfg.DefaultAnthropicModel, cfg.DefaultModel)
+		applyModelAlias(anthropicReq, aliases)
+		if err := applyModelFallback(w, r, cfg, modelsCache, anthropicReq); err != nil {
+			writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		if err := checkLicenseTier(cfg, tokenManager, anthropicReq); err != nil {
+			writeAnthropicError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+		applyModelMaxTokens(w, cfg, modelsCache, anthropicReq)
+		applyMaxTokensLimit(w, r, cfg, anthropicReq)
+		for field := range anthropicReq {
+			if droppedFields[field] && cfg.Debug {
+				log.Printf("Warning: dropping %s from /v1/messages request; Copilot does not support it", field)
 			}
 		}
 		openaiReq := convertAnthropicToOpenAI(anthropicReq)
@@ -229,33 +457,33 @@ func anthropicHandler(cfg *config.Config, tokenManager *copilot.TokenManager) ht
 			http.Error(w, "Failed to marshal request: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		recordRequestBody(ctx, requestModel(openaiReq), len(bodyBytes))
+		recordAuditUser(ctx, openaiReq)
 
-		// Prepare request to Copilot API
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.githubcopilot.com/chat/completions", strings.NewReader(string(bodyBytes)))
+		// Send request to the next healthy Copilot API endpoint
+		timing := stats.begin(openaiReq)
+		resp, err := pool.send(r, cfg, http.MethodPost, "/chat/completions", bodyBytes, copilotToken)
 		if err != nil {
-			http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
+			timing.done(true)
+			writeUpstreamError(w, err)
 			return
 		}
-		for k, v := range r.Header {
-			if strings.ToLower(k) == "authorization" || strings.ToLower(k) == "host" || strings.ToLower(k) == "connection" || strings.ToLower(k) == "content-length" {
-				continue
-			}
-			for _, vv := range v {
-				req.Header.Add(k, vv)
+		defer resp.Body.Close()
+		stream := isSSEResponse(resp)
+
+		// Otherwise, convert full response to Anthropic format before writing any header
+		failed := resp.StatusCode >= http.StatusBadRequest
+		var anthropicResp map[string]interface{}
+		if !stream {
+			var openaiResp map[string]interface{}
+			respBytes, _ := io.ReadAll(resp.Body)
+			if err := json.Unmarshal(respBytes, &openaiResp); err != nil {
+				timing.done(true)
+				http.Error(w, "Failed to decode Copilot response: "+err.Error(), http.StatusBadGateway)
+				return
 			}
+			anthropicResp = convertOpenAIToAnthropic(openaiResp)
 		}
-		req.Header.Set("Authorization", "Bearer "+copilotToken)
-		req.Header.Set("Copilot-Integration-Id", "vscode-chat")
-		req.Header.Set("Editor-Version", "Go/1.21+")
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			http.Error(w, "Failed to contact Copilot API: "+err.Error(), http.StatusBadGateway)
-			return
-		}
-		defer resp.Body.Close()
 
 		// Propagate status code and headers
 		for k, v := range resp.Header {
@@ -263,42 +491,83 @@ func anthropicHandler(cfg *config.Config, tokenManager *copilot.TokenManager) ht
 				w.Header().Add(k, vv)
 			}
 		}
+		// The converted body has a length of its own, computed by the ResponseWriter
+		w.Header().Del("Content-Length")
+		if stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
 		w.WriteHeader(resp.StatusCode)
 
 		// If streaming, convert stream to Anthropic format
-		if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
-			convertOpenAIStreamToAnthropic(w, resp.Body)
-			return
-		}
-
-		// Otherwise, convert full response to Anthropic format
-		var openaiResp map[string]interface{}
-		respBytes, _ := io.ReadAll(resp.Body)
-		if err := json.Unmarshal(respBytes, &openaiResp); err != nil {
-			http.Error(w, "Failed to decode Copilot response: "+err.Error(), http.StatusBadGateway)
+		if stream {
+			convertOpenAIStreamToAnthropic(w, timing.stream(resp.Body, failed))
 			return
 		}
-		anthropicResp := convertOpenAIToAnthropic(openaiResp)
-		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(anthropicResp)
+		timing.done(failed)
+	}
+}
+
+// resolveDefaultModel returns the model to request: the request's own model, otherwise
+// the endpoint's default, otherwise the global default. "" lets Copilot choose.
+func resolveDefaultModel(requestModel string, endpointDefault string, globalDefault string) string {
+	switch {
+	case requestModel != "":
+		return requestModel
+	case endpointDefault != "":
+		return endpointDefault
+	default:
+		return globalDefault
 	}
 }
 
+// applyDefaultModel fills in the model of a request body that has none, removing the
+// field if no default is configured either.
+func applyDefaultModel(body map[string]interface{}, endpointDefault, globalDefault string) {
+	if model, ok := body["model"]; ok && model != nil && model != "" {
+		return
+	}
+	if model := resolveDefaultModel("", endpointDefault, globalDefault); model != "" {
+		body["model"] = model
+	} else {
+		delete(body, "model")
+	}
+}
+
+// convertedFields are the Anthropic request fields that convertAnthropicToOpenAI
+// replaces with their OpenAI equivalents.
+var convertedFields = map[string]bool{
+	"stop_sequences": true, // becomes stop
+	"metadata":       true, // metadata.user_id becomes user
+}
+
+// droppedFields are the Anthropic request fields with no OpenAI equivalent, which are
+// not sent to Copilot.
+var droppedFields = map[string]bool{
+	"top_k":           true,
+	"response_format": true, // not an Anthropic field; JSON mode is only for /v1/chat/completions
+}
+
 // convertAnthropicToOpenAI converts Anthropic-style request to OpenAI/Copilot format.
+// Fields in neither convertedFields nor droppedFields, including ones added to the
+// Anthropic API later, are forwarded as they are.
 func convertAnthropicToOpenAI(body map[string]interface{}) map[string]interface{} {
-	// Minimal conversion: map "messages", "model", "max_tokens", "temperature", "stream"
-	out := map[string]interface{}{
-		"messages":    body["messages"],
-		"model":       body["model"],
-		"max_tokens":  body["max_tokens"],
-		"temperature": body["temperature"],
-		"stream":      body["stream"],
+	out := make(map[string]interface{}, len(body))
+	for k, v := range body {
+		if !convertedFields[k] && !droppedFields[k] {
+			out[k] = v
+		}
 	}
-	if tools, ok := body["tools"]; ok {
-		out["tools"] = tools
+	if stop, ok := body["stop_sequences"]; ok {
+		out["stop"] = stop
 	}
-	if toolChoice, ok := body["tool_choice"]; ok {
-		out["tool_choice"] = toolChoice
+	// Anthropic identifies the end user in metadata.user_id, OpenAI in user
+	if metadata, ok := body["metadata"].(map[string]interface{}); ok {
+		if userID, ok := metadata["user_id"].(string); ok && userID != "" {
+			out["user"] = userID
+		}
 	}
 	return out
 }
@@ -306,12 +575,12 @@ func convertAnthropicToOpenAI(body map[string]interface{}) map[string]interface{
 // convertOpenAIToAnthropic converts OpenAI/Copilot response to Anthropic-style response.
 func convertOpenAIToAnthropic(body map[string]interface{}) map[string]interface{} {
 	// Minimal conversion: wrap OpenAI response in Anthropic-like structure
-	return map[string]interface{}{
+	out := map[string]interface{}{
 		"id":      body["id"],
 		"type":    "message",
 		"role":    "assistant",
 		"model":   body["model"],
-		"content": body["choices"],
+		"content": anthropicContentBlocks(body),
 		"usage":   body["usage"],
 		"stop_reason": func() interface{} {
 			if choices, ok := body["choices"].([]interface{}); ok && len(choices) > 0 {
@@ -322,22 +591,60 @@ func convertOpenAIToAnthropic(body map[string]interface{}) map[string]interface{
 			return nil
 		}(),
 	}
+	if topP, ok := body["top_p"]; ok {
+		out["top_p"] = topP
+	}
+	if seed, ok := body["seed"]; ok {
+		out["seed"] = seed
+	}
+	return out
 }
 
-// convertOpenAIStreamToAnthropic converts OpenAI/Copilot streaming response to Anthropic-style SSE.
-func convertOpenAIStreamToAnthropic(w http.ResponseWriter, body io.Reader) {
-	// This is a minimal passthrough for now; real implementation would parse and reformat SSE events.
-	buf := make([]byte, 4096)
-	for {
-		n, err := body.Read(buf)
-		if n > 0 {
-			_, _ = w.Write(buf[:n])
-			if f, ok := w.(http.Flusher); ok {
-				f.Flush()
+// anthropicContentBlocks converts the message of the first OpenAI choice into Anthropic
+// content blocks: a text block for its content followed by one tool_use block per tool call.
+func anthropicContentBlocks(body map[string]interface{}) []interface{} {
+	blocks := []interface{}{}
+	choices, _ := body["choices"].([]interface{})
+	if len(choices) == 0 {
+		return blocks
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	message, _ := choice["message"].(map[string]interface{})
+	if text, ok := message["content"].(string); ok && text != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+	}
+	toolCalls, _ := message["tool_calls"].([]interface{})
+	for _, tc := range toolCalls {
+		call, ok := tc.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fn, _ := call["function"].(map[string]interface{})
+		input := map[string]interface{}{}
+		if args, ok := fn["arguments"].(string); ok && args != "" {
+			if err := json.Unmarshal([]byte(args), &input); err != nil {
+				log.Printf("Ignoring invalid arguments of tool call %v: %v", call["id"], err)
+				input = map[string]interface{}{}
 			}
 		}
-		if err != nil {
-			break
+		blocks = append(blocks, map[string]interface{}{
+			"type":  "tool_use",
+			"id":    call["id"],
+			"name":  fn["name"],
+			"input": input,
+		})
+	}
+	return blocks
+}
+
+// convertOpenAIStreamToAnthropic converts OpenAI/Copilot streaming response to Anthropic-style SSE.
+func convertOpenAIStreamToAnthropic(w http.ResponseWriter, body io.Reader) {
+	// This is a minimal passthrough for now; a real implementation would reformat each event.
+	events := sse.NewWriter(w)
+	scanner := sse.NewScanner(body)
+	for scanner.Scan() {
+		if err := events.WriteEvent(scanner.Event()); err != nil {
+			return
 		}
 	}
 }
@@ -345,23 +652,94 @@ func convertOpenAIStreamToAnthropic(w http.ResponseWriter, body io.Reader) {
 // modelsHandler serves the cached models JSON at /v1/models.
 func modelsHandler(modelsCache *copilot.ModelsCache) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsJSON(r.Header.Get("Accept")) {
+			http.Error(w, "Not Acceptable: /v1/models only serves application/json", http.StatusNotAcceptable)
+			return
+		}
+		if modelsCache == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "models unavailable at startup"})
+			return
+		}
 		ctx := r.Context()
-		models, err := modelsCache.GetModels(ctx)
+		models, err := modelsCache.ListModels(ctx)
 		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				// The catalog is slow rather than down; a later request may find it cached
+				w.Header().Set("Retry-After", "10")
+			}
 			http.Error(w, "Failed to fetch models: "+err.Error(), http.StatusServiceUnavailable)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
+		var body interface{}
+		if acceptsMediaType(r.Header.Get("Accept"), anthropicMediaType) {
+			body = anthropicModelList(models, modelsCache.LastFetch())
+		} else {
+			body = openAIModelList(models, modelsCache.LastFetch())
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(models)
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+// acceptsJSON reports whether an Accept header value allows a JSON response.
+// A missing header accepts anything; application/vnd.api+json and the Anthropic media
+// type are treated as aliases.
+func acceptsJSON(accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
 	}
+	return acceptsMediaType(accept, "application/json", "application/vnd.api+json", anthropicMediaType, "application/*", "*/*")
+}
+
+// acceptsMediaType reports whether an Accept header value lists one of mediaTypes
+// with a non-zero quality.
+func acceptsMediaType(accept string, mediaTypes ...string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		if slices.Contains(mediaTypes, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultKeyLabel is the label assigned to requests authenticated with COPILOT_TOKEN.
+const defaultKeyLabel = "default"
+
+// adminKeyLabel is the label assigned to requests authenticated with ADMIN_TOKEN.
+const adminKeyLabel = "admin"
+
+// keyLabelFromContext returns the authenticated API key label, or "" if unauthenticated.
+func keyLabelFromContext(ctx context.Context) string {
+	label, _ := ctxkey.AuthLabelFrom(ctx)
+	return label
 }
 
 // AuthMiddleware checks for Bearer token in Authorization header.
+// The label of the matching key is stored in the request context.
+// Paths in cfg.AuthExemptPaths (config.DefaultAuthExemptPaths if nil) skip authentication.
 func AuthMiddleware(cfg *config.Config, next http.Handler) http.Handler {
+	keys, _ := NewKeyStore(cfg.APIKeys, "")
+	return authMiddleware(cfg, keys, next)
+}
+
+// authMiddleware is AuthMiddleware taking the API keys from keys.
+func authMiddleware(cfg *config.Config, keys *KeyStore, next http.Handler) http.Handler {
+	exempt := newPathMatcher(cfg.AuthExemptPaths)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Allow unauthenticated access to healthz and /v1/models
-		if r.URL.Path == "/healthz" || r.URL.Path == "/v1/models" {
+		// Allow unauthenticated access to the configured public paths
+		if exempt.match(r.URL.Path) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -371,17 +749,100 @@ func AuthMiddleware(cfg *config.Config, next http.Handler) http.Handler {
 			return
 		}
 		token := strings.TrimPrefix(auth, "Bearer ")
-		if token == "" || token != cfg.CopilotToken {
+		label, err := lookupKeyLabel(cfg, keys, token)
+		if err != nil {
+			http.Error(w, "Too Many Requests: "+err.Error()+" for key "+label, http.StatusTooManyRequests)
+			return
+		}
+		if label == "" {
 			http.Error(w, "Forbidden: invalid access token", http.StatusForbidden)
 			return
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(ctxkey.WithAuthLabel(r.Context(), label)))
 	})
 }
 
+// pathMatcher matches request paths against exact paths, "/prefix/*" patterns and
+// "/prefix/*/suffix" patterns, where * stands for one or more characters.
+type pathMatcher struct {
+	exact     map[string]bool
+	wildcards []pathWildcard
+}
+
+// pathWildcard is a pattern of a pathMatcher holding a *.
+type pathWildcard struct {
+	prefix, suffix string
+}
+
+// newPathMatcher compiles patterns, falling back to config.DefaultAuthExemptPaths if nil.
+func newPathMatcher(patterns []string) pathMatcher {
+	if patterns == nil {
+		patterns = config.DefaultAuthExemptPaths
+	}
+	m := pathMatcher{exact: make(map[string]bool)}
+	for _, p := range patterns {
+		if prefix, suffix, ok := strings.Cut(p, "*"); ok {
+			m.wildcards = append(m.wildcards, pathWildcard{prefix, suffix})
+		} else {
+			m.exact[p] = true
+		}
+	}
+	return m
+}
+
+// match reports whether path matches any pattern.
+func (m pathMatcher) match(path string) bool {
+	if m.exact[path] {
+		return true
+	}
+	for _, w := range m.wildcards {
+		if w.suffix == "" && strings.HasPrefix(path, w.prefix) {
+			return true
+		}
+		if w.suffix != "" && len(path) > len(w.prefix)+len(w.suffix) &&
+			strings.HasPrefix(path, w.prefix) && strings.HasSuffix(path, w.suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupKeyLabel returns the label of the API key matching token, or "" if none match.
+// A key of keys over its daily limit returns errDailyLimit.
+func lookupKeyLabel(cfg *config.Config, keys *KeyStore, token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	if token == cfg.CopilotToken {
+		return defaultKeyLabel, nil
+	}
+	if cfg.AdminToken != "" && token == cfg.AdminToken {
+		return adminKeyLabel, nil
+	}
+	return keys.authenticate(token)
+}
+
+// appendVary adds field to the Vary header of w unless it is already listed, keeping
+// the fields added by other middleware and upstream responses.
+func appendVary(w http.ResponseWriter, field string) {
+	if field == "" {
+		return
+	}
+	for _, v := range w.Header().Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	w.Header().Add("Vary", field)
+}
+
 // CORS middleware adds CORS headers based on config.
 func CORS(cfg *config.Config, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The allowed origin echoed back depends on Origin, so caches must key on it
+		appendVary(w, "Origin")
 		origins := strings.Split(cfg.CORSAllowedOrigins, ",")
 		origin := r.Header.Get("Origin")
 		allowed := false
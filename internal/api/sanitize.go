@@ -0,0 +1,38 @@
+package api
+
+import (
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// sanitizeRequestBody prepares a chat completions body for Copilot once its model is
+// resolved. Top-level fields set to null are removed, temperature is clamped to [0, 2]
+// and a missing max_tokens is set to cfg.DefaultMaxTokens, lowered to the output limit
+// of model if it is known. model is nil for models missing from the models cache. An
+// error is returned if messages is missing or empty.
+func sanitizeRequestBody(body map[string]interface{}, cfg *config.Config, model *copilot.Model) error {
+	for field, v := range body {
+		if v == nil {
+			delete(body, field)
+		}
+	}
+
+	if messages, _ := body["messages"].([]interface{}); len(messages) == 0 {
+		return invalidParam("messages", "messages must contain at least one message")
+	}
+
+	if t, ok := body["temperature"].(float64); ok {
+		body["temperature"] = min(max(t, 0), 2)
+	}
+
+	_, hasMax := body["max_tokens"]
+	_, hasMaxCompletion := body["max_completion_tokens"]
+	if !hasMax && !hasMaxCompletion && cfg.DefaultMaxTokens > 0 {
+		n := cfg.DefaultMaxTokens
+		if model != nil && model.Limits.MaxOutputTokens > 0 {
+			n = min(n, model.Limits.MaxOutputTokens)
+		}
+		body["max_tokens"] = n
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// applySmartRouting replaces the requested model by the model of the same family with
+// the smallest context window that fits the conversation, counted with the tokenizer of
+// the requested model. The chosen model is reported in the X-Routed-Model response
+// header. If no model of the family fits, an error is returned. Models without a known
+// input limit are not considered, and requests for a model missing from the models list
+// are left alone.
+func applySmartRouting(w http.ResponseWriter, r *http.Request, cfg *config.Config, modelsCache *copilot.ModelsCache, body map[string]interface{}) error {
+	model, _ := body["model"].(string)
+	if !cfg.EnableSmartRouting || model == "" || modelsCache == nil {
+		return nil
+	}
+	if _, ok := modelsCache.Lookup(model); !ok {
+		return nil
+	}
+	models, err := modelsCache.ListModels(r.Context())
+	if err != nil {
+		return nil
+	}
+
+	messages, _ := body["messages"].([]interface{})
+	tokens := newTokenCounter(model).messages(messages)
+	family := modelFamily(model)
+	var best *copilot.Model
+	largest := 0
+	for i, m := range models {
+		limit := m.Limits.MaxInputTokens
+		if limit <= 0 || !strings.HasPrefix(modelName(m.ID), family) {
+			continue
+		}
+		largest = max(largest, limit)
+		if limit >= tokens && (best == nil || limit < best.Limits.MaxInputTokens) {
+			best = &models[i]
+		}
+	}
+	if best == nil {
+		if largest == 0 {
+			return nil
+		}
+		return invalidParam("messages", "the conversation has about %d tokens, more than the largest %s context window of %d tokens", tokens, family, largest)
+	}
+
+	if best.ID != model {
+		log.Printf("Routed %s request of about %d tokens to %s", model, tokens, best.ID)
+	}
+	body["model"] = best.ID
+	w.Header().Set("X-Routed-Model", best.ID)
+	return nil
+}
+
+// modelName returns a model ID without its publisher prefix, lowercased.
+func modelName(id string) string {
+	id = strings.ToLower(id)
+	if _, name, ok := strings.Cut(id, "/"); ok {
+		return name
+	}
+	return id
+}
+
+// modelFamily returns the family of a model: its name up to the first dash-separated
+// part holding a version digit, so "gpt-4o-mini" and "gpt-4o" are both of family
+// "gpt-4o" and "claude-3.5-sonnet" is of family "claude-3.5".
+func modelFamily(id string) string {
+	parts := strings.Split(modelName(id), "-")
+	for i, part := range parts {
+		if strings.ContainsAny(part, "0123456789") {
+			return strings.Join(parts[:i+1], "-")
+		}
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// statsWindow is how many recent latencies per model the percentiles are computed from.
+const statsWindow = 1000
+
+// autoModel is the stats key of requests that leave the model choice to Copilot.
+const autoModel = "auto"
+
+// sseDataPrefix starts every data line of a server-sent events stream.
+var sseDataPrefix = []byte("data:")
+
+// statsTracker records upstream latency, errors and streaming throughput per model.
+type statsTracker struct {
+	mu     sync.Mutex
+	models map[string]*modelStats
+}
+
+// modelStats are the recorded stats of one model.
+type modelStats struct {
+	latencies     []time.Duration // ring buffer of the last statsWindow latencies
+	next          int
+	requests      int64
+	errors        int64
+	streamEvents  int64
+	streamSeconds float64
+}
+
+// modelStatsSnapshot is the JSON form of modelStats returned by /admin/stats.
+type modelStatsSnapshot struct {
+	Requests        int64              `json:"requests"`
+	Errors          int64              `json:"errors"`
+	LatencyMs       map[string]float64 `json:"latency_ms"`
+	TokensPerSecond float64            `json:"tokens_per_second"`
+}
+
+// newStatsTracker returns an empty statsTracker.
+func newStatsTracker() *statsTracker {
+	return &statsTracker{models: make(map[string]*modelStats)}
+}
+
+// get returns the stats of model, creating them if needed. s.mu must be held.
+func (s *statsTracker) get(model string) *modelStats {
+	m, ok := s.models[model]
+	if !ok {
+		m = &modelStats{latencies: make([]time.Duration, 0, statsWindow)}
+		s.models[model] = m
+	}
+	return m
+}
+
+// record adds a completed upstream request for model.
+func (s *statsTracker) record(model string, latency time.Duration, failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.get(model)
+	m.requests++
+	if failed {
+		m.errors++
+	}
+	if len(m.latencies) < statsWindow {
+		m.latencies = append(m.latencies, latency)
+	} else {
+		m.latencies[m.next] = latency
+		m.next = (m.next + 1) % statsWindow
+	}
+}
+
+// recordStream adds a finished stream of events that took d after its first byte.
+func (s *statsTracker) recordStream(model string, events int64, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.get(model)
+	m.streamEvents += events
+	m.streamSeconds += d.Seconds()
+}
+
+// snapshot returns the current stats of every model.
+func (s *statsTracker) snapshot() map[string]modelStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]modelStatsSnapshot, len(s.models))
+	for model, m := range s.models {
+		sorted := slices.Clone(m.latencies)
+		slices.Sort(sorted)
+		snap := modelStatsSnapshot{
+			Requests: m.requests,
+			Errors:   m.errors,
+			LatencyMs: map[string]float64{
+				"p50": percentileMs(sorted, 0.50),
+				"p95": percentileMs(sorted, 0.95),
+				"p99": percentileMs(sorted, 0.99),
+			},
+		}
+		if m.streamSeconds > 0 {
+			snap.TokensPerSecond = float64(m.streamEvents) / m.streamSeconds
+		}
+		out[model] = snap
+	}
+	return out
+}
+
+// percentileMs returns the nearest-rank percentile p of sorted in milliseconds.
+func percentileMs(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return float64(sorted[idx]) / float64(time.Millisecond)
+}
+
+// begin starts timing an upstream request for the model of body.
+func (s *statsTracker) begin(body map[string]interface{}) *upstreamTiming {
+	model, _ := body["model"].(string)
+	if model == "" {
+		model = autoModel
+	}
+	return &upstreamTiming{stats: s, model: model, start: time.Now()}
+}
+
+// upstreamTiming measures a single upstream request.
+type upstreamTiming struct {
+	stats *statsTracker
+	model string
+	start time.Time
+}
+
+// done records the request as complete now.
+func (t *upstreamTiming) done(failed bool) {
+	t.stats.record(t.model, time.Since(t.start), failed)
+}
+
+// stream wraps a streamed response body. The request is recorded as complete when the
+// first byte arrives (time to first token), and the stream throughput once it ends.
+func (t *upstreamTiming) stream(body io.Reader, failed bool) io.Reader {
+	return &timedStream{timing: t, body: body, failed: failed}
+}
+
+// timedStream counts the data events of a streamed response for an upstreamTiming.
+// Each event usually carries one token.
+type timedStream struct {
+	timing    *upstreamTiming
+	body      io.Reader
+	failed    bool
+	firstByte time.Time
+	events    int64
+	tail      []byte // end of the previous read, for prefixes split across reads
+	finished  bool
+}
+
+func (s *timedStream) Read(p []byte) (int, error) {
+	n, err := s.body.Read(p)
+	if n > 0 {
+		if s.firstByte.IsZero() {
+			s.firstByte = time.Now()
+			s.timing.done(s.failed)
+		}
+		buf := append(s.tail, p[:n]...)
+		s.events += int64(bytes.Count(buf, sseDataPrefix))
+		keep := min(len(buf), len(sseDataPrefix)-1)
+		s.tail = append(s.tail[:0], buf[len(buf)-keep:]...)
+	}
+	if err != nil && !s.finished {
+		s.finished = true
+		if s.firstByte.IsZero() {
+			s.timing.done(true)
+		} else {
+			s.timing.stats.recordStream(s.timing.model, s.events, time.Since(s.firstByte))
+		}
+	}
+	return n, err
+}
+
+// statsHandler handles GET /admin/stats.
+func statsHandler(stats *statsTracker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"models": stats.snapshot()})
+	}
+}
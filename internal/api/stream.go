@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultStreamBufferSize is the read buffer size used when none is configured.
+const defaultStreamBufferSize = 4096
+
+// ssePeekSize is how many body bytes isSSEResponse inspects when the content type is
+// not conclusive.
+const ssePeekSize = 16
+
+// sseFieldPrefixes are the line starts that identify a server-sent events body.
+var sseFieldPrefixes = [][]byte{[]byte("data:"), []byte("event:"), []byte("id:"), []byte("retry:"), []byte(":")}
+
+// isSSEResponse reports whether resp is a server-sent events stream. The Content-Type
+// decides when it is text/event-stream or application/json; otherwise the start of the
+// body is inspected. resp.Body is replaced so peeked bytes are still read by the caller.
+func isSSEResponse(resp *http.Response) bool {
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		switch mediaType {
+		case "text/event-stream":
+			return true
+		case "application/json":
+			return false
+		}
+	}
+
+	br := bufio.NewReader(resp.Body)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{br, resp.Body}
+	peek, _ := br.Peek(ssePeekSize)
+	peek = bytes.TrimLeft(peek, " \t\r\n")
+	for _, prefix := range sseFieldPrefixes {
+		if bytes.HasPrefix(peek, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// streamCopier copies streamed upstream responses to clients, flushing either after
+// every write or at a fixed interval.
+type streamCopier struct {
+	flushInterval time.Duration
+	buffers       sync.Pool
+}
+
+// newStreamCopier creates a streamCopier reading bufferSize bytes at a time.
+// A flushInterval of zero flushes after every write.
+func newStreamCopier(bufferSize int, flushInterval time.Duration) *streamCopier {
+	if bufferSize <= 0 {
+		bufferSize = defaultStreamBufferSize
+	}
+	return &streamCopier{
+		flushInterval: flushInterval,
+		buffers: sync.Pool{New: func() interface{} {
+			buf := make([]byte, bufferSize)
+			return &buf
+		}},
+	}
+}
+
+// copy streams body to w until body is exhausted.
+func (c *streamCopier) copy(w http.ResponseWriter, body io.Reader) {
+	bufp := c.buffers.Get().(*[]byte)
+	defer c.buffers.Put(bufp)
+	buf := *bufp
+
+	flusher, _ := w.(http.Flusher)
+	if flusher == nil || c.flushInterval <= 0 {
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				_, _ = w.Write(buf[:n])
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	// Writes and flushes share a lock because ResponseWriter is not safe for concurrent use
+	var mu sync.Mutex
+	pending := false
+	done := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		ticker := time.NewTicker(c.flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				mu.Lock()
+				if pending {
+					flusher.Flush()
+					pending = false
+				}
+				mu.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			mu.Lock()
+			_, _ = w.Write(buf[:n])
+			pending = true
+			mu.Unlock()
+		}
+		if err != nil {
+			break
+		}
+	}
+	close(done)
+	<-flushed
+	if pending {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// errStreamUnauthorized reports an event stream whose first event rejects the token.
+var errStreamUnauthorized = errors.New("copilot rejected the token in the event stream")
+
+// streamErrorDetector passes an event stream through and watches for error events,
+// either data objects holding an "error" field or events named error. An authentication
+// error before any content is reported by awaitFirstEvent so the request can be retried.
+// Errors after content has been forwarded cannot be taken back, so they are also sent as
+// an out-of-band event named error right after the data line carrying them.
+type streamErrorDetector struct {
+	src       io.Reader
+	line      []byte // the line being read, for inspection once complete
+	event     string // name of the event being read, from its event: line
+	sentData  bool   // whether a data line without an error has been seen
+	authError bool   // whether an authentication error was seen before any content
+	pending   []byte
+	err       error
+}
+
+// newStreamErrorDetector wraps the event stream body.
+func newStreamErrorDetector(body io.Reader) *streamErrorDetector {
+	return &streamErrorDetector{src: body}
+}
+
+// awaitFirstEvent reads up to the first data line of the stream and returns
+// errStreamUnauthorized if it is an authentication error. The bytes read are kept and
+// returned by Read.
+func (d *streamErrorDetector) awaitFirstEvent() error {
+	buf := make([]byte, defaultStreamBufferSize)
+	for !d.sentData && !d.authError && d.err == nil {
+		var n int
+		n, d.err = d.src.Read(buf)
+		d.pending = append(d.pending, d.scan(buf[:n])...)
+	}
+	if d.authError {
+		return errStreamUnauthorized
+	}
+	return nil
+}
+
+func (d *streamErrorDetector) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		var n int
+		n, d.err = d.src.Read(p)
+		d.pending = d.scan(p[:n])
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// scan inspects the lines completed by chunk and returns the bytes to forward for it:
+// chunk itself, with an error event inserted after any error data line following content.
+func (d *streamErrorDetector) scan(chunk []byte) []byte {
+	out := make([]byte, 0, len(chunk))
+	for len(chunk) > 0 {
+		i := bytes.IndexByte(chunk, '\n')
+		if i < 0 {
+			d.line = append(d.line, chunk...)
+			out = append(out, chunk...)
+			d.inspectPartial()
+			break
+		}
+		d.line = append(d.line, chunk[:i+1]...)
+		out = append(out, chunk[:i+1]...)
+		out = append(out, d.inspect(d.line)...)
+		d.line = d.line[:0]
+		chunk = chunk[i+1:]
+	}
+	return out
+}
+
+// inspectPartial decides the first data line before it is complete if its payload is
+// not a JSON object, which is all an error can be, so content is not held back.
+func (d *streamErrorDetector) inspectPartial() {
+	if d.sentData || d.event == "error" {
+		return
+	}
+	data, ok := bytes.CutPrefix(bytes.TrimLeft(d.line, " \t"), sseDataPrefix)
+	if data = bytes.TrimLeft(data, " \t"); ok && len(data) > 0 && data[0] != '{' {
+		d.sentData = true
+	}
+}
+
+// inspect records the events of a complete event stream line and returns the bytes to
+// insert after it.
+func (d *streamErrorDetector) inspect(line []byte) []byte {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		d.event = ""
+		return nil
+	}
+	if name, ok := bytes.CutPrefix(trimmed, []byte("event:")); ok {
+		d.event = string(bytes.TrimSpace(name))
+		return nil
+	}
+	data, ok := bytes.CutPrefix(trimmed, sseDataPrefix)
+	if !ok {
+		return nil
+	}
+	data = bytes.TrimSpace(data)
+
+	apiErr, isErr := streamError(data)
+	if !isErr && d.event != "error" {
+		d.sentData = true
+		return nil
+	}
+	if !d.sentData {
+		if isAuthError(apiErr) {
+			d.authError = true
+		}
+		return nil
+	}
+	if d.event == "error" {
+		return nil
+	}
+	// Content was forwarded already; end the event and repeat the error as its own event
+	return append(append([]byte("\nevent: error\ndata: "), data...), '\n')
+}
+
+// streamError returns the error object of an event stream data payload and whether the
+// payload is an error.
+func streamError(data []byte) (map[string]interface{}, bool) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil || payload["error"] == nil {
+		return nil, false
+	}
+	apiErr, _ := payload["error"].(map[string]interface{})
+	return apiErr, true
+}
+
+// isAuthError reports whether an error object reports a rejected token: a code or status
+// of 401, or a code or type of unauthorized.
+func isAuthError(apiErr map[string]interface{}) bool {
+	for _, field := range []string{"code", "status", "type"} {
+		switch v := apiErr[field].(type) {
+		case float64:
+			if v == http.StatusUnauthorized {
+				return true
+			}
+		case string:
+			if v == "401" || strings.EqualFold(v, "unauthorized") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resendOnStreamAuthError watches the chat completion event stream of resp for errors.
+// If its first event rejects the token, the token is refreshed and the request sent once
+// more, since nothing has reached the client yet. It returns the response to forward.
+func resendOnStreamAuthError(r *http.Request, cfg *config.Config, tokenManager copilot.TokenSource, pool *endpointPool, bodyBytes []byte, resp *http.Response) (*http.Response, error) {
+	detector := newStreamErrorDetector(resp.Body)
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{detector, resp.Body}
+	if detector.awaitFirstEvent() == nil || tokenManager == nil {
+		return resp, nil
+	}
+
+	if err := tokenManager.ForceRefresh(r.Context()); err != nil {
+		log.Printf("Warning: Copilot rejected the token in the event stream and refreshing it failed: %v", err)
+		return resp, nil
+	}
+	copilotToken, err := tokenManager.GetToken(r.Context())
+	if err != nil {
+		log.Printf("Warning: Copilot rejected the token in the event stream and no new token is available: %v", err)
+		return resp, nil
+	}
+	resp.Body.Close()
+	retried, err := pool.send(r, cfg, r.Method, "/chat/completions", bodyBytes, copilotToken)
+	if err != nil {
+		return nil, fmt.Errorf("retrying with a refreshed token: %w", err)
+	}
+	if retried.StatusCode < http.StatusBadRequest && isSSEResponse(retried) {
+		retried.Body = struct {
+			io.Reader
+			io.Closer
+		}{newStreamErrorDetector(retried.Body), retried.Body}
+	}
+	return retried, nil
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"copilot-api/internal/sse"
+)
+
+// includeUsageRequested reports whether a chat completion request asks for a final
+// usage chunk with stream_options.include_usage.
+func includeUsageRequested(body map[string]interface{}) bool {
+	if body["stream"] != true {
+		return false
+	}
+	opts, _ := body["stream_options"].(map[string]interface{})
+	return opts["include_usage"] == true
+}
+
+// usageStream passes a chat completion event stream through event by event and, if the
+// upstream sends no usage chunk, inserts one before [DONE] as OpenAI does for
+// stream_options.include_usage. Completion tokens are counted from the delta content of
+// the streamed chunks, prompt tokens from the request messages.
+type usageStream struct {
+	events       *sse.Scanner
+	out          bytes.Buffer
+	writer       *sse.Writer
+	counter      tokenCounter
+	promptTokens int
+	content      strings.Builder
+	lastChunk    map[string]interface{}
+	sawUsage     bool
+	err          error
+}
+
+// newUsageStream wraps the event stream body answering the chat completion request reqBody.
+func newUsageStream(body io.Reader, reqBody map[string]interface{}) *usageStream {
+	model, _ := reqBody["model"].(string)
+	counter := newTokenCounter(model)
+	messages, _ := reqBody["messages"].([]interface{})
+	s := &usageStream{
+		events:       sse.NewScanner(body),
+		counter:      counter,
+		promptTokens: counter.messages(messages),
+	}
+	s.writer = sse.NewWriter(&s.out)
+	return s
+}
+
+func (s *usageStream) Read(p []byte) (int, error) {
+	for s.out.Len() == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if !s.events.Scan() {
+			s.err = io.EOF
+			if err := s.events.Err(); err != nil {
+				s.err = err
+			}
+			continue
+		}
+		s.inspect(s.events.Event())
+	}
+	return s.out.Read(p)
+}
+
+// inspect records the content and usage of an event and writes the events to forward
+// for it.
+func (s *usageStream) inspect(event sse.Event) {
+	if event.Data == "[DONE]" {
+		if !s.sawUsage {
+			_ = s.writer.WriteEvent(s.usageEvent())
+		}
+		_ = s.writer.WriteEvent(event)
+		return
+	}
+	_ = s.writer.WriteEvent(event)
+
+	var chunk map[string]interface{}
+	if err := json.Unmarshal([]byte(event.Data), &chunk); err != nil {
+		return
+	}
+	s.lastChunk = chunk
+	if chunk["usage"] != nil {
+		s.sawUsage = true
+	}
+	choices, _ := chunk["choices"].([]interface{})
+	for _, c := range choices {
+		choice, _ := c.(map[string]interface{})
+		delta, _ := choice["delta"].(map[string]interface{})
+		if content, ok := delta["content"].(string); ok {
+			s.content.WriteString(content)
+		}
+	}
+}
+
+// usageEvent returns the usage chunk for the content streamed so far, carrying the id,
+// created time and model of the last chunk.
+func (s *usageStream) usageEvent() sse.Event {
+	completionTokens := s.counter.text(s.content.String())
+	chunk := map[string]interface{}{
+		"object":  "chat.completion.chunk",
+		"choices": []interface{}{},
+		"usage": map[string]int{
+			"prompt_tokens":     s.promptTokens,
+			"completion_tokens": completionTokens,
+			"total_tokens":      s.promptTokens + completionTokens,
+		},
+	}
+	for _, field := range []string{"id", "created", "model", "system_fingerprint"} {
+		if v, ok := s.lastChunk[field]; ok {
+			chunk[field] = v
+		}
+	}
+	data, _ := json.Marshal(chunk)
+	return sse.Event{Data: string(data)}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// Tokens added per message and per reply by the OpenAI chat format.
+const (
+	tokensPerMessage = 3
+	tokensPerName    = 1
+	tokensPerReply   = 3
+)
+
+// Tokenizer prefixes, checked in order. Models not listed here are estimated.
+var tokenizerPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", "o200k_base"},
+	{"gpt-4.1", "o200k_base"},
+	{"gpt-4.5", "o200k_base"},
+	{"gpt-5", "o200k_base"},
+	{"o1", "o200k_base"},
+	{"o3", "o200k_base"},
+	{"o4", "o200k_base"},
+	{"gpt-4", "cl100k_base"},
+	{"gpt-3.5-turbo", "cl100k_base"},
+	{"text-embedding-", "cl100k_base"},
+}
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = map[string]*tiktoken.Tiktoken{}
+)
+
+func init() {
+	// Use the embedded BPE files so token counting never touches the network
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+}
+
+// tokenizerForModel returns the tiktoken encoding name for model, or "" if the
+// model's tokenizer is unknown. Vendor prefixes such as "openai/" are ignored.
+func tokenizerForModel(model string) string {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, p := range tokenizerPrefixes {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.encoding
+		}
+	}
+	return ""
+}
+
+// encodingForModel returns the tokenizer for model, or nil if it is unknown.
+func encodingForModel(model string) *tiktoken.Tiktoken {
+	name := tokenizerForModel(model)
+	if name == "" {
+		return nil
+	}
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	if enc, ok := encodings[name]; ok {
+		return enc
+	}
+	enc, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		return nil
+	}
+	encodings[name] = enc
+	return enc
+}
+
+// tokenCounter counts tokens with a tiktoken encoding, or estimates them from the
+// text length when the model's tokenizer is unknown.
+type tokenCounter struct {
+	enc *tiktoken.Tiktoken
+}
+
+func newTokenCounter(model string) tokenCounter {
+	return tokenCounter{enc: encodingForModel(model)}
+}
+
+// estimated reports whether counts are a character-based estimate.
+func (c tokenCounter) estimated() bool {
+	return c.enc == nil
+}
+
+// text returns the number of tokens in s.
+func (c tokenCounter) text(s string) int {
+	if s == "" {
+		return 0
+	}
+	if c.enc == nil {
+		// Roughly four characters per token for English text
+		return (len([]rune(s)) + 3) / 4
+	}
+	return len(c.enc.EncodeOrdinary(s))
+}
+
+// content returns the number of tokens in a message content value, which is either a
+// string or an array of content parts. Only text parts are counted.
+func (c tokenCounter) content(v interface{}) int {
+	switch v := v.(type) {
+	case string:
+		return c.text(v)
+	case []interface{}:
+		n := 0
+		for _, part := range v {
+			if p, ok := part.(map[string]interface{}); ok {
+				if s, ok := p["text"].(string); ok {
+					n += c.text(s)
+				}
+			}
+		}
+		return n
+	}
+	return 0
+}
+
+// messages returns the number of prompt tokens for a list of chat messages.
+func (c tokenCounter) messages(messages []interface{}) int {
+	n := 0
+	for _, raw := range messages {
+		msg, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		n += tokensPerMessage
+		if role, ok := msg["role"].(string); ok {
+			n += c.text(role)
+		}
+		if name, ok := msg["name"].(string); ok {
+			n += c.text(name) + tokensPerName
+		}
+		n += c.content(msg["content"])
+	}
+	return n + tokensPerReply
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"copilot-api/pkg/config"
+)
+
+// tunnelDialTimeout limits connecting to the Copilot API for a tunnel.
+const tunnelDialTimeout = 10 * time.Second
+
+// tunnelHandler serves CONNECT /tunnel/{host:port}, an authenticated TCP tunnel to the
+// Copilot API for clients that talk to it directly, TLS included. Only the host of
+// CopilotBaseURL or COPILOT_ENDPOINTS can be reached, so the proxy is not an open relay.
+// Once the tunnel is established bytes are copied both ways unchanged until either side
+// closes its connection.
+func tunnelHandler(cfg *config.Config) http.HandlerFunc {
+	allowed := tunnelTargets(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path+"; use CONNECT")
+			return
+		}
+		target := strings.TrimPrefix(r.URL.Path, "/tunnel/")
+		if !allowed[target] {
+			writeOpenAIError(w, http.StatusForbidden, "invalid_request_error", "",
+				"Tunnels can only be opened to the Copilot API, not "+target)
+			return
+		}
+
+		dialer := net.Dialer{Timeout: tunnelDialTimeout}
+		upstream, err := dialer.DialContext(r.Context(), "tcp", target)
+		if err != nil {
+			http.Error(w, "Failed to contact Copilot API: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		client, buffered, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			upstream.Close()
+			http.Error(w, "Tunnels are not supported on this connection", http.StatusInternalServerError)
+			return
+		}
+		// Server read and write timeouts must not cut a long-lived tunnel
+		_ = client.SetDeadline(time.Time{})
+		if _, err := client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+			client.Close()
+			upstream.Close()
+			return
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			// Bytes the client sent after the CONNECT request are already buffered
+			if n := buffered.Reader.Buffered(); n > 0 {
+				data, _ := buffered.Reader.Peek(n)
+				if _, err := upstream.Write(data); err != nil {
+					return
+				}
+			}
+			_, _ = io.Copy(upstream, client)
+			closeWrite(upstream)
+		}()
+		_, _ = io.Copy(client, upstream)
+		closeWrite(client)
+		<-done
+		client.Close()
+		upstream.Close()
+	}
+}
+
+// closeWrite shuts down the writing side of conn if it supports it, telling the peer
+// that no more data follows, and closes it otherwise.
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+		return
+	}
+	_ = conn.Close()
+}
+
+// tunnelTargets returns the host:port addresses of the configured Copilot API endpoints.
+func tunnelTargets(cfg *config.Config) map[string]bool {
+	urls := cfg.CopilotEndpoints
+	if len(urls) == 0 {
+		urls = []string{cfg.CopilotBaseURL}
+	}
+	targets := make(map[string]bool)
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+		}
+		targets[net.JoinHostPort(u.Hostname(), port)] = true
+	}
+	return targets
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"copilot-api/pkg/config"
+)
+
+// defaultIntegrationID is the Copilot-Integration-Id sent when no per-key override applies.
+const defaultIntegrationID = "vscode-chat"
+
+// newUpstreamRequest builds a request to the Copilot API at url, copying the client's
+// headers (except for hop-by-hop and auth) and setting the Copilot authentication headers.
+func newUpstreamRequest(r *http.Request, cfg *config.Config, method, url, contentType string, body []byte, copilotToken string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(r.Context(), method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// Copy headers except for hop-by-hop, auth and sensitive client headers
+	filter := newSensitiveHeaderFilter(cfg.StripUpstreamHeaders)
+	for k, v := range r.Header {
+		if strings.ToLower(k) == "authorization" || strings.ToLower(k) == "host" || strings.ToLower(k) == "connection" || strings.ToLower(k) == "content-length" {
+			continue
+		}
+		if filter.blocks(k) && !isTraceContextHeader(k) {
+			continue
+		}
+		for _, vv := range v {
+			req.Header.Add(k, vv)
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+copilotToken)
+	req.Header.Set("Copilot-Integration-Id", integrationID(r, cfg))
+	req.Header.Set("Editor-Version", "Go/1.21+")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	} else {
+		req.Header.Del("Content-Type")
+	}
+	if cfg.UserAgent != "" {
+		req.Header.Set("User-Agent", cfg.UserAgent)
+	}
+	return req, nil
+}
+
+// traceContextHeaders are the W3C and B3 trace context and request ID headers. They are
+// always forwarded to Copilot, even if listed in STRIP_UPSTREAM_HEADERS, so that upstream
+// requests can be correlated with the client's traces.
+var traceContextHeaders = []string{"traceparent", "tracestate", "X-B3-TraceId", "X-B3-SpanId", "X-B3-Sampled", "X-Trace-Id", "X-Request-Id"}
+
+// isTraceContextHeader reports whether name is one of traceContextHeaders, ignoring case.
+func isTraceContextHeader(name string) bool {
+	for _, h := range traceContextHeaders {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultSensitiveHeaders are client headers that are never forwarded to Copilot.
+var defaultSensitiveHeaders = []string{"Cookie", "Set-Cookie", "X-Auth-Token", "X-Session-Id"}
+
+// sensitiveHeaderFilter is a set of lower-cased header names withheld from upstream requests.
+type sensitiveHeaderFilter map[string]bool
+
+// newSensitiveHeaderFilter returns a filter for the default sensitive headers plus extra.
+func newSensitiveHeaderFilter(extra []string) sensitiveHeaderFilter {
+	f := make(sensitiveHeaderFilter, len(defaultSensitiveHeaders)+len(extra))
+	for _, h := range defaultSensitiveHeaders {
+		f[strings.ToLower(h)] = true
+	}
+	for _, h := range extra {
+		f[strings.ToLower(strings.TrimSpace(h))] = true
+	}
+	return f
+}
+
+// blocks reports whether the header name must not be forwarded, ignoring case.
+func (f sensitiveHeaderFilter) blocks(name string) bool {
+	return f[strings.ToLower(name)]
+}
+
+// integrationID returns the Copilot-Integration-Id for the API key that authenticated r.
+func integrationID(r *http.Request, cfg *config.Config) string {
+	if id, ok := cfg.IntegrationIdPerKey[keyLabelFromContext(r.Context())]; ok && id != "" {
+		return id
+	}
+	return defaultIntegrationID
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// concurrencyTransport bounds the requests in flight to the upstream APIs across all
+// clients, as GitHub's secondary rate limit counts concurrent requests. A request holds
+// a slot of sem until its response headers arrive, or for a streamed response until
+// its body is closed. Requests waiting for a slot open no connection.
+type concurrencyTransport struct {
+	next http.RoundTripper
+	sem  *semaphore.Weighted // nil for no limit
+}
+
+// newUpstreamSemaphore returns the semaphore shared by every upstream transport of a
+// router, or nil if limit is not positive.
+func newUpstreamSemaphore(limit int) *semaphore.Weighted {
+	if limit <= 0 {
+		return nil
+	}
+	return semaphore.NewWeighted(int64(limit))
+}
+
+// slotsHeldKey is the context key marking requests whose slots reserveSlots took.
+type slotsHeldKey struct{}
+
+// reserveSlots takes n slots of sem at once for a request fanning out into n upstream
+// requests that stay open until all of them are answered, like the streams merged by
+// emulateMultipleN. Taken one by one, two such requests could each hold part of the
+// slots while waiting for the rest. Requests made with the returned context use the
+// reserved slots, and release returns them.
+func reserveSlots(ctx context.Context, sem *semaphore.Weighted, n int) (context.Context, func(), error) {
+	if sem == nil {
+		return ctx, func() {}, nil
+	}
+	if err := sem.Acquire(ctx, int64(n)); err != nil {
+		return nil, nil, err
+	}
+	return context.WithValue(ctx, slotsHeldKey{}, true), func() { sem.Release(int64(n)) }, nil
+}
+
+func (t concurrencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.sem == nil || req.Context().Value(slotsHeldKey{}) != nil {
+		return t.next.RoundTrip(req)
+	}
+	if err := t.sem.Acquire(req.Context(), 1); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.sem.Release(1)
+		return resp, err
+	}
+	if !isSSEResponse(resp) {
+		t.sem.Release(1)
+		return resp, nil
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: func() { t.sem.Release(1) }}
+	return resp, nil
+}
+
+// releasingBody calls release once when the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rateLimitHeaders are the Copilot API rate limit response headers.
+var rateLimitHeaders = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-RateLimit-Resource"}
+
+// rateLimitWindow is how long an X-RateLimit-Remaining value counts towards the
+// copilot_upstream_rate_limit_remaining gauge.
+const rateLimitWindow = time.Minute
+
+// rateLimitTransport records the X-RateLimit-Remaining header of upstream responses and
+// removes the rate limit headers from them unless they are forwarded to clients.
+type rateLimitTransport struct {
+	next      http.RoundTripper
+	forward   bool
+	remaining *remainingTracker // nil if metrics are disabled
+}
+
+func (t rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if t.remaining != nil {
+		if v, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+			t.remaining.record(v, time.Now())
+		}
+	}
+	if !t.forward {
+		for _, h := range rateLimitHeaders {
+			resp.Header.Del(h)
+		}
+	}
+	return resp, nil
+}
+
+// remainingTracker keeps the minimum X-RateLimit-Remaining value of every second in the
+// last rateLimitWindow.
+type remainingTracker struct {
+	mu      sync.Mutex
+	seconds [int(rateLimitWindow / time.Second)]struct {
+		unix int64
+		min  int
+	}
+}
+
+// record notes a remaining value seen at now.
+func (t *remainingTracker) record(remaining int, now time.Time) {
+	sec := now.Unix()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := &t.seconds[sec%int64(len(t.seconds))]
+	if b.unix != sec || remaining < b.min {
+		b.unix, b.min = sec, remaining
+	}
+}
+
+// minimum returns the smallest value recorded within rateLimitWindow before now, or
+// false if there is none.
+func (t *remainingTracker) minimum(now time.Time) (int, bool) {
+	oldest := now.Unix() - int64(len(t.seconds))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	min, found := 0, false
+	for _, b := range t.seconds {
+		if b.unix > oldest && (!found || b.min < min) {
+			min, found = b.min, true
+		}
+	}
+	return min, found
+}
+
+// registerRateLimitMetrics registers the copilot_upstream_rate_limit_remaining gauge,
+// which is NaN while no upstream response carried the header in the last minute.
+func registerRateLimitMetrics(registry *prometheus.Registry, remaining *remainingTracker) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "copilot_upstream_rate_limit_remaining",
+		Help: "Smallest X-RateLimit-Remaining value sent by the Copilot API in the last minute.",
+	}, func() float64 {
+		if min, ok := remaining.minimum(time.Now()); ok {
+			return float64(min)
+		}
+		return math.NaN()
+	}))
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// UpstreamUsage caches the Copilot usage GitHub reports for Config.GitHubOrg, served by
+// GET /admin/upstream-usage.
+type UpstreamUsage struct {
+	cfg    *config.Config
+	tokens copilot.TokenSource
+	client *http.Client
+
+	fetchMu   sync.Mutex // serializes fetches so concurrent misses share one
+	mu        sync.RWMutex
+	data      []byte
+	fetchedAt time.Time
+}
+
+// NewUpstreamUsage returns an UpstreamUsage fetching with the OAuth token of tokens.
+// The usage is fetched on the first request and cached for cfg.UsageCacheTTL; Start
+// keeps it fresh in the background.
+func NewUpstreamUsage(cfg *config.Config, tokens copilot.TokenSource) *UpstreamUsage {
+	return &UpstreamUsage{cfg: cfg, tokens: tokens, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Start refreshes the cached usage now and then every 90% of the cache TTL, until ctx is
+// done. It does nothing if no organization is configured. Failed refreshes are logged
+// and keep the previous usage until it expires.
+func (u *UpstreamUsage) Start(ctx context.Context) {
+	if u.cfg.GitHubOrg == "" || u.cfg.UsageCacheTTL <= 0 {
+		return
+	}
+	go func() {
+		for {
+			if err := u.refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Warning: failed to refresh GitHub Copilot usage: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(u.cfg.UsageCacheTTL * 9 / 10):
+			}
+		}
+	}()
+}
+
+// get returns the cached usage if it is younger than the cache TTL, otherwise fetches
+// it. cached reports whether no fetch was needed.
+func (u *UpstreamUsage) get(ctx context.Context) (data []byte, fetchedAt time.Time, cached bool, err error) {
+	if data, fetchedAt, ok := u.fresh(); ok {
+		return data, fetchedAt, true, nil
+	}
+	u.fetchMu.Lock()
+	defer u.fetchMu.Unlock()
+	// Another request may have fetched it while this one waited
+	if data, fetchedAt, ok := u.fresh(); ok {
+		return data, fetchedAt, true, nil
+	}
+	if err := u.fetch(ctx); err != nil {
+		return nil, time.Time{}, false, err
+	}
+	data, fetchedAt, _ = u.fresh()
+	return data, fetchedAt, false, nil
+}
+
+// fresh returns the cached usage and whether it is younger than the cache TTL.
+func (u *UpstreamUsage) fresh() ([]byte, time.Time, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.data, u.fetchedAt, u.data != nil && time.Since(u.fetchedAt) < u.cfg.UsageCacheTTL
+}
+
+// refresh fetches the usage regardless of the cached copy.
+func (u *UpstreamUsage) refresh(ctx context.Context) error {
+	u.fetchMu.Lock()
+	defer u.fetchMu.Unlock()
+	return u.fetch(ctx)
+}
+
+// fetch requests the organization's usage since the start of the current UTC month from
+// the GitHub API and caches the response body as is. u.fetchMu must be held.
+func (u *UpstreamUsage) fetch(ctx context.Context) error {
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	endpoint := u.cfg.GitHubAPIURL + "/orgs/" + url.PathEscape(u.cfg.GitHubOrg) + "/copilot/usage?since=" + since.Format(time.RFC3339)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "token "+u.tokens.OAuthToken())
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", u.cfg.UserAgent)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub returned %s: %s", resp.Status, truncate(string(body), 200))
+	}
+
+	u.mu.Lock()
+	u.data, u.fetchedAt = body, time.Now()
+	u.mu.Unlock()
+	return nil
+}
+
+// truncate shortens s to at most n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// upstreamUsageHandler serves GET /admin/upstream-usage, the GitHub Copilot usage of the
+// configured organization as returned by GitHub. X-Cache tells whether it was cached.
+func upstreamUsageHandler(cfg *config.Config, usage *UpstreamUsage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeOpenAIError(w, http.StatusMethodNotAllowed, "invalid_request_error", "",
+				"Method "+r.Method+" is not allowed on "+r.URL.Path)
+			return
+		}
+		if cfg.GitHubOrg == "" {
+			writeOpenAIError(w, http.StatusNotImplemented, "api_error", "", "GITHUB_ORG is not configured")
+			return
+		}
+		data, fetchedAt, cached, err := usage.get(r.Context())
+		if err != nil {
+			writeOpenAIError(w, http.StatusBadGateway, "api_error", "", "Failed to fetch GitHub Copilot usage: "+err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Last-Modified", fetchedAt.UTC().Format(http.TimeFormat))
+		if cached {
+			w.Header().Set("X-Cache", "HIT")
+		} else {
+			w.Header().Set("X-Cache", "MISS")
+		}
+		_, _ = w.Write(data)
+	}
+}
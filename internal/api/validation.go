@@ -0,0 +1,214 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+)
+
+// maxCompletionCount is the largest n accepted on chat completions, as on OpenAI.
+const maxCompletionCount = 128
+
+// functionNamePattern matches the function names OpenAI accepts in tools.
+var functionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// validationError describes an invalid request field.
+type validationError struct {
+	Param   string
+	Message string
+}
+
+func (e *validationError) Error() string {
+	return e.Message
+}
+
+// invalidParam returns a validationError for param.
+func invalidParam(param, format string, args ...interface{}) error {
+	return &validationError{Param: param, Message: fmt.Sprintf(format, args...)}
+}
+
+// writeValidationError writes err as a 400 OpenAI-format invalid_request_error.
+func writeValidationError(w http.ResponseWriter, err error) {
+	var param string
+	var verr *validationError
+	if errors.As(err, &verr) {
+		param = verr.Param
+	}
+	writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", param, err.Error())
+}
+
+// validateChatCompletionsRequest checks the fields of a /v1/chat/completions body that
+// Copilot would otherwise reject with an opaque error.
+func validateChatCompletionsRequest(body map[string]interface{}) error {
+	rawMessages, ok := body["messages"]
+	if !ok || rawMessages == nil {
+		return invalidParam("messages", "messages is required")
+	}
+	messages, ok := rawMessages.([]interface{})
+	if !ok {
+		return invalidParam("messages", "messages must be an array")
+	}
+	for i, raw := range messages {
+		param := fmt.Sprintf("messages[%d]", i)
+		msg, ok := raw.(map[string]interface{})
+		if !ok {
+			return invalidParam(param, "%s must be an object", param)
+		}
+		if _, ok := msg["role"].(string); !ok {
+			return invalidParam(param+".role", "%s.role must be a string", param)
+		}
+		switch msg["content"].(type) {
+		case string, []interface{}:
+		case nil:
+			// Assistant messages that only carry tool calls have no content
+			if _, hasToolCalls := msg["tool_calls"]; !hasToolCalls {
+				return invalidParam(param+".content", "%s.content is required", param)
+			}
+		default:
+			return invalidParam(param+".content", "%s.content must be a string or an array", param)
+		}
+	}
+
+	if v, ok := body["stream"]; ok && v != nil {
+		if _, ok := v.(bool); !ok {
+			return invalidParam("stream", "stream must be a boolean")
+		}
+	}
+	if v, ok := body["temperature"]; ok && v != nil {
+		t, ok := v.(float64)
+		if !ok {
+			return invalidParam("temperature", "temperature must be a number")
+		}
+		if t < 0 || t > 2 {
+			return invalidParam("temperature", "temperature must be between 0 and 2")
+		}
+	}
+	if v, ok := body["max_tokens"]; ok && v != nil {
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) || n <= 0 {
+			return invalidParam("max_tokens", "max_tokens must be a positive integer")
+		}
+	}
+	if v, ok := body["n"]; ok && v != nil {
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) || n <= 0 || n > maxCompletionCount {
+			return invalidParam("n", "n must be an integer between 1 and %d", maxCompletionCount)
+		}
+	}
+	if v, ok := body["seed"]; ok && v != nil {
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return invalidParam("seed", "seed must be an integer")
+		}
+	}
+	if v, ok := body["response_format"]; ok && v != nil {
+		if err := validateResponseFormat(v); err != nil {
+			return err
+		}
+	}
+	if v, ok := body["tools"]; ok && v != nil {
+		tools, ok := v.([]interface{})
+		if !ok {
+			return invalidParam("tools", "tools must be an array")
+		}
+		if err := validateTools(tools); err != nil {
+			return err
+		}
+	}
+	if v, ok := body["tool_choice"]; ok && v != nil {
+		if err := validateToolChoice(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateTools checks the function definitions of an OpenAI tools array.
+func validateTools(tools []interface{}) error {
+	for i, raw := range tools {
+		param := fmt.Sprintf("tools[%d]", i)
+		tool, ok := raw.(map[string]interface{})
+		if !ok {
+			return invalidParam(param, "%s must be an object", param)
+		}
+		if tool["type"] != "function" {
+			return invalidParam(param+".type", "%s.type must be function", param)
+		}
+		fn, ok := tool["function"].(map[string]interface{})
+		if !ok {
+			return invalidParam(param+".function", "%s.function is required", param)
+		}
+		if err := validateFunctionName(param+".function.name", fn["name"]); err != nil {
+			return err
+		}
+		param += ".function.parameters"
+		if fn["parameters"] == nil {
+			return invalidParam(param, "%s is required", param)
+		}
+		schema, ok := fn["parameters"].(map[string]interface{})
+		if !ok {
+			return invalidParam(param, "%s must be a JSON Schema object", param)
+		}
+		if schema["type"] != "object" {
+			return invalidParam(param+".type", "%s.type must be object", param)
+		}
+		if _, ok := schema["properties"].(map[string]interface{}); !ok {
+			return invalidParam(param+".properties", "%s.properties must be an object", param)
+		}
+	}
+	return nil
+}
+
+// validateFunctionName checks the function name at param.
+func validateFunctionName(param string, v interface{}) error {
+	name, ok := v.(string)
+	if !ok || name == "" {
+		return invalidParam(param, "%s is required", param)
+	}
+	if !functionNamePattern.MatchString(name) {
+		return invalidParam(param, "%s must be 1 to 64 letters, digits, underscores or dashes", param)
+	}
+	return nil
+}
+
+// validateToolChoice checks an OpenAI tool_choice value: none, auto, required or a
+// named function.
+func validateToolChoice(v interface{}) error {
+	switch choice := v.(type) {
+	case string:
+		switch choice {
+		case "none", "auto", "required":
+			return nil
+		}
+	case map[string]interface{}:
+		if choice["type"] != "function" {
+			return invalidParam("tool_choice.type", "tool_choice.type must be function")
+		}
+		fn, ok := choice["function"].(map[string]interface{})
+		if !ok {
+			return invalidParam("tool_choice.function", "tool_choice.function is required")
+		}
+		return validateFunctionName("tool_choice.function.name", fn["name"])
+	}
+	return invalidParam("tool_choice", `tool_choice must be "none", "auto", "required" or a function object`)
+}
+
+// validateResponseFormat checks an OpenAI response_format value.
+func validateResponseFormat(v interface{}) error {
+	format, ok := v.(map[string]interface{})
+	if !ok {
+		return invalidParam("response_format", "response_format must be an object")
+	}
+	switch format["type"] {
+	case "text", "json_object":
+	case "json_schema":
+		if _, ok := format["json_schema"].(map[string]interface{}); !ok {
+			return invalidParam("response_format.json_schema", "response_format.json_schema must be an object")
+		}
+	default:
+		return invalidParam("response_format.type", "response_format.type must be one of text, json_object, json_schema")
+	}
+	return nil
+}
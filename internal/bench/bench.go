@@ -0,0 +1,370 @@
+// Package bench measures the end-to-end throughput of the proxy by sending concurrent
+// chat completion requests for a fixed time and summarizing latency and token usage.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"copilot-api/internal/sse"
+)
+
+// Prompt is the user message sent by every benchmark request.
+const Prompt = "Write a short paragraph about the history of the telescope."
+
+// Options configures a benchmark run.
+type Options struct {
+	Addr        string        // Address or base URL of the proxy, e.g. localhost:9191
+	Token       string        // Access token sent as a bearer token
+	Model       string        // Model requested; empty lets the proxy pick its default
+	Concurrency int           // Number of requests in flight at once (default: 1)
+	Duration    time.Duration // How long new requests are started for
+	Stream      bool          // Request event stream responses and measure time to first token
+	Client      *http.Client  // Client used for the requests (default: http.DefaultClient)
+}
+
+// Percentiles summarizes a latency distribution in milliseconds.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// Result is the summary of a benchmark run. Latencies and tokens are counted over the
+// successful requests only.
+type Result struct {
+	Requests         int            `json:"requests"`
+	Successes        int            `json:"successes"`
+	SuccessRate      float64        `json:"success_rate"`
+	DurationSeconds  float64        `json:"duration_seconds"`
+	Latency          Percentiles    `json:"latency_ms"`
+	TimeToFirstToken *Percentiles   `json:"time_to_first_token_ms,omitempty"`
+	PromptTokens     int            `json:"prompt_tokens"`
+	CompletionTokens int            `json:"completion_tokens"`
+	TokensPerSecond  float64        `json:"tokens_per_second"` // completion tokens per second of the run
+	Errors           map[string]int `json:"errors"`
+}
+
+// outcome is the measurement of a single request.
+type outcome struct {
+	latency          time.Duration
+	firstToken       time.Duration // zero unless streamed content was received
+	promptTokens     int
+	completionTokens int
+	errType          string // empty for a successful request
+	retryAfter       time.Duration
+}
+
+// Run sends requests from opts.Concurrency workers until opts.Duration has passed and
+// all requests in flight have completed. When the proxy answers with a Retry-After
+// header, no worker starts a request before that time has passed.
+func Run(ctx context.Context, opts Options) (*Result, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Duration <= 0 {
+		return nil, errors.New("duration must be positive")
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	body, err := json.Marshal(requestBody(opts))
+	if err != nil {
+		return nil, err
+	}
+	target := chatURL(opts.Addr)
+
+	var (
+		mu         sync.Mutex
+		outcomes   []outcome
+		pauseUntil time.Time
+		wg         sync.WaitGroup
+	)
+	start := time.Now()
+	deadline := start.Add(opts.Duration)
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				next := pauseUntil
+				mu.Unlock()
+				if next.After(deadline) || time.Now().After(deadline) {
+					return
+				}
+				if wait := time.Until(next); wait > 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(wait):
+					}
+				}
+				if ctx.Err() != nil {
+					return
+				}
+
+				o := send(ctx, opts, target, body)
+				mu.Lock()
+				outcomes = append(outcomes, o)
+				if o.retryAfter > 0 {
+					if until := time.Now().Add(o.retryAfter); until.After(pauseUntil) {
+						pauseUntil = until
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return summarize(outcomes, time.Since(start), opts.Stream), nil
+}
+
+// requestBody returns the chat completion request sent by every worker.
+func requestBody(opts Options) map[string]interface{} {
+	body := map[string]interface{}{
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": Prompt},
+		},
+	}
+	if opts.Model != "" {
+		body["model"] = opts.Model
+	}
+	if opts.Stream {
+		body["stream"] = true
+		body["stream_options"] = map[string]interface{}{"include_usage": true}
+	}
+	return body
+}
+
+// chatURL returns the chat completions endpoint of the proxy at addr, which may be a
+// URL, a host:port or a :port on localhost.
+func chatURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return strings.TrimSuffix(addr, "/") + "/v1/chat/completions"
+}
+
+// send makes one request and measures it.
+func send(ctx context.Context, opts Options, target string, body []byte) outcome {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return outcome{errType: "request"}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	start := time.Now()
+	resp, err := opts.Client.Do(req)
+	if err != nil {
+		return outcome{errType: transportErrorType(err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return outcome{
+			errType:    "http_" + strconv.Itoa(resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	var o outcome
+	var usage *usage
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		usage, o.firstToken, err = readStream(resp.Body, start)
+	} else {
+		usage, err = readCompletion(resp.Body)
+	}
+	o.latency = time.Since(start)
+	if err != nil {
+		o.errType = transportErrorType(err)
+		return o
+	}
+	if usage != nil {
+		o.promptTokens = usage.PromptTokens
+		o.completionTokens = usage.CompletionTokens
+	}
+	return o
+}
+
+// usage is the usage object of a chat completion.
+type usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// readCompletion returns the usage of a chat completion response.
+func readCompletion(body io.Reader) (*usage, error) {
+	var resp struct {
+		Usage *usage `json:"usage"`
+	}
+	if err := json.NewDecoder(body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("%w: %v", errDecode, err)
+	}
+	return resp.Usage, nil
+}
+
+// readStream reads a chat completion event stream to its end and returns its usage
+// chunk and how long after start the first content arrived.
+func readStream(body io.Reader, start time.Time) (*usage, time.Duration, error) {
+	var u *usage
+	var firstToken time.Duration
+	scanner := sse.NewScanner(body)
+	for scanner.Scan() {
+		data := scanner.Event().Data
+		if data == "[DONE]" {
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Usage *usage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", errDecode, err)
+		}
+		if firstToken == 0 {
+			for _, c := range chunk.Choices {
+				if c.Delta.Content != "" {
+					firstToken = time.Since(start)
+					break
+				}
+			}
+		}
+		if chunk.Usage != nil {
+			u = chunk.Usage
+		}
+	}
+	return u, firstToken, scanner.Err()
+}
+
+// errDecode reports a response body that is not a valid chat completion.
+var errDecode = errors.New("invalid response body")
+
+// transportErrorType names the kind of a failed request for the error counts.
+func transportErrorType(err error) string {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errDecode):
+		return "decode"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "network"
+	}
+}
+
+// parseRetryAfter returns the wait requested by a Retry-After header, given either in
+// seconds or as an HTTP date, or zero if there is none.
+func parseRetryAfter(val string) time.Duration {
+	if val == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// summarize computes the Result of the outcomes of a run that took elapsed.
+func summarize(outcomes []outcome, elapsed time.Duration, stream bool) *Result {
+	res := &Result{
+		Requests:        len(outcomes),
+		DurationSeconds: elapsed.Seconds(),
+		Errors:          map[string]int{},
+	}
+	var latencies, firstTokens []time.Duration
+	for _, o := range outcomes {
+		if o.errType != "" {
+			res.Errors[o.errType]++
+			continue
+		}
+		res.Successes++
+		res.PromptTokens += o.promptTokens
+		res.CompletionTokens += o.completionTokens
+		latencies = append(latencies, o.latency)
+		if o.firstToken > 0 {
+			firstTokens = append(firstTokens, o.firstToken)
+		}
+	}
+	if res.Requests > 0 {
+		res.SuccessRate = float64(res.Successes) / float64(res.Requests)
+	}
+	if elapsed > 0 {
+		res.TokensPerSecond = float64(res.CompletionTokens) / elapsed.Seconds()
+	}
+	res.Latency = percentiles(latencies)
+	if stream {
+		ttft := percentiles(firstTokens)
+		res.TimeToFirstToken = &ttft
+	}
+	return res
+}
+
+// percentiles returns the nearest-rank 50th, 95th and 99th percentiles of durations.
+func percentiles(durations []time.Duration) Percentiles {
+	if len(durations) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(durations)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return float64(durations[i]) / float64(time.Millisecond)
+	}
+	return Percentiles{P50: rank(50), P95: rank(95), P99: rank(99)}
+}
+
+// WriteTable writes the result as a human-readable table.
+func (r *Result) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Requests\t%d\n", r.Requests)
+	fmt.Fprintf(tw, "Success rate\t%.1f%%\n", r.SuccessRate*100)
+	fmt.Fprintf(tw, "Duration\t%.1fs\n", r.DurationSeconds)
+	fmt.Fprintf(tw, "Latency p50/p95/p99\t%.0f / %.0f / %.0f ms\n", r.Latency.P50, r.Latency.P95, r.Latency.P99)
+	if r.TimeToFirstToken != nil {
+		fmt.Fprintf(tw, "Time to first token p50/p95/p99\t%.0f / %.0f / %.0f ms\n",
+			r.TimeToFirstToken.P50, r.TimeToFirstToken.P95, r.TimeToFirstToken.P99)
+	}
+	fmt.Fprintf(tw, "Prompt tokens\t%d\n", r.PromptTokens)
+	fmt.Fprintf(tw, "Completion tokens\t%d\n", r.CompletionTokens)
+	fmt.Fprintf(tw, "Tokens/second\t%.1f\n", r.TokensPerSecond)
+	types := make([]string, 0, len(r.Errors))
+	for t := range r.Errors {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(tw, "Errors (%s)\t%d\n", t, r.Errors[t])
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,169 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TokenFunc returns the token to authenticate a request with, such as
+// TokenManager.GetToken.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// How the models list of the Copilot API is combined with the GitHub Models catalog.
+const (
+	// MergeNone uses the Copilot API list alone.
+	MergeNone = ""
+	// MergeUnion lists the models of both lists.
+	MergeUnion = "union"
+	// MergeIntersection lists the catalog models the Copilot API also lists.
+	MergeIntersection = "intersection"
+)
+
+// WithCopilotModelsEndpoint fetches the models list from the Copilot API endpoint url,
+// which lists the models available to the account, instead of the GitHub Models
+// catalog. Requests are authenticated with a Copilot token from token rather than the
+// static token of the cache. merge selects whether and how the catalog is combined with
+// it; a Copilot model matches a catalog model as by Lookup.
+func WithCopilotModelsEndpoint(url string, token TokenFunc, merge string) ModelsCacheOption {
+	return func(c *ModelsCache) {
+		c.copilotModelsURL = url
+		c.copilotToken = token
+		c.mergeModels = merge
+	}
+}
+
+// copilotModelList is the models list of the Copilot API.
+type copilotModelList struct {
+	Data []struct {
+		ID           string `json:"id"`
+		Name         string `json:"name"`
+		Vendor       string `json:"vendor"`
+		Version      string `json:"version"`
+		Capabilities struct {
+			Limits struct {
+				MaxPromptTokens int `json:"max_prompt_tokens"`
+				MaxOutputTokens int `json:"max_output_tokens"`
+			} `json:"limits"`
+			Supports struct {
+				Streaming bool `json:"streaming"`
+				ToolCalls bool `json:"tool_calls"`
+				Vision    bool `json:"vision"`
+			} `json:"supports"`
+		} `json:"capabilities"`
+	} `json:"data"`
+}
+
+// fetchCopilotModels fetches the models list of the Copilot API and converts it to
+// catalog models.
+func (c *ModelsCache) fetchCopilotModels(ctx context.Context) ([]Model, error) {
+	token, err := c.copilotToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Copilot token for models: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.copilotModelsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Copilot-Integration-Id", "vscode-chat")
+	req.Header.Set("Editor-Version", "Go/1.21+")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Copilot models: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Copilot models API error: %s - %s", resp.Status, string(body))
+	}
+	var list copilotModelList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("invalid Copilot models JSON: %w", err)
+	}
+
+	models := make([]Model, 0, len(list.Data))
+	for _, m := range list.Data {
+		model := Model{
+			ID:                       m.ID,
+			Name:                     m.Name,
+			Publisher:                m.Vendor,
+			Version:                  m.Version,
+			SupportedInputModalities: []string{"text"},
+			Limits: ModelLimits{
+				MaxInputTokens:  m.Capabilities.Limits.MaxPromptTokens,
+				MaxOutputTokens: m.Capabilities.Limits.MaxOutputTokens,
+			},
+		}
+		if model.Name == "" {
+			model.Name = m.ID
+		}
+		if m.Capabilities.Supports.Streaming {
+			model.Capabilities = append(model.Capabilities, "streaming")
+		}
+		if m.Capabilities.Supports.ToolCalls {
+			model.Capabilities = append(model.Capabilities, "tool-calling")
+		}
+		if m.Capabilities.Supports.Vision {
+			model.SupportedInputModalities = append(model.SupportedInputModalities, "image")
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// mergeModelLists combines the Copilot API models with the catalog models as selected
+// by merge. Catalog entries are kept for models in both lists, as they carry more
+// details.
+func mergeModelLists(copilotModels, catalog []Model, merge string) []Model {
+	inCopilot := func(m Model) bool {
+		for _, cm := range copilotModels {
+			if sameModel(m.ID, cm.ID) {
+				return true
+			}
+		}
+		return false
+	}
+	var merged []Model
+	for _, m := range catalog {
+		if merge == MergeUnion || inCopilot(m) {
+			merged = append(merged, m)
+		}
+	}
+	if merge != MergeUnion {
+		return merged
+	}
+	for _, cm := range copilotModels {
+		known := false
+		for _, m := range catalog {
+			if sameModel(m.ID, cm.ID) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			merged = append(merged, cm)
+		}
+	}
+	return merged
+}
+
+// sameModel reports whether two model IDs name the same model, ignoring the publisher
+// prefix of catalog IDs.
+func sameModel(a, b string) bool {
+	trim := func(id string) string {
+		if _, name, ok := strings.Cut(id, "/"); ok {
+			return name
+		}
+		return id
+	}
+	return a == b || trim(a) == trim(b)
+}
@@ -0,0 +1,136 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"copilot-api/internal/version"
+)
+
+// deviceFlowScope is the OAuth scope requested by the device flow, as by the Copilot plugins.
+const deviceFlowScope = "read:user"
+
+// defaultPollInterval is used when GitHub does not say how often to poll.
+const defaultPollInterval = 5 * time.Second
+
+// DeviceCode is GitHub's answer to a device flow request: the user enters UserCode at
+// VerificationURI while the device polls with DeviceCode.
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// RequestDeviceCode starts GitHub's OAuth device flow for the OAuth app clientID.
+// githubURL is the web URL of the GitHub host, e.g. https://github.com.
+func RequestDeviceCode(ctx context.Context, githubURL, clientID string) (*DeviceCode, error) {
+	var code DeviceCode
+	form := url.Values{"client_id": {clientID}, "scope": {deviceFlowScope}}
+	if err := postDeviceFlowForm(ctx, githubURL+"/login/device/code", form, &code); err != nil {
+		return nil, fmt.Errorf("device code request failed: %w", err)
+	}
+	if code.DeviceCode == "" || code.UserCode == "" {
+		return nil, errors.New("device code request returned no code")
+	}
+	return &code, nil
+}
+
+// PollDeviceToken polls GitHub until the user has authorized code and returns the
+// OAuth token. It gives up when the user denies access, the code expires or ctx ends.
+func PollDeviceToken(ctx context.Context, githubURL, clientID string, code *DeviceCode) (string, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	form := url.Values{
+		"client_id":   {clientID},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("device authorization not completed: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+
+		var resp struct {
+			AccessToken      string `json:"access_token"`
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+			Interval         int    `json:"interval"`
+		}
+		if err := postDeviceFlowForm(ctx, githubURL+"/login/oauth/access_token", form, &resp); err != nil {
+			return "", fmt.Errorf("device token request failed: %w", err)
+		}
+		switch resp.Error {
+		case "":
+			if resp.AccessToken == "" {
+				return "", errors.New("device token request returned no token")
+			}
+			return resp.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			// GitHub sends the new interval; add 5 seconds as the spec requires if it does not
+			if resp.Interval > 0 {
+				interval = time.Duration(resp.Interval) * time.Second
+			} else {
+				interval += 5 * time.Second
+			}
+		default:
+			if resp.ErrorDescription != "" {
+				return "", fmt.Errorf("device authorization failed: %s: %s", resp.Error, resp.ErrorDescription)
+			}
+			return "", fmt.Errorf("device authorization failed: %s", resp.Error)
+		}
+	}
+}
+
+// postDeviceFlowForm posts form to a GitHub OAuth endpoint and decodes the JSON answer into v.
+func postDeviceFlowForm(ctx context.Context, endpoint string, form url.Values, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// SaveToAppsJSON writes the OAuth token and its GitHub username to the apps.json file
+// at path in the Copilot config format, under the key "<host>:<clientID>", keeping the
+// other entries. An empty path selects apps.json in the Copilot config directory.
+func (tm *TokenManager) SaveToAppsJSON(path, clientID string) error {
+	if path == "" {
+		path = filepath.Join(tm.configDir, "github-copilot", "apps.json")
+	}
+	user, err := tm.lookupUsername()
+	if err != nil {
+		return err
+	}
+	return writeHostsEntry(path, tm.githubHost+":"+clientID, struct {
+		User        string `json:"user"`
+		OAuthToken  string `json:"oauth_token"`
+		GitHubAppID string `json:"githubAppId"`
+	}{user, tm.OAuthToken(), clientID})
+}
@@ -0,0 +1,171 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// dnsPort is the port of fallback DNS servers given without one.
+	dnsPort = "53"
+	// dnsAddrDialTimeout limits dialing one of several addresses of a host, so that an
+	// unresponsive address does not use up the whole dial timeout before the next is
+	// tried. The last address tried gets the dialer's full timeout.
+	dnsAddrDialTimeout = 5 * time.Second
+)
+
+// DNSCache resolves upstream host names, caching the addresses for a fixed TTL instead of
+// querying DNS for every new connection. If the system resolver fails, the fallback DNS
+// servers are asked in order; a host that does not exist is not looked up again there.
+type DNSCache struct {
+	ttl       time.Duration
+	fallbacks []*net.Resolver
+	dialer    *net.Dialer
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+// dnsEntry holds the cached addresses of a host.
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+	next    atomic.Uint32 // round-robin position
+}
+
+// NewDNSCache creates a DNSCache keeping answers for ttl. fallbackServers are DNS server
+// IPs, optionally with a port, asked when the system resolver fails.
+func NewDNSCache(ttl time.Duration, fallbackServers []string) *DNSCache {
+	c := &DNSCache{
+		ttl:     ttl,
+		dialer:  &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		entries: make(map[string]*dnsEntry),
+	}
+	for _, server := range fallbackServers {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, dnsPort)
+		}
+		c.fallbacks = append(c.fallbacks, &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return c.dialer.DialContext(ctx, network, server)
+			},
+		})
+	}
+	return c
+}
+
+// Set caches addrs for host for the cache TTL, replacing any cached answer.
+func (c *DNSCache) Set(host string, addrs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[host] = &dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+}
+
+// DialContext connects to addr like net.Dialer.DialContext, resolving its host through
+// the cache. Connections rotate over the host's addresses of the family network asks for;
+// if one cannot be reached within dnsAddrDialTimeout the next is tried.
+func (c *DNSCache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return c.dialer.DialContext(ctx, network, addr)
+	}
+	entry, err := c.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	start := int(entry.next.Add(1) - 1)
+	var ips []string
+	for i := range entry.addrs {
+		if ip := entry.addrs[(start+i)%len(entry.addrs)]; matchesNetwork(network, ip) {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+	}
+	var firstErr error
+	for i, ip := range ips {
+		dialCtx, cancel := ctx, context.CancelFunc(func() {})
+		if i < len(ips)-1 {
+			dialCtx, cancel = context.WithTimeout(ctx, dnsAddrDialTimeout)
+		}
+		conn, err := c.dialer.DialContext(dialCtx, network, net.JoinHostPort(ip, port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// matchesNetwork reports whether ip can be dialed on network: tcp4 and udp4 only take
+// IPv4 addresses, tcp6 and udp6 only IPv6 addresses, and other networks either.
+func matchesNetwork(network, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	switch network {
+	case "tcp4", "udp4":
+		return parsed.To4() != nil
+	case "tcp6", "udp6":
+		return parsed.To4() == nil
+	}
+	return true
+}
+
+// lookup returns the cached addresses of host, resolving them if missing or expired.
+// A stale answer is kept if resolving fails.
+func (c *DNSCache) lookup(ctx context.Context, host string) (*dnsEntry, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry, nil
+	}
+
+	addrs, err := c.resolve(ctx, host)
+	if err != nil {
+		if ok {
+			return entry, nil
+		}
+		return nil, err
+	}
+	fresh := &dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mu.Lock()
+	c.entries[host] = fresh
+	c.mu.Unlock()
+	return fresh, nil
+}
+
+// resolve looks host up with the system resolver, then with each fallback resolver.
+func (c *DNSCache) resolve(ctx context.Context, host string) ([]string, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	for _, r := range c.fallbacks {
+		if err == nil || isNotFound(err) || ctx.Err() != nil {
+			break
+		}
+		addrs, err = r.LookupHost(ctx, host)
+	}
+	if err == nil && len(addrs) == 0 {
+		err = &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	return addrs, err
+}
+
+// isNotFound reports whether err says the host does not exist (NXDOMAIN).
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,86 @@
+package copilot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// legacyTokenKey is the key holding the OAuth token in the old Copilot CLI config.
+const legacyTokenKey = "github.token"
+
+// WithMigrateOldConfig copies an OAuth token found only in the old Copilot CLI config,
+// ~/.copilot/config.json, into hosts.json in the Copilot config directory.
+func WithMigrateOldConfig(migrate bool) TokenManagerOption {
+	return func(tm *TokenManager) {
+		tm.migrateOldConfig = migrate
+	}
+}
+
+// legacyConfigPath returns the location of the old Copilot CLI config file.
+func legacyConfigPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".copilot", "config.json")
+}
+
+// loadLegacyOAuthToken reads the github.com OAuth token from an old Copilot CLI config
+// file, which looks like {"github.token": "gho_..."}.
+func loadLegacyOAuthToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var cfg map[string]interface{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	token, _ := cfg[legacyTokenKey].(string)
+	if token == "" {
+		return "", fmt.Errorf("%s holds no %s", path, legacyTokenKey)
+	}
+	return token, nil
+}
+
+// migrateOldConfig copies the OAuth token of the old Copilot CLI config file src into
+// hosts.json in dstDir, keeping the entries of other hosts.
+func migrateOldConfig(src, dstDir string) error {
+	token, err := loadLegacyOAuthToken(src)
+	if err != nil {
+		return err
+	}
+	return writeHostsEntry(filepath.Join(dstDir, "hosts.json"), defaultGitHubHost, struct {
+		OAuthToken string `json:"oauth_token"`
+	}{token})
+}
+
+// writeHostsEntry sets the entry for host in the hosts.json or apps.json file at path,
+// keeping the entries of other hosts. The file is replaced atomically.
+func writeHostsEntry(path, host string, entry interface{}) error {
+	hosts := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &hosts); err != nil {
+			return fmt.Errorf("invalid JSON in %s: %w", path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	hosts[host] = raw
+	data, err := json.Marshal(hosts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, path)
+}
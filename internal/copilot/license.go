@@ -0,0 +1,42 @@
+package copilot
+
+import "strings"
+
+// Copilot license tiers reported by TokenManager.LicenseTier.
+const (
+	LicenseIndividual = "individual"
+	LicenseBusiness   = "business"
+	LicenseEnterprise = "enterprise"
+)
+
+// licenseTier derives the license tier from a token response: an enterprise trial or
+// an enterprise or business seat SKU, otherwise the individual flag or any other SKU,
+// which are individual plans. It returns "" if the response says nothing about the
+// license.
+func (t *CopilotToken) licenseTier() string {
+	sku := strings.ToLower(t.SKU)
+	switch {
+	case t.EnterpriseTrial, strings.Contains(sku, "enterprise"):
+		return LicenseEnterprise
+	case strings.Contains(sku, "business"):
+		return LicenseBusiness
+	case t.Individual != nil && !*t.Individual:
+		// A seat assigned by an organization without a recognizable SKU
+		return LicenseBusiness
+	case t.Individual != nil, sku != "":
+		return LicenseIndividual
+	}
+	return ""
+}
+
+// LicenseTier returns the Copilot license tier of the account, one of
+// LicenseIndividual, LicenseBusiness and LicenseEnterprise, or "" if no token has been
+// obtained or the token endpoint did not describe the license.
+func (tm *TokenManager) LicenseTier() string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	if tm.githubToken == nil {
+		return ""
+	}
+	return tm.githubToken.licenseTier()
+}
@@ -0,0 +1,73 @@
+package copilot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// staleLockAge is the age after which a lock file is removed even if its owner is alive.
+const staleLockAge = 300 * time.Second
+
+// errLockReplaced is returned by acquireLock when the lock file was removed or replaced
+// while it was being locked.
+var errLockReplaced = errors.New("lock file was replaced")
+
+// openLockFile opens the lock file at lockPath, creating it and its directory if needed.
+func openLockFile(lockPath string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+}
+
+// checkLockFile makes sure the locked file f is still the file at lockPath, since
+// releaseLock and removeStaleLock remove lock files, and records the PID of this process
+// in it for debugging and stale lock detection.
+func checkLockFile(f *os.File, lockPath string) error {
+	locked, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(lockPath)
+	if err != nil || !os.SameFile(locked, current) {
+		return errLockReplaced
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	return err
+}
+
+// removeStaleLock removes the lock file if it is older than staleLockAge or the process
+// that created it is no longer running. Lock files without a PID, written by older
+// versions, only expire by age. It reports whether the lock was removed.
+func removeStaleLock(lockPath string) bool {
+	info, err := os.Stat(lockPath)
+	if err != nil {
+		return false
+	}
+	if time.Since(info.ModTime()) > staleLockAge {
+		return os.Remove(lockPath) == nil
+	}
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 || processRunning(pid) {
+		return false
+	}
+	// Only remove the file if nobody holds the lock, e.g. a process that has just
+	// locked it but not yet written its PID
+	f, err := acquireLock(lockPath)
+	if err != nil {
+		return false
+	}
+	releaseLock(lockPath, f)
+	return true
+}
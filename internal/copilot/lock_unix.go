@@ -0,0 +1,48 @@
+//go:build !windows
+
+package copilot
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// acquireLock takes the lock for token refresh with flock(2) on the lock file at
+// lockPath, without waiting. The kernel releases the lock when the process exits, so a
+// crash cannot leave it held. The lock file records the PID of the holder.
+func acquireLock(lockPath string) (*os.File, error) {
+	f, err := openLockFile(lockPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := checkLockFile(f, lockPath); err != nil {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// releaseLock removes the lock file and releases the lock. The file is removed while
+// still locked so that no other process can lock it after it is gone.
+func releaseLock(lockPath string, f *os.File) {
+	_ = os.Remove(lockPath)
+	_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	_ = f.Close()
+}
+
+// processRunning reports whether a process with the given PID exists.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 only checks that the process exists; EPERM means it belongs to another user
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
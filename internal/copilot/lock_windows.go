@@ -0,0 +1,49 @@
+//go:build windows
+
+package copilot
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// acquireLock takes the lock for token refresh with LockFileEx on the lock file at
+// lockPath, without waiting. Windows releases the lock when the process exits, so a
+// crash cannot leave it held. The lock file records the PID of the holder.
+func acquireLock(lockPath string) (*os.File, error) {
+	f, err := openLockFile(lockPath)
+	if err != nil {
+		return nil, err
+	}
+	ol := new(windows.Overlapped)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, ol); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := checkLockFile(f, lockPath); err != nil {
+		_ = windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, ol)
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// releaseLock releases the lock and removes the lock file. Windows cannot remove open
+// files, so unlike on Unix the file is removed after it is closed.
+func releaseLock(lockPath string, f *os.File) {
+	_ = windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, new(windows.Overlapped))
+	_ = f.Close()
+	_ = os.Remove(lockPath)
+}
+
+// processRunning reports whether a process with the given PID exists.
+func processRunning(pid int) bool {
+	// FindProcess opens the process and fails if it does not exist
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
@@ -0,0 +1,52 @@
+package copilot
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// tokenMetrics holds the Prometheus metrics of a TokenManager.
+type tokenMetrics struct {
+	refreshes *prometheus.CounterVec
+	lockWait  prometheus.Histogram
+}
+
+// newTokenMetrics registers the token metrics of tm with registry.
+func newTokenMetrics(registry prometheus.Registerer, tm *TokenManager) *tokenMetrics {
+	m := &tokenMetrics{
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "copilot_token_refresh_total",
+			Help: "Copilot token refreshes by result.",
+		}, []string{"result"}),
+		lockWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "copilot_token_lock_wait_seconds",
+			Help:    "Time spent waiting for the token file lock before a refresh.",
+			Buckets: []float64{0.001, 0.01, 0.1, 1, 2, 5, 10},
+		}),
+	}
+	expiry := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "copilot_token_expiry_seconds",
+		Help: "Seconds until the current Copilot token expires; negative once expired, 0 without a token.",
+	}, func() float64 {
+		expiresAt := tm.TokenExpiry()
+		if expiresAt.IsZero() {
+			return 0
+		}
+		return time.Until(expiresAt).Seconds()
+	})
+	// Report both results from the start so rates work before the first failure
+	m.refreshes.WithLabelValues("success")
+	m.refreshes.WithLabelValues("failure")
+	registry.MustRegister(m.refreshes, m.lockWait, expiry)
+	return m
+}
+
+// observeRefresh counts a refresh attempt that ended with err.
+func (m *tokenMetrics) observeRefresh(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.refreshes.WithLabelValues(result).Inc()
+}
@@ -0,0 +1,139 @@
+package copilot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// aliasFilePollInterval is how often WatchAliasFile checks the alias file for changes.
+const aliasFilePollInterval = 500 * time.Millisecond
+
+// AtomicAliasMap holds model aliases that can be replaced while requests resolve them.
+type AtomicAliasMap struct {
+	mu sync.RWMutex
+	m  map[string]string
+}
+
+// NewAtomicAliasMap returns an AtomicAliasMap holding m, which must not be modified
+// afterwards.
+func NewAtomicAliasMap(m map[string]string) *AtomicAliasMap {
+	return &AtomicAliasMap{m: m}
+}
+
+// Get returns the model from is an alias of, or "" if it is not an alias.
+func (a *AtomicAliasMap) Get(from string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.m[from]
+}
+
+// Swap replaces all aliases with m, which must not be modified afterwards.
+func (a *AtomicAliasMap) Swap(m map[string]string) {
+	a.mu.Lock()
+	a.m = m
+	a.mu.Unlock()
+}
+
+// Map returns the current aliases, which must not be modified. It is nil for a nil
+// AtomicAliasMap.
+func (a *AtomicAliasMap) Map() map[string]string {
+	if a == nil {
+		return nil
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.m
+}
+
+// WatchAliasFile replaces the aliases with the JSON object in path whenever the file
+// changes, until ctx is done. The file is expected to have been loaded already. A file
+// that cannot be read or parsed is logged and leaves the aliases unchanged.
+func WatchAliasFile(ctx context.Context, path string, aliases *AtomicAliasMap) {
+	var lastMod time.Time
+	var lastSize int64
+	if info, err := os.Stat(path); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
+	}
+	for {
+		if sleepContext(ctx, aliasFilePollInterval) != nil {
+			return
+		}
+		info, err := os.Stat(path)
+		if err != nil || (info.ModTime().Equal(lastMod) && info.Size() == lastSize) {
+			continue
+		}
+		lastMod, lastSize = info.ModTime(), info.Size()
+		m, err := loadAliasFile(path)
+		if err != nil {
+			log.Printf("Warning: keeping the current model aliases: %v", err)
+			continue
+		}
+		aliases.Swap(m)
+		log.Printf("Reloaded %d model aliases from %s", len(m), path)
+	}
+}
+
+// loadAliasFile reads a JSON object of alias targets from path.
+func loadAliasFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// WithModelAliases checks the targets of aliases against the models list after every
+// successful refresh and logs a warning for each one that is not listed.
+func WithModelAliases(aliases map[string]string) ModelsCacheOption {
+	return WithAliasMap(NewAtomicAliasMap(aliases))
+}
+
+// WithAliasMap is WithModelAliases for aliases that may be replaced at runtime; the
+// current ones are checked after every refresh.
+func WithAliasMap(aliases *AtomicAliasMap) ModelsCacheOption {
+	return func(c *ModelsCache) {
+		c.aliases = aliases
+	}
+}
+
+// ValidateModelAliases returns an error for every alias whose target model is not in
+// cache, ordered by alias. Targets are matched like Lookup, so the publisher prefix is
+// optional. The models list is fetched if the cache is empty; an error fetching it is
+// returned as the only error.
+func ValidateModelAliases(ctx context.Context, aliases map[string]string, cache *ModelsCache) []error {
+	if len(aliases) == 0 {
+		return nil
+	}
+	if _, err := cache.ListModels(ctx); err != nil {
+		return []error{fmt.Errorf("cannot validate model aliases: %w", err)}
+	}
+	return cache.unknownAliasTargets(aliases)
+}
+
+// unknownAliasTargets is ValidateModelAliases for the models already cached. refresh
+// uses it because ListModels may start another refresh.
+func (c *ModelsCache) unknownAliasTargets(aliases map[string]string) []error {
+	names := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, alias := range names {
+		if target := aliases[alias]; !c.HasModel(target) {
+			errs = append(errs, fmt.Errorf("model alias %q targets %q, which is not in the models list", alias, target))
+		}
+	}
+	return errs
+}
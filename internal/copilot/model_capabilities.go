@@ -0,0 +1,81 @@
+package copilot
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Capabilities accepted by GetModelsByCapability and Model.Supports.
+const (
+	// CapabilityVision is a model taking image input.
+	CapabilityVision = "vision"
+	// CapabilityFunctionCalling is a model supporting tools.
+	CapabilityFunctionCalling = "function_calling"
+	// CapabilityStreaming is a model supporting streamed responses.
+	CapabilityStreaming = "streaming"
+	// CapabilityJSONMode is a model honouring response_format json_object natively.
+	CapabilityJSONMode = "json_mode"
+	// CapabilityLongContext is a model with a context window of over 100k tokens.
+	CapabilityLongContext = "long_context"
+)
+
+// longContextTokens is the context window above which a model has CapabilityLongContext.
+const longContextTokens = 100_000
+
+// capabilityTests maps each capability to a test of a model. Most capabilities are
+// names in the catalog capabilities list; vision comes from the input modalities and
+// long context from the limits.
+var capabilityTests = map[string]func(Model) bool{
+	CapabilityVision:          func(m Model) bool { return slices.Contains(m.SupportedInputModalities, "image") },
+	CapabilityFunctionCalling: func(m Model) bool { return m.HasCapability("tool-calling") },
+	CapabilityStreaming:       func(m Model) bool { return m.HasCapability("streaming") },
+	CapabilityJSONMode:        func(m Model) bool { return m.HasCapability("json-mode") },
+	CapabilityLongContext:     func(m Model) bool { return m.Limits.MaxInputTokens > longContextTokens },
+}
+
+// Supports reports whether the model has capability, one of the Capability constants.
+// Unknown capabilities are not supported.
+func (m Model) Supports(capability string) bool {
+	test := capabilityTests[capability]
+	return test != nil && test(m)
+}
+
+// GetModelsByCapability returns the cached models having every capability of caps, all
+// cached models if caps is empty. It does not refresh the cache.
+func (c *ModelsCache) GetModelsByCapability(caps ...string) ([]Model, error) {
+	return c.filterByCapability(caps, func(m Model) bool {
+		for _, capability := range caps {
+			if !m.Supports(capability) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// GetModelsByAnyCapability returns the cached models having at least one capability of
+// caps, none if caps is empty. It does not refresh the cache.
+func (c *ModelsCache) GetModelsByAnyCapability(caps ...string) ([]Model, error) {
+	return c.filterByCapability(caps, func(m Model) bool {
+		return slices.ContainsFunc(caps, m.Supports)
+	})
+}
+
+// filterByCapability returns the cached models matching match, after checking that caps
+// are known capabilities. The result is never nil.
+func (c *ModelsCache) filterByCapability(caps []string, match func(Model) bool) ([]Model, error) {
+	for _, capability := range caps {
+		if capabilityTests[capability] == nil {
+			return nil, fmt.Errorf("unknown model capability %q", capability)
+		}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	models := []Model{}
+	for _, m := range c.models {
+		if match(m) {
+			models = append(models, m)
+		}
+	}
+	return models, nil
+}
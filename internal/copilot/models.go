@@ -6,36 +6,171 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
+
+	"copilot-api/internal/version"
 )
 
+// defaultModelsURL is the GitHub Models catalog endpoint.
+const defaultModelsURL = "https://models.github.ai/catalog/models"
+
+// Model is a single entry of the GitHub Models catalog.
+type Model struct {
+	ID                        string      `json:"id"`
+	Name                      string      `json:"name"`
+	Publisher                 string      `json:"publisher"`
+	Summary                   string      `json:"summary"`
+	RateLimitTier             string      `json:"rate_limit_tier"`
+	SupportedInputModalities  []string    `json:"supported_input_modalities"`
+	SupportedOutputModalities []string    `json:"supported_output_modalities"`
+	Tags                      []string    `json:"tags"`
+	Registry                  string      `json:"registry"`
+	Version                   string      `json:"version"`
+	Capabilities              []string    `json:"capabilities"`
+	Limits                    ModelLimits `json:"limits"`
+	HTMLURL                   string      `json:"html_url"`
+}
+
+// HasCapability reports whether the model lists capability, e.g. "tool-calling".
+func (m Model) HasCapability(capability string) bool {
+	for _, c := range m.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelLimits holds the token limits of a model.
+type ModelLimits struct {
+	MaxInputTokens  int `json:"max_input_tokens"`
+	MaxOutputTokens int `json:"max_output_tokens"`
+}
+
 // ModelsCache holds the cached models list and manages refresh.
 type ModelsCache struct {
 	mu         sync.RWMutex
 	modelsJSON []byte
+	models     []Model
 	lastFetch  time.Time
 	ttl        time.Duration
 	apiToken   string
+	modelsURL  string
+	userAgent  string
+	aliases    *AtomicAliasMap
+	cfg        ModelsConfig
+
+	copilotModelsURL string
+	copilotToken     TokenFunc
+	mergeModels      string
+	redis            *RedisModelsCache
+
+	refreshCtx    context.Context
+	refreshCancel context.CancelFunc
+	refreshWG     sync.WaitGroup
+}
+
+// ModelsConfig holds the timeouts of models list fetches. Zero values mean no timeout
+// beyond the 15-second limit of each HTTP request.
+type ModelsConfig struct {
+	// FetchTimeout limits fetching the models list while a caller waits for it, which
+	// happens when the cache is empty.
+	FetchTimeout time.Duration
+	// RefreshTimeout limits background refreshes of an expired cache.
+	RefreshTimeout time.Duration
+}
+
+// ModelsCacheOption configures optional ModelsCache behavior.
+type ModelsCacheOption func(*ModelsCache)
+
+// WithModelsURL overrides the catalog endpoint the models list is fetched from.
+func WithModelsURL(url string) ModelsCacheOption {
+	return func(c *ModelsCache) {
+		c.modelsURL = url
+	}
+}
+
+// WithModelsUserAgent sets the User-Agent sent when fetching the models list.
+func WithModelsUserAgent(ua string) ModelsCacheOption {
+	return func(c *ModelsCache) {
+		if ua != "" {
+			c.userAgent = ua
+		}
+	}
+}
+
+// WithModelsConfig sets the fetch and refresh timeouts of the cache.
+func WithModelsConfig(cfg ModelsConfig) ModelsCacheOption {
+	return func(c *ModelsCache) {
+		c.cfg = cfg
+	}
 }
 
 // NewModelsCache creates a new ModelsCache and fetches models on startup.
 // apiToken is your Copilot (GitHub) token for authentication.
-func NewModelsCache(ctx context.Context, apiToken string, ttl time.Duration) (*ModelsCache, error) {
-	cache := &ModelsCache{
-		ttl:      ttl,
-		apiToken: apiToken,
-	}
+func NewModelsCache(ctx context.Context, apiToken string, ttl time.Duration, opts ...ModelsCacheOption) (*ModelsCache, error) {
+	cache := newModelsCache(apiToken, ttl, opts...)
 	if err := cache.refresh(ctx); err != nil {
+		cache.Close()
 		return nil, err
 	}
+	cache.startRefreshLoop(cache.refreshDue())
 	return cache, nil
 }
 
+// NewEmptyModelsCache creates a ModelsCache without fetching the models list. The list
+// is fetched on the first GetModels call, or by the background refresh a tenth of the
+// TTL later; use it when the startup fetch failed.
+func NewEmptyModelsCache(apiToken string, ttl time.Duration, opts ...ModelsCacheOption) *ModelsCache {
+	cache := newModelsCache(apiToken, ttl, opts...)
+	cache.startRefreshLoop(time.Now().Add(ttl / 10))
+	return cache
+}
+
+// newModelsCache creates a ModelsCache without starting its background refresh.
+func newModelsCache(apiToken string, ttl time.Duration, opts ...ModelsCacheOption) *ModelsCache {
+	cache := &ModelsCache{
+		ttl:       ttl,
+		apiToken:  apiToken,
+		modelsURL: defaultModelsURL,
+		userAgent: version.UserAgent(),
+	}
+	for _, opt := range opts {
+		opt(cache)
+	}
+	cache.refreshCtx, cache.refreshCancel = context.WithCancel(context.Background())
+	return cache
+}
+
+// Close cancels background refreshes and waits for them to finish.
+func (c *ModelsCache) Close() {
+	c.refreshCancel()
+	c.refreshWG.Wait()
+}
+
 // GetModels returns the cached models JSON. If expired, it refreshes in the background.
+// An empty cache is filled synchronously, within the configured FetchTimeout.
 func (c *ModelsCache) GetModels(ctx context.Context) ([]byte, error) {
+	return c.GetModelsWithForce(ctx, false)
+}
+
+// GetModelsWithForce is GetModels, but with force it refetches the models list
+// synchronously regardless of the cache age, like ForceRefresh.
+func (c *ModelsCache) GetModelsWithForce(ctx context.Context, force bool) ([]byte, error) {
+	if force {
+		if _, err := c.ForceRefresh(ctx); err != nil {
+			return nil, fmt.Errorf("models not available: %w", err)
+		}
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return c.modelsJSON, nil
+	}
+
 	c.mu.RLock()
 	models := c.modelsJSON
 	expired := time.Since(c.lastFetch) > c.ttl
@@ -44,51 +179,257 @@ func (c *ModelsCache) GetModels(ctx context.Context) ([]byte, error) {
 	if !expired && len(models) > 0 {
 		return models, nil
 	}
+	if len(models) == 0 {
+		if c.cfg.FetchTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.cfg.FetchTimeout)
+			defer cancel()
+		}
+		if err := c.refresh(ctx); err != nil {
+			return nil, fmt.Errorf("models not available: %w", err)
+		}
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return c.modelsJSON, nil
+	}
 
 	// Refresh in background if expired, but return stale data if available
-	go c.refresh(context.Background())
-	if len(models) > 0 {
-		return models, nil
+	c.refreshWG.Add(1)
+	go func() {
+		defer c.refreshWG.Done()
+		ctx := c.refreshCtx
+		if c.cfg.RefreshTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, c.cfg.RefreshTimeout)
+			defer cancel()
+		}
+		_ = c.refresh(ctx)
+	}()
+	return models, nil
+}
+
+// ListModels returns the cached models, refreshing them like GetModels.
+func (c *ModelsCache) ListModels(ctx context.Context) ([]Model, error) {
+	if _, err := c.GetModels(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.models, nil
+}
+
+// ForceRefresh discards the cache age and synchronously refetches the models list
+// through the same refresh path as the background refresh. With WithRedisCache the list
+// is fetched upstream too and replaces the one in Redis. It returns the number of models
+// in the refreshed cache.
+func (c *ModelsCache) ForceRefresh(ctx context.Context) (int, error) {
+	c.mu.Lock()
+	c.lastFetch = time.Time{}
+	c.mu.Unlock()
+	if err := c.refreshFrom(ctx, true); err != nil {
+		return 0, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.models), nil
+}
+
+// Lookup returns the cached model with the given ID. The publisher prefix of catalog
+// IDs is optional, so "gpt-4o" matches "openai/gpt-4o".
+func (c *ModelsCache) Lookup(id string) (Model, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, m := range c.models {
+		if m.ID == id {
+			return m, true
+		}
 	}
-	return nil, errors.New("models not available")
+	for _, m := range c.models {
+		if _, name, ok := strings.Cut(m.ID, "/"); ok && name == id {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+// HasModel reports whether id is a cached model, matched as by Lookup.
+func (c *ModelsCache) HasModel(id string) bool {
+	_, ok := c.Lookup(id)
+	return ok
+}
+
+// ModelCount returns the number of cached models.
+func (c *ModelsCache) ModelCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.models)
 }
 
-// refresh fetches the models list from the GitHub Models API.
+// LastFetch returns when the models list was last fetched successfully.
+func (c *ModelsCache) LastFetch() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastFetch
+}
+
+// startRefreshLoop starts refreshLoop, making its first refresh at next.
+func (c *ModelsCache) startRefreshLoop(next time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.refreshWG.Add(1)
+	go c.refreshLoop(c.refreshCtx, next)
+}
+
+// refreshDue returns when the cached list should be refreshed ahead of expiry: once 90%
+// of the TTL has passed since the last fetch.
+func (c *ModelsCache) refreshDue() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastFetch.Add(c.ttl - c.ttl/10)
+}
+
+// refreshLoop refreshes the models list before the cache expires, so that requests are
+// not the first to notice a stale list. A failed refresh is retried a tenth of the TTL
+// later.
+func (c *ModelsCache) refreshLoop(ctx context.Context, next time.Time) {
+	defer c.refreshWG.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		// A request or ForceRefresh may have refreshed the list in the meantime
+		if due := c.refreshDue(); time.Now().Before(due) {
+			next = due
+			continue
+		}
+		refreshCtx := ctx
+		var cancel context.CancelFunc = func() {}
+		if c.cfg.RefreshTimeout > 0 {
+			refreshCtx, cancel = context.WithTimeout(ctx, c.cfg.RefreshTimeout)
+		}
+		err := c.refresh(refreshCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Warning: background models refresh failed: %v", err)
+			}
+			next = time.Now().Add(c.ttl / 10)
+			continue
+		}
+		next = c.refreshDue()
+	}
+}
+
+// refresh fetches the models list from the GitHub Models API, or from the Copilot API
+// if WithCopilotModelsEndpoint is used. With WithRedisCache the list is taken from Redis
+// if another instance stored it there.
 func (c *ModelsCache) refresh(ctx context.Context) error {
-	const modelsURL = "https://models.github.ai/catalog/models"
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, modelsURL, nil)
+	return c.refreshFrom(ctx, false)
+}
+
+// refreshFrom is refresh; with force a list stored in Redis is ignored and replaced.
+func (c *ModelsCache) refreshFrom(ctx context.Context, force bool) error {
+	var data []byte
+	var models []Model
+	var err error
+	if c.redis == nil {
+		data, models, err = c.fetch(ctx)
+	} else if data, err = c.redis.getModels(ctx, force); err == nil {
+		models, err = parseModels(data)
+	}
 	if err != nil {
 		return err
 	}
+
+	c.mu.Lock()
+	c.modelsJSON = data
+	c.models = models
+	c.lastFetch = time.Now()
+	c.mu.Unlock()
+
+	for _, err := range c.unknownAliasTargets(c.aliases.Map()) {
+		log.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+// fetch fetches the models list upstream and returns it in the catalog format along
+// with the parsed models.
+func (c *ModelsCache) fetch(ctx context.Context) ([]byte, []Model, error) {
+	if c.copilotModelsURL == "" {
+		data, err := c.fetchCatalog(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		models, err := parseModels(data)
+		if err != nil {
+			return nil, nil, err
+		}
+		return data, models, nil
+	}
+	models, err := c.fetchCopilotModels(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.mergeModels != MergeNone {
+		catalogData, err := c.fetchCatalog(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		catalog, err := parseModels(catalogData)
+		if err != nil {
+			return nil, nil, err
+		}
+		models = mergeModelLists(models, catalog, c.mergeModels)
+	}
+	// Keep the catalog format so that SaveToFile and LoadFromFile work alike
+	data, err := json.Marshal(models)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, models, nil
+}
+
+// fetchCatalog fetches the models JSON of the GitHub Models catalog.
+func (c *ModelsCache) fetchCatalog(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.modelsURL, nil)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Accept", "application/vnd.github+json")
 	req.Header.Set("Authorization", "Bearer "+c.apiToken)
 	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", c.userAgent)
 
 	client := &http.Client{Timeout: 15 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to fetch models: %w", err)
+		return nil, fmt.Errorf("failed to fetch models: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("models API error: %s - %s", resp.Status, string(body))
+		return nil, fmt.Errorf("models API error: %s - %s", resp.Status, string(body))
 	}
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
+	return io.ReadAll(resp.Body)
+}
+
+// parseModels decodes the catalog JSON into typed models. Entries missing required
+// fields are logged so that upstream schema changes are noticed early.
+func parseModels(data []byte) ([]Model, error) {
+	var models []Model
+	if err := json.Unmarshal(data, &models); err != nil {
+		return nil, fmt.Errorf("invalid models JSON: %w", err)
 	}
-	// Validate JSON
-	var js []interface{}
-	if err := json.Unmarshal(data, &js); err != nil {
-		return fmt.Errorf("invalid models JSON: %w", err)
+	for i, m := range models {
+		if m.ID == "" || m.Name == "" {
+			log.Printf("Warning: models API entry %d is missing id or name; the catalog schema may have changed", i)
+		}
 	}
-
-	c.mu.Lock()
-	c.modelsJSON = data
-	c.lastFetch = time.Now()
-	c.mu.Unlock()
-	return nil
+	return models, nil
 }
 
 // SaveToFile writes the cached models JSON to a file (optional).
@@ -107,13 +448,13 @@ func (c *ModelsCache) LoadFromFile(path string) error {
 	if err != nil {
 		return err
 	}
-	// Validate JSON
-	var js []interface{}
-	if err := json.Unmarshal(data, &js); err != nil {
-		return fmt.Errorf("invalid models JSON: %w", err)
+	models, err := parseModels(data)
+	if err != nil {
+		return err
 	}
 	c.mu.Lock()
 	c.modelsJSON = data
+	c.models = models
 	c.lastFetch = time.Now()
 	c.mu.Unlock()
 	return nil
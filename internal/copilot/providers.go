@@ -0,0 +1,20 @@
+package copilot
+
+import "strings"
+
+// GetByProvider groups the cached models by publisher, keeping catalog order within
+// each group. Models without a publisher are grouped by the prefix of their ID, e.g.
+// "openai" for "openai/gpt-4o".
+func (c *ModelsCache) GetByProvider() map[string][]Model {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	providers := make(map[string][]Model)
+	for _, m := range c.models {
+		provider := m.Publisher
+		if provider == "" {
+			provider, _, _ = strings.Cut(m.ID, "/")
+		}
+		providers[provider] = append(providers[provider], m)
+	}
+	return providers
+}
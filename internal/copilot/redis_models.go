@@ -0,0 +1,190 @@
+package copilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// redisModelsKey is the Redis key the models list is stored under.
+	redisModelsKey = "copilot-api:models"
+	// redisModelsLockKey is held by the instance fetching a missing models list.
+	redisModelsLockKey = "copilot-api:models:lock"
+	// redisFillLockTTL bounds how long other instances wait for the lock holder; it is
+	// longer than an upstream fetch may take.
+	redisFillLockTTL = 30 * time.Second
+	// redisFillPoll is how often waiting instances look for the list.
+	redisFillPoll = 100 * time.Millisecond
+)
+
+// ErrRedisUnreachable is returned by NewRedisModelsCache if the Redis server does not
+// answer.
+var ErrRedisUnreachable = errors.New("redis unreachable")
+
+// releaseFillLock deletes the fill lock if it is still held by the owner passed in
+// ARGV[1], so an instance whose lock expired does not release another's.
+var releaseFillLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// RedisModelsCache keeps the models JSON in Redis, so that the instances of a
+// horizontally scaled deployment share one models list. When it is missing, a lock key
+// lets one instance fetch it upstream while the others wait for it.
+type RedisModelsCache struct {
+	client  *redis.Client
+	ttl     time.Duration
+	fetcher *ModelsCache // fetches the list upstream on a miss
+	group   singleflight.Group
+}
+
+// NewRedisModelsCache connects to the Redis server at redisAddr, a host:port or a
+// redis:// URL, and returns a RedisModelsCache storing the models list there for ttl; a
+// ttl of zero stores it without expiry. On a miss the list is fetched upstream with
+// apiToken and opts, as by NewModelsCache.
+func NewRedisModelsCache(ctx context.Context, redisAddr, apiToken string, ttl time.Duration, opts ...ModelsCacheOption) (*RedisModelsCache, error) {
+	redisOpts := &redis.Options{Addr: redisAddr}
+	if strings.Contains(redisAddr, "://") {
+		var err error
+		if redisOpts, err = redis.ParseURL(redisAddr); err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %w", err)
+		}
+	}
+	client := redis.NewClient(redisOpts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%w at %s: %w", ErrRedisUnreachable, redisOpts.Addr, err)
+	}
+	return &RedisModelsCache{client: client, ttl: ttl, fetcher: newModelsCache(apiToken, ttl, opts...)}, nil
+}
+
+// WithRedisCache puts the in-memory cache in front of r: refreshes take the models list
+// from Redis and only fetch it upstream when Redis does not hold it.
+func WithRedisCache(r *RedisModelsCache) ModelsCacheOption {
+	return func(c *ModelsCache) {
+		c.redis = r
+	}
+}
+
+// Close closes the Redis connection.
+func (r *RedisModelsCache) Close() {
+	r.fetcher.Close()
+	_ = r.client.Close()
+}
+
+// GetModels returns the models JSON stored in Redis. On a miss it is fetched upstream
+// and stored for the TTL.
+func (r *RedisModelsCache) GetModels(ctx context.Context) ([]byte, error) {
+	return r.getModels(ctx, false)
+}
+
+// getModels is GetModels; with force the list is fetched upstream even if Redis holds
+// it. If Redis cannot be reached the list is fetched upstream as well.
+func (r *RedisModelsCache) getModels(ctx context.Context, force bool) ([]byte, error) {
+	if !force {
+		if data, ok := r.get(ctx); ok {
+			return data, nil
+		}
+	}
+	// Requests missing at once share a single upstream fetch, which is not canceled
+	// with the first caller's ctx. The HTTP client and Redis timeouts bound it.
+	ch := r.group.DoChan(strconv.FormatBool(force), func() (interface{}, error) {
+		return r.fill(context.WithoutCancel(ctx), force)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// get returns the models JSON stored in Redis and whether there was one.
+func (r *RedisModelsCache) get(ctx context.Context) ([]byte, bool) {
+	data, err := r.client.Get(ctx, redisModelsKey).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		log.Printf("Warning: failed to read the models list from Redis: %v", err)
+	}
+	return data, err == nil
+}
+
+// fill fetches the models list upstream and stores it in Redis. Unless force is set,
+// instances coordinate through a lock key: the one taking it fetches the list, and the
+// others wait for it to appear, fetching it themselves only if it does not in time.
+func (r *RedisModelsCache) fill(ctx context.Context, force bool) ([]byte, error) {
+	if !force {
+		owner := strconv.FormatInt(rand.Int64(), 36)
+		locked, err := r.client.SetNX(ctx, redisModelsLockKey, owner, redisFillLockTTL).Result()
+		if err == nil && locked {
+			defer releaseFillLock.Run(context.WithoutCancel(ctx), r.client, []string{redisModelsLockKey}, owner)
+		} else if err == nil {
+			if data, ok := r.waitForFill(ctx); ok {
+				return data, nil
+			}
+		}
+	}
+	data, _, err := r.fetcher.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.client.Set(ctx, redisModelsKey, data, r.ttl).Err(); err != nil {
+		log.Printf("Warning: failed to store the models list in Redis: %v", err)
+	}
+	return data, nil
+}
+
+// waitForFill waits up to redisFillLockTTL for the instance holding the fill lock to
+// store the models list. It gives up early once the lock is released without a list.
+func (r *RedisModelsCache) waitForFill(ctx context.Context) ([]byte, bool) {
+	deadline := time.Now().Add(redisFillLockTTL)
+	for time.Now().Before(deadline) {
+		if sleepContext(ctx, redisFillPoll) != nil {
+			return nil, false
+		}
+		if data, ok := r.get(ctx); ok {
+			return data, true
+		}
+		if n, err := r.client.Exists(ctx, redisModelsLockKey).Result(); err != nil || n == 0 {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// SaveToFile writes the models JSON stored in Redis to a file.
+func (r *RedisModelsCache) SaveToFile(path string) error {
+	data, err := r.client.Get(context.Background(), redisModelsKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return errors.New("no models to save")
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadFromFile stores the models JSON of a file in Redis for the TTL.
+func (r *RedisModelsCache) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err := parseModels(data); err != nil {
+		return err
+	}
+	return r.client.Set(context.Background(), redisModelsKey, data, r.ttl).Err()
+}
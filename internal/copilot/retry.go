@@ -0,0 +1,89 @@
+package copilot
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryConfig sets the exponential backoff of TokenManager refreshes: between attempts
+// to take the token file lock, which other processes may hold, and between failed
+// background refreshes. Each delay doubles up to its maximum, with a random part so
+// competing processes spread out.
+type RetryConfig struct {
+	InitialDelay time.Duration // First delay between lock attempts (default: 200ms)
+	MaxDelay     time.Duration // Largest delay between lock attempts (default: 10s)
+	// LockBudget is how long a refresh keeps retrying the lock before waiting for the
+	// holder to write a new token instead (default: 5s)
+	LockBudget time.Duration
+	// RefreshInitialDelay is the least time between failed background refreshes
+	// (default: 30s)
+	RefreshInitialDelay time.Duration
+	// RefreshMaxDelay is the largest time between failed background refreshes, and the
+	// time waited right away when the token endpoint rejects the OAuth token with 401
+	// or 403, which retrying soon does not fix (default: 5m)
+	RefreshMaxDelay time.Duration
+}
+
+// DefaultRetryConfig is the RetryConfig of a TokenManager without WithRetryConfig.
+var DefaultRetryConfig = RetryConfig{
+	InitialDelay: 200 * time.Millisecond,
+	MaxDelay:     10 * time.Second,
+	LockBudget:   5 * time.Second,
+
+	RefreshInitialDelay: 30 * time.Second,
+	RefreshMaxDelay:     5 * time.Minute,
+}
+
+// WithRetryConfig sets the backoff of token refreshes. Zero fields keep their defaults.
+func WithRetryConfig(cfg RetryConfig) TokenManagerOption {
+	return func(tm *TokenManager) {
+		if cfg.InitialDelay > 0 {
+			tm.retry.InitialDelay = cfg.InitialDelay
+		}
+		if cfg.MaxDelay > 0 {
+			tm.retry.MaxDelay = cfg.MaxDelay
+		}
+		if cfg.LockBudget > 0 {
+			tm.retry.LockBudget = cfg.LockBudget
+		}
+		if cfg.RefreshInitialDelay > 0 {
+			tm.retry.RefreshInitialDelay = cfg.RefreshInitialDelay
+		}
+		if cfg.RefreshMaxDelay > 0 {
+			tm.retry.RefreshMaxDelay = cfg.RefreshMaxDelay
+		}
+	}
+}
+
+// delay returns the backoff before retry number attempt, counted from 0: half of the
+// exponential delay plus a random duration up to the other half.
+func (c RetryConfig) delay(attempt int) time.Duration {
+	d := c.InitialDelay
+	for i := 0; i < attempt && d < c.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, c.MaxDelay)
+	if d <= 1 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// refreshDelay returns how long the background loop waits after failures failed
+// refreshes in a row, the last ending with err. The delay doubles from
+// RefreshInitialDelay up to RefreshMaxDelay, plus up to a tenth at random, and is
+// RefreshMaxDelay at once for a rejected OAuth token.
+func (c RetryConfig) refreshDelay(failures int, err error) time.Duration {
+	d := c.RefreshInitialDelay
+	for i := 1; i < failures && d < c.RefreshMaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, c.RefreshMaxDelay)
+	if isAuthRejected(err) {
+		d = c.RefreshMaxDelay
+	}
+	if d < 10 {
+		return d
+	}
+	return d + rand.N(d/10)
+}
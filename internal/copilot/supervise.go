@@ -0,0 +1,59 @@
+package copilot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Restart delays of SupervisedGoroutine. The delay doubles after every failure and is
+// reset once the goroutine has run for longer than the largest delay.
+const (
+	supervisorMinBackoff = time.Second
+	supervisorMaxBackoff = time.Minute
+)
+
+// SupervisedGoroutine runs fn in a new goroutine and restarts it if it panics or returns
+// before ctx is done, so a failed background loop cannot silently stop. Panics are
+// recovered and logged with their stack; restarts wait 1s, doubling up to a minute. wg
+// is done once fn has returned after ctx is done.
+func SupervisedGoroutine(ctx context.Context, name string, fn func(context.Context), wg *sync.WaitGroup) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		backoff := supervisorMinBackoff
+		for {
+			started := time.Now()
+			err := runRecovered(ctx, fn)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.Error("background goroutine panicked", "goroutine", name, "error", err, "restart_in", backoff)
+			} else {
+				slog.Warn("background goroutine exited", "goroutine", name, "restart_in", backoff)
+			}
+			if time.Since(started) > supervisorMaxBackoff {
+				backoff = supervisorMinBackoff
+			}
+			if sleepContext(ctx, backoff) != nil {
+				return
+			}
+			backoff = min(backoff*2, supervisorMaxBackoff)
+		}
+	}()
+}
+
+// runRecovered calls fn, returning the panic it raised as an error.
+func runRecovered(ctx context.Context, fn func(context.Context)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v\n%s", r, debug.Stack())
+		}
+	}()
+	fn(ctx)
+	return nil
+}
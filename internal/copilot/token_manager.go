@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -12,12 +13,56 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+
+	"copilot-api/internal/version"
 )
 
+// defaultGitHubHost is the host of github.com OAuth tokens in the Copilot config files.
+const defaultGitHubHost = "github.com"
+
+// defaultExpiryGrace is how long before expiry a token stops being handed out.
+const defaultExpiryGrace = 120 * time.Second
+
+// defaultPrewarm is how long before expiry the background loop refreshes the token.
+const defaultPrewarm = 5 * time.Minute
+
+// maxSubscribers is the most refresh event channels a TokenManager hands out.
+const maxSubscribers = 16
+
 // CopilotToken holds the structure of the Copilot token as stored in token.json.
+// Besides the token, the token endpoint describes the account's Copilot license.
 type CopilotToken struct {
 	Token     string  `json:"token"`
 	ExpiresAt float64 `json:"expires_at"`
+
+	RefreshIn       int    `json:"refresh_in,omitempty"`
+	SKU             string `json:"sku,omitempty"`
+	Individual      *bool  `json:"individual,omitempty"`
+	EnterpriseTrial bool   `json:"enterprise_trial,omitempty"`
+	ChatEnabled     *bool  `json:"chat_enabled,omitempty"`
+
+	// Fields holds every field of the token response, including those above.
+	Fields map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON decodes a token response, keeping all of its fields in Fields.
+func (t *CopilotToken) UnmarshalJSON(data []byte) error {
+	type plain CopilotToken
+	if err := json.Unmarshal(data, (*plain)(t)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &t.Fields)
+}
+
+// TokenRefreshEvent reports the outcome of a token refresh. Error is nil for a
+// successful refresh, in which case Token and ExpiresAt describe the new token.
+type TokenRefreshEvent struct {
+	Token     string
+	ExpiresAt time.Time
+	Error     error
 }
 
 // TokenManager manages Copilot OAuth and GitHub tokens, handles refresh, file lock, and concurrency.
@@ -28,39 +73,171 @@ type TokenManager struct {
 	configDir     string
 	tokenFile     string
 	authURL       string
+	refreshCtx    context.Context // canceled by Close
 	refreshCancel context.CancelFunc
 	refreshWG     sync.WaitGroup
+	refreshGroup  singleflight.Group
 	isSelfWriting bool
+	prewarm       time.Duration
+	expiryGrace   time.Duration
+	retry         RetryConfig
+	githubHost    string
+	userAgent     string
+	userURL       string
+	hostsFile     string
+	usernameMu    sync.Mutex
+	username      string
+	registry      prometheus.Registerer
+	metrics       *tokenMetrics
+
+	legacyConfigFile string
+	migrateOldConfig bool
+
+	subMu       sync.Mutex
+	subscribers []chan TokenRefreshEvent
+	closed      bool
+}
+
+// TokenManagerOption configures optional TokenManager behavior.
+type TokenManagerOption func(*TokenManager)
+
+// WithPrewarm sets how long before expiry the background loop refreshes the token,
+// so requests never wait on a refresh while the old token is still valid.
+func WithPrewarm(d time.Duration) TokenManagerOption {
+	return func(tm *TokenManager) {
+		tm.prewarm = d
+	}
+}
+
+// WithExpiryGrace sets how long before expiry a token is considered expired, so it does
+// not run out during a slow upstream call.
+func WithExpiryGrace(d time.Duration) TokenManagerOption {
+	return func(tm *TokenManager) {
+		if d < 0 {
+			d = 0
+		}
+		tm.expiryGrace = d
+	}
+}
+
+// WithGitHubHost selects the GitHub host whose OAuth token is used. For a GitHub
+// Enterprise Server host the token endpoint is served under /api/v3 on that host.
+func WithGitHubHost(host string) TokenManagerOption {
+	return func(tm *TokenManager) {
+		if host == "" || host == defaultGitHubHost {
+			return
+		}
+		tm.githubHost = host
+		tm.authURL = "https://" + host + "/api/v3/copilot_internal/v2/token"
+		tm.userURL = "https://" + host + "/api/v3/user"
+	}
+}
+
+// WithUserAgent sets the User-Agent sent on token refresh requests.
+func WithUserAgent(ua string) TokenManagerOption {
+	return func(tm *TokenManager) {
+		if ua != "" {
+			tm.userAgent = ua
+		}
+	}
+}
+
+// WithOAuthToken uses token as the GitHub OAuth token instead of reading it from the
+// Copilot config files.
+func WithOAuthToken(token string) TokenManagerOption {
+	return func(tm *TokenManager) {
+		tm.oauthToken = token
+	}
+}
+
+// WithAuthURL overrides the endpoint used to exchange the OAuth token for a Copilot token.
+func WithAuthURL(url string) TokenManagerOption {
+	return func(tm *TokenManager) {
+		tm.authURL = url
+	}
+}
+
+// WithUserURL overrides the endpoint used to look up the OAuth token's GitHub username.
+func WithUserURL(url string) TokenManagerOption {
+	return func(tm *TokenManager) {
+		tm.userURL = url
+	}
+}
+
+// WithHostsJSON writes the OAuth token to the Copilot hosts.json at path after every
+// successful token refresh, for tools that read it from there. An empty path selects
+// hosts.json in the Copilot config directory.
+func WithHostsJSON(path string) TokenManagerOption {
+	return func(tm *TokenManager) {
+		if path == "" {
+			path = filepath.Join(tm.configDir, "github-copilot", "hosts.json")
+		}
+		tm.hostsFile = path
+	}
+}
+
+// WithMetrics registers token refresh metrics with registry.
+func WithMetrics(registry *prometheus.Registry) TokenManagerOption {
+	return func(tm *TokenManager) {
+		tm.registry = registry
+	}
+}
+
+// WithTokenFile keeps the Copilot token in path instead of the token.json of
+// TokenFilePath.
+func WithTokenFile(path string) TokenManagerOption {
+	return func(tm *TokenManager) {
+		tm.tokenFile = path
+	}
 }
 
 // NewTokenManager creates a new TokenManager and initializes it.
-func NewTokenManager(ctx context.Context) (*TokenManager, error) {
+func NewTokenManager(ctx context.Context, opts ...TokenManagerOption) (*TokenManager, error) {
 	configDir := getConfigDir()
-	tokenFile := filepath.Join(configDir, "github-copilot", "token.json")
+	tokenFile := TokenFilePath()
 	authURL := "https://api.github.com/copilot_internal/v2/token"
 
 	tm := &TokenManager{
-		configDir: configDir,
-		tokenFile: tokenFile,
-		authURL:   authURL,
+		configDir:   configDir,
+		tokenFile:   tokenFile,
+		authURL:     authURL,
+		prewarm:     defaultPrewarm,
+		expiryGrace: defaultExpiryGrace,
+		retry:       DefaultRetryConfig,
+		githubHost:  defaultGitHubHost,
+		userAgent:   version.UserAgent(),
+		userURL:     "https://api.github.com/user",
+
+		legacyConfigFile: legacyConfigPath(),
+	}
+	for _, opt := range opts {
+		opt(tm)
+	}
+	if tm.registry != nil {
+		tm.metrics = newTokenMetrics(tm.registry, tm)
 	}
 
-	// Load OAuth token from config files
-	oauthToken, err := tm.loadOAuthToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to load Copilot OAuth token: %w", err)
+	// Load OAuth token from config files unless one was given
+	if tm.oauthToken == "" {
+		oauthToken, err := tm.loadOAuthToken()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Copilot OAuth token: %w", err)
+		}
+		tm.oauthToken = oauthToken
+	}
+
+	// Make sure the token file can be written on a fresh machine
+	if err := os.MkdirAll(filepath.Dir(tm.tokenFile), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create token directory: %w", err)
 	}
-	tm.oauthToken = oauthToken
 
 	// Load GitHub token from file (if exists)
 	_ = tm.loadTokenFromFile()
 
 	// Start background refresh and file watcher
-	refreshCtx, cancel := context.WithCancel(ctx)
-	tm.refreshCancel = cancel
-	tm.refreshWG.Add(2)
-	go tm.refreshLoop(refreshCtx)
-	go tm.watchTokenFile(refreshCtx)
+	tm.refreshCtx, tm.refreshCancel = context.WithCancel(ctx)
+	SupervisedGoroutine(tm.refreshCtx, "token refresh", tm.refreshLoop, &tm.refreshWG)
+	SupervisedGoroutine(tm.refreshCtx, "token file watcher", tm.watchTokenFile, &tm.refreshWG)
 
 	return tm, nil
 }
@@ -71,15 +248,103 @@ func (tm *TokenManager) Close() {
 		tm.refreshCancel()
 	}
 	tm.refreshWG.Wait()
+
+	tm.subMu.Lock()
+	defer tm.subMu.Unlock()
+	tm.closed = true
+	for _, ch := range tm.subscribers {
+		close(ch)
+	}
+	tm.subscribers = nil
+}
+
+// Subscribe returns a channel that receives an event after every token refresh,
+// successful or not. Events are dropped for a subscriber that has not consumed the
+// previous one, so a slow consumer never delays a refresh. The channel is closed by
+// Close. At most 16 subscribers are allowed.
+func (tm *TokenManager) Subscribe() (<-chan TokenRefreshEvent, error) {
+	tm.subMu.Lock()
+	defer tm.subMu.Unlock()
+	if tm.closed {
+		return nil, errors.New("token manager is closed")
+	}
+	if len(tm.subscribers) >= maxSubscribers {
+		return nil, fmt.Errorf("too many token refresh subscribers (max %d)", maxSubscribers)
+	}
+	ch := make(chan TokenRefreshEvent, 1)
+	tm.subscribers = append(tm.subscribers, ch)
+	return ch, nil
+}
+
+// publishRefresh sends the outcome of a refresh to every subscriber without blocking.
+func (tm *TokenManager) publishRefresh(err error) {
+	event := TokenRefreshEvent{Error: err}
+	if err == nil {
+		tm.mu.RLock()
+		if tm.githubToken != nil {
+			event.Token = tm.githubToken.Token
+			event.ExpiresAt = time.Unix(int64(tm.githubToken.ExpiresAt), 0)
+		}
+		tm.mu.RUnlock()
+	}
+
+	tm.subMu.Lock()
+	defer tm.subMu.Unlock()
+	for _, ch := range tm.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// SetOAuthToken replaces the OAuth token used for later token refreshes, for example
+// after it was rotated in a secret store.
+func (tm *TokenManager) SetOAuthToken(token string) {
+	tm.mu.Lock()
+	tm.oauthToken = token
+	tm.mu.Unlock()
+	tm.usernameMu.Lock()
+	tm.username = ""
+	tm.usernameMu.Unlock()
+}
+
+// OAuthToken returns the GitHub OAuth token Copilot tokens are requested with.
+func (tm *TokenManager) OAuthToken() string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.oauthToken
+}
+
+// ForceRefresh fetches a new Copilot token even if the current one is still valid.
+func (tm *TokenManager) ForceRefresh(ctx context.Context) error {
+	return tm.refreshToken(ctx, true)
+}
+
+// RefreshToken fetches a new Copilot token in place of rejected, a token Copilot
+// refused. It does nothing if rejected has already been replaced.
+func (tm *TokenManager) RefreshToken(ctx context.Context, rejected string) error {
+	if !tm.holds(rejected) {
+		return nil
+	}
+	return tm.ForceRefresh(ctx)
+}
+
+// holds reports whether token is the current Copilot token.
+func (tm *TokenManager) holds(token string) bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.githubToken != nil && tm.githubToken.Token == token
 }
 
 // GetToken returns the current valid Copilot token, refreshing if needed.
 func (tm *TokenManager) GetToken(ctx context.Context) (string, error) {
 	tm.mu.RLock()
 	token := tm.githubToken
+	valid := tm.validLocked()
 	tm.mu.RUnlock()
 
-	if token != nil && token.ExpiresAt > float64(time.Now().Unix())+120 {
+	if valid {
 		return token.Token, nil
 	}
 
@@ -95,7 +360,19 @@ func (tm *TokenManager) GetToken(ctx context.Context) (string, error) {
 	return tm.githubToken.Token, nil
 }
 
-// loadOAuthToken loads the OAuth token from apps.json or hosts.json.
+// TokenExpiry returns when the current Copilot token expires, or the zero time if
+// no token has been obtained.
+func (tm *TokenManager) TokenExpiry() time.Time {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	if tm.githubToken == nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(tm.githubToken.ExpiresAt), 0)
+}
+
+// loadOAuthToken loads the OAuth token for the configured GitHub host from apps.json or
+// hosts.json. For github.com the old Copilot CLI config is used as a last resort.
 func (tm *TokenManager) loadOAuthToken() (string, error) {
 	for _, fname := range []string{"apps.json", "hosts.json"} {
 		path := filepath.Join(tm.configDir, "github-copilot", fname)
@@ -110,27 +387,69 @@ func (tm *TokenManager) loadOAuthToken() (string, error) {
 			continue
 		}
 		for host, v := range hosts {
-			if strings.Contains(host, "github.com") && v.OAuthToken != "" {
+			if strings.Contains(host, tm.githubHost) && v.OAuthToken != "" {
 				return v.OAuthToken, nil
 			}
 		}
 	}
-	return "", errors.New("GitHub OAuth token not found in config")
+	if tm.githubHost == defaultGitHubHost {
+		if token, err := loadLegacyOAuthToken(tm.legacyConfigFile); err == nil {
+			tm.useLegacyConfig()
+			return token, nil
+		}
+	}
+	return "", fmt.Errorf("GitHub OAuth token for %s not found in config", tm.githubHost)
 }
 
-// loadTokenFromFile loads the GitHub token from token.json.
+// useLegacyConfig warns that the OAuth token comes from the old Copilot CLI config and
+// migrates it if enabled.
+func (tm *TokenManager) useLegacyConfig() {
+	copilotDir := filepath.Join(tm.configDir, "github-copilot")
+	if !tm.migrateOldConfig {
+		log.Printf("Warning: using the GitHub OAuth token from the old Copilot CLI config %s; move it to %s or set MIGRATE_OLD_CONFIG=true",
+			tm.legacyConfigFile, filepath.Join(copilotDir, "hosts.json"))
+		return
+	}
+	if err := migrateOldConfig(tm.legacyConfigFile, copilotDir); err != nil {
+		log.Printf("Warning: failed to migrate the old Copilot CLI config %s: %v", tm.legacyConfigFile, err)
+		return
+	}
+	log.Printf("Migrated the GitHub OAuth token from the old Copilot CLI config %s to %s",
+		tm.legacyConfigFile, filepath.Join(copilotDir, "hosts.json"))
+}
+
+// loadTokenFromFile loads the GitHub token from token.json. A file that is empty or not
+// valid JSON, e.g. after a crash mid-write, is deleted. The in-memory token is only
+// replaced by a token that passes validation.
 func (tm *TokenManager) loadTokenFromFile() error {
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
 	data, err := os.ReadFile(tm.tokenFile)
 	if err != nil {
 		return err
 	}
 	var token CopilotToken
 	if err := json.Unmarshal(data, &token); err != nil {
-		return err
+		// Also covers an empty file
+		log.Printf("Warning: deleting corrupt Copilot token file %s: %v", tm.tokenFile, err)
+		_ = os.Remove(tm.tokenFile)
+		return fmt.Errorf("corrupt token file: %w", err)
 	}
+	if err := token.validate(); err != nil {
+		return fmt.Errorf("invalid token in %s: %w", tm.tokenFile, err)
+	}
+	tm.mu.Lock()
 	tm.githubToken = &token
+	tm.mu.Unlock()
+	return nil
+}
+
+// validate checks that the token has a value and an expiry time.
+func (t *CopilotToken) validate() error {
+	if t.Token == "" {
+		return errors.New("token is empty")
+	}
+	if t.ExpiresAt <= 0 {
+		return errors.New("expires_at is not set")
+	}
 	return nil
 }
 
@@ -142,6 +461,9 @@ func (tm *TokenManager) saveTokenToFile() error {
 	if token == nil {
 		return errors.New("no token to save")
 	}
+	if err := os.MkdirAll(filepath.Dir(tm.tokenFile), 0700); err != nil {
+		return err
+	}
 	tempFile := tm.tokenFile + ".tmp"
 	tm.isSelfWriting = true
 	defer func() { tm.isSelfWriting = false }()
@@ -159,46 +481,98 @@ func (tm *TokenManager) saveTokenToFile() error {
 func (tm *TokenManager) isTokenValid() bool {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
-	return tm.githubToken != nil && tm.githubToken.ExpiresAt > float64(time.Now().Unix())+120
+	return tm.validLocked()
+}
+
+// validLocked reports whether the token is valid for longer than the expiry grace
+// period. tm.mu must be held.
+func (tm *TokenManager) validLocked() bool {
+	if tm.githubToken == nil {
+		return false
+	}
+	expiresAt := time.Unix(int64(tm.githubToken.ExpiresAt), 0)
+	return time.Until(expiresAt) > tm.expiryGrace
 }
 
 // refreshToken refreshes the Copilot token from the API, with file lock for concurrency.
+// Concurrent callers share a single in-flight refresh. It runs until Close rather than
+// until ctx is done, so a caller going away does not abort it for the others; the
+// caller itself stops waiting once ctx is done.
 func (tm *TokenManager) refreshToken(ctx context.Context, force bool) error {
 	// If not forced, skip if token is valid
 	if !force && tm.isTokenValid() {
 		return nil
 	}
+	ch := tm.refreshGroup.DoChan("refresh", func() (interface{}, error) {
+		// The lock wait and the HTTP client timeout bound the shared refresh
+		refreshCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		defer cancel()
+		if tm.refreshCtx != nil {
+			defer context.AfterFunc(tm.refreshCtx, cancel)()
+		}
+		err := tm.doRefreshToken(refreshCtx)
+		if tm.metrics != nil {
+			tm.metrics.observeRefresh(err)
+		}
+		tm.publishRefresh(err)
+		return nil, err
+	})
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
+// doRefreshToken performs a single token refresh.
+func (tm *TokenManager) doRefreshToken(ctx context.Context) error {
 	// Try to acquire file lock
 	lockPath := tm.tokenFile + ".lock"
-	lockAcquired := false
-	for i := 0; i < 5; i++ {
-		err := acquireLock(lockPath)
-		if err == nil {
-			lockAcquired = true
+	var lock *os.File
+	lockStart := time.Now()
+	deadline := lockStart.Add(tm.retry.LockBudget)
+	for attempt := 0; ; attempt++ {
+		var err error
+		if lock, err = acquireLock(lockPath); err == nil {
 			break
 		}
-		time.Sleep(1 * time.Second)
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		if removeStaleLock(lockPath) {
+			continue
+		}
+		if err := sleepContext(ctx, min(tm.retry.delay(attempt), remaining)); err != nil {
+			return err
+		}
+	}
+	if tm.metrics != nil {
+		tm.metrics.lockWait.Observe(time.Since(lockStart).Seconds())
 	}
-	if !lockAcquired {
+	if lock == nil {
 		// Wait for another process to refresh
-		time.Sleep(5 * time.Second)
+		if err := sleepContext(ctx, 5*time.Second); err != nil {
+			return err
+		}
 		_ = tm.loadTokenFromFile()
 		if tm.isTokenValid() {
 			return nil
 		}
 		return errors.New("could not acquire lock to refresh Copilot token")
 	}
-	defer releaseLock(lockPath)
+	defer releaseLock(lockPath, lock)
 
 	// Send authentication request
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tm.authURL, nil)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "token "+tm.oauthToken)
+	req.Header.Set("Authorization", "token "+tm.OAuthToken())
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Editor-Plugin-Version", "copilot.go")
+	req.Header.Set("Editor-Plugin-Version", "copilot.go "+tm.userAgent)
+	req.Header.Set("User-Agent", tm.userAgent)
 
 	client := &http.Client{Timeout: 15 * time.Second}
 	resp, err := client.Do(req)
@@ -207,7 +581,7 @@ func (tm *TokenManager) refreshToken(ctx context.Context, force bool) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return fmt.Errorf("token refresh failed: %s", resp.Status)
+		return &refreshStatusError{code: resp.StatusCode, status: resp.Status}
 	}
 	var token CopilotToken
 	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
@@ -219,27 +593,127 @@ func (tm *TokenManager) refreshToken(ctx context.Context, force bool) error {
 	if err := tm.saveTokenToFile(); err != nil {
 		return fmt.Errorf("failed to save Copilot token: %w", err)
 	}
+	if tm.hostsFile != "" {
+		// The new token is usable even if hosts.json cannot be written
+		if err := tm.SaveToHostsJSON(tm.hostsFile); err != nil {
+			log.Printf("Warning: failed to write %s: %v", tm.hostsFile, err)
+		}
+	}
 	return nil
 }
 
-// refreshLoop periodically refreshes the Copilot token.
+// refreshStatusError is returned by a token refresh the token endpoint answered with a
+// status other than 200.
+type refreshStatusError struct {
+	code   int
+	status string
+}
+
+func (e *refreshStatusError) Error() string {
+	return "token refresh failed: " + e.status
+}
+
+// SaveToHostsJSON writes the OAuth token and its GitHub username to the hosts.json file
+// at path in the Copilot config format, keeping entries for other hosts. The username
+// is looked up once and cached.
+func (tm *TokenManager) SaveToHostsJSON(path string) error {
+	user, err := tm.lookupUsername()
+	if err != nil {
+		return err
+	}
+	return writeHostsEntry(path, tm.githubHost, struct {
+		OAuthToken string `json:"oauth_token"`
+		User       string `json:"user"`
+	}{tm.OAuthToken(), user})
+}
+
+// lookupUsername returns the GitHub login of the OAuth token, fetching it on first use.
+func (tm *TokenManager) lookupUsername() (string, error) {
+	tm.usernameMu.Lock()
+	defer tm.usernameMu.Unlock()
+	if tm.username != "" {
+		return tm.username, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, tm.userURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "token "+tm.OAuthToken())
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", tm.userAgent)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up GitHub user: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub user lookup failed: %s", resp.Status)
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return "", fmt.Errorf("failed to decode GitHub user: %w", err)
+	}
+	if user.Login == "" {
+		return "", errors.New("GitHub user lookup returned no login")
+	}
+	tm.username = user.Login
+	return tm.username, nil
+}
+
+// prewarmWindow returns how long before expiry the token should be refreshed.
+func (tm *TokenManager) prewarmWindow() time.Duration {
+	if tm.prewarm < tm.expiryGrace {
+		return tm.expiryGrace
+	}
+	return tm.prewarm
+}
+
+// needsPrewarm reports whether the token is missing or inside the pre-warm window.
+func (tm *TokenManager) needsPrewarm() bool {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	if tm.githubToken == nil {
+		return true
+	}
+	expiresAt := time.Unix(int64(tm.githubToken.ExpiresAt), 0)
+	return time.Until(expiresAt) < tm.prewarmWindow()
+}
+
+// refreshLoop periodically refreshes the Copilot token. The refresh happens once the
+// token enters the pre-warm window, while it is still valid for requests. Failed
+// refreshes are retried with the refresh backoff of the RetryConfig.
 func (tm *TokenManager) refreshLoop(ctx context.Context) {
-	defer tm.refreshWG.Done()
+	failures := 0
+	var lastErr error
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			// Refresh token if needed
-			_ = tm.refreshToken(ctx, false)
-			// Sleep until 2 minutes before expiration, or 5 minutes if unknown
+			// Refresh token in the background before it expires
+			if !tm.needsPrewarm() {
+				failures = 0
+			} else if lastErr = tm.refreshToken(ctx, true); lastErr != nil {
+				failures++
+			} else {
+				failures = 0
+			}
+			// Sleep until the pre-warm window opens, or 5 minutes if unknown
 			tm.mu.RLock()
 			var sleep time.Duration = 5 * time.Minute
-			if tm.githubToken != nil {
-				exp := int64(tm.githubToken.ExpiresAt)
-				now := time.Now().Unix()
-				if exp > now+120 {
-					sleep = time.Duration(exp-now-120) * time.Second
+			if failures > 0 {
+				sleep = tm.retry.refreshDelay(failures, lastErr)
+			} else if tm.githubToken != nil {
+				expiresAt := time.Unix(int64(tm.githubToken.ExpiresAt), 0)
+				if until := time.Until(expiresAt) - tm.prewarmWindow(); until > 0 {
+					sleep = until
+				} else {
+					// Still inside the window with a short-lived token, refresh again soon
+					sleep = 30 * time.Second
 				}
 			}
 			tm.mu.RUnlock()
@@ -254,48 +728,40 @@ func (tm *TokenManager) refreshLoop(ctx context.Context) {
 
 // watchTokenFile watches token.json for changes and reloads it.
 func (tm *TokenManager) watchTokenFile(ctx context.Context) {
-	defer tm.refreshWG.Done()
 	lastMod := int64(0)
 	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			info, err := os.Stat(tm.tokenFile)
-			if err == nil {
-				mod := info.ModTime().Unix()
-				if mod != lastMod {
-					lastMod = mod
-					if !tm.isSelfWriting {
-						_ = tm.loadTokenFromFile()
-					}
-				}
-			}
-			// Clean up stale lock files
-			lockPath := tm.tokenFile + ".lock"
-			if info, err := os.Stat(lockPath); err == nil {
-				age := time.Now().Unix() - info.ModTime().Unix()
-				if age > 300 {
-					_ = os.Remove(lockPath)
+		info, err := os.Stat(tm.tokenFile)
+		if err == nil {
+			mod := info.ModTime().Unix()
+			if mod != lastMod {
+				lastMod = mod
+				if !tm.isSelfWriting {
+					_ = tm.loadTokenFromFile()
 				}
 			}
-			time.Sleep(2 * time.Second)
+		}
+		// Clean up lock files left behind by crashed processes
+		removeStaleLock(tm.tokenFile + ".lock")
+		if sleepContext(ctx, 2*time.Second) != nil {
+			return
 		}
 	}
 }
 
-// acquireLock tries to create a lock file for token refresh.
-func acquireLock(lockPath string) error {
-	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL, 0600)
-	if err != nil {
-		return err
+// sleepContext waits for d, returning ctx.Err() early if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
 	}
-	return f.Close()
 }
 
-// releaseLock removes the lock file.
-func releaseLock(lockPath string) {
-	_ = os.Remove(lockPath)
+// TokenFilePath returns the path of the token.json a TokenManager keeps the Copilot
+// token in.
+func TokenFilePath() string {
+	return filepath.Join(getConfigDir(), "github-copilot", "token.json")
 }
 
 // getConfigDir returns the OS-specific config directory.
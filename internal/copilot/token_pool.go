@@ -0,0 +1,216 @@
+package copilot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TokenSource hands out Copilot tokens. It is implemented by TokenManager for a single
+// OAuth token and by TokenPool for several.
+type TokenSource interface {
+	GetToken(ctx context.Context) (string, error)
+	ForceRefresh(ctx context.Context) error
+	RefreshToken(ctx context.Context, rejected string) error
+	TokenExpiry() time.Time
+	LicenseTier() string
+	OAuthToken() string
+	Close()
+}
+
+// seatRejectCooldown is how long a seat whose OAuth token the token endpoint rejected is
+// skipped before it is tried again.
+const seatRejectCooldown = 5 * time.Minute
+
+// TokenPool spreads requests over the Copilot seats of several GitHub accounts. Each
+// OAuth token has its own TokenManager, so every seat keeps and refreshes its Copilot
+// token independently of the others.
+type TokenPool struct {
+	seats []*TokenManager
+	next  atomic.Uint32
+
+	mu       sync.Mutex
+	rejected []seatRejection // by seat
+}
+
+// seatRejection records that the token endpoint rejected the OAuth token of a seat.
+type seatRejection struct {
+	until time.Time // the seat is skipped until then
+	err   error
+}
+
+// NewTokenPool returns a TokenPool with a TokenManager for each of oauthTokens, created
+// with opts. Each seat keeps its Copilot token in its own file next to token.json.
+// Metrics get a seat label holding the index of the OAuth token, and WithHostsJSON only
+// applies to the first seat.
+func NewTokenPool(ctx context.Context, oauthTokens []string, opts ...TokenManagerOption) (*TokenPool, error) {
+	if len(oauthTokens) == 0 {
+		return nil, errors.New("token pool needs at least one OAuth token")
+	}
+	p := &TokenPool{seats: make([]*TokenManager, 0, len(oauthTokens)), rejected: make([]seatRejection, len(oauthTokens))}
+	for i, oauthToken := range oauthTokens {
+		seatOpts := append(opts[:len(opts):len(opts)],
+			WithOAuthToken(oauthToken),
+			WithTokenFile(seatTokenFile(oauthToken)),
+			func(tm *TokenManager) {
+				if tm.registry != nil {
+					tm.registry = prometheus.WrapRegistererWith(prometheus.Labels{"seat": strconv.Itoa(i)}, tm.registry)
+				}
+				if i > 0 {
+					tm.hostsFile = ""
+				}
+			})
+		tm, err := NewTokenManager(ctx, seatOpts...)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("seat %d: %w", i, err)
+		}
+		p.seats = append(p.seats, tm)
+	}
+	return p, nil
+}
+
+// seatTokenFile returns the file the Copilot token of oauthToken is kept in. It is named
+// after a hash of the OAuth token so it stays the same when the list is reordered.
+func seatTokenFile(oauthToken string) string {
+	sum := sha256.Sum256([]byte(oauthToken))
+	return filepath.Join(getConfigDir(), "github-copilot", "token-"+hex.EncodeToString(sum[:6])+".json")
+}
+
+// Seats returns the number of OAuth tokens in the pool.
+func (p *TokenPool) Seats() int {
+	return len(p.seats)
+}
+
+// GetToken returns a valid Copilot token of the next seat in round-robin order. If the
+// token endpoint rejects that seat's OAuth token with 401 or 403, the following seats
+// are tried in turn, and the seat is skipped for seatRejectCooldown.
+func (p *TokenPool) GetToken(ctx context.Context) (string, error) {
+	n := uint32(len(p.seats))
+	start := p.next.Add(1) - 1
+	var err error
+	for i := uint32(0); i < n; i++ {
+		seat := int((start + i) % n)
+		if rejectErr := p.rejectedErr(seat); rejectErr != nil {
+			err = rejectErr
+			continue
+		}
+		var token string
+		if token, err = p.seats[seat].GetToken(ctx); err == nil {
+			return token, nil
+		}
+		if !isAuthRejected(err) {
+			return "", err
+		}
+		p.reject(seat, err)
+	}
+	return "", err
+}
+
+// rejectedErr returns the rejection of seat while it is cooling down, and nil otherwise.
+func (p *TokenPool) rejectedErr(seat int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Now().Before(p.rejected[seat].until) {
+		return p.rejected[seat].err
+	}
+	return nil
+}
+
+// reject skips seat for seatRejectCooldown after the token endpoint rejected its OAuth
+// token with err.
+func (p *TokenPool) reject(seat int, err error) {
+	log.Printf("Warning: OAuth token of seat %d was rejected, skipping it for %v: %v", seat, seatRejectCooldown, err)
+	p.mu.Lock()
+	p.rejected[seat] = seatRejection{until: time.Now().Add(seatRejectCooldown), err: err}
+	p.mu.Unlock()
+}
+
+// isAuthRejected reports whether err is a token refresh the token endpoint answered
+// with 401 or 403.
+func isAuthRejected(err error) bool {
+	var statusErr *refreshStatusError
+	return errors.As(err, &statusErr) && (statusErr.code == http.StatusUnauthorized || statusErr.code == http.StatusForbidden)
+}
+
+// ForceRefresh fetches new Copilot tokens for all seats at once. It only fails if no
+// seat could be refreshed.
+func (p *TokenPool) ForceRefresh(ctx context.Context) error {
+	errs := make([]error, len(p.seats))
+	var wg sync.WaitGroup
+	for i, tm := range p.seats {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tm.ForceRefresh(ctx); err != nil {
+				errs[i] = fmt.Errorf("seat %d: %w", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RefreshToken fetches a new Copilot token for the seat that issued rejected, a token
+// Copilot refused, leaving the other seats alone. It does nothing if no seat holds
+// rejected any more.
+func (p *TokenPool) RefreshToken(ctx context.Context, rejected string) error {
+	for i, tm := range p.seats {
+		if !tm.holds(rejected) {
+			continue
+		}
+		err := tm.ForceRefresh(ctx)
+		if isAuthRejected(err) {
+			p.reject(i, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// TokenExpiry returns the latest expiry of the seats' Copilot tokens, or the zero time
+// if no seat has obtained a token.
+func (p *TokenPool) TokenExpiry() time.Time {
+	var latest time.Time
+	for _, tm := range p.seats {
+		if expiry := tm.TokenExpiry(); expiry.After(latest) {
+			latest = expiry
+		}
+	}
+	return latest
+}
+
+// LicenseTier returns the license tier of the first seat. Seats may differ in tier, and
+// only the first one is reported.
+func (p *TokenPool) LicenseTier() string {
+	return p.seats[0].LicenseTier()
+}
+
+// OAuthToken returns the OAuth token of the first seat, so that per-account lookups
+// such as the upstream usage report on the first seat.
+func (p *TokenPool) OAuthToken() string {
+	return p.seats[0].OAuthToken()
+}
+
+// Close stops the background goroutines of all seats.
+func (p *TokenPool) Close() {
+	for _, tm := range p.seats {
+		tm.Close()
+	}
+}
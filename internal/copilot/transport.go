@@ -0,0 +1,42 @@
+package copilot
+
+import (
+	"net/http"
+	"time"
+
+	"copilot-api/pkg/config"
+)
+
+// Upstream transport defaults, used for Config fields left at zero.
+const (
+	defaultMaxIdleConns          = 100
+	defaultMaxIdleConnsPerHost   = 100
+	defaultIdleConnTimeout       = 90 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultExpectContinueTimeout = 1 * time.Second
+)
+
+// NewUpstreamTransport returns the transport for requests to the Copilot API. Unlike
+// http.DefaultTransport, which keeps only two idle connections per host, it keeps enough
+// idle connections to reuse them when all traffic goes to a single host. Host names are
+// resolved through a DNSCache if cfg.DNSCacheTTL is set.
+func NewUpstreamTransport(cfg *config.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConns = orDefault(cfg.UpstreamMaxIdleConns, defaultMaxIdleConns)
+	t.MaxIdleConnsPerHost = orDefault(cfg.UpstreamMaxIdleConnsPerHost, defaultMaxIdleConnsPerHost)
+	t.IdleConnTimeout = orDefault(cfg.UpstreamIdleConnTimeout, defaultIdleConnTimeout)
+	t.TLSHandshakeTimeout = orDefault(cfg.UpstreamTLSHandshakeTimeout, defaultTLSHandshakeTimeout)
+	t.ExpectContinueTimeout = orDefault(cfg.UpstreamExpectContinueTimeout, defaultExpectContinueTimeout)
+	if cfg.DNSCacheTTL > 0 {
+		t.DialContext = NewDNSCache(cfg.DNSCacheTTL, cfg.FallbackDNSServers).DialContext
+	}
+	return t
+}
+
+// orDefault returns v, or def if v is not positive.
+func orDefault[T int | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
@@ -0,0 +1,44 @@
+// Package ctxkey defines the request context values shared between packages. Each value
+// has a key of its own unexported struct type, so keys cannot collide with those of
+// other packages, and is only reachable through the accessors below.
+package ctxkey
+
+import "context"
+
+type (
+	requestIDKey struct{}
+	authLabelKey struct{}
+)
+
+// WithRequestID returns a copy of ctx carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID stored in ctx, if any.
+func RequestIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// MustRequestIDFrom returns the request ID stored in ctx. It panics if there is none, so
+// it is only for code running behind the middleware that always sets one.
+func MustRequestIDFrom(ctx context.Context) string {
+	id, ok := RequestIDFrom(ctx)
+	if !ok {
+		panic("ctxkey: no request ID in context")
+	}
+	return id
+}
+
+// WithAuthLabel returns a copy of ctx carrying the label of the API key that
+// authenticated the request.
+func WithAuthLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, authLabelKey{}, label)
+}
+
+// AuthLabelFrom returns the API key label stored in ctx, if any.
+func AuthLabelFrom(ctx context.Context) (string, bool) {
+	label, ok := ctx.Value(authLabelKey{}).(string)
+	return label, ok
+}
@@ -0,0 +1,60 @@
+// Package pidfile manages a file holding the server's process ID for process supervisors.
+package pidfile
+
+import (
+	"errors"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Write writes the current process ID to path. If the file already holds the PID of
+// another running process a warning is logged and the file is overwritten anyway,
+// so container restarts with a leftover PID file still succeed.
+func Write(path string) error {
+	if pid, err := Read(path); err == nil && pid != os.Getpid() && processRunning(pid) {
+		log.Printf("Warning: PID file %s refers to running process %d; overwriting", path, pid)
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// Read returns the process ID stored in path.
+func Read(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// Remove deletes the PID file if it still holds the current process ID.
+func Remove(path string) error {
+	pid, err := Read(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err == nil && pid != os.Getpid() {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// processRunning reports whether a process with the given PID exists.
+func processRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess only succeeds on Windows if the process exists
+		return true
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
@@ -0,0 +1,189 @@
+// Package sse reads and writes server-sent event streams one event at a time, as sent
+// by the Copilot API for streamed completions.
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxEventSize is the largest event a Scanner accepts unless SetMaxEventSize is
+// used.
+const DefaultMaxEventSize = 1 << 20
+
+// ErrEventTooLarge is returned by Scanner.Err when an event exceeds the maximum size.
+var ErrEventTooLarge = errors.New("sse: event too large")
+
+// Event is a single server-sent event. Data holds the values of all data: lines of the
+// event joined by newlines. ID and Type are empty if the event has no id: or event:
+// line; a missing type means the default type, message.
+type Event struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// Scanner reads the events of a stream. Successive calls to Scan step through the
+// events; comments and events without data lines are skipped. An event cut off by the
+// end of the stream is still returned. The memory used is bounded by the maximum event
+// size.
+type Scanner struct {
+	r       *bufio.Reader
+	maxSize int
+	event   Event
+	retry   time.Duration
+	err     error
+}
+
+// NewScanner returns a Scanner reading from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: bufio.NewReader(r), maxSize: DefaultMaxEventSize}
+}
+
+// SetMaxEventSize sets the largest event, counted in bytes as sent, that Scan accepts.
+// It must be called before the first Scan.
+func (s *Scanner) SetMaxEventSize(n int) {
+	s.maxSize = n
+}
+
+// Scan advances to the next event, which is then available through Event. It returns
+// false at the end of the stream or on an error, reported by Err.
+func (s *Scanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	var event Event
+	var data strings.Builder
+	hasData := false
+	size := 0
+	for {
+		line, err := s.readLine(&size)
+		if err != nil && err != io.EOF {
+			s.err = err
+			return false
+		}
+		if len(line) > 0 {
+			field, value := parseLine(line)
+			switch field {
+			case "data":
+				hasData = true
+				data.WriteString(value)
+				data.WriteByte('\n')
+			case "event":
+				event.Type = value
+			case "id":
+				event.ID = value
+			case "retry":
+				if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+					s.retry = time.Duration(ms) * time.Millisecond
+				}
+			}
+		} else if err == nil && !hasData {
+			// A blank line ends an event without data, which is not dispatched
+			event = Event{}
+			size = 0
+			continue
+		}
+		if err == io.EOF {
+			s.err = io.EOF
+		}
+		if len(line) == 0 || err == io.EOF {
+			if !hasData {
+				return false
+			}
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			s.event = event
+			return true
+		}
+	}
+}
+
+// readLine reads one line without its line ending, adding its length to size and
+// failing with ErrEventTooLarge once size exceeds the maximum event size.
+func (s *Scanner) readLine(size *int) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := s.r.ReadSlice('\n')
+		*size += len(chunk)
+		if *size > s.maxSize {
+			return nil, ErrEventTooLarge
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+		return line, err
+	}
+}
+
+// parseLine splits an event stream line into its field name and value. Comments, which
+// start with a colon, have no field name.
+func parseLine(line []byte) (string, string) {
+	field, value, found := bytes.Cut(line, []byte(":"))
+	if !found {
+		return string(field), ""
+	}
+	value = bytes.TrimPrefix(value, []byte(" "))
+	return string(field), string(value)
+}
+
+// Event returns the event read by the last successful Scan.
+func (s *Scanner) Event() Event {
+	return s.event
+}
+
+// Retry returns the reconnection time last set by a retry: line, or zero if none was sent.
+func (s *Scanner) Retry() time.Duration {
+	return s.retry
+}
+
+// Err returns the first error met by Scan, or nil at the end of the stream.
+func (s *Scanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// Writer formats events onto a stream. If the destination can be flushed, such as an
+// http.ResponseWriter, it is flushed after every event.
+type Writer struct {
+	w       io.Writer
+	flusher interface{ Flush() }
+	buf     bytes.Buffer
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	flusher, _ := w.(interface{ Flush() })
+	return &Writer{w: w, flusher: flusher}
+}
+
+// WriteEvent writes e, with one data: line per line of its data, followed by the blank
+// line that ends it.
+func (w *Writer) WriteEvent(e Event) error {
+	w.buf.Reset()
+	if e.ID != "" {
+		w.buf.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Type != "" {
+		w.buf.WriteString("event: " + e.Type + "\n")
+	}
+	for _, line := range strings.Split(e.Data, "\n") {
+		w.buf.WriteString("data: " + line + "\n")
+	}
+	w.buf.WriteByte('\n')
+	if _, err := w.w.Write(w.buf.Bytes()); err != nil {
+		return err
+	}
+	if w.flusher != nil {
+		w.flusher.Flush()
+	}
+	return nil
+}
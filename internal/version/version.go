@@ -0,0 +1,12 @@
+// Package version holds build metadata injected at link time, e.g.:
+//
+//	go build -ldflags "-X copilot-api/internal/version.Version=1.2.0" ./cmd/go-copilot-api
+package version
+
+// Version is the release version of the binary.
+var Version = "1.0"
+
+// UserAgent returns the default User-Agent sent on upstream requests.
+func UserAgent() string {
+	return "go-copilot-api/" + Version
+}
@@ -3,63 +3,460 @@ package config
 import (
 	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
-	"path/filepath"
-	"runtime"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+
+	"copilot-api/internal/version"
 )
 
+// APIKey is an additional client access token identified by a label.
+type APIKey struct {
+	Label      string `json:"label"`
+	Token      string `json:"token"`
+	DailyLimit int    `json:"daily_limit,omitempty"` // Requests allowed per UTC day (0 = unlimited)
+}
+
+// DefaultAuthExemptPaths are the paths served without authentication unless
+// AUTH_EXEMPT_PATHS is set.
+var DefaultAuthExemptPaths = []string{"/healthz", "/v1/models", "/v1/models/search", "/v1/models/*/compatibility", "/v1/providers", "/v1/providers/*", "/livez", "/readyz", "/version"}
+
+// DefaultCopilotBaseURL is the Copilot API the chat, embeddings and messages endpoints
+// are derived from unless COPILOT_BASE_URL is set.
+const DefaultCopilotBaseURL = "https://api.githubcopilot.com"
+
+// DefaultGitHubAPIURL is the GitHub REST API used for github.com accounts unless
+// GITHUB_API_URL is set.
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// DefaultGitHubAuthURL is the endpoint exchanging a github.com OAuth token for a Copilot
+// token unless GITHUB_AUTH_URL is set.
+const DefaultGitHubAuthURL = "https://api.github.com/copilot_internal/v2/token"
+
+// DefaultGitHubOAuthClientID is the OAuth app used by the login subcommand unless
+// GITHUB_OAUTH_CLIENT_ID is set. It is the app of the Copilot editor plugins.
+const DefaultGitHubOAuthClientID = "Iv1.b507a08c87ecfe98"
+
+// DefaultCSP is the Content-Security-Policy sent unless CSP_POLICY is set. The API
+// serves no documents, so nothing may be loaded or framed.
+const DefaultCSP = "default-src 'none'; frame-ancestors 'none'"
+
+// DefaultSupportedAnthropicVersions are the anthropic-version header values accepted on
+// /v1/messages unless SUPPORTED_ANTHROPIC_VERSIONS is set.
+var DefaultSupportedAnthropicVersions = []string{"2023-01-01", "2023-06-01"}
+
 // Config holds application configuration loaded from environment variables or defaults.
 type Config struct {
 	ServerAddr         string
 	Debug              bool
 	CopilotOAuthToken  string
-	CopilotToken       string // API access token for authentication
-	ServerPort         string // Port to listen on (default: 9191)
-	CORSAllowedOrigins string // Comma-separated list of allowed CORS origins (default: *)
-	DefaultModel       string // Default model to use if not specified in request
+	CopilotOAuthTokens []string // OAuth tokens of several Copilot seats to rotate through; CopilotOAuthToken is the first
+	CopilotToken       string   // API access token for authentication
+	AdminToken         string   // Access token for admin endpoints (admin endpoints are disabled if empty)
+	ServerPort         string   // Port to listen on (default: 9191)
+	CORSAllowedOrigins string   // Comma-separated list of allowed CORS origins (default: *)
+	DefaultModel       string   // Default model to use if not specified in request and no endpoint default is set
+	CopilotBaseURL     string   // Scheme and host of the Copilot API (default: https://api.githubcopilot.com)
+	CopilotEndpoints   []string // Copilot API base URLs to load balance across; overrides CopilotBaseURL
+	APIKeys            []APIKey
+	APIKeysStateFile   string // Keeps keys added and revoked through /admin/keys across restarts (optional)
+
+	CopilotTokenGenerated bool     // CopilotToken was generated because none was configured
+	OAuthTokenSearchPaths []string // Where the Copilot OAuth token is looked for, in priority order (see DefaultOAuthTokenSearchPaths)
+	OAuthTokenSource      string   // Entry of OAuthTokenSearchPaths CopilotOAuthToken was found in
+	GCPSecretName         string   // Secret Manager secret holding the Copilot OAuth token; re-read on SIGHUP (optional, needs the gcpsm build tag)
+
+	DefaultChatModel      string // Default model for /v1/chat/completions (falls back to DefaultModel)
+	DefaultEmbeddingModel string // Default model for /v1/embeddings (falls back to DefaultModel)
+	DefaultAnthropicModel string // Default model for /v1/messages (falls back to DefaultModel)
+	FallbackModel         string // Model substituted for requested models missing from the models list
+	DefaultMaxTokens      int    // max_tokens set on chat completions that send none, capped by the model's limit (default: 4096, 0 = none)
+
+	// IntegrationIdPerKey maps API key labels to the Copilot-Integration-Id sent upstream.
+	IntegrationIdPerKey  map[string]string
+	IntegrationIdMapFile string // File IntegrationIdPerKey was read from (optional)
+
+	// MaxTokensPerKey maps API key labels to the largest max_tokens their requests may use.
+	MaxTokensPerKey map[string]int
+	MaxTokensFile   string // File MaxTokensPerKey was read from (optional)
+
+	AuditLogFile    string // Append an entry for every request to this JSON Lines file (optional)
+	AuditSigningKey string // HMAC-SHA256 key signing each audit log entry (optional)
+
+	// ModelAliases maps model names clients may request to the model sent to Copilot.
+	ModelAliases       map[string]string
+	ModelAliasFile     string // File ModelAliases was read from; reloaded when it changes (optional)
+	StrictModelAliases bool   // Refuse to start if an alias targets a model missing from the models list
+
+	EnterpriseOnlyModels []string // Models refused when the Copilot license is an individual plan (optional)
+
+	TokenPrewarmSeconds  int    // Refresh the Copilot token this many seconds before expiry (default: 300)
+	TokenExpiryGraceSecs int    // Treat the Copilot token as expired this many seconds early (default: 120)
+	PIDFile              string // Path of the PID file written at startup (optional)
+	EnableRequestDedup   bool   // Share one upstream call between identical concurrent non-streaming requests
+	EmulateMultipleN     bool   // Serve chat completions with n > 1 by sending n upstream requests (otherwise rejected)
+	EnableSmartRouting   bool   // Route chat completions to the model of the requested family with the smallest fitting context window
+	EnableMetrics        bool   // Serve Prometheus metrics on /metrics
+	EnableTunnel         bool   // Serve CONNECT /tunnel/{host:port} tunnels to the Copilot API
+	GitHubEnterpriseURL  string // GitHub Enterprise Server URL, e.g. https://github.example.com (optional)
+	GitHubAuthURL        string // Endpoint exchanging the OAuth token for a Copilot token (default: derived from the GitHub host)
+	GitHubAPIURL         string // GitHub REST API base URL (default: https://api.github.com, or /api/v3 on the GitHub Enterprise Server host)
+	GitHubOrg            string // Organization whose Copilot usage /admin/upstream-usage serves (optional)
+	GitHubOAuthClientID  string // OAuth app whose device flow the login subcommand runs (default: the Copilot plugins' app)
+	WriteHostsJSON       bool   // Write the OAuth token to the Copilot hosts.json after every token refresh
+	MigrateOldConfig     bool   // Copy an OAuth token found only in ~/.copilot/config.json to the Copilot hosts.json
+	TrustedProxyCount    int    // Number of reverse proxies in front of the server (default: 0)
+
+	RateLimitPerMinute int // Requests allowed per client IP per minute (default: 0 = unlimited)
+	RateLimitBurst     int // Requests a client IP may send at once before being limited (default: 10)
+	RateLimiterMaxIPs  int // Client IPs tracked by the rate limiter; the least recently seen is dropped (default: 10000)
+
+	ForwardRateLimitHeaders bool // Pass the X-RateLimit-* headers of Copilot responses on to clients (default: true)
+
+	ResponseCacheSize int           // Max cached chat completion responses (default: 0 = disabled)
+	ResponseCacheTTL  time.Duration // How long cached responses are served (default: 5m)
+	UsageCacheTTL     time.Duration // How long the GitHub Copilot usage of GitHubOrg is cached (default: 1h)
+
+	ModelsFetchTimeout   time.Duration // Limit on fetching an empty models list for a request (default: 5s)
+	ModelsRefreshTimeout time.Duration // Limit on background refreshes of the models list (default: 30s)
+
+	UsesCopilotModelsEndpoint bool   // Fetch the models list from CopilotBaseURL + "/models" instead of the GitHub Models catalog
+	MergeModelLists           string // Combine the Copilot models list with the catalog: "union", "intersection" or "" for none
+
+	ModelsCacheBackend string        // Where the models list is cached: "memory", or "redis" to share it through Redis (default: memory)
+	RedisAddr          string        // Redis host:port or redis:// URL of the redis models cache backend
+	ModelsCacheTTL     time.Duration // How long the models list is cached between refreshes (default: 6h)
+
+	UserAgent            string   // User-Agent sent on all upstream requests (default: go-copilot-api/<version>)
+	StripUpstreamHeaders []string // Client headers withheld from Copilot in addition to Cookie, Set-Cookie, X-Auth-Token, X-Session-Id
+	AuthExemptPaths      []string // Paths served without authentication; "/prefix/*" exempts all sub-paths
+
+	SupportedAnthropicVersions []string // anthropic-version header values accepted on /v1/messages
+
+	MaxRequestBodyBytes int64  // Largest accepted request body (default: 10 MiB)
+	MaxFileUploadBytes  int64  // Largest accepted /v1/files and /v1/audio/transcriptions upload (default: 100 MiB, 0 = unlimited)
+	AudioAPIURL         string // Whisper-compatible endpoint serving /v1/audio/transcriptions (optional)
+	AssistantsAPIURL    string // Base URL of an OpenAI Assistants API serving /v1/assistants and /v1/threads (optional)
+	AssistantsAPIKey    string // Bearer token sent to AssistantsAPIURL instead of any Copilot credentials (optional)
+	TLSCertFile         string // Serve HTTPS with this certificate (requires TLSKeyFile)
+	TLSKeyFile          string // Private key for TLSCertFile
+
+	CSP string // Content-Security-Policy header sent on every response (default: DefaultCSP, empty = none)
+
+	MaxConcurrentRequests int // Max requests served at once; further requests wait (default: 0 = unlimited)
+	UpstreamMaxAttempts   int // Attempts per upstream request on transport errors and 502/503/504 (default: 3)
+	// MaxUpstreamConcurrency bounds the requests in flight to the upstream APIs across
+	// all clients; streamed responses hold their slot until they end (default: 20, 0 = unlimited)
+	MaxUpstreamConcurrency int
+	// ShutdownTimeout is how long shutdown waits for in-flight requests (default: 30s)
+	ShutdownTimeout time.Duration
+	// PreShutdownDelay is how long /readyz reports shutting down before connections are
+	// drained, so load balancers stop sending requests first (default: 0)
+	PreShutdownDelay time.Duration
+
+	UpstreamMaxIdleConns          int           // Idle connections kept to Copilot across all hosts (default: 100)
+	UpstreamMaxIdleConnsPerHost   int           // Idle connections kept per Copilot host (default: MaxConcurrentRequests, or 100)
+	UpstreamIdleConnTimeout       time.Duration // How long idle upstream connections are kept (default: 90s)
+	UpstreamTLSHandshakeTimeout   time.Duration // Timeout for upstream TLS handshakes (default: 10s)
+	UpstreamExpectContinueTimeout time.Duration // Wait for a 100-continue response before sending the body (default: 1s)
+
+	DNSCacheTTL        time.Duration // How long resolved Copilot API addresses are reused (default: 60s; 0 disables the cache)
+	FallbackDNSServers []string      // DNS servers asked when the system resolver fails (optional)
+
+	LogSampleRate        float64       // Fraction of successful requests logged in debug mode, 0.0 to 1.0 (default: 1.0)
+	SlowRequestThreshold time.Duration // Requests taking longer are logged regardless of LogSampleRate (default: 0 = none)
+
+	StreamBufferSize    int           // Read buffer size for streamed responses in bytes (default: 4096)
+	StreamFlushInterval time.Duration // Batch streamed data for this long between flushes (default: 0 = flush every write)
 }
 
 // Load reads configuration from environment variables, falling back to sensible defaults.
-// It will attempt to load the Copilot OAuth token from the environment variable COPILOT_OAUTH_TOKEN,
-// or, if not set, from the GitHub Copilot apps.json file in the user's config directory.
+// If CONFIG_DIR is set, settings missing from the environment are read from that
+// directory as by LoadFromDir.
+// Invalid values are reported as an error joining one ValidationError per field.
+// The Copilot OAuth token is taken from the first of OAuthTokenSearchPaths that holds one.
 func Load() (*Config, error) {
+	if dir := os.Getenv("CONFIG_DIR"); dir != "" {
+		return LoadFromDir(dir)
+	}
+	return load(nil)
+}
+
+// load reads the configuration from the environment, falling back to values for
+// variables that are not set.
+func load(values map[string]string) (*Config, error) {
+	dirMu.Lock()
+	defer dirMu.Unlock()
+	dirValues = values
+	defer func() { dirValues = nil }()
+
 	cfg := &Config{
-		ServerAddr:         getEnv("SERVER_ADDR", ":8080"),
-		Debug:              getEnvBool("DEBUG", false),
-		CopilotToken:       getEnv("COPILOT_TOKEN", randomToken()),
-		ServerPort:         getEnv("COPILOT_SERVER_PORT", "9191"),
-		CORSAllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", "*"),
-		DefaultModel:       getEnv("DEFAULT_MODEL", ""),
+		ServerAddr:           getEnv("SERVER_ADDR", ":8080"),
+		Debug:                getEnvBool("DEBUG", false),
+		ServerPort:           getEnv("COPILOT_SERVER_PORT", "9191"),
+		CORSAllowedOrigins:   getEnv("CORS_ALLOWED_ORIGINS", "*"),
+		DefaultModel:         getEnv("DEFAULT_MODEL", ""),
+		TokenPrewarmSeconds:  getEnvInt("TOKEN_PREWARM_SECONDS", 300),
+		TokenExpiryGraceSecs: getEnvInt("TOKEN_EXPIRY_GRACE_SECS", 120),
+		PIDFile:              getEnv("PID_FILE", ""),
+		EnableRequestDedup:   getEnvBool("ENABLE_REQUEST_DEDUP", false),
+		EmulateMultipleN:     getEnvBool("EMULATE_MULTIPLE_N", false),
+		EnableSmartRouting:   getEnvBool("ENABLE_SMART_ROUTING", false),
+		EnableMetrics:        getEnvBool("ENABLE_METRICS", false),
+		EnableTunnel:         getEnvBool("ENABLE_TUNNEL", false),
+		GitHubEnterpriseURL:  getEnv("GITHUB_ENTERPRISE_URL", ""),
+		WriteHostsJSON:       getEnvBool("WRITE_HOSTS_JSON", false),
+		MigrateOldConfig:     getEnvBool("MIGRATE_OLD_CONFIG", false),
+		TrustedProxyCount:    getEnvInt("TRUSTED_PROXY_COUNT", 0),
+		RateLimitPerMinute:   getEnvInt("RATE_LIMIT_PER_MINUTE", 0),
+		RateLimitBurst:       getEnvInt("RATE_LIMIT_BURST", 10),
+		RateLimiterMaxIPs:    getEnvInt("RATE_LIMITER_MAX_IPS", 10000),
+		ResponseCacheSize:    getEnvInt("RESPONSE_CACHE_SIZE", 0),
+		ResponseCacheTTL:     getEnvDuration("RESPONSE_CACHE_TTL", 5*time.Minute),
+		UserAgent:            getEnv("USER_AGENT", version.UserAgent()),
+
+		ForwardRateLimitHeaders: getEnvBool("FORWARD_RATE_LIMIT_HEADERS", true),
+
+		ModelsFetchTimeout:   getEnvDuration("MODELS_FETCH_TIMEOUT", 5*time.Second),
+		ModelsRefreshTimeout: getEnvDuration("MODELS_REFRESH_TIMEOUT", 30*time.Second),
+
+		UsesCopilotModelsEndpoint: getEnvBool("USE_COPILOT_MODELS_ENDPOINT", false),
+		MergeModelLists:           strings.ToLower(getEnv("MERGE_MODEL_LISTS", "")),
+
+		ModelsCacheBackend: strings.ToLower(getEnv("MODELS_CACHE_BACKEND", "memory")),
+		RedisAddr:          getEnv("REDIS_ADDR", ""),
+		ModelsCacheTTL:     getEnvDuration("MODELS_CACHE_TTL", 6*time.Hour),
+
+		DefaultChatModel:      getEnv("DEFAULT_CHAT_MODEL", ""),
+		DefaultEmbeddingModel: getEnv("DEFAULT_EMBEDDING_MODEL", ""),
+		DefaultAnthropicModel: getEnv("DEFAULT_ANTHROPIC_MODEL", ""),
+		FallbackModel:         getEnv("FALLBACK_MODEL", ""),
+
+		MaxConcurrentRequests: getEnvInt("MAX_CONCURRENT_REQUESTS", 0),
+		UpstreamMaxAttempts:   getEnvInt("UPSTREAM_MAX_ATTEMPTS", 3),
+		UpstreamMaxIdleConns:  getEnvInt("UPSTREAM_MAX_IDLE_CONNS", 100),
+		StreamBufferSize:      getEnvInt("STREAM_BUFFER_SIZE", 4096),
+		StreamFlushInterval:   getEnvDuration("STREAM_FLUSH_INTERVAL", 0),
+		MaxRequestBodyBytes:   int64(getEnvInt("MAX_REQUEST_BODY_BYTES", 10<<20)),
+		MaxFileUploadBytes:    int64(getEnvInt("MAX_FILE_UPLOAD_BYTES", 100<<20)),
+		AudioAPIURL:           getEnv("AUDIO_API_URL", ""),
+		AssistantsAPIURL:      getEnv("ASSISTANTS_API_URL", ""),
+		AssistantsAPIKey:      getEnv("ASSISTANTS_API_KEY", ""),
+		TLSCertFile:           getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:            getEnv("TLS_KEY_FILE", ""),
+		CSP:                   getEnv("CSP_POLICY", DefaultCSP),
+	}
+
+	perHost := 100
+	if cfg.MaxConcurrentRequests > 0 {
+		perHost = cfg.MaxConcurrentRequests
+	}
+	cfg.MaxUpstreamConcurrency = getEnvInt("MAX_UPSTREAM_CONCURRENCY", 20)
+	cfg.DefaultMaxTokens = getEnvInt("DEFAULT_MAX_TOKENS", 4096)
+	cfg.UpstreamMaxIdleConnsPerHost = getEnvInt("UPSTREAM_MAX_IDLE_CONNS_PER_HOST", perHost)
+	cfg.UpstreamIdleConnTimeout = getEnvDuration("UPSTREAM_IDLE_CONN_TIMEOUT", 90*time.Second)
+	cfg.UpstreamTLSHandshakeTimeout = getEnvDuration("UPSTREAM_TLS_HANDSHAKE_TIMEOUT", 10*time.Second)
+	cfg.UpstreamExpectContinueTimeout = getEnvDuration("UPSTREAM_EXPECT_CONTINUE_TIMEOUT", time.Second)
+	cfg.DNSCacheTTL = getEnvDuration("DNS_CACHE_TTL", 60*time.Second)
+	cfg.FallbackDNSServers = getEnvList("FALLBACK_DNS_SERVERS")
+	cfg.LogSampleRate = getEnvFloat("LOG_SAMPLE_RATE", 1.0)
+	cfg.SlowRequestThreshold = getEnvDuration("SLOW_REQUEST_THRESHOLD", 0)
+	cfg.ShutdownTimeout = getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second)
+	cfg.PreShutdownDelay = getEnvDuration("PRE_SHUTDOWN_DELAY", 0)
+
+	// COPILOT_API_URL is the older name of COPILOT_BASE_URL
+	cfg.CopilotBaseURL = baseURL(getEnv("COPILOT_BASE_URL", getEnv("COPILOT_API_URL", DefaultCopilotBaseURL)))
+	cfg.GitHubAuthURL = getEnv("GITHUB_AUTH_URL", "")
+	if cfg.GitHubAuthURL == "" && cfg.enterpriseHost() == "" {
+		// GitHub Enterprise Server hosts serve the token endpoint under their own /api/v3
+		cfg.GitHubAuthURL = DefaultGitHubAuthURL
+	}
+	cfg.GitHubOAuthClientID = getEnv("GITHUB_OAUTH_CLIENT_ID", DefaultGitHubOAuthClientID)
+	cfg.GitHubAPIURL = DefaultGitHubAPIURL
+	if host := cfg.enterpriseHost(); host != "" {
+		cfg.GitHubAPIURL = "https://" + host + "/api/v3"
+	}
+	cfg.GitHubAPIURL = strings.TrimSuffix(getEnv("GITHUB_API_URL", cfg.GitHubAPIURL), "/")
+	cfg.GitHubOrg = getEnv("GITHUB_ORG", "")
+	cfg.UsageCacheTTL = getEnvDuration("USAGE_CACHE_TTL", time.Hour)
+
+	cfg.SupportedAnthropicVersions = append([]string{}, DefaultSupportedAnthropicVersions...)
+	if versions := getEnvList("SUPPORTED_ANTHROPIC_VERSIONS"); len(versions) > 0 {
+		cfg.SupportedAnthropicVersions = versions
+	}
+
+	copilotToken, err := readSecretField("COPILOT_TOKEN", "COPILOT_TOKEN_FILE")
+	if err != nil {
+		return nil, err
+	}
+	if copilotToken == "" {
+		copilotToken = randomToken()
+		cfg.CopilotTokenGenerated = true
+	}
+	cfg.CopilotToken = copilotToken
+	if cfg.AdminToken, err = readSecretField("ADMIN_TOKEN", "ADMIN_TOKEN_FILE"); err != nil {
+		return nil, err
+	}
+	cfg.AuditLogFile = getEnv("AUDIT_LOG_FILE", "")
+	if cfg.AuditSigningKey, err = readSecretField("AUDIT_SIGNING_KEY", "AUDIT_SIGNING_KEY_FILE"); err != nil {
+		return nil, err
+	}
+
+	cfg.CopilotEndpoints = getEnvList("COPILOT_ENDPOINTS")
+	cfg.StripUpstreamHeaders = getEnvList("STRIP_UPSTREAM_HEADERS")
+	cfg.AuthExemptPaths = append([]string{}, DefaultAuthExemptPaths...)
+	if _, ok := lookupEnv("AUTH_EXEMPT_PATHS"); ok {
+		// An empty value means every path requires authentication
+		cfg.AuthExemptPaths = append([]string{}, getEnvList("AUTH_EXEMPT_PATHS")...)
+	}
+
+	apiKeys, err := readSecretField("API_KEYS", "API_KEYS_FILE")
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseAPIKeys(apiKeys)
+	if err != nil {
+		return nil, err
 	}
+	cfg.APIKeys = keys
+	cfg.APIKeysStateFile = getEnv("API_KEYS_STATE_FILE", "")
 
-	// Try to get Copilot OAuth token from env first
-	token := getEnv("COPILOT_OAUTH_TOKEN", "")
-	if token == "" {
-		// Try to auto-detect from apps.json
-		token = findCopilotToken()
+	cfg.IntegrationIdMapFile = getEnv("INTEGRATION_ID_MAP_FILE", "")
+	if cfg.IntegrationIdMapFile != "" {
+		m, err := loadStringMap(cfg.IntegrationIdMapFile)
+		if err != nil {
+			return nil, fmt.Errorf("INTEGRATION_ID_MAP_FILE: %w", err)
+		}
+		cfg.IntegrationIdPerKey = m
+	}
+
+	// MODEL_ALIAS_FILE is accepted as another name of MODEL_ALIASES_FILE
+	cfg.ModelAliasFile = getEnv("MODEL_ALIASES_FILE", getEnv("MODEL_ALIAS_FILE", ""))
+	if cfg.ModelAliasFile != "" {
+		m, err := loadStringMap(cfg.ModelAliasFile)
+		if err != nil {
+			return nil, fmt.Errorf("MODEL_ALIASES_FILE: %w", err)
+		}
+		cfg.ModelAliases = m
+	}
+	cfg.StrictModelAliases = getEnvBool("STRICT_MODEL_ALIASES", false)
+	cfg.EnterpriseOnlyModels = getEnvList("ENTERPRISE_ONLY_MODELS")
+
+	cfg.MaxTokensFile = getEnv("MAX_TOKENS_FILE", "")
+	if cfg.MaxTokensFile != "" {
+		m, err := loadIntMap(cfg.MaxTokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("MAX_TOKENS_FILE: %w", err)
+		}
+		cfg.MaxTokensPerKey = m
+	}
+
+	cfg.GCPSecretName = getEnv("GCP_SECRET_NAME", "")
+	cfg.OAuthTokenSearchPaths = DefaultOAuthTokenSearchPaths()
+	if paths := parseSearchPaths(getEnv("OAUTH_TOKEN_SEARCH_PATHS", "")); len(paths) > 0 {
+		cfg.OAuthTokenSearchPaths = paths
+	}
+	// An unreadable COPILOT_OAUTH_TOKEN_FILE is an error rather than a skipped search path
+	if _, err := readSecretField("COPILOT_OAUTH_TOKEN", "COPILOT_OAUTH_TOKEN_FILE"); err != nil {
+		return nil, err
+	}
+	if tokens := getEnvList("COPILOT_OAUTH_TOKENS"); len(tokens) > 0 {
+		cfg.CopilotOAuthTokens = tokens
+		cfg.CopilotOAuthToken, cfg.OAuthTokenSource = tokens[0], "$COPILOT_OAUTH_TOKENS"
+	} else {
+		cfg.CopilotOAuthToken, cfg.OAuthTokenSource, _ = findOAuthToken(cfg.OAuthTokenSearchPaths, cfg.enterpriseHost())
+		// A Copilot config file holding several accounts makes a pool of their seats
+		if strings.HasSuffix(cfg.OAuthTokenSource, ".json") {
+			if tokens := copilotConfigTokens(expandHome(cfg.OAuthTokenSource), cfg.enterpriseHost()); len(tokens) > 1 {
+				cfg.CopilotOAuthTokens = tokens
+			}
+		}
 	}
-	cfg.CopilotOAuthToken = token
 
 	if cfg.CopilotOAuthToken == "" {
-		fmt.Fprintln(os.Stderr, "Warning: Copilot OAuth token not found in environment or apps.json")
+		fmt.Fprintln(os.Stderr, "Warning: Copilot OAuth token not found in any of OAUTH_TOKEN_SEARCH_PATHS")
 	}
 
+	if verrs := Validate(cfg); len(verrs) > 0 {
+		errs := make([]error, len(verrs))
+		for i, e := range verrs {
+			errs[i] = e
+		}
+		return nil, errors.Join(errs...)
+	}
 	return cfg, nil
 }
 
+// GitHubHost returns the GitHub host the Copilot OAuth token belongs to:
+// the GitHub Enterprise Server host if configured, otherwise github.com.
+func (c *Config) GitHubHost() string {
+	if host := c.enterpriseHost(); host != "" {
+		return host
+	}
+	return "github.com"
+}
+
+// enterpriseHost returns the hostname of GitHubEnterpriseURL, or "" if unset.
+func (c *Config) enterpriseHost() string {
+	raw := strings.TrimSpace(c.GitHubEnterpriseURL)
+	if raw == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// baseURL reduces raw to its scheme and host so endpoint paths can be appended without
+// doubling slashes or path segments. Values that are not absolute URLs are returned
+// unchanged for Validate to report.
+func baseURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return raw
+	}
+	return u.Scheme + "://" + u.Host
+}
+
 // getEnv returns the value of the environment variable if set, otherwise returns the default.
 func getEnv(key, def string) string {
-	if val, ok := os.LookupEnv(key); ok {
+	if val, ok := lookupEnv(key); ok {
 		return val
 	}
 	return def
 }
 
+// readSecretField returns the secret in the environment variable envKey if set, otherwise
+// the trimmed contents of the file named by fileEnvKey, as mounted for Docker and
+// Kubernetes secrets. It returns "" if neither is set.
+func readSecretField(envKey, fileEnvKey string) (string, error) {
+	if val, ok := lookupEnv(envKey); ok {
+		return val, nil
+	}
+	path := getEnv(fileEnvKey, "")
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s: cannot read secret file: %w", fileEnvKey, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
 // getEnvBool returns the boolean value of the environment variable if set, otherwise returns the default.
 func getEnvBool(key string, def bool) bool {
-	val, ok := os.LookupEnv(key)
+	val, ok := lookupEnv(key)
 	if !ok {
 		return def
 	}
@@ -71,53 +468,116 @@ func getEnvBool(key string, def bool) bool {
 	return b
 }
 
-// (removed getEnvInt and defaultWorkers, no longer needed)
+// getEnvInt returns the integer value of the environment variable if set, otherwise returns the default.
+func getEnvInt(key string, def int) int {
+	val, ok := lookupEnv(key)
+	if !ok {
+		return def
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid integer for %s: %v, using default %v\n", key, err, def)
+		return def
+	}
+	return i
+}
 
-// randomToken generates a random fallback token if COPILOT_TOKEN is not set.
-func randomToken() string {
-	b := make([]byte, 32)
-	_, err := rand.Read(b)
+// getEnvFloat returns the float value of the environment variable if set, otherwise returns the default.
+func getEnvFloat(key string, def float64) float64 {
+	val, ok := lookupEnv(key)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(val, 64)
 	if err != nil {
-		// fallback to a static string if random fails
-		return "default-token"
+		fmt.Fprintf(os.Stderr, "Invalid number for %s: %v, using default %v\n", key, err, def)
+		return def
 	}
-	return fmt.Sprintf("%x", b)
+	return f
 }
 
-// findCopilotToken attempts to locate and parse the Copilot OAuth token from the user's config directory.
-// Checks platform-specific locations for apps.json and returns the first oauth_token found.
-func findCopilotToken() string {
-	var configPath string
-	if runtime.GOOS == "windows" {
-		localAppData := os.Getenv("LOCALAPPDATA")
-		if localAppData != "" {
-			configPath = filepath.Join(localAppData, "github-copilot", "apps.json")
-		}
-	} else {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			configPath = filepath.Join(home, ".config", "github-copilot", "apps.json")
+// getEnvDuration returns the duration value of the environment variable if set, otherwise returns the default.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	val, ok := lookupEnv(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid duration for %s: %v, using default %v\n", key, err, def)
+		return def
+	}
+	return d
+}
+
+// getEnvList returns the non-empty entries of a comma-separated environment variable.
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(getEnv(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
 		}
 	}
-	if configPath == "" {
-		return ""
+	return list
+}
+
+// reservedKeyLabels are the labels of requests authenticated with COPILOT_TOKEN and
+// ADMIN_TOKEN. Admin access is granted by label, so API keys must not use them.
+var reservedKeyLabels = []string{"default", "admin"}
+
+// parseAPIKeys parses a comma-separated list of label:token pairs.
+func parseAPIKeys(val string) ([]APIKey, error) {
+	var keys []APIKey
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		label, token, ok := strings.Cut(entry, ":")
+		if !ok || label == "" || token == "" {
+			return nil, fmt.Errorf("invalid API_KEYS entry %q: expected label:token", entry)
+		}
+		if slices.Contains(reservedKeyLabels, label) {
+			return nil, fmt.Errorf("invalid API_KEYS entry: label %q is reserved", label)
+		}
+		keys = append(keys, APIKey{Label: label, Token: token})
 	}
-	data, err := os.ReadFile(configPath)
+	return keys, nil
+}
+
+// loadStringMap reads a JSON object of string values from path.
+func loadStringMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return ""
+		return nil, err
 	}
-	var apps map[string]struct {
-		User        string `json:"user"`
-		OAuthToken  string `json:"oauth_token"`
-		GitHubAppId string `json:"githubAppId"`
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", path, err)
 	}
-	if err := json.Unmarshal(data, &apps); err != nil {
-		return ""
+	return m, nil
+}
+
+// loadIntMap reads a JSON object of integer values from path.
+func loadIntMap(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
 	}
-	for _, v := range apps {
-		if v.OAuthToken != "" {
-			return v.OAuthToken
-		}
+	var m map[string]int
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", path, err)
 	}
-	return ""
+	return m, nil
+}
+
+// randomToken generates a random fallback token if COPILOT_TOKEN is not set.
+func randomToken() string {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		// fallback to a static string if random fails
+		return "default-token"
+	}
+	return fmt.Sprintf("%x", b)
 }
@@ -0,0 +1,41 @@
+//go:build gcpsm
+
+package config
+
+import (
+	"context"
+	"os"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func init() {
+	accessGCPSecret = accessSecretManager
+}
+
+// accessSecretManager reads the secret version name with the Secret Manager client,
+// using Application Default Credentials. SECRET_MANAGER_EMULATOR_HOST points the client
+// at an unauthenticated plaintext endpoint instead, such as an emulator.
+func accessSecretManager(ctx context.Context, name string) (string, error) {
+	var opts []option.ClientOption
+	if host := os.Getenv("SECRET_MANAGER_EMULATOR_HOST"); host != "" {
+		opts = append(opts,
+			option.WithEndpoint(host),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	}
+	client, err := secretmanager.NewClient(ctx, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	resp, err := client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{Name: name})
+	if err != nil {
+		return "", err
+	}
+	return string(resp.GetPayload().GetData()), nil
+}
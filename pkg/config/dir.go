@@ -0,0 +1,71 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// dirMu serializes loads, which read dirValues.
+	dirMu sync.Mutex
+	// dirValues holds the settings read from a config directory during a load.
+	dirValues map[string]string
+)
+
+// LoadFromDir reads configuration like Load, taking settings missing from the
+// environment from the files in dir, as a Kubernetes ConfigMap or Secret mounted as a
+// volume: each file name is an environment variable name and the file content, trimmed
+// of surrounding whitespace, its value. Hidden files and subdirectories are ignored and
+// binary files are skipped with a warning.
+func LoadFromDir(dir string) (*Config, error) {
+	values, err := readConfigDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("CONFIG_DIR: %w", err)
+	}
+	return load(values)
+}
+
+// readConfigDir returns the settings held by the files in dir.
+func readConfigDir(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		// Mounted ConfigMaps keep their data in hidden ..data directories
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Keys are usually symlinks into ..data, so follow them
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.IndexByte(data, 0) >= 0 {
+			fmt.Fprintf(os.Stderr, "Warning: skipping binary file %s in CONFIG_DIR\n", path)
+			continue
+		}
+		values[entry.Name()] = strings.TrimSpace(string(data))
+	}
+	return values, nil
+}
+
+// lookupEnv returns the value of the environment variable key, or if it is not set the
+// value read from the config directory being loaded.
+func lookupEnv(key string) (string, bool) {
+	if val, ok := os.LookupEnv(key); ok {
+		return val, true
+	}
+	val, ok := dirValues[key]
+	return val, ok
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// gcpSecretTimeout limits a Secret Manager lookup, so that an unreachable API does not
+// hold up startup.
+const gcpSecretTimeout = 10 * time.Second
+
+// accessGCPSecret returns the payload of the Secret Manager secret version name. Builds
+// with the gcpsm tag replace it with a Secret Manager client (see config_gcpsm.go).
+var accessGCPSecret = func(ctx context.Context, name string) (string, error) {
+	return "", errors.New("Secret Manager support is not compiled in; build with -tags gcpsm")
+}
+
+// FetchGCPSecret returns the latest version of the Google Cloud Secret Manager secret
+// name, trimmed of surrounding whitespace. name is a resource name such as
+// projects/my-project/secrets/copilot-oauth-token, optionally with a /versions/ suffix,
+// or a bare secret name in the GOOGLE_CLOUD_PROJECT project.
+func FetchGCPSecret(ctx context.Context, name string) (string, error) {
+	version, err := gcpSecretVersionName(name)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, gcpSecretTimeout)
+	defer cancel()
+	secret, err := accessGCPSecret(ctx, version)
+	if err != nil {
+		return "", fmt.Errorf("GCP secret %s: %w", version, err)
+	}
+	return strings.TrimSpace(secret), nil
+}
+
+// gcpSecretVersionName returns the resource name of the secret version FetchGCPSecret
+// reads for name.
+func gcpSecretVersionName(name string) (string, error) {
+	if !strings.HasPrefix(name, "projects/") {
+		project := os.Getenv("GOOGLE_CLOUD_PROJECT")
+		if project == "" {
+			return "", fmt.Errorf("GCP secret %q: GOOGLE_CLOUD_PROJECT must be set for a secret name without a project", name)
+		}
+		name = "projects/" + project + "/secrets/" + name
+	}
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	return name, nil
+}
+
+// gcpSecretToken returns the OAuth token held by the GCP_SECRET_NAME secret, or "" if it
+// cannot be read. Errors are logged so the search can go on with the next source.
+func gcpSecretToken() string {
+	name := getEnv("GCP_SECRET_NAME", "")
+	if name == "" {
+		return ""
+	}
+	token, err := FetchGCPSecret(context.Background(), name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return ""
+	}
+	return token
+}
@@ -0,0 +1,174 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hiddenValue is written by Marshal in place of secrets.
+const hiddenValue = "<hidden>"
+
+// envSetting is an environment variable written by Marshal, with the comment describing
+// it and its value in a Config.
+type envSetting struct {
+	key     string
+	comment string
+	secret  bool
+	value   func(*Config) string
+}
+
+// envSettings are the settings written by Marshal, in the order of the Config fields.
+var envSettings = []envSetting{
+	{key: "SERVER_ADDR", comment: "Address to listen on if COPILOT_SERVER_PORT is empty", value: func(c *Config) string { return c.ServerAddr }},
+	{key: "DEBUG", comment: "Log requests and upstream traffic", value: func(c *Config) string { return strconv.FormatBool(c.Debug) }},
+	{key: "COPILOT_OAUTH_TOKEN", comment: "GitHub OAuth token; empty if it was found in a file of OAUTH_TOKEN_SEARCH_PATHS", secret: true,
+		value: func(c *Config) string {
+			if strings.HasPrefix(c.OAuthTokenSource, "$") && c.OAuthTokenSource != "$COPILOT_OAUTH_TOKENS" {
+				return c.CopilotOAuthToken
+			}
+			return ""
+		}},
+	{key: "COPILOT_OAUTH_TOKENS", comment: "Comma-separated GitHub OAuth tokens of several Copilot seats to rotate through", secret: true,
+		value: func(c *Config) string {
+			if c.OAuthTokenSource == "$COPILOT_OAUTH_TOKENS" {
+				return strings.Join(c.CopilotOAuthTokens, ",")
+			}
+			return ""
+		}},
+	{key: "COPILOT_TOKEN", comment: "Token clients authenticate with", secret: true, value: func(c *Config) string { return c.CopilotToken }},
+	{key: "ADMIN_TOKEN", comment: "Token for the /admin endpoints; empty disables them", secret: true, value: func(c *Config) string { return c.AdminToken }},
+	{key: "COPILOT_SERVER_PORT", comment: "Port to listen on", value: func(c *Config) string { return c.ServerPort }},
+	{key: "CORS_ALLOWED_ORIGINS", comment: "Comma-separated allowed CORS origins", value: func(c *Config) string { return c.CORSAllowedOrigins }},
+	{key: "DEFAULT_MODEL", comment: "Model used when a request names none", value: func(c *Config) string { return c.DefaultModel }},
+	{key: "COPILOT_BASE_URL", comment: "Copilot API base URL", value: func(c *Config) string { return c.CopilotBaseURL }},
+	{key: "COPILOT_ENDPOINTS", comment: "Comma-separated Copilot API base URLs to load balance across", value: func(c *Config) string { return strings.Join(c.CopilotEndpoints, ",") }},
+	{key: "API_KEYS", comment: "Comma-separated label:token client keys", secret: true,
+		value: func(c *Config) string {
+			keys := make([]string, len(c.APIKeys))
+			for i, k := range c.APIKeys {
+				keys[i] = k.Label + ":" + k.Token
+			}
+			return strings.Join(keys, ",")
+		}},
+	{key: "API_KEYS_STATE_FILE", comment: "File keeping keys added and revoked through /admin/keys", value: func(c *Config) string { return c.APIKeysStateFile }},
+	{key: "OAUTH_TOKEN_SEARCH_PATHS", comment: "Where the GitHub OAuth token is looked for, in priority order", value: func(c *Config) string {
+		return strings.Join(c.OAuthTokenSearchPaths, string(os.PathListSeparator))
+	}},
+	{key: "GCP_SECRET_NAME", comment: "Secret Manager secret holding the GitHub OAuth token", value: func(c *Config) string { return c.GCPSecretName }},
+	{key: "DEFAULT_CHAT_MODEL", comment: "Default model of /v1/chat/completions", value: func(c *Config) string { return c.DefaultChatModel }},
+	{key: "DEFAULT_EMBEDDING_MODEL", comment: "Default model of /v1/embeddings", value: func(c *Config) string { return c.DefaultEmbeddingModel }},
+	{key: "DEFAULT_ANTHROPIC_MODEL", comment: "Default model of /v1/messages", value: func(c *Config) string { return c.DefaultAnthropicModel }},
+	{key: "FALLBACK_MODEL", comment: "Model used in place of requested models missing from the models list", value: func(c *Config) string { return c.FallbackModel }},
+	{key: "DEFAULT_MAX_TOKENS", comment: "max_tokens set on chat completions that send none (0 = none)", value: func(c *Config) string { return strconv.Itoa(c.DefaultMaxTokens) }},
+	{key: "INTEGRATION_ID_MAP_FILE", comment: "JSON file mapping API key labels to Copilot-Integration-Id values", value: func(c *Config) string { return c.IntegrationIdMapFile }},
+	{key: "MAX_TOKENS_FILE", comment: "JSON file mapping API key labels to their largest max_tokens", value: func(c *Config) string { return c.MaxTokensFile }},
+	{key: "AUDIT_LOG_FILE", comment: "JSON Lines file an entry is appended to for every request", value: func(c *Config) string { return c.AuditLogFile }},
+	{key: "AUDIT_SIGNING_KEY", comment: "HMAC-SHA256 key signing the audit log entries", secret: true, value: func(c *Config) string { return c.AuditSigningKey }},
+	{key: "MODEL_ALIASES_FILE", comment: "JSON file mapping model aliases to Copilot models", value: func(c *Config) string { return c.ModelAliasFile }},
+	{key: "STRICT_MODEL_ALIASES", comment: "Refuse to start if an alias targets an unknown model", value: func(c *Config) string { return strconv.FormatBool(c.StrictModelAliases) }},
+	{key: "ENTERPRISE_ONLY_MODELS", comment: "Comma-separated models refused on individual Copilot plans", value: func(c *Config) string { return strings.Join(c.EnterpriseOnlyModels, ",") }},
+	{key: "TOKEN_PREWARM_SECONDS", comment: "Refresh the Copilot token this many seconds before it expires", value: func(c *Config) string { return strconv.Itoa(c.TokenPrewarmSeconds) }},
+	{key: "TOKEN_EXPIRY_GRACE_SECS", comment: "Treat the Copilot token as expired this many seconds early", value: func(c *Config) string { return strconv.Itoa(c.TokenExpiryGraceSecs) }},
+	{key: "PID_FILE", comment: "PID file written at startup", value: func(c *Config) string { return c.PIDFile }},
+	{key: "ENABLE_REQUEST_DEDUP", comment: "Share one upstream call between identical concurrent requests", value: func(c *Config) string { return strconv.FormatBool(c.EnableRequestDedup) }},
+	{key: "EMULATE_MULTIPLE_N", comment: "Serve n > 1 with one upstream request per choice", value: func(c *Config) string { return strconv.FormatBool(c.EmulateMultipleN) }},
+	{key: "ENABLE_SMART_ROUTING", comment: "Route to the smallest fitting model of the requested family", value: func(c *Config) string { return strconv.FormatBool(c.EnableSmartRouting) }},
+	{key: "ENABLE_METRICS", comment: "Serve Prometheus metrics on /metrics", value: func(c *Config) string { return strconv.FormatBool(c.EnableMetrics) }},
+	{key: "ENABLE_TUNNEL", comment: "Serve CONNECT tunnels to the Copilot API", value: func(c *Config) string { return strconv.FormatBool(c.EnableTunnel) }},
+	{key: "GITHUB_ENTERPRISE_URL", comment: "GitHub Enterprise Server URL", value: func(c *Config) string { return c.GitHubEnterpriseURL }},
+	{key: "GITHUB_AUTH_URL", comment: "Endpoint exchanging the OAuth token for a Copilot token", value: func(c *Config) string { return c.GitHubAuthURL }},
+	{key: "GITHUB_API_URL", comment: "GitHub REST API base URL", value: func(c *Config) string { return c.GitHubAPIURL }},
+	{key: "GITHUB_ORG", comment: "Organization whose Copilot usage /admin/upstream-usage serves", value: func(c *Config) string { return c.GitHubOrg }},
+	{key: "GITHUB_OAUTH_CLIENT_ID", comment: "OAuth app used by the login subcommand", value: func(c *Config) string { return c.GitHubOAuthClientID }},
+	{key: "WRITE_HOSTS_JSON", comment: "Write the OAuth token to the Copilot hosts.json on every refresh", value: func(c *Config) string { return strconv.FormatBool(c.WriteHostsJSON) }},
+	{key: "MIGRATE_OLD_CONFIG", comment: "Copy an OAuth token from ~/.copilot/config.json to hosts.json", value: func(c *Config) string { return strconv.FormatBool(c.MigrateOldConfig) }},
+	{key: "TRUSTED_PROXY_COUNT", comment: "Number of reverse proxies in front of the server", value: func(c *Config) string { return strconv.Itoa(c.TrustedProxyCount) }},
+	{key: "RATE_LIMIT_PER_MINUTE", comment: "Requests allowed per client IP per minute (0 = unlimited)", value: func(c *Config) string { return strconv.Itoa(c.RateLimitPerMinute) }},
+	{key: "RATE_LIMIT_BURST", comment: "Requests a client IP may send at once", value: func(c *Config) string { return strconv.Itoa(c.RateLimitBurst) }},
+	{key: "RATE_LIMITER_MAX_IPS", comment: "Client IPs tracked by the rate limiter", value: func(c *Config) string { return strconv.Itoa(c.RateLimiterMaxIPs) }},
+	{key: "FORWARD_RATE_LIMIT_HEADERS", comment: "Pass the Copilot X-RateLimit-* headers on to clients", value: func(c *Config) string { return strconv.FormatBool(c.ForwardRateLimitHeaders) }},
+	{key: "RESPONSE_CACHE_SIZE", comment: "Cached chat completion responses (0 = disabled)", value: func(c *Config) string { return strconv.Itoa(c.ResponseCacheSize) }},
+	{key: "RESPONSE_CACHE_TTL", comment: "How long cached responses are served", value: func(c *Config) string { return c.ResponseCacheTTL.String() }},
+	{key: "USAGE_CACHE_TTL", comment: "How long the GitHub Copilot usage report is cached", value: func(c *Config) string { return c.UsageCacheTTL.String() }},
+	{key: "MODELS_FETCH_TIMEOUT", comment: "Limit on fetching an empty models list for a request", value: func(c *Config) string { return c.ModelsFetchTimeout.String() }},
+	{key: "MODELS_REFRESH_TIMEOUT", comment: "Limit on background refreshes of the models list", value: func(c *Config) string { return c.ModelsRefreshTimeout.String() }},
+	{key: "USE_COPILOT_MODELS_ENDPOINT", comment: "Fetch the models list from the Copilot API", value: func(c *Config) string { return strconv.FormatBool(c.UsesCopilotModelsEndpoint) }},
+	{key: "MERGE_MODEL_LISTS", comment: "Combine the Copilot models with the catalog: union, intersection or empty", value: func(c *Config) string { return c.MergeModelLists }},
+	{key: "MODELS_CACHE_BACKEND", comment: "Where the models list is cached: memory or redis", value: func(c *Config) string { return c.ModelsCacheBackend }},
+	{key: "REDIS_ADDR", comment: "Redis host:port or redis:// URL of the redis models cache; a password in the URL is written as xxxxx",
+		value: func(c *Config) string {
+			if u, err := url.Parse(c.RedisAddr); err == nil && u.User != nil {
+				return u.Redacted()
+			}
+			return c.RedisAddr
+		}},
+	{key: "MODELS_CACHE_TTL", comment: "How long the models list is cached between refreshes", value: func(c *Config) string { return c.ModelsCacheTTL.String() }},
+	{key: "USER_AGENT", comment: "User-Agent sent upstream", value: func(c *Config) string { return c.UserAgent }},
+	{key: "STRIP_UPSTREAM_HEADERS", comment: "Comma-separated client headers withheld from Copilot", value: func(c *Config) string { return strings.Join(c.StripUpstreamHeaders, ",") }},
+	{key: "AUTH_EXEMPT_PATHS", comment: "Comma-separated paths served without authentication", value: func(c *Config) string { return strings.Join(c.AuthExemptPaths, ",") }},
+	{key: "SUPPORTED_ANTHROPIC_VERSIONS", comment: "Comma-separated anthropic-version values accepted", value: func(c *Config) string { return strings.Join(c.SupportedAnthropicVersions, ",") }},
+	{key: "MAX_REQUEST_BODY_BYTES", comment: "Largest accepted request body", value: func(c *Config) string { return strconv.FormatInt(c.MaxRequestBodyBytes, 10) }},
+	{key: "MAX_FILE_UPLOAD_BYTES", comment: "Largest accepted file upload (0 = unlimited)", value: func(c *Config) string { return strconv.FormatInt(c.MaxFileUploadBytes, 10) }},
+	{key: "AUDIO_API_URL", comment: "Whisper-compatible endpoint serving /v1/audio/transcriptions", value: func(c *Config) string { return c.AudioAPIURL }},
+	{key: "ASSISTANTS_API_URL", comment: "OpenAI Assistants API serving /v1/assistants and /v1/threads", value: func(c *Config) string { return c.AssistantsAPIURL }},
+	{key: "ASSISTANTS_API_KEY", comment: "Bearer token sent to ASSISTANTS_API_URL", secret: true, value: func(c *Config) string { return c.AssistantsAPIKey }},
+	{key: "TLS_CERT_FILE", comment: "Certificate to serve HTTPS with", value: func(c *Config) string { return c.TLSCertFile }},
+	{key: "TLS_KEY_FILE", comment: "Private key of TLS_CERT_FILE", value: func(c *Config) string { return c.TLSKeyFile }},
+	{key: "CSP_POLICY", comment: "Content-Security-Policy sent on every response", value: func(c *Config) string { return c.CSP }},
+	{key: "MAX_CONCURRENT_REQUESTS", comment: "Requests served at once (0 = unlimited)", value: func(c *Config) string { return strconv.Itoa(c.MaxConcurrentRequests) }},
+	{key: "UPSTREAM_MAX_ATTEMPTS", comment: "Attempts per upstream request on transport errors and 502/503/504", value: func(c *Config) string { return strconv.Itoa(c.UpstreamMaxAttempts) }},
+	{key: "MAX_UPSTREAM_CONCURRENCY", comment: "Requests in flight to the Copilot API (0 = unlimited)", value: func(c *Config) string { return strconv.Itoa(c.MaxUpstreamConcurrency) }},
+	{key: "SHUTDOWN_TIMEOUT", comment: "How long shutdown waits for in-flight requests", value: func(c *Config) string { return c.ShutdownTimeout.String() }},
+	{key: "PRE_SHUTDOWN_DELAY", comment: "How long /readyz fails before connections are drained", value: func(c *Config) string { return c.PreShutdownDelay.String() }},
+	{key: "UPSTREAM_MAX_IDLE_CONNS", comment: "Idle connections kept to the Copilot API", value: func(c *Config) string { return strconv.Itoa(c.UpstreamMaxIdleConns) }},
+	{key: "UPSTREAM_MAX_IDLE_CONNS_PER_HOST", comment: "Idle connections kept per Copilot API host", value: func(c *Config) string { return strconv.Itoa(c.UpstreamMaxIdleConnsPerHost) }},
+	{key: "UPSTREAM_IDLE_CONN_TIMEOUT", comment: "How long idle upstream connections are kept", value: func(c *Config) string { return c.UpstreamIdleConnTimeout.String() }},
+	{key: "UPSTREAM_TLS_HANDSHAKE_TIMEOUT", comment: "Timeout for TLS handshakes with the Copilot API", value: func(c *Config) string { return c.UpstreamTLSHandshakeTimeout.String() }},
+	{key: "UPSTREAM_EXPECT_CONTINUE_TIMEOUT", comment: "How long to wait for 100 Continue", value: func(c *Config) string { return c.UpstreamExpectContinueTimeout.String() }},
+	{key: "DNS_CACHE_TTL", comment: "How long resolved Copilot API addresses are reused", value: func(c *Config) string { return c.DNSCacheTTL.String() }},
+	{key: "FALLBACK_DNS_SERVERS", comment: "Comma-separated DNS servers asked when the system resolver fails", value: func(c *Config) string { return strings.Join(c.FallbackDNSServers, ",") }},
+	{key: "LOG_SAMPLE_RATE", comment: "Fraction of successful requests logged in debug mode", value: func(c *Config) string { return strconv.FormatFloat(c.LogSampleRate, 'g', -1, 64) }},
+	{key: "SLOW_REQUEST_THRESHOLD", comment: "Requests taking longer are always logged (0 = none)", value: func(c *Config) string { return c.SlowRequestThreshold.String() }},
+	{key: "STREAM_BUFFER_SIZE", comment: "Read buffer size for streamed responses in bytes", value: func(c *Config) string { return strconv.Itoa(c.StreamBufferSize) }},
+	{key: "STREAM_FLUSH_INTERVAL", comment: "Batch streamed data for this long between flushes", value: func(c *Config) string { return c.StreamFlushInterval.String() }},
+}
+
+// plainEnvValue matches values written to a .env file without quotes.
+var plainEnvValue = regexp.MustCompile(`^[A-Za-z0-9_./:,@+*-]*$`)
+
+// Marshal renders cfg as a .env file that Load reads back to the same configuration:
+// one commented KEY=VALUE line per setting. Secrets are written as <hidden> and must be
+// filled in by hand, except a COPILOT_TOKEN generated by Load, which is written with a
+// warning to replace it. Settings read from JSON files are written as the file names.
+func Marshal(cfg *Config) string {
+	var b strings.Builder
+	b.WriteString("# go-copilot-api configuration\n")
+	b.WriteString("# Secrets are written as " + hiddenValue + ": fill them in, or set the matching *_FILE variable instead.\n")
+	for _, s := range envSettings {
+		value := s.value(cfg)
+		b.WriteString("\n# " + s.comment + "\n")
+		switch {
+		case s.key == "COPILOT_TOKEN" && cfg.CopilotTokenGenerated:
+			b.WriteString("# WARNING: generated at random because COPILOT_TOKEN was not set; replace it with a token of your own\n")
+		case s.secret && value != "":
+			b.WriteString("# Secret: replace " + hiddenValue + " with the value\n")
+			b.WriteString(s.key + "=" + hiddenValue + "\n")
+			continue
+		}
+		b.WriteString(s.key + "=" + quoteEnvValue(value) + "\n")
+	}
+	return b.String()
+}
+
+// quoteEnvValue returns value as written in a .env file: as is if it holds no special
+// characters, otherwise double-quoted with the characters godotenv interprets escaped.
+func quoteEnvValue(value string) string {
+	if plainEnvValue.MatchString(value) {
+		return value
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "!", `\!`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(value) + `"`
+}
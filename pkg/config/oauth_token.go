@@ -0,0 +1,211 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Special OAuth token search path entries. Any other entry is a file path.
+const (
+	// SearchGHCLI is the GitHub CLI hosts.yml.
+	SearchGHCLI = "gh"
+	// SearchKeychain is the GitHub CLI item in the macOS Keychain.
+	SearchKeychain = "keychain"
+	// SearchGCPSecret is the GCP_SECRET_NAME secret in Google Cloud Secret Manager.
+	SearchGCPSecret = "gcp-secret"
+)
+
+// errOAuthTokenNotFound is returned by findOAuthToken when no search path holds a token.
+var errOAuthTokenNotFound = errors.New("GitHub OAuth token not found")
+
+// DefaultOAuthTokenSearchPaths returns the places searched for the Copilot OAuth token
+// unless OAUTH_TOKEN_SEARCH_PATHS is set, in priority order: the COPILOT_OAUTH_TOKEN and
+// GITHUB_TOKEN variables, the GCP_SECRET_NAME secret, the COPILOT_OAUTH_TOKEN_FILE file,
+// the Copilot apps.json under XDG_CONFIG_HOME, the Copilot apps.json and hosts.json in
+// the user config directory, the GitHub CLI hosts.yml and the macOS Keychain.
+func DefaultOAuthTokenSearchPaths() []string {
+	paths := []string{"$COPILOT_OAUTH_TOKEN", "$GITHUB_TOKEN"}
+	if getEnv("GCP_SECRET_NAME", "") != "" {
+		paths = append(paths, SearchGCPSecret)
+	}
+	if file := getEnv("COPILOT_OAUTH_TOKEN_FILE", ""); file != "" {
+		paths = append(paths, file)
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		paths = append(paths, filepath.Join(xdg, "github-copilot", "apps.json"))
+	}
+	var configDir string
+	if runtime.GOOS == "windows" {
+		configDir = os.Getenv("LOCALAPPDATA")
+	} else if home, err := os.UserHomeDir(); err == nil {
+		configDir = filepath.Join(home, ".config")
+	}
+	if configDir != "" {
+		paths = append(paths,
+			filepath.Join(configDir, "github-copilot", "apps.json"),
+			filepath.Join(configDir, "github-copilot", "hosts.json"))
+	}
+	return append(paths, SearchGHCLI, SearchKeychain)
+}
+
+// findOAuthToken returns the first OAuth token found in searchPaths. An entry of the
+// form $NAME is an environment variable holding the token, SearchGHCLI and
+// SearchKeychain are the GitHub CLI stores, SearchGCPSecret is the GCP_SECRET_NAME
+// secret, and anything else is a file: a Copilot apps.json or hosts.json if it ends in
+// .json, otherwise a file holding only the token. Sources that are missing or
+// unreadable are skipped. If enterpriseHost is set only
+// tokens for that GitHub Enterprise Server host are considered. The search path entry
+// holding the token is returned with it.
+func findOAuthToken(searchPaths []string, enterpriseHost string) (string, string, error) {
+	for _, path := range searchPaths {
+		var token string
+		switch {
+		case strings.HasPrefix(path, "$"):
+			token = strings.TrimSpace(getEnv(path[1:], ""))
+		case path == SearchGHCLI:
+			token = ghCLIToken(enterpriseHost)
+		case path == SearchKeychain:
+			token = keychainToken(enterpriseHost)
+		case path == SearchGCPSecret:
+			token = gcpSecretToken()
+		case strings.HasSuffix(path, ".json"):
+			token = copilotConfigToken(expandHome(path), enterpriseHost)
+		default:
+			if data, err := os.ReadFile(expandHome(path)); err == nil {
+				token = strings.TrimSpace(string(data))
+			}
+		}
+		if token != "" {
+			return token, path, nil
+		}
+	}
+	return "", "", errOAuthTokenNotFound
+}
+
+// parseSearchPaths splits an OAUTH_TOKEN_SEARCH_PATHS value on the OS path list
+// separator, colon on Unix and semicolon on Windows.
+func parseSearchPaths(val string) []string {
+	var paths []string
+	for _, p := range filepath.SplitList(val) {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// expandHome replaces a leading ~ in path with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
+
+// copilotConfigToken returns the first oauth_token of a Copilot apps.json or hosts.json.
+func copilotConfigToken(path, enterpriseHost string) string {
+	if tokens := copilotConfigTokens(path, enterpriseHost); len(tokens) > 0 {
+		return tokens[0]
+	}
+	return ""
+}
+
+// copilotConfigTokens returns the distinct oauth_tokens of a Copilot apps.json or
+// hosts.json, ordered by their keys.
+func copilotConfigTokens(path, enterpriseHost string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var apps map[string]struct {
+		User        string `json:"user"`
+		OAuthToken  string `json:"oauth_token"`
+		GitHubAppId string `json:"githubAppId"`
+	}
+	if err := json.Unmarshal(data, &apps); err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(apps))
+	for key := range apps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var tokens []string
+	for _, key := range keys {
+		if enterpriseHost != "" && !strings.Contains(key, enterpriseHost) {
+			continue
+		}
+		if token := apps[key].OAuthToken; token != "" && !slices.Contains(tokens, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// ghCLIToken returns the oauth_token the GitHub CLI stores in its hosts.yml when it is
+// not using the system keyring.
+func ghCLIToken(enterpriseHost string) string {
+	dir := os.Getenv("GH_CONFIG_DIR")
+	if dir == "" {
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			dir = filepath.Join(xdg, "gh")
+		} else if runtime.GOOS == "windows" {
+			dir = filepath.Join(os.Getenv("AppData"), "GitHub CLI")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gh")
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "hosts.yml"))
+	if err != nil {
+		return ""
+	}
+	var hosts map[string]struct {
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return ""
+	}
+	return hosts[tokenHost(enterpriseHost)].OAuthToken
+}
+
+// keychainToken returns the token the GitHub CLI stores in the macOS Keychain.
+func keychainToken(enterpriseHost string) string {
+	if runtime.GOOS != "darwin" {
+		return ""
+	}
+	out, err := exec.Command("security", "find-generic-password", "-s", "gh:"+tokenHost(enterpriseHost), "-w").Output()
+	if err != nil {
+		return ""
+	}
+	token := strings.TrimSpace(string(out))
+	if encoded, ok := strings.CutPrefix(token, "go-keyring-base64:"); ok {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return ""
+		}
+		token = string(decoded)
+	}
+	return token
+}
+
+// tokenHost returns the GitHub host the GitHub CLI stores the token under.
+func tokenHost(enterpriseHost string) string {
+	if enterpriseHost == "" {
+		return "github.com"
+	}
+	return enterpriseHost
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ValidationError describes an invalid configuration value.
+type ValidationError struct {
+	Field   string
+	Value   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Message)
+}
+
+// Validate checks cfg for values that would make the server misbehave and returns one
+// error per invalid field.
+func Validate(cfg *Config) []ValidationError {
+	var errs []ValidationError
+	invalid := func(field, value, message string) {
+		errs = append(errs, ValidationError{Field: field, Value: value, Message: message})
+	}
+
+	if cfg.ServerPort != "" {
+		if port, err := strconv.Atoi(cfg.ServerPort); err != nil || port < 1 || port > 65535 {
+			invalid("ServerPort", cfg.ServerPort, "must be a port number between 1 and 65535")
+		}
+	}
+	if cfg.CORSAllowedOrigins != "*" {
+		for _, origin := range strings.Split(cfg.CORSAllowedOrigins, ",") {
+			origin = strings.TrimSpace(origin)
+			u, err := url.Parse(origin)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				invalid("CORSAllowedOrigins", origin, "must be * or a comma-separated list of origin URLs")
+			}
+		}
+	}
+	for _, u := range []struct{ field, value string }{
+		{"CopilotBaseURL", cfg.CopilotBaseURL},
+		{"GitHubAuthURL", cfg.GitHubAuthURL},
+		{"GitHubAPIURL", cfg.GitHubAPIURL},
+		{"AudioAPIURL", cfg.AudioAPIURL},
+		{"AssistantsAPIURL", cfg.AssistantsAPIURL},
+	} {
+		if u.value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(u.value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			invalid(u.field, u.value, "must be an http or https URL")
+		}
+	}
+	for _, m := range []struct{ field, value string }{
+		{"DefaultModel", cfg.DefaultModel},
+		{"DefaultChatModel", cfg.DefaultChatModel},
+		{"DefaultEmbeddingModel", cfg.DefaultEmbeddingModel},
+		{"DefaultAnthropicModel", cfg.DefaultAnthropicModel},
+		{"FallbackModel", cfg.FallbackModel},
+	} {
+		if strings.ContainsAny(m.value, " \t\r\n") {
+			invalid(m.field, m.value, "must be a model ID without spaces")
+		}
+	}
+	switch cfg.MergeModelLists {
+	case "", "union", "intersection":
+	default:
+		invalid("MergeModelLists", cfg.MergeModelLists, "must be union, intersection or empty")
+	}
+	switch cfg.ModelsCacheBackend {
+	case "", "memory":
+	case "redis":
+		if cfg.RedisAddr == "" {
+			invalid("RedisAddr", cfg.RedisAddr, "must be set when ModelsCacheBackend is redis")
+		}
+	default:
+		invalid("ModelsCacheBackend", cfg.ModelsCacheBackend, "must be memory or redis")
+	}
+	if cfg.LogSampleRate < 0 || cfg.LogSampleRate > 1 {
+		invalid("LogSampleRate", strconv.FormatFloat(cfg.LogSampleRate, 'g', -1, 64), "must be between 0.0 and 1.0")
+	}
+	if cfg.MaxRequestBodyBytes <= 0 {
+		invalid("MaxRequestBodyBytes", strconv.FormatInt(cfg.MaxRequestBodyBytes, 10), "must be positive")
+	}
+	for label, limit := range cfg.MaxTokensPerKey {
+		if limit <= 0 {
+			invalid("MaxTokensPerKey", label+"="+strconv.Itoa(limit), "limits must be positive")
+		}
+	}
+	for _, server := range cfg.FallbackDNSServers {
+		host := server
+		if h, _, err := net.SplitHostPort(server); err == nil {
+			host = h
+		}
+		if net.ParseIP(host) == nil {
+			invalid("FallbackDNSServers", server, "must be an IP address, optionally with a port")
+		}
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		invalid("TLSCertFile", cfg.TLSCertFile+","+cfg.TLSKeyFile, "TLS_CERT_FILE and TLS_KEY_FILE must both be set or both be empty")
+	}
+	return errs
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestAdminKeys(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "keys.json")
+	cfg := &config.Config{CopilotToken: "test-token", AdminToken: "admin-token", APIKeys: []config.APIKey{{Label: "team-a", Token: "token-a"}}}
+	keys, err := api.NewKeyStore(cfg.APIKeys, statePath)
+	if err != nil {
+		t.Fatalf("NewKeyStore: %v", err)
+	}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithKeyStore(keys))
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	list := func() map[string]map[string]interface{} {
+		rr := do(http.MethodGet, "/admin/keys", "admin-token", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected list 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if strings.Contains(rr.Body.String(), "token-") {
+			t.Errorf("expected tokens to be hidden, got %s", rr.Body.String())
+		}
+		var resp struct {
+			Keys []map[string]interface{} `json:"keys"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid list response: %v", err)
+		}
+		byLabel := make(map[string]map[string]interface{})
+		for _, k := range resp.Keys {
+			byLabel[k["label"].(string)] = k
+		}
+		return byLabel
+	}
+
+	if rr := do(http.MethodGet, "/admin/keys", "token-a", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("expected a non-admin key to be refused, got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/v1/files", "token-b", ""); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected an unknown key to be refused, got %d", rr.Code)
+	}
+
+	rr := do(http.MethodPost, "/admin/keys", "admin-token", `{"label":"team-b","token":"token-b","daily_limit":2}`)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, body := range []string{`{"label":"team-b","token":"token-c"}`, `{"label":"team-c","token":"token-a"}`} {
+		if rr := do(http.MethodPost, "/admin/keys", "admin-token", body); rr.Code != http.StatusConflict {
+			t.Errorf("%s: expected 409 for a duplicate, got %d", body, rr.Code)
+		}
+	}
+	for _, body := range []string{`{"token":"token-c"}`, `{"label":"admin","token":"token-c"}`, `{"label":"team-c","token":"token-c","daily_limit":-1}`} {
+		if rr := do(http.MethodPost, "/admin/keys", "admin-token", body); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, rr.Code)
+		}
+	}
+
+	// The new key authenticates at once, up to its daily limit
+	for i := 0; i < 2; i++ {
+		if rr := do(http.MethodGet, "/v1/files", "token-b", ""); rr.Code == http.StatusForbidden || rr.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d: expected the added key to authenticate, got %d", i+1, rr.Code)
+		}
+	}
+	if rr := do(http.MethodGet, "/v1/files", "token-b", ""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 past the daily limit, got %d", rr.Code)
+	}
+	if b := list()["team-b"]; b["request_count"] != float64(2) || b["last_used"] == nil || b["daily_limit"] != float64(2) {
+		t.Errorf("expected two counted requests for team-b, got %v", b)
+	}
+
+	if rr := do(http.MethodDelete, "/admin/keys/team-a", "admin-token", ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodDelete, "/admin/keys/team-a", "admin-token", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a revoked key, got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "/v1/files", "token-a", ""); rr.Code != http.StatusForbidden {
+		t.Errorf("expected the revoked key to be refused, got %d", rr.Code)
+	}
+
+	// A restart with the same API_KEYS keeps the changes
+	restarted, err := api.NewKeyStore(cfg.APIKeys, statePath)
+	if err != nil {
+		t.Fatalf("NewKeyStore: %v", err)
+	}
+	handler = api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithKeyStore(restarted))
+	got := list()
+	if _, ok := got["team-a"]; ok || len(got) != 1 || got["team-b"] == nil {
+		t.Errorf("expected only team-b after a restart, got %v", got)
+	}
+}
+
+func TestKeyStoreConfigStaysAuthoritative(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "keys.json")
+	keys, err := api.NewKeyStore([]config.APIKey{{Label: "team-a", Token: "token-a"}}, statePath)
+	if err != nil {
+		t.Fatalf("NewKeyStore: %v", err)
+	}
+	if err := keys.Add(config.APIKey{Label: "team-b", Token: "token-b"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := keys.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "token-a") || !strings.Contains(string(data), "token-b") {
+		t.Errorf("expected only the key added at runtime to be saved, got %s", data)
+	}
+
+	// The token of team-a is rotated in API_KEYS before the restart
+	cfg := &config.Config{CopilotToken: "test-token", APIKeys: []config.APIKey{{Label: "team-a", Token: "token-a2"}}}
+	restarted, err := api.NewKeyStore(cfg.APIKeys, statePath)
+	if err != nil {
+		t.Fatalf("NewKeyStore: %v", err)
+	}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithKeyStore(restarted))
+	for token, want := range map[string]bool{"token-a": false, "token-a2": true, "token-b": true} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/files", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if got := rr.Code != http.StatusForbidden; got != want {
+			t.Errorf("%s: expected accepted=%v, got status %d", token, want, rr.Code)
+		}
+	}
+}
+
+func TestKeyStoreRejectsReservedLabels(t *testing.T) {
+	if _, err := api.NewKeyStore([]config.APIKey{{Label: "admin", Token: "token-a"}}, ""); err == nil {
+		t.Error("expected a configured key labelled admin to be rejected")
+	}
+
+	statePath := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(statePath, []byte(`{"keys":[{"label":"admin","token":"token-a"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.NewKeyStore(nil, statePath); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("expected a saved key labelled admin to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestAnthropicSamplingParameters(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","top_p":0.9,"choices":[{"finish_reason":"stop"}]}`))
+	}))
+	defer upstream.Close()
+
+	buf := captureLog(t)
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, Debug: true}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"gpt-4o","max_tokens":100,"messages":[{"role":"user","content":"hi"}],"top_p":0.9,"top_k":40,"stop_sequences":["END","STOP"]}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	t.Run("request", func(t *testing.T) {
+		if upstreamBody["top_p"] != 0.9 {
+			t.Errorf("expected top_p 0.9 upstream, got %v", upstreamBody["top_p"])
+		}
+		if want := []interface{}{"END", "STOP"}; !reflect.DeepEqual(upstreamBody["stop"], want) {
+			t.Errorf("expected stop %v upstream, got %v", want, upstreamBody["stop"])
+		}
+		for _, field := range []string{"top_k", "stop_sequences"} {
+			if _, ok := upstreamBody[field]; ok {
+				t.Errorf("expected %s not to be sent upstream", field)
+			}
+		}
+		if !strings.Contains(buf.String(), "top_k") {
+			t.Errorf("expected dropped top_k to be logged in debug mode, got %q", buf.String())
+		}
+	})
+
+	t.Run("response", func(t *testing.T) {
+		var resp map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		if resp["top_p"] != 0.9 {
+			t.Errorf("expected top_p 0.9 in response, got %v", resp["top_p"])
+		}
+		if resp["stop_reason"] != "stop" {
+			t.Errorf("expected stop_reason stop, got %v", resp["stop_reason"])
+		}
+	})
+}
+
+func TestUserFieldForwarding(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{"anthropic metadata.user_id", "/v1/messages", `{"messages":[{"role":"user","content":"hi"}],"metadata":{"user_id":"u123"}}`},
+		{"openai user", "/v1/chat/completions", `{"messages":[{"role":"user","content":"hi"}],"user":"u123"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if upstreamBody["user"] != "u123" {
+				t.Errorf("expected user u123 upstream, got %v", upstreamBody["user"])
+			}
+			if _, ok := upstreamBody["metadata"]; ok {
+				t.Errorf("expected metadata not to be sent upstream")
+			}
+		})
+	}
+}
+
+func TestAnthropicSeedRoundTrip(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","seed":42,"choices":[{"finish_reason":"stop"}]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"gpt-4o","max_tokens":100,"temperature":0,"seed":42,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if upstreamBody["seed"] != float64(42) {
+		t.Errorf("expected seed 42 upstream, got %v", upstreamBody["seed"])
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if resp["seed"] != float64(42) {
+		t.Errorf("expected seed 42 in response, got %v", resp["seed"])
+	}
+}
+
+func TestAnthropicContentBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "text only",
+			message: `{"role":"assistant","content":"Hello there"}`,
+			want:    `[{"type":"text","text":"Hello there"}]`,
+		},
+		{
+			name:    "tool only",
+			message: `{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}`,
+			want:    `[{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}}]`,
+		},
+		{
+			name:    "text and tools",
+			message: `{"role":"assistant","content":"Checking both","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},{"id":"call_2","type":"function","function":{"name":"get_time","arguments":""}}]}`,
+			want:    `[{"type":"text","text":"Checking both"},{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}},{"type":"tool_use","id":"call_2","name":"get_time","input":{}}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"message":` + tt.message + `,"finish_reason":"stop"}]}`))
+			}))
+			defer upstream.Close()
+
+			cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+			handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"gpt-4o","max_tokens":100,"messages":[{"role":"user","content":"hi"}]}`))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+
+			var resp struct {
+				Content []interface{} `json:"content"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			var want []interface{}
+			_ = json.Unmarshal([]byte(tt.want), &want)
+			if !reflect.DeepEqual(resp.Content, want) {
+				t.Errorf("expected content %s, got %v", tt.want, resp.Content)
+			}
+		})
+	}
+}
+
+func TestAnthropicUnknownFieldsForwarded(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"finish_reason":"stop"}]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(
+		`{"model":"gpt-4o","max_tokens":100,"messages":[{"role":"user","content":"hi"}],"new_anthropic_field":true,"thinking":{"type":"enabled","budget_tokens":1024},"top_k":5,"response_format":{"type":"json_object"}}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if upstreamBody["new_anthropic_field"] != true {
+		t.Errorf("expected new_anthropic_field to be forwarded, got %v", upstreamBody["new_anthropic_field"])
+	}
+	want := map[string]interface{}{"type": "enabled", "budget_tokens": float64(1024)}
+	if !reflect.DeepEqual(upstreamBody["thinking"], want) {
+		t.Errorf("expected thinking %v upstream, got %v", want, upstreamBody["thinking"])
+	}
+	for _, field := range []string{"top_k", "response_format"} {
+		if _, ok := upstreamBody[field]; ok {
+			t.Errorf("expected dropped %s not to be sent upstream", field)
+		}
+	}
+}
+
+func TestAnthropicResponseContentLength(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		var body string
+		if req["stream"] == true {
+			body = "data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+				"data: {\"id\":\"chatcmpl-1\",\"model\":\"gpt-4o\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n" +
+				"data: [DONE]\n\n"
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			body = `{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`
+			w.Header().Set("Content-Type", "application/json")
+		}
+		// Declared up front, as Copilot does for buffered responses
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	srv := httptest.NewServer(api.NewRouter(cfg, newTestTokenManager(t), nil))
+	defer srv.Close()
+
+	for _, stream := range []bool{false, true} {
+		reqBody := `{"model":"gpt-4o","max_tokens":10,"stream":` + strconv.FormatBool(stream) + `,"messages":[{"role":"user","content":"hi"}]}`
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/messages", strings.NewReader(reqBody))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("stream=%v: request failed: %v", stream, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("stream=%v: failed to read the whole body: %v", stream, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("stream=%v: expected status 200, got %d: %s", stream, resp.StatusCode, body)
+		}
+		if resp.ContentLength != -1 && resp.ContentLength != int64(len(body)) {
+			t.Errorf("stream=%v: Content-Length %d does not match the %d byte body", stream, resp.ContentLength, len(body))
+		}
+		if !stream {
+			if resp.ContentLength != int64(len(body)) {
+				t.Errorf("expected a Content-Length of %d, got %d", len(body), resp.ContentLength)
+			}
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(body, &msg); err != nil || msg.Type != "message" {
+				t.Errorf("expected a complete Anthropic message, got %s (%v)", body, err)
+			}
+		} else if !strings.Contains(string(body), "[DONE]") {
+			t.Errorf("expected the converted stream to be complete, got %s", body)
+		}
+	}
+}
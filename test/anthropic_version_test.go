@@ -0,0 +1,66 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestAnthropicVersionHeader(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"message":{"content":"hi"},"finish_reason":"stop"}]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	tests := []struct {
+		name       string
+		version    string
+		wantStatus int
+	}{
+		{"missing", "", http.StatusOK},
+		{"valid", "2023-06-01", http.StatusOK},
+		{"unsupported", "2099-01-01", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"gpt-4o","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("anthropic-beta", "tools-2024-04-04")
+			if tt.version != "" {
+				req.Header.Set("anthropic-version", tt.version)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusBadRequest {
+				return
+			}
+			var body struct {
+				Type  string `json:"type"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON error body: %v", err)
+			}
+			if body.Type != "error" || body.Error.Type != "invalid_request_error" || !strings.Contains(body.Error.Message, tt.version) {
+				t.Errorf("expected an Anthropic invalid_request_error naming %s, got %s", tt.version, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestAssistantsProxy(t *testing.T) {
+	type seen struct {
+		method, uri, body, beta, auth string
+		header                        http.Header
+	}
+	var got seen
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = seen{r.Method, r.URL.RequestURI(), string(body), strings.Join(r.Header.Values("OpenAI-Beta"), ","), r.Header.Get("Authorization"), r.Header.Clone()}
+		if strings.HasSuffix(r.URL.Path, "/runs") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("event: thread.run.created\ndata: {\"id\":\"run_1\"}\n\ndata: [DONE]\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"asst_1","object":"assistant"}`))
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", AssistantsAPIURL: backend.URL + "/v1/", AssistantsAPIKey: "sk-assistants"}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	tests := []struct {
+		method, path, body string
+		wantURI            string
+	}{
+		{http.MethodGet, "/v1/assistants?limit=5&order=desc", "", "/v1/assistants?limit=5&order=desc"},
+		{http.MethodPost, "/v1/assistants", `{"model":"gpt-4o"}`, "/v1/assistants"},
+		{http.MethodDelete, "/v1/assistants/asst_1", "", "/v1/assistants/asst_1"},
+		{http.MethodPost, "/v1/threads/thread_1/messages", `{"role":"user","content":"hi"}`, "/v1/threads/thread_1/messages"},
+		{http.MethodPost, "/v1/threads/thread_1/runs", `{"assistant_id":"asst_1","stream":true}`, "/v1/threads/thread_1/runs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			got = seen{}
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("OpenAI-Beta", "assistants=v1")
+			req.Header.Set("X-Client-Header", "private")
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if got.method != tt.method || got.uri != tt.wantURI || got.body != tt.body {
+				t.Errorf("expected %s %s with body %q upstream, got %s %s with body %q", tt.method, tt.wantURI, tt.body, got.method, got.uri, got.body)
+			}
+			if got.beta != "assistants=v2" {
+				t.Errorf("expected OpenAI-Beta: assistants=v2 upstream, got %q", got.beta)
+			}
+			if got.auth != "Bearer sk-assistants" {
+				t.Errorf("expected ASSISTANTS_API_KEY upstream, got %q", got.auth)
+			}
+			for _, h := range []string{"Copilot-Integration-Id", "Editor-Version", "X-Client-Header"} {
+				if v := got.header.Get(h); v != "" {
+					t.Errorf("expected no %s sent to the Assistants API, got %q", h, v)
+				}
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/threads/thread_1/runs", strings.NewReader(`{"assistant_id":"asst_1","stream":true}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" || !strings.Contains(rr.Body.String(), "thread.run.created") {
+		t.Errorf("expected the run event stream to be passed through, got %q: %s", ct, rr.Body.String())
+	}
+}
+
+func TestAssistantsProxyWithoutKey(t *testing.T) {
+	auth := "unset"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer backend.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", AssistantsAPIURL: backend.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+	req := httptest.NewRequest(http.MethodGet, "/v1/assistants", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if auth != "" {
+		t.Errorf("expected no Authorization header without ASSISTANTS_API_KEY, got %q", auth)
+	}
+}
+
+func TestAssistantsNotConfigured(t *testing.T) {
+	handler := api.NewRouter(&config.Config{CopilotToken: "test-token"}, newTestTokenManager(t), nil)
+	for _, path := range []string{"/v1/assistants", "/v1/assistants/asst_1", "/v1/threads", "/v1/threads/thread_1/runs"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer test-token")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("%s: expected status 501, got %d", path, rr.Code)
+			continue
+		}
+		var resp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || !strings.Contains(resp.Error.Message, "ASSISTANTS_API_URL") {
+			t.Errorf("%s: expected an error naming ASSISTANTS_API_URL, got %s", path, rr.Body.String())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestAudioTranscriptions(t *testing.T) {
+	var gotModel, gotAudio, gotAuth string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "bad multipart body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		audio, _ := io.ReadAll(file)
+		gotModel, gotAudio = r.FormValue("model"), string(audio)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"text":"hello world"}`))
+	}))
+	defer backend.Close()
+
+	transcribe := func(cfg *config.Config) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("model", "whisper-1")
+		part, _ := mw.CreateFormFile("file", "speech.wav")
+		_, _ = part.Write([]byte("RIFF-fake-audio"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &body)
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rr := httptest.NewRecorder()
+		api.NewRouter(cfg, newTestTokenManager(t), nil).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		rr := transcribe(&config.Config{CopilotToken: "test-token"})
+		if rr.Code != http.StatusNotImplemented {
+			t.Fatalf("expected status 501, got %d", rr.Code)
+		}
+		var resp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Error.Message == "" {
+			t.Errorf("expected a JSON error message, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("proxied", func(t *testing.T) {
+		rr := transcribe(&config.Config{CopilotToken: "test-token", AudioAPIURL: backend.URL + "/v1/audio/transcriptions"})
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if rr.Body.String() != `{"text":"hello world"}` {
+			t.Errorf("expected the backend response, got %s", rr.Body.String())
+		}
+		if gotModel != "whisper-1" || gotAudio != "RIFF-fake-audio" {
+			t.Errorf("expected model and file to be forwarded, got model %q and file %q", gotModel, gotAudio)
+		}
+		if gotAuth != "" {
+			t.Errorf("expected the client token not to be forwarded, got %q", gotAuth)
+		}
+	})
+}
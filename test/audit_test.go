@@ -0,0 +1,107 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/audit"
+	"copilot-api/pkg/config"
+)
+
+func TestAuditLogSigning(t *testing.T) {
+	const signingKey = "audit-secret"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	var buf bytes.Buffer
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithAuditLog(audit.NewLogger(&buf, signingKey)))
+
+	for _, target := range []string{"/v1/chat/completions", "/v1/embeddings", "/v1/chat/completions"} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"input":"hi"}`))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 audit entries, got %d: %s", len(lines), buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatalf("invalid audit entry: %v", err)
+	}
+	if entry["path"] != "/v1/embeddings" || entry["status"] != float64(200) || entry["key"] != "default" || entry["sig"] == "" {
+		t.Errorf("unexpected audit entry %v", entry)
+	}
+	if strings.Contains(buf.String(), signingKey) {
+		t.Error("expected the signing key not to appear in the audit log")
+	}
+
+	invalid, err := audit.Verify(strings.NewReader(buf.String()), signingKey)
+	if err != nil || len(invalid) != 0 {
+		t.Fatalf("expected all entries to verify, got invalid lines %v (err %v)", invalid, err)
+	}
+	if invalid, _ := audit.Verify(strings.NewReader(buf.String()), "other-key"); len(invalid) != 3 {
+		t.Errorf("expected every entry to fail with another key, got invalid lines %v", invalid)
+	}
+
+	// Tamper with the status of the second entry
+	lines[1] = strings.Replace(lines[1], `"status":200`, `"status":500`, 1)
+	invalid, err = audit.Verify(strings.NewReader(strings.Join(lines, "\n")), signingKey)
+	if err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if !reflect.DeepEqual(invalid, []int{2}) {
+		t.Errorf("expected line 2 to be reported as tampered, got %v", invalid)
+	}
+}
+
+func TestAuditLogUser(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}))
+	defer upstream.Close()
+
+	var buf bytes.Buffer
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithAuditLog(audit.NewLogger(&buf, "")))
+
+	requests := []struct{ target, body, wantUser string }{
+		{"/v1/chat/completions", `{"model":"gpt-4o","user":"u-openai","messages":[{"role":"user","content":"hi"}]}`, "u-openai"},
+		{"/v1/messages", `{"model":"gpt-4o","max_tokens":16,"metadata":{"user_id":"u123"},"messages":[{"role":"user","content":"hi"}]}`, "u123"},
+		{"/v1/chat/completions", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, ""},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(http.MethodPost, r.target, strings.NewReader(r.body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(requests) {
+		t.Fatalf("expected %d audit entries, got %d: %s", len(requests), len(lines), buf.String())
+	}
+	for i, line := range lines {
+		var entry audit.Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid audit entry: %v", err)
+		}
+		if entry.Status != http.StatusOK || entry.User != requests[i].wantUser {
+			t.Errorf("%s: expected status 200 and user %q, got %d and %q", requests[i].target, requests[i].wantUser, entry.Status, entry.User)
+		}
+	}
+}
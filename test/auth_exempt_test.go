@@ -0,0 +1,50 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestAuthExemptPaths(t *testing.T) {
+	tests := []struct {
+		name         string
+		exempt       []string
+		path         string
+		wantExempted bool
+	}{
+		{"default healthz", nil, "/healthz", true},
+		{"default models", nil, "/v1/models", true},
+		{"default provider models", nil, "/v1/providers/openai/models", true},
+		{"default chat", nil, "/v1/chat/completions", false},
+		{"configured exact path", []string{"/status"}, "/status", true},
+		{"exact path is not a prefix", []string{"/status"}, "/status/db", false},
+		{"unlisted default path", []string{"/status"}, "/v1/models", false},
+		{"wildcard sub-path", []string{"/public/*"}, "/public/docs/index.html", true},
+		{"wildcard does not match siblings", []string{"/public/*"}, "/publicity", false},
+		{"empty list", []string{}, "/healthz", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{CopilotToken: "test-token", AuthExemptPaths: tt.exempt}
+			reached := false
+			handler := api.AuthMiddleware(cfg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reached = true
+			}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if reached != tt.wantExempted {
+				t.Errorf("expected exempted=%v for %s, got status %d", tt.wantExempted, tt.path, rr.Code)
+			}
+			if !tt.wantExempted && rr.Code != http.StatusUnauthorized {
+				t.Errorf("expected 401 without a token, got %d", rr.Code)
+			}
+		})
+	}
+}
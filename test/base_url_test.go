@@ -0,0 +1,71 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+func TestCopilotBaseURL(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","model":"gpt-4o","choices":[],"data":[]}`))
+	}))
+	defer upstream.Close()
+	tokenServer, calls := newTokenServer(t, time.Hour)
+
+	setTestConfigHome(t)
+	t.Setenv("COPILOT_OAUTH_TOKEN", "test-oauth-token")
+	t.Setenv("COPILOT_TOKEN", "test-token")
+	// The path is dropped so handler paths are not appended to it
+	t.Setenv("COPILOT_BASE_URL", upstream.URL+"/v1/")
+	t.Setenv("GITHUB_AUTH_URL", tokenServer.URL)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.CopilotBaseURL != upstream.URL {
+		t.Errorf("expected CopilotBaseURL %s, got %s", upstream.URL, cfg.CopilotBaseURL)
+	}
+
+	tm := startTestTokenManager(t, copilot.WithAuthURL(cfg.GitHubAuthURL), copilot.WithOAuthToken(cfg.CopilotOAuthToken))
+	handler := api.NewRouter(cfg, tm, nil)
+
+	requests := []struct{ target, body string }{
+		{"/v1/chat/completions", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`},
+		{"/v1/embeddings", `{"model":"text-embedding-3-small","input":"hi"}`},
+		{"/v1/messages", `{"model":"gpt-4o","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`},
+	}
+	for _, tt := range requests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", tt.target, rr.Code, rr.Body.String())
+		}
+	}
+
+	mu.Lock()
+	got := strings.Join(paths, ",")
+	mu.Unlock()
+	if want := "/chat/completions,/embeddings,/chat/completions"; got != want {
+		t.Errorf("expected upstream paths %s, got %s", want, got)
+	}
+	if calls.Load() == 0 {
+		t.Error("expected the Copilot token to be fetched from GITHUB_AUTH_URL")
+	}
+}
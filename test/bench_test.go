@@ -0,0 +1,152 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/bench"
+)
+
+// newBenchServer returns a mock chat completions server that answers after delay with
+// 10 completion tokens, streamed as five chunks if the request asks for a stream.
+func newBenchServer(t *testing.T, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer bench-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		const usage = `{"prompt_tokens":12,"completion_tokens":10,"total_tokens":22}`
+		if req["stream"] != true {
+			time.Sleep(delay)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id":"chatcmpl-1","choices":[{"message":{"content":"hi"}}],"usage":%s}`, usage)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < 5; i++ {
+			time.Sleep(delay / 5)
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"tok%d \"}}]}\n\n", i)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprintf(w, "data: {\"choices\":[],\"usage\":%s}\n\ndata: [DONE]\n\n", usage)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestBenchmarkThroughput(t *testing.T) {
+	srv, requests := newBenchServer(t, 20*time.Millisecond)
+
+	res, err := bench.Run(context.Background(), bench.Options{
+		Addr:        srv.URL,
+		Token:       "bench-token",
+		Concurrency: 4,
+		Duration:    300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Requests != int(requests.Load()) || res.Requests < 8 {
+		t.Fatalf("expected at least 8 requests matching the %d served, got %d", requests.Load(), res.Requests)
+	}
+	if res.SuccessRate != 1 || len(res.Errors) != 0 {
+		t.Errorf("expected every request to succeed, got rate %v and errors %v", res.SuccessRate, res.Errors)
+	}
+	if res.Latency.P50 < 20 || res.Latency.P99 < res.Latency.P50 || res.Latency.P99 > 1000 {
+		t.Errorf("expected latencies of at least 20ms in order, got %+v", res.Latency)
+	}
+	if res.CompletionTokens != 10*res.Successes || res.PromptTokens != 12*res.Successes {
+		t.Errorf("expected usage summed over %d requests, got %d prompt and %d completion tokens", res.Successes, res.PromptTokens, res.CompletionTokens)
+	}
+	if want := float64(res.CompletionTokens) / res.DurationSeconds; res.TokensPerSecond != want || want < 100 {
+		t.Errorf("expected at least 100 tokens/second, computed as %v, got %v", want, res.TokensPerSecond)
+	}
+	if res.TimeToFirstToken != nil {
+		t.Errorf("expected no time to first token without streaming, got %+v", res.TimeToFirstToken)
+	}
+
+	var table bytes.Buffer
+	if err := res.WriteTable(&table); err != nil {
+		t.Fatalf("WriteTable: %v", err)
+	}
+	if !strings.Contains(table.String(), "Tokens/second") {
+		t.Errorf("expected a tokens/second row, got %q", table.String())
+	}
+}
+
+func TestBenchmarkStreaming(t *testing.T) {
+	srv, _ := newBenchServer(t, 50*time.Millisecond)
+
+	res, err := bench.Run(context.Background(), bench.Options{
+		Addr:        srv.URL,
+		Token:       "bench-token",
+		Concurrency: 2,
+		Duration:    200 * time.Millisecond,
+		Stream:      true,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Successes == 0 || res.SuccessRate != 1 {
+		t.Fatalf("expected every request to succeed, got %+v", res)
+	}
+	if res.CompletionTokens != 10*res.Successes {
+		t.Errorf("expected the usage chunk to be counted, got %d completion tokens for %d requests", res.CompletionTokens, res.Successes)
+	}
+	ttft := res.TimeToFirstToken
+	if ttft == nil {
+		t.Fatal("expected time to first token to be reported")
+	}
+	// The first chunk arrives after a fifth of the response time
+	if ttft.P50 < 10 || ttft.P50 >= res.Latency.P50 {
+		t.Errorf("expected time to first token between 10ms and the %vms latency, got %+v", res.Latency.P50, ttft)
+	}
+}
+
+func TestBenchmarkErrorsAndRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	res, err := bench.Run(context.Background(), bench.Options{
+		Addr:        srv.URL,
+		Concurrency: 1,
+		Duration:    300 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	// Retry-After reaches past the end of the run, so no second request is made
+	if res.Requests != 1 || requests.Load() != 1 {
+		t.Errorf("expected a single request, got %d (%d served)", res.Requests, requests.Load())
+	}
+	if res.Errors["http_429"] != 1 || res.SuccessRate != 0 {
+		t.Errorf("expected one http_429 error, got %v with success rate %v", res.Errors, res.SuccessRate)
+	}
+
+	srv.Close()
+	res, err = bench.Run(context.Background(), bench.Options{Addr: srv.URL, Duration: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Errors["network"] == 0 || res.Successes != 0 {
+		t.Errorf("expected network errors against a closed server, got %+v", res)
+	}
+}
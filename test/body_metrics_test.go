@@ -0,0 +1,123 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+// bodySizeSamples returns the sample count of the body size histogram name for path and
+// model, and its cumulative bucket counts by upper bound.
+func bodySizeSamples(t *testing.T, registry *prometheus.Registry, name, path, model string) (uint64, map[float64]uint64) {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["path"] != path || labels["model"] != model {
+				continue
+			}
+			buckets := map[float64]uint64{}
+			for _, b := range m.GetHistogram().GetBucket() {
+				buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			return m.GetHistogram().GetSampleCount(), buckets
+		}
+	}
+	return 0, nil
+}
+
+func TestBodySizeMetrics(t *testing.T) {
+	// Answers with as many bytes of content as the request's max_tokens, streamed if asked
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			MaxTokens int  `json:"max_tokens"`
+			Stream    bool `json:"stream"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		content := strings.Repeat("a", req.MaxTokens)
+		if req.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 0; i < 4; i++ {
+				_, _ = w.Write([]byte(`data: {"choices":[{"delta":{"content":"` + content + `"}}]}` + "\n\n"))
+				w.(http.Flusher).Flush()
+			}
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"content":"` + content + `"}}]}`))
+	}))
+	defer upstream.Close()
+
+	registry := prometheus.NewRegistry()
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithMetrics(registry))
+	post := func(model, prompt string, maxTokens int, stream bool) {
+		t.Helper()
+		body, _ := json.Marshal(map[string]interface{}{
+			"model":      model,
+			"max_tokens": maxTokens,
+			"stream":     stream,
+			"messages":   []map[string]string{{"role": "user", "content": prompt}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(string(body)))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	post("gpt-4o", "hi", 10, false)
+	post("gpt-4o", "hello", 20, false)
+	post("gpt-4o", strings.Repeat("long prompt ", 20000), 200_000, false) // ~240KB in, ~200KB out
+	post("o1", "stream", 50_000, true)                                    // 4 chunks of ~50KB
+
+	const path = "/v1/chat/completions"
+	count, buckets := bodySizeSamples(t, registry, "copilot_request_body_bytes", path, "gpt-4o")
+	if count != 3 {
+		t.Fatalf("expected 3 request samples for gpt-4o, got %d", count)
+	}
+	if buckets[1<<10] != 2 || buckets[100<<10] != 2 || buckets[1<<20] != 3 {
+		t.Errorf("expected two requests under 1KB and one between 100KB and 1MB, got %v", buckets)
+	}
+	count, buckets = bodySizeSamples(t, registry, "copilot_response_body_bytes", path, "gpt-4o")
+	if count != 3 || buckets[1<<10] != 2 || buckets[100<<10] != 2 || buckets[1<<20] != 3 {
+		t.Errorf("expected two responses under 1KB and one between 100KB and 1MB, got %d samples in %v", count, buckets)
+	}
+
+	// The streamed chunks add up to about 200KB
+	count, buckets = bodySizeSamples(t, registry, "copilot_response_body_bytes", path, "o1")
+	if count != 1 || buckets[100<<10] != 0 || buckets[1<<20] != 1 {
+		t.Errorf("expected the stream between 100KB and 1MB, got %d samples in %v", count, buckets)
+	}
+
+	// Requests without a body only count the response, under their route
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if count, _ := bodySizeSamples(t, registry, "copilot_request_body_bytes", "/readyz", ""); count != 0 {
+		t.Errorf("expected no request sample without a body, got %d", count)
+	}
+	if count, _ := bodySizeSamples(t, registry, "copilot_response_body_bytes", "/readyz", ""); count != 1 {
+		t.Errorf("expected a response sample for /readyz, got %d", count)
+	}
+}
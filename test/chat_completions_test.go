@@ -0,0 +1,206 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// mockCopilot is a mock Copilot chat completions API that records the requests it gets.
+// Requests authenticated with a token other than validToken are rejected with 401.
+type mockCopilot struct {
+	*httptest.Server
+	mu         sync.Mutex
+	validToken string
+	requests   []*http.Request
+	bodies     []map[string]interface{}
+}
+
+func newMockCopilot(t *testing.T, validToken string) *mockCopilot {
+	t.Helper()
+	m := &mockCopilot{validToken: validToken}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockCopilot) serve(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	m.mu.Lock()
+	m.requests = append(m.requests, r)
+	m.bodies = append(m.bodies, body)
+	valid := m.validToken
+	m.mu.Unlock()
+
+	if r.URL.Path != "/chat/completions" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":{"message":"unauthorized: token expired"}}`))
+		return
+	}
+	if body["stream"] == true {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, content := range []string{"Hel", "lo"} {
+			_, _ = io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"`+content+`"}}]}`+"\n\n")
+			w.(http.Flusher).Flush()
+		}
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Request-Id", "upstream-1")
+	_, _ = w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"Hello"},"finish_reason":"stop"}],"usage":{"prompt_tokens":8,"completion_tokens":1,"total_tokens":9}}`))
+}
+
+// received returns the requests the mock has served so far.
+func (m *mockCopilot) received() ([]*http.Request, []map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*http.Request(nil), m.requests...), append([]map[string]interface{}(nil), m.bodies...)
+}
+
+// postChatBody sends a chat completion request with body to handler as an authenticated client.
+func postChatBody(handler http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestChatCompletionsRoundTrip(t *testing.T) {
+	upstream := newMockCopilot(t, "test-copilot-token")
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	t.Run("non-streaming", func(t *testing.T) {
+		rr := postChatBody(handler, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+
+		requests, bodies := upstream.received()
+		if len(requests) != 1 {
+			t.Fatalf("expected 1 upstream request, got %d", len(requests))
+		}
+		got := requests[0]
+		for header, want := range map[string]string{
+			"Authorization":          "Bearer test-copilot-token",
+			"Copilot-Integration-Id": "vscode-chat",
+			"Content-Type":           "application/json",
+		} {
+			if v := got.Header.Get(header); v != want {
+				t.Errorf("expected upstream %s %q, got %q", header, want, v)
+			}
+		}
+		if got.Method != http.MethodPost {
+			t.Errorf("expected a POST upstream, got %s", got.Method)
+		}
+		if bodies[0]["model"] != "gpt-4o" {
+			t.Errorf("expected the request body to be forwarded, got %v", bodies[0])
+		}
+
+		var resp struct {
+			ID      string `json:"id"`
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", rr.Body.String(), err)
+		}
+		if resp.ID != "chatcmpl-1" || len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "Hello" {
+			t.Errorf("unexpected response %s", rr.Body.String())
+		}
+		if rr.Header().Get("X-Request-Id") != "upstream-1" {
+			t.Errorf("expected upstream headers to be forwarded, got %v", rr.Header())
+		}
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		rr := postChatBody(handler, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" {
+			t.Errorf("expected Content-Type text/event-stream, got %q", ct)
+		}
+		var content strings.Builder
+		var events []string
+		for _, line := range strings.Split(rr.Body.String(), "\n") {
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			events = append(events, data)
+			var chunk struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+			}
+			if json.Unmarshal([]byte(data), &chunk) == nil && len(chunk.Choices) > 0 {
+				content.WriteString(chunk.Choices[0].Delta.Content)
+			}
+		}
+		if len(events) != 3 || events[2] != "[DONE]" {
+			t.Errorf("expected two chunks and [DONE], got %q", events)
+		}
+		if content.String() != "Hello" {
+			t.Errorf("expected the streamed content Hello, got %q", content.String())
+		}
+	})
+}
+
+func TestChatCompletionsRefreshesRejectedToken(t *testing.T) {
+	// The token in token.json has expired upstream; only a refreshed one is accepted
+	upstream := newMockCopilot(t, "refreshed-token-1")
+	tokenSrv, calls := newTokenServer(t, time.Hour)
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t, copilot.WithAuthURL(tokenSrv.URL)), nil)
+
+	rr := postChatBody(handler, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the request to succeed after a token refresh, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected 1 token refresh, got %d", n)
+	}
+	requests, _ := upstream.received()
+	if len(requests) != 2 {
+		t.Fatalf("expected the rejected request to be sent again, got %d upstream requests", len(requests))
+	}
+	if got := requests[1].Header.Get("Authorization"); got != "Bearer refreshed-token-1" {
+		t.Errorf("expected the retry to use the refreshed token, got %q", got)
+	}
+
+	// A token that is still rejected after the refresh is reported to the client
+	upstream.mu.Lock()
+	upstream.validToken = "never-issued"
+	upstream.mu.Unlock()
+	rr = postChatBody(handler, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected the upstream 401 to be forwarded, got %d", rr.Code)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected one more token refresh, got %d in total", n)
+	}
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+// weatherTool is a valid function tool definition.
+const weatherTool = `{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}`
+
+func TestChatCompletionsValidation(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	tests := []struct {
+		name      string
+		body      string
+		wantParam string // empty means the request is valid
+	}{
+		{name: "valid base case", body: `{"messages":[{"role":"user","content":"hi"}]}`},
+		{name: "valid with all options", body: `{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":[{"type":"text","text":"hi"}]}],"stream":false,"temperature":0.7,"max_tokens":256}`},
+		{name: "valid tool call message", body: `{"messages":[{"role":"assistant","content":null,"tool_calls":[]}]}`},
+		{name: "valid temperature bounds", body: `{"messages":[{"role":"user","content":"hi"}],"temperature":2}`},
+		{name: "missing messages", body: `{"model":"gpt-4o"}`, wantParam: "messages"},
+		{name: "null messages", body: `{"messages":null}`, wantParam: "messages"},
+		{name: "messages not an array", body: `{"messages":"hi"}`, wantParam: "messages"},
+		{name: "message not an object", body: `{"messages":["hi"]}`, wantParam: "messages[0]"},
+		{name: "missing role", body: `{"messages":[{"content":"hi"}]}`, wantParam: "messages[0].role"},
+		{name: "role not a string", body: `{"messages":[{"role":1,"content":"hi"}]}`, wantParam: "messages[0].role"},
+		{name: "missing content", body: `{"messages":[{"role":"user","content":"hi"},{"role":"user"}]}`, wantParam: "messages[1].content"},
+		{name: "content is a number", body: `{"messages":[{"role":"user","content":42}]}`, wantParam: "messages[0].content"},
+		{name: "content is an object", body: `{"messages":[{"role":"user","content":{"text":"hi"}}]}`, wantParam: "messages[0].content"},
+		{name: "stream not a boolean", body: `{"messages":[{"role":"user","content":"hi"}],"stream":"true"}`, wantParam: "stream"},
+		{name: "temperature not a number", body: `{"messages":[{"role":"user","content":"hi"}],"temperature":"hot"}`, wantParam: "temperature"},
+		{name: "temperature below range", body: `{"messages":[{"role":"user","content":"hi"}],"temperature":-0.1}`, wantParam: "temperature"},
+		{name: "temperature above range", body: `{"messages":[{"role":"user","content":"hi"}],"temperature":2.5}`, wantParam: "temperature"},
+		{name: "max_tokens zero", body: `{"messages":[{"role":"user","content":"hi"}],"max_tokens":0}`, wantParam: "max_tokens"},
+		{name: "n zero", body: `{"messages":[{"role":"user","content":"hi"}],"n":0}`, wantParam: "n"},
+		{name: "max_tokens negative", body: `{"messages":[{"role":"user","content":"hi"}],"max_tokens":-5}`, wantParam: "max_tokens"},
+		{name: "max_tokens fractional", body: `{"messages":[{"role":"user","content":"hi"}],"max_tokens":1.5}`, wantParam: "max_tokens"},
+		{name: "max_tokens string", body: `{"messages":[{"role":"user","content":"hi"}],"max_tokens":"100"}`, wantParam: "max_tokens"},
+		{name: "valid seed", body: `{"messages":[{"role":"user","content":"hi"}],"seed":42}`},
+		{name: "seed fractional", body: `{"messages":[{"role":"user","content":"hi"}],"seed":4.2}`, wantParam: "seed"},
+		{name: "seed string", body: `{"messages":[{"role":"user","content":"hi"}],"seed":"42"}`, wantParam: "seed"},
+		{name: "valid tools", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[` + weatherTool + `],"tool_choice":"auto"}`},
+		{name: "valid named tool_choice", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[` + weatherTool + `],"tool_choice":{"type":"function","function":{"name":"get_weather"}}}`},
+		{name: "valid tool_choice required", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[` + weatherTool + `],"tool_choice":"required"}`},
+		{name: "tools not an array", body: `{"messages":[{"role":"user","content":"hi"}],"tools":{}}`, wantParam: "tools"},
+		{name: "tool not an object", body: `{"messages":[{"role":"user","content":"hi"}],"tools":["get_weather"]}`, wantParam: "tools[0]"},
+		{name: "tool type not function", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"retrieval","function":{"name":"f","parameters":{"type":"object","properties":{}}}}]}`, wantParam: "tools[0].type"},
+		{name: "tool missing function", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function"}]}`, wantParam: "tools[0].function"},
+		{name: "function missing name", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[` + weatherTool + `,{"type":"function","function":{"parameters":{"type":"object","properties":{}}}}]}`, wantParam: "tools[1].function.name"},
+		{name: "function name with spaces", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"get weather","parameters":{"type":"object","properties":{}}}}]}`, wantParam: "tools[0].function.name"},
+		{name: "function name too long", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"` + strings.Repeat("a", 65) + `","parameters":{"type":"object","properties":{}}}}]}`, wantParam: "tools[0].function.name"},
+		{name: "function missing parameters", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"f"}}]}`, wantParam: "tools[0].function.parameters"},
+		{name: "parameters not an object schema", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"f","parameters":{"type":"string"}}}]}`, wantParam: "tools[0].function.parameters.type"},
+		{name: "parameters missing properties", body: `{"messages":[{"role":"user","content":"hi"}],"tools":[{"type":"function","function":{"name":"f","parameters":{"type":"object"}}}]}`, wantParam: "tools[0].function.parameters.properties"},
+		{name: "unknown tool_choice", body: `{"messages":[{"role":"user","content":"hi"}],"tool_choice":"any"}`, wantParam: "tool_choice"},
+		{name: "tool_choice wrong type", body: `{"messages":[{"role":"user","content":"hi"}],"tool_choice":{"type":"tool","function":{"name":"f"}}}`, wantParam: "tool_choice.type"},
+		{name: "tool_choice missing function", body: `{"messages":[{"role":"user","content":"hi"}],"tool_choice":{"type":"function"}}`, wantParam: "tool_choice.function"},
+		{name: "tool_choice invalid name", body: `{"messages":[{"role":"user","content":"hi"}],"tool_choice":{"type":"function","function":{"name":"get.weather"}}}`, wantParam: "tool_choice.function.name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamCalls.Store(0)
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if tt.wantParam == "" {
+				if rr.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+				}
+				if upstreamCalls.Load() != 1 {
+					t.Errorf("expected request to be forwarded upstream")
+				}
+				return
+			}
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if upstreamCalls.Load() != 0 {
+				t.Errorf("invalid request was forwarded upstream")
+			}
+			var got struct {
+				Error struct {
+					Message string `json:"message"`
+					Type    string `json:"type"`
+					Param   string `json:"param"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("response is not an OpenAI error: %v", err)
+			}
+			if got.Error.Type != "invalid_request_error" {
+				t.Errorf("expected invalid_request_error, got %q", got.Error.Type)
+			}
+			if got.Error.Param != tt.wantParam {
+				t.Errorf("expected param %q, got %q (%s)", tt.wantParam, got.Error.Param, got.Error.Message)
+			}
+			if !strings.HasPrefix(got.Error.Message, tt.wantParam+" ") {
+				t.Errorf("expected the message to name %s, got %q", tt.wantParam, got.Error.Message)
+			}
+		})
+	}
+}
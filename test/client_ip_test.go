@@ -0,0 +1,66 @@
+package test
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+// captureLog redirects the standard logger into a buffer for the duration of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestRealClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies int
+		remoteAddr     string
+		xff            string
+		xRealIP        string
+		wantIP         string
+	}{
+		{name: "no trusted proxies ignores headers", trustedProxies: 0, remoteAddr: "10.0.0.1:1234", xff: "203.0.113.7", wantIP: "10.0.0.1"},
+		{name: "one proxy uses forwarded client", trustedProxies: 1, remoteAddr: "10.0.0.1:1234", xff: "203.0.113.7", wantIP: "203.0.113.7"},
+		{name: "one proxy ignores spoofed entries", trustedProxies: 1, remoteAddr: "10.0.0.1:1234", xff: "198.51.100.9, 203.0.113.7", wantIP: "203.0.113.7"},
+		{name: "one proxy prefers X-Real-IP", trustedProxies: 1, remoteAddr: "10.0.0.1:1234", xff: "198.51.100.9", xRealIP: "203.0.113.8", wantIP: "203.0.113.8"},
+		{name: "two proxies", trustedProxies: 2, remoteAddr: "10.0.0.2:1234", xff: "198.51.100.9, 203.0.113.7, 10.0.0.1", wantIP: "203.0.113.7"},
+		{name: "two proxies ignore X-Real-IP", trustedProxies: 2, remoteAddr: "10.0.0.2:1234", xff: "203.0.113.7, 10.0.0.1", xRealIP: "198.51.100.9", wantIP: "203.0.113.7"},
+		{name: "more proxies than entries uses leftmost", trustedProxies: 5, remoteAddr: "10.0.0.1:1234", xff: "203.0.113.7", wantIP: "203.0.113.7"},
+		{name: "no forwarded header", trustedProxies: 2, remoteAddr: "10.0.0.1:1234", wantIP: "10.0.0.1"},
+		{name: "ipv6 client", trustedProxies: 1, remoteAddr: "[::1]:1234", xff: "2001:db8::1", wantIP: "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := captureLog(t)
+			cfg := &config.Config{Debug: true, LogSampleRate: 1, TrustedProxyCount: tt.trustedProxies}
+			handler := api.NewRouter(cfg, nil, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !strings.Contains(buf.String(), tt.wantIP+" GET /healthz") {
+				t.Errorf("expected client IP %s in log, got %q", tt.wantIP, buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"copilot-api/pkg/config"
+)
+
+// writeConfigDir writes one file per key in a new temporary directory, as a mounted
+// ConfigMap, and returns the directory.
+func writeConfigDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestLoadFromDir(t *testing.T) {
+	setTestConfigHome(t)
+	for _, key := range []string{"COPILOT_TOKEN", "DEBUG", "COPILOT_SERVER_PORT", "COPILOT_ENDPOINTS", "DEFAULT_MODEL", "STREAM_BUFFER_SIZE"} {
+		unsetEnv(t, key)
+	}
+	dir := writeConfigDir(t, map[string]string{
+		"COPILOT_TOKEN":       "dir-token\n",
+		"DEBUG":               "true",
+		"COPILOT_SERVER_PORT": "5555\n",
+		"COPILOT_ENDPOINTS":   "https://a.example.com,https://b.example.com",
+		"STREAM_BUFFER_SIZE":  "8192",
+		"DEFAULT_MODEL":       "gpt-4o\x00\x01",
+		".hidden":             "ignored",
+	})
+	if runtime.GOOS != "windows" {
+		// Mounted ConfigMaps link each key into a hidden ..data directory
+		data := filepath.Join(dir, "..data")
+		if err := os.Mkdir(data, 0o700); err != nil {
+			t.Fatalf("failed to create ..data: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(data, "ADMIN_TOKEN"), []byte("dir-admin-token"), 0o600); err != nil {
+			t.Fatalf("failed to write ADMIN_TOKEN: %v", err)
+		}
+		if err := os.Symlink(filepath.Join("..data", "ADMIN_TOKEN"), filepath.Join(dir, "ADMIN_TOKEN")); err != nil {
+			t.Fatalf("failed to link ADMIN_TOKEN: %v", err)
+		}
+	}
+	// Environment variables take priority over the directory
+	t.Setenv("COPILOT_SERVER_PORT", "1234")
+
+	cfg, err := config.LoadFromDir(dir)
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	if cfg.CopilotToken != "dir-token" || cfg.CopilotTokenGenerated {
+		t.Errorf("expected COPILOT_TOKEN from the directory, got %q (generated %v)", cfg.CopilotToken, cfg.CopilotTokenGenerated)
+	}
+	if !cfg.Debug {
+		t.Error("expected DEBUG from the directory")
+	}
+	if cfg.StreamBufferSize != 8192 {
+		t.Errorf("expected STREAM_BUFFER_SIZE 8192, got %d", cfg.StreamBufferSize)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(cfg.CopilotEndpoints, want) {
+		t.Errorf("expected COPILOT_ENDPOINTS %v, got %v", want, cfg.CopilotEndpoints)
+	}
+	if cfg.ServerPort != "1234" {
+		t.Errorf("expected the environment to override the directory, got port %q", cfg.ServerPort)
+	}
+	if cfg.DefaultModel != "" {
+		t.Errorf("expected the binary DEFAULT_MODEL file to be skipped, got %q", cfg.DefaultModel)
+	}
+	if runtime.GOOS != "windows" && cfg.AdminToken != "dir-admin-token" {
+		t.Errorf("expected ADMIN_TOKEN through the symlink, got %q", cfg.AdminToken)
+	}
+}
+
+func TestLoadConfigDirEnv(t *testing.T) {
+	setTestConfigHome(t)
+	unsetEnv(t, "COPILOT_TOKEN")
+	t.Setenv("CONFIG_DIR", writeConfigDir(t, map[string]string{"COPILOT_TOKEN": "dir-token"}))
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CopilotToken != "dir-token" {
+		t.Errorf("expected COPILOT_TOKEN from CONFIG_DIR, got %q", cfg.CopilotToken)
+	}
+
+	// Settings are read from the directory only while loading
+	cfg, err = config.LoadFromDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadFromDir: %v", err)
+	}
+	if !cfg.CopilotTokenGenerated {
+		t.Errorf("expected a generated token for an empty directory, got %q", cfg.CopilotToken)
+	}
+
+	t.Setenv("CONFIG_DIR", filepath.Join(t.TempDir(), "missing"))
+	if _, err := config.Load(); err == nil {
+		t.Error("expected an error for a missing CONFIG_DIR")
+	}
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"copilot-api/pkg/config"
+)
+
+func TestConfigValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantField string
+	}{
+		{"valid defaults", nil, ""},
+		{"port out of range", map[string]string{"COPILOT_SERVER_PORT": "70000"}, "ServerPort"},
+		{"port not a number", map[string]string{"COPILOT_SERVER_PORT": "http"}, "ServerPort"},
+		{"origin not a URL", map[string]string{"CORS_ALLOWED_ORIGINS": "https://ok.example.com,not-a-url"}, "CORSAllowedOrigins"},
+		{"model with spaces", map[string]string{"DEFAULT_MODEL": "gpt 4o"}, "DefaultModel"},
+		{"body limit not positive", map[string]string{"MAX_REQUEST_BODY_BYTES": "0"}, "MaxRequestBodyBytes"},
+		{"partial TLS config", map[string]string{"TLS_CERT_FILE": "/etc/tls/cert.pem"}, "TLSCertFile"},
+		{"base URL without scheme", map[string]string{"COPILOT_BASE_URL": "api.example.com"}, "CopilotBaseURL"},
+		{"log sample rate above 1", map[string]string{"LOG_SAMPLE_RATE": "1.5"}, "LogSampleRate"},
+		{"unknown model list merge", map[string]string{"MERGE_MODEL_LISTS": "both"}, "MergeModelLists"},
+		{"API key labelled admin", map[string]string{"API_KEYS": "team:t1,admin:t2"}, "API_KEYS"},
+		{"API key labelled default", map[string]string{"API_KEYS": "default:t1"}, "API_KEYS"},
+		{"unknown models cache backend", map[string]string{"MODELS_CACHE_BACKEND": "memcached"}, "ModelsCacheBackend"},
+		{"redis models cache without address", map[string]string{"MODELS_CACHE_BACKEND": "redis"}, "RedisAddr"},
+		{"fallback DNS server not an IP", map[string]string{"FALLBACK_DNS_SERVERS": "1.1.1.1,dns.example.com"}, "FallbackDNSServers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestConfigHome(t)
+			t.Setenv("COPILOT_OAUTH_TOKEN", "test-oauth-token")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := config.Load()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("expected valid config, got %v", err)
+				}
+				if errs := config.Validate(cfg); len(errs) != 0 {
+					t.Errorf("expected no validation errors, got %v", errs)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected an error naming %s", tt.wantField)
+			}
+			if !strings.Contains(err.Error(), tt.wantField) {
+				t.Errorf("expected error to name %s, got %q", tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestConfigValidationReportsEveryField(t *testing.T) {
+	cfg := &config.Config{
+		ServerPort:          "0",
+		CORSAllowedOrigins:  "*",
+		MaxRequestBodyBytes: -1,
+		TLSKeyFile:          "/etc/tls/key.pem",
+	}
+	errs := config.Validate(cfg)
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	if got := strings.Join(fields, ","); got != "ServerPort,MaxRequestBodyBytes,TLSCertFile" {
+		t.Errorf("expected errors for ServerPort, MaxRequestBodyBytes and TLSCertFile, got %s", got)
+	}
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestContentTypeMiddleware(t *testing.T) {
+	cfg := &config.Config{CopilotToken: "test-token"}
+	handler := api.NewRouter(cfg, nil, nil)
+
+	const body = `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"json", http.MethodPost, "/v1/chat/completions/count_tokens", "application/json", body, http.StatusOK},
+		{"json with charset", http.MethodPost, "/v1/chat/completions/count_tokens", "application/json; charset=utf-8", body, http.StatusOK},
+		{"form encoded", http.MethodPost, "/v1/chat/completions/count_tokens", "application/x-www-form-urlencoded", "model=gpt-4", http.StatusUnsupportedMediaType},
+		{"text plain", http.MethodPut, "/v1/chat/completions/count_tokens", "text/plain", body, http.StatusUnsupportedMediaType},
+		{"missing", http.MethodPost, "/v1/chat/completions/count_tokens", "", body, http.StatusUnsupportedMediaType},
+		{"GET", http.MethodGet, "/v1/messages/count_tokens", "", "", http.StatusMethodNotAllowed},
+		// Without a token manager /healthz reports an error, but the body is not rejected
+		{"exempt path", http.MethodPost, "/healthz", "text/plain", "ping", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if rr.Code == http.StatusUnsupportedMediaType {
+				var resp struct {
+					Error struct {
+						Type string `json:"type"`
+					} `json:"error"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.Error.Type != "invalid_request_error" {
+					t.Errorf("expected JSON error body, got %q", rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestMaxRequestBody(t *testing.T) {
+	cfg := &config.Config{CopilotToken: "test-token", MaxRequestBodyBytes: 64}
+	handler := api.NewRouter(cfg, nil, nil)
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"` + strings.Repeat("a", 100) + `"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions/count_tokens", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", rr.Code)
+	}
+}
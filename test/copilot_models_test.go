@@ -0,0 +1,88 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+// copilotModelsJSON is a Copilot API models list sharing gpt-4o with testModelsJSON.
+const copilotModelsJSON = `{"object":"list","data":[
+	{"id":"gpt-4o","name":"GPT-4o","vendor":"Azure OpenAI","version":"gpt-4o-2024-11-20","capabilities":{"limits":{"max_prompt_tokens":64000,"max_output_tokens":4096},"supports":{"streaming":true,"tool_calls":true}}},
+	{"id":"claude-3.5-sonnet","name":"Claude 3.5 Sonnet","vendor":"Anthropic","capabilities":{"limits":{"max_prompt_tokens":90000,"max_output_tokens":8192},"supports":{"streaming":true,"vision":true}}}
+]}`
+
+func TestCopilotModelsEndpoint(t *testing.T) {
+	var gotAuth string
+	copilotAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		if r.URL.Path != "/models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(copilotModelsJSON))
+	}))
+	defer copilotAPI.Close()
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+	tokens := func(ctx context.Context) (string, error) { return "copilot-token", nil }
+
+	tests := []struct {
+		merge string
+		want  []string
+	}{
+		{copilot.MergeNone, []string{"gpt-4o", "claude-3.5-sonnet"}},
+		{copilot.MergeUnion, []string{"openai/gpt-4o", "openai/gpt-4o-mini", "meta/llama-3.3-70b-instruct", "claude-3.5-sonnet"}},
+		{copilot.MergeIntersection, []string{"openai/gpt-4o"}},
+	}
+	for _, tt := range tests {
+		t.Run("merge "+tt.merge, func(t *testing.T) {
+			cache, err := copilot.NewModelsCache(context.Background(), "catalog-token", time.Hour,
+				copilot.WithModelsURL(catalog.URL),
+				copilot.WithCopilotModelsEndpoint(copilotAPI.URL+"/models", tokens, tt.merge))
+			if err != nil {
+				t.Fatalf("NewModelsCache: %v", err)
+			}
+			defer cache.Close()
+
+			models, err := cache.ListModels(context.Background())
+			if err != nil {
+				t.Fatalf("ListModels: %v", err)
+			}
+			var ids []string
+			for _, m := range models {
+				ids = append(ids, m.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("expected models %v, got %v", tt.want, ids)
+			}
+			if gotAuth != "Bearer copilot-token" {
+				t.Errorf("expected the Copilot token on the models request, got %q", gotAuth)
+			}
+		})
+	}
+
+	cache, err := copilot.NewModelsCache(context.Background(), "catalog-token", time.Hour,
+		copilot.WithModelsURL(catalog.URL),
+		copilot.WithCopilotModelsEndpoint(copilotAPI.URL+"/models", tokens, copilot.MergeNone))
+	if err != nil {
+		t.Fatalf("NewModelsCache: %v", err)
+	}
+	defer cache.Close()
+	claude, ok := cache.Lookup("claude-3.5-sonnet")
+	if !ok {
+		t.Fatal("expected claude-3.5-sonnet to be cached")
+	}
+	if claude.Publisher != "Anthropic" || claude.Limits.MaxInputTokens != 90000 || !claude.HasCapability("streaming") || !slices.Contains(claude.SupportedInputModalities, "image") {
+		t.Errorf("expected the Copilot model details to be converted, got %+v", claude)
+	}
+}
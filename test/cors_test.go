@@ -0,0 +1,61 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestCORSVaryOrigin(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Vary", "Accept-Encoding")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		name       string
+		origin     string
+		dedup      bool
+		wantOrigin string
+	}{
+		{"allowed origin", "https://app.example.com", false, "https://app.example.com"},
+		{"blocked origin", "https://evil.example.com", false, ""},
+		{"deduplicated response", "https://app.example.com", true, "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, CORSAllowedOrigins: "https://app.example.com", EnableRequestDedup: tt.dedup}
+			handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+			// A plain response and a proxied one carrying the upstream Vary header
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/healthz", nil),
+				httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)),
+			} {
+				req.Header.Set("Origin", tt.origin)
+				req.Header.Set("Authorization", "Bearer test-token")
+				req.Header.Set("Content-Type", "application/json")
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+
+				if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+					t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", req.URL.Path, tt.wantOrigin, got)
+				}
+				want := []string{"Origin"}
+				if req.URL.Path != "/healthz" {
+					want = append(want, "Accept-Encoding")
+				}
+				if got := rr.Header().Values("Vary"); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s: expected Vary %v, got %v", req.URL.Path, want, got)
+				}
+			}
+		})
+	}
+}
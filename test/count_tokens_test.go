@@ -0,0 +1,68 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func postCountTokens(t *testing.T, handler http.Handler, path, body string) map[string]interface{} {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("%s: expected status 200, got %d: %s", path, rr.Code, rr.Body.String())
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s: invalid JSON response: %v", path, err)
+	}
+	return resp
+}
+
+func TestCountTokens(t *testing.T) {
+	// The token manager is nil: counting must never need an upstream call
+	cfg := &config.Config{CopilotToken: "test-token"}
+	handler := api.NewRouter(cfg, nil, nil)
+
+	// "tiktoken is great!" is 6 tokens in both cl100k_base and o200k_base.
+	// Each message adds 3 tokens plus its role, and the reply adds 3 more.
+	t.Run("openai format", func(t *testing.T) {
+		resp := postCountTokens(t, handler, "/v1/chat/completions/count_tokens",
+			`{"model":"gpt-4","messages":[{"role":"user","content":"tiktoken is great!"}]}`)
+		usage, _ := resp["usage"].(map[string]interface{})
+		if got := usage["prompt_tokens"]; got != float64(13) {
+			t.Errorf("expected 13 prompt tokens, got %v", got)
+		}
+		if _, ok := resp["estimated"]; ok {
+			t.Errorf("expected exact count for a known model, got %v", resp)
+		}
+	})
+
+	t.Run("anthropic format", func(t *testing.T) {
+		resp := postCountTokens(t, handler, "/v1/messages/count_tokens",
+			`{"model":"openai/gpt-4o","system":"tiktoken is great!","messages":[{"role":"user","content":[{"type":"text","text":"tiktoken is great!"}]}]}`)
+		if got := resp["input_tokens"]; got != float64(19) {
+			t.Errorf("expected 19 input tokens, got %v", got)
+		}
+	})
+
+	t.Run("unknown model is estimated", func(t *testing.T) {
+		resp := postCountTokens(t, handler, "/v1/messages/count_tokens",
+			`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hello world"}]}`)
+		if got := resp["input_tokens"]; got != float64(10) {
+			t.Errorf("expected 10 estimated input tokens, got %v", got)
+		}
+		if resp["estimated"] != true {
+			t.Errorf("expected estimated flag, got %v", resp)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/ctxkey"
+	"copilot-api/pkg/config"
+)
+
+// requestIDKey has the same name and underlying type as the key used by ctxkey.
+type requestIDKey struct{}
+
+func TestContextKeysIsolated(t *testing.T) {
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "ours")
+	if id, ok := ctxkey.RequestIDFrom(ctx); ok {
+		t.Errorf("expected a same-named key of another package to be ignored, got %q", id)
+	}
+
+	ctx = ctxkey.WithRequestID(ctx, "theirs")
+	if v, _ := ctx.Value(requestIDKey{}).(string); v != "ours" {
+		t.Errorf("expected our value to be untouched, got %q", v)
+	}
+	if id, ok := ctxkey.RequestIDFrom(ctx); !ok || id != "theirs" {
+		t.Errorf("expected request ID %q, got %q (%v)", "theirs", id, ok)
+	}
+	if _, ok := ctxkey.AuthLabelFrom(ctx); ok {
+		t.Error("expected the request ID not to be read as an auth label")
+	}
+	if id := ctxkey.MustRequestIDFrom(ctx); id != "theirs" {
+		t.Errorf("expected MustRequestIDFrom to return %q, got %q", "theirs", id)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustRequestIDFrom to panic without a request ID")
+		}
+	}()
+	ctxkey.MustRequestIDFrom(context.Background())
+}
+
+func TestRequestIDLogged(t *testing.T) {
+	buf := captureLog(t)
+	cfg := &config.Config{Debug: true, LogSampleRate: 1}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	logged := func(id string) string {
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		if id != "" {
+			req.Header.Set("X-Request-Id", id)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		fields := strings.Fields(buf.String())
+		if len(fields) == 0 {
+			t.Fatal("expected the request to be logged")
+		}
+		return fields[len(fields)-1]
+	}
+
+	generated := logged("")
+	if len(generated) != 32 || generated == logged("") {
+		t.Errorf("expected a unique 32 character request ID, got %q", generated)
+	}
+	if id := logged("client-id-1"); id != "client-id-1" {
+		t.Errorf("expected the client request ID to be kept, got %q", id)
+	}
+	if id := logged("bad id"); len(id) != 32 {
+		t.Errorf("expected an invalid client request ID to be replaced, got %q", id)
+	}
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestRequestDedup(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-shared","choices":[]}`))
+	}))
+	defer upstream.Close()
+	tm := newTestTokenManager(t)
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		body      string
+		other     string    // Body of the second request; defaults to body
+		tokens    [2]string // Defaults to test-token for both
+		wantCalls int32
+	}{
+		{name: "identical requests share a call", enabled: true, body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, wantCalls: 1},
+		{name: "streaming requests are not deduplicated", enabled: true, body: `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`, wantCalls: 2},
+		{name: "requests of different keys are not shared", enabled: true, body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, tokens: [2]string{"test-token", "team-token"}, wantCalls: 2},
+		{name: "requests differing in other fields are not shared", enabled: true, body: `{"model":"gpt-4o","temperature":0.2,"messages":[{"role":"user","content":"hi"}]}`,
+			other: `{"model":"gpt-4o","temperature":0.9,"response_format":{"type":"json_object"},"messages":[{"role":"user","content":"hi"}]}`, wantCalls: 2},
+		{name: "dedup disabled", enabled: false, body: `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, EnableRequestDedup: tt.enabled,
+				APIKeys: []config.APIKey{{Label: "team", Token: "team-token"}}}
+			handler := api.NewRouter(cfg, tm, nil)
+
+			var wg sync.WaitGroup
+			bodies := make([]string, 2)
+			for i := range bodies {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					body := tt.body
+					if i == 1 && tt.other != "" {
+						body = tt.other
+					}
+					req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+					token := tt.tokens[i]
+					if token == "" {
+						token = "test-token"
+					}
+					req.Header.Set("Authorization", "Bearer "+token)
+					req.Header.Set("Content-Type", "application/json")
+					rr := httptest.NewRecorder()
+					handler.ServeHTTP(rr, req)
+					bodies[i] = rr.Body.String()
+				}(i)
+			}
+			wg.Wait()
+
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Errorf("expected %d upstream calls, got %d", tt.wantCalls, got)
+			}
+			for i, b := range bodies {
+				if !strings.Contains(b, "chatcmpl-shared") {
+					t.Errorf("response %d missing upstream body: %q", i, b)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestDedupSurvivesFirstClientDisconnect(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-shared","choices":[]}`))
+	}))
+	defer upstream.Close()
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, EnableRequestDedup: true}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	post := func(ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/v1/chat/completions",
+			strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// The first client starts the shared call and disconnects while it runs
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		_ = post(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	rr := post(context.Background())
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "chatcmpl-shared") {
+		t.Errorf("expected the waiting duplicate to get the response, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected one shared upstream call, got %d", got)
+	}
+}
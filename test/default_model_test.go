@@ -0,0 +1,116 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestDefaultModelPrecedence(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+	tm := newTestTokenManager(t)
+
+	tests := []struct {
+		name            string
+		requestModel    string
+		endpointDefault string
+		globalDefault   string
+		want            string // "" means no model is sent
+	}{
+		{"request, endpoint and global", "req-model", "endpoint-model", "global-model", "req-model"},
+		{"request and endpoint", "req-model", "endpoint-model", "", "req-model"},
+		{"request and global", "req-model", "", "global-model", "req-model"},
+		{"request only", "req-model", "", "", "req-model"},
+		{"endpoint and global", "", "endpoint-model", "global-model", "endpoint-model"},
+		{"endpoint only", "", "endpoint-model", "", "endpoint-model"},
+		{"global only", "", "", "global-model", "global-model"},
+		{"none", "", "", "", ""},
+	}
+
+	endpoints := []struct {
+		target string
+		body   string
+		cfg    func(cfg *config.Config, model string)
+	}{
+		{"/v1/chat/completions", `"messages":[{"role":"user","content":"hi"}]`, func(cfg *config.Config, m string) { cfg.DefaultChatModel = m }},
+		{"/v1/embeddings", `"input":"hi"`, func(cfg *config.Config, m string) { cfg.DefaultEmbeddingModel = m }},
+		{"/v1/messages", `"max_tokens":10,"messages":[{"role":"user","content":"hi"}]`, func(cfg *config.Config, m string) { cfg.DefaultAnthropicModel = m }},
+	}
+
+	for _, ep := range endpoints {
+		for _, tt := range tests {
+			t.Run(ep.target+"/"+tt.name, func(t *testing.T) {
+				cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, DefaultModel: tt.globalDefault}
+				ep.cfg(cfg, tt.endpointDefault)
+				handler := api.NewRouter(cfg, tm, nil)
+
+				body := "{" + ep.body
+				if tt.requestModel != "" {
+					body += `,"model":"` + tt.requestModel + `"`
+				}
+				body += "}"
+				req := httptest.NewRequest(http.MethodPost, ep.target, strings.NewReader(body))
+				req.Header.Set("Authorization", "Bearer test-token")
+				req.Header.Set("Content-Type", "application/json")
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				if rr.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+				}
+
+				model, sent := upstreamBody["model"]
+				if tt.want == "" {
+					if sent && model != nil {
+						t.Errorf("expected no model upstream, got %v", model)
+					}
+					return
+				}
+				if model != tt.want {
+					t.Errorf("expected model %q upstream, got %v", tt.want, model)
+				}
+			})
+		}
+	}
+}
+
+func TestEndpointDefaultsAreIndependent(t *testing.T) {
+	var upstreamModel interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		upstreamModel = body["model"]
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	// Only embeddings has its own default; chat falls back to the global one
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, DefaultModel: "gpt-4o", DefaultEmbeddingModel: "text-embedding-3-small"}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	for target, want := range map[string]string{
+		"/v1/chat/completions": "gpt-4o",
+		"/v1/embeddings":       "text-embedding-3-small",
+	} {
+		body := `{"input":"hi","messages":[{"role":"user","content":"hi"}]}`
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if upstreamModel != want {
+			t.Errorf("%s: expected model %q upstream, got %v", target, want, upstreamModel)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+// newDeviceFlowServer mocks GitHub's device flow endpoints. The access token endpoint
+// answers authorization_pending pendingPolls times before returning result, which is
+// either an OAuth token or an error code prefixed with "error:".
+func newDeviceFlowServer(t *testing.T, pendingPolls int32, result string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var polls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/device/code", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("client_id") != "Iv1.test" {
+			t.Errorf("unexpected device code request %s client_id=%q", r.Method, r.FormValue("client_id"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"device_code":"dev-123","user_code":"ABCD-1234","verification_uri":"https://github.com/login/device","expires_in":900,"interval":1}`))
+	})
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("device_code") != "dev-123" || r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:device_code" {
+			t.Errorf("unexpected access token request %v", r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if polls.Add(1) <= pendingPolls {
+			_, _ = w.Write([]byte(`{"error":"authorization_pending"}`))
+			return
+		}
+		if code, ok := strings.CutPrefix(result, "error:"); ok {
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": "The user has denied your application access."})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": result, "token_type": "bearer", "scope": "read:user"})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &polls
+}
+
+func TestDeviceFlowLogin(t *testing.T) {
+	github, polls := newDeviceFlowServer(t, 1, "gho_device")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	code, err := copilot.RequestDeviceCode(ctx, github.URL, "Iv1.test")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode: %v", err)
+	}
+	if code.UserCode != "ABCD-1234" || code.VerificationURI != "https://github.com/login/device" {
+		t.Errorf("unexpected device code %+v", code)
+	}
+	oauthToken, err := copilot.PollDeviceToken(ctx, github.URL, "Iv1.test", code)
+	if err != nil {
+		t.Fatalf("PollDeviceToken: %v", err)
+	}
+	if oauthToken != "gho_device" {
+		t.Errorf("expected the OAuth token gho_device, got %q", oauthToken)
+	}
+	if n := polls.Load(); n != 2 {
+		t.Errorf("expected polling to continue past authorization_pending, got %d polls", n)
+	}
+
+	// Save the token and fetch a Copilot token as the login subcommand does
+	copilotDir := setTestConfigHome(t)
+	writeTestToken(t, copilotDir, "previous-account-token", time.Hour)
+	tokenSrv, _ := newTokenServer(t, time.Hour)
+	userSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token gho_device" {
+			t.Errorf("expected the new OAuth token on the user lookup, got %q", r.Header.Get("Authorization"))
+		}
+		_, _ = w.Write([]byte(`{"login":"octocat"}`))
+	}))
+	defer userSrv.Close()
+	tm := startTestTokenManager(t,
+		copilot.WithOAuthToken(oauthToken),
+		copilot.WithAuthURL(tokenSrv.URL),
+		copilot.WithUserURL(userSrv.URL),
+	)
+	if err := tm.SaveToAppsJSON("", "Iv1.test"); err != nil {
+		t.Fatalf("SaveToAppsJSON: %v", err)
+	}
+	if err := tm.ForceRefresh(ctx); err != nil {
+		t.Fatalf("ForceRefresh: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(copilotDir, "apps.json"))
+	if err != nil {
+		t.Fatalf("apps.json not written: %v", err)
+	}
+	var apps map[string]struct {
+		User        string `json:"user"`
+		OAuthToken  string `json:"oauth_token"`
+		GitHubAppID string `json:"githubAppId"`
+	}
+	if err := json.Unmarshal(data, &apps); err != nil {
+		t.Fatalf("invalid apps.json: %v", err)
+	}
+	entry := apps["github.com:Iv1.test"]
+	if entry.User != "octocat" || entry.OAuthToken != "gho_device" || entry.GitHubAppID != "Iv1.test" {
+		t.Errorf("unexpected apps.json %s", data)
+	}
+	token, err := tm.GetToken(ctx)
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != "refreshed-token-1" {
+		t.Errorf("expected the freshly fetched Copilot token, got %q", token)
+	}
+}
+
+func TestDeviceFlowAccessDenied(t *testing.T) {
+	github, _ := newDeviceFlowServer(t, 0, "error:access_denied")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	code, err := copilot.RequestDeviceCode(ctx, github.URL, "Iv1.test")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode: %v", err)
+	}
+	_, err = copilot.PollDeviceToken(ctx, github.URL, "Iv1.test", code)
+	if err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Errorf("expected an access_denied error, got %v", err)
+	}
+}
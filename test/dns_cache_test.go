@@ -0,0 +1,123 @@
+package test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+func TestDNSCacheDialsCachedAddresses(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.Context().Value(http.LocalAddrContextKey).(net.Addr).String())
+		mu.Lock()
+		hits[host]++
+		mu.Unlock()
+		if !strings.HasPrefix(r.Host, "copilot.test:") {
+			t.Errorf("expected the original host in the request, got %q", r.Host)
+		}
+	})
+	upstream := httptest.NewServer(handler)
+	defer upstream.Close()
+	_, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+
+	// A second upstream on another loopback address and the same port, for round-robin
+	addrs := []string{"127.0.0.1"}
+	if ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.2", port)); err == nil {
+		second := &httptest.Server{Listener: ln, Config: &http.Server{Handler: handler}}
+		second.Start()
+		defer second.Close()
+		addrs = append(addrs, "127.0.0.2")
+	}
+
+	cache := copilot.NewDNSCache(time.Minute, nil)
+	cache.Set("copilot.test", addrs)
+	tr := copilot.NewUpstreamTransport(&config.Config{})
+	tr.DialContext = cache.DialContext
+	tr.DisableKeepAlives = true
+	client := &http.Client{Transport: tr}
+
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get("http://copilot.test:" + port + "/chat/completions")
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(addrs) == 1 {
+		if hits["127.0.0.1"] != 4 {
+			t.Errorf("expected all requests to reach the cached address, got %v", hits)
+		}
+		return
+	}
+	if hits["127.0.0.1"] != 2 || hits["127.0.0.2"] != 2 {
+		t.Errorf("expected requests to rotate over the cached addresses, got %v", hits)
+	}
+}
+
+func TestDNSCacheSkipsUnreachableAddress(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	_, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+
+	// Nothing listens on 127.0.0.3, so that connection is refused
+	cache := copilot.NewDNSCache(time.Minute, nil)
+	cache.Set("copilot.test", []string{"127.0.0.3", "127.0.0.1"})
+	for i := 0; i < 2; i++ {
+		conn, err := cache.DialContext(context.Background(), "tcp", "copilot.test:"+port)
+		if err != nil {
+			t.Fatalf("dial %d: expected the reachable address to be used, got %v", i, err)
+		}
+		if got := conn.RemoteAddr().String(); got != upstream.Listener.Addr().String() {
+			t.Errorf("dial %d: connected to %s", i, got)
+		}
+		conn.Close()
+	}
+}
+
+func TestDNSCacheDialsAddressFamily(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	_, port, _ := net.SplitHostPort(upstream.Listener.Addr().String())
+
+	cache := copilot.NewDNSCache(time.Minute, nil)
+	cache.Set("copilot.test", []string{"::1", "127.0.0.1"})
+	for i := 0; i < 2; i++ {
+		conn, err := cache.DialContext(context.Background(), "tcp4", "copilot.test:"+port)
+		if err != nil {
+			t.Fatalf("dial %d: expected the IPv4 address to be used, got %v", i, err)
+		}
+		if got := conn.RemoteAddr().String(); got != upstream.Listener.Addr().String() {
+			t.Errorf("dial %d: connected to %s", i, got)
+		}
+		conn.Close()
+	}
+
+	cache.Set("copilot.test", []string{"127.0.0.1"})
+	if _, err := cache.DialContext(context.Background(), "tcp6", "copilot.test:"+port); err == nil || !strings.Contains(err.Error(), "no suitable address") {
+		t.Errorf("expected tcp6 to find no IPv4 address suitable, got %v", err)
+	}
+}
+
+func TestDNSCacheNotFound(t *testing.T) {
+	cache := copilot.NewDNSCache(time.Minute, nil)
+	_, err := cache.DialContext(context.Background(), "tcp", "does-not-exist.invalid:443")
+	if err == nil {
+		t.Fatal("expected a host that does not exist to fail")
+	}
+	if !strings.Contains(err.Error(), "does-not-exist.invalid") {
+		t.Errorf("expected a lookup error naming the host, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildServer builds the go-copilot-api binary into a temporary directory.
+func buildServer(t *testing.T) string {
+	t.Helper()
+	bin := filepath.Join(t.TempDir(), "go-copilot-api")
+	out, err := exec.Command("go", "build", "-o", bin, "copilot-api/cmd/go-copilot-api").CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to build the server: %v\n%s", err, out)
+	}
+	return bin
+}
+
+func TestDryRun(t *testing.T) {
+	bin := buildServer(t)
+
+	tests := []struct {
+		name     string
+		flag     string
+		env      []string
+		setup    func(t *testing.T, home string)
+		wantCode int
+		want     []string // substrings of the combined output
+	}{
+		{
+			name:     "valid",
+			flag:     "--dry-run",
+			env:      []string{"COPILOT_OAUTH_TOKEN=gho_dryrunsecret1234", "COPILOT_TOKEN=client-secret-abcd", "ADMIN_TOKEN=admin-secret-efgh"},
+			wantCode: 0,
+			want:     []string{"CopilotOAuthToken", "****1234", "****abcd", "configuration is valid"},
+		},
+		{
+			name:     "warnings only",
+			flag:     "--validate-config",
+			env:      []string{"COPILOT_OAUTH_TOKEN=gho_dryrunsecret1234"},
+			wantCode: 0,
+			want:     []string{"COPILOT_TOKEN is not set", "ADMIN_TOKEN is not set", "configuration is valid"},
+		},
+		{
+			name:     "invalid values",
+			flag:     "--dry-run",
+			env:      []string{"COPILOT_OAUTH_TOKEN=gho_dryrunsecret1234", "COPILOT_SERVER_PORT=99999", "LOG_SAMPLE_RATE=2"},
+			wantCode: 1,
+			want:     []string{"invalid ServerPort", "invalid LogSampleRate", "configuration is invalid"},
+		},
+		{
+			name:     "no OAuth token",
+			flag:     "--dry-run",
+			wantCode: 1,
+			want:     []string{"no Copilot OAuth token found", "configuration is invalid"},
+		},
+		{
+			name: "token directory not writable",
+			flag: "--dry-run",
+			env:  []string{"COPILOT_OAUTH_TOKEN=gho_dryrunsecret1234"},
+			// A file in place of ~/.config keeps the directory from being created, even as root
+			setup: func(t *testing.T, home string) {
+				if err := os.WriteFile(filepath.Join(home, ".config"), nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantCode: 1,
+			want:     []string{"token.json cannot be written", "configuration is invalid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			home := t.TempDir()
+			if tt.setup != nil {
+				tt.setup(t, home)
+			}
+			cmd := exec.Command(bin, tt.flag)
+			// A .env in the working directory would be loaded, so run in an empty one
+			cmd.Dir = t.TempDir()
+			cmd.Env = append([]string{"HOME=" + home, "PATH=" + os.Getenv("PATH"), "OAUTH_TOKEN_SEARCH_PATHS=$COPILOT_OAUTH_TOKEN"}, tt.env...)
+			out, err := cmd.CombinedOutput()
+
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("failed to run the server: %v", err)
+			}
+			if code != tt.wantCode {
+				t.Fatalf("expected exit code %d, got %d:\n%s", tt.wantCode, code, out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), want) {
+					t.Errorf("expected %q in the output:\n%s", want, out)
+				}
+			}
+			for _, secret := range []string{"gho_dryrunsecret1234", "client-secret-abcd", "admin-secret-efgh"} {
+				if strings.Contains(string(out), secret) {
+					t.Errorf("expected %s to be masked:\n%s", secret, out)
+				}
+			}
+			if strings.Contains(string(out), "Starting server") {
+				t.Errorf("expected the server not to start:\n%s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+
+	"copilot-api/pkg/config"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	clearOAuthTokenEnv(t)
+	dir := t.TempDir()
+	writeTokenSource(t, filepath.Join(dir, "aliases.json"), `{"fast":"gpt-4o-mini"}`)
+	writeTokenSource(t, filepath.Join(dir, "ids.json"), `{"team":"team-id"}`)
+	writeTokenSource(t, filepath.Join(dir, "max_tokens.json"), `{"team":512}`)
+	secrets := map[string]string{
+		"COPILOT_OAUTH_TOKEN": "gho_roundtrip",
+		"ADMIN_TOKEN":         "admin-secret",
+		"API_KEYS":            "team:team-secret,ci:ci-secret",
+		"AUDIT_SIGNING_KEY":   "signing-secret",
+	}
+	env := map[string]string{
+		"DEBUG":                    "true",
+		"COPILOT_SERVER_PORT":      "8181",
+		"CORS_ALLOWED_ORIGINS":     "https://a.example,https://b.example",
+		"DEFAULT_MODEL":            "gpt-4o",
+		"COPILOT_ENDPOINTS":        "https://one.example,https://two.example",
+		"OAUTH_TOKEN_SEARCH_PATHS": "$COPILOT_OAUTH_TOKEN" + string(os.PathListSeparator) + "~/token",
+		"MODEL_ALIASES_FILE":       filepath.Join(dir, "aliases.json"),
+		"INTEGRATION_ID_MAP_FILE":  filepath.Join(dir, "ids.json"),
+		"MAX_TOKENS_FILE":          filepath.Join(dir, "max_tokens.json"),
+		"ENABLE_METRICS":           "true",
+		"RATE_LIMIT_PER_MINUTE":    "60",
+		"RESPONSE_CACHE_TTL":       "90s",
+		"AUTH_EXEMPT_PATHS":        "",
+		"USER_AGENT":               `my agent/1.0 "quoted" $HOME`,
+		"CSP_POLICY":               "default-src 'self'; img-src *",
+		"LOG_SAMPLE_RATE":          "0.25",
+		"SHUTDOWN_TIMEOUT":         "2m",
+		"MAX_REQUEST_BODY_BYTES":   "2048",
+		"FALLBACK_DNS_SERVERS":     "1.1.1.1,8.8.8.8",
+	}
+	for key, val := range secrets {
+		t.Setenv(key, val)
+	}
+	for key, val := range env {
+		t.Setenv(key, val)
+	}
+	os.Unsetenv("COPILOT_TOKEN")
+	want, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	dump := config.Marshal(want)
+	for _, secret := range []string{"gho_roundtrip", "admin-secret", "team-secret", "signing-secret"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("expected %s to be hidden:\n%s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, "COPILOT_TOKEN="+want.CopilotToken) || !strings.Contains(dump, "WARNING: generated at random") {
+		t.Errorf("expected the generated COPILOT_TOKEN with a warning:\n%s", dump)
+	}
+
+	parsed, err := godotenv.Unmarshal(dump)
+	if err != nil {
+		t.Fatalf("the dump is not a valid .env file: %v\n%s", err, dump)
+	}
+	for key, val := range parsed {
+		if val == "<hidden>" {
+			// Filled in by hand
+			if val = secrets[key]; val == "" {
+				t.Fatalf("unexpected hidden setting %s", key)
+			}
+		}
+		t.Setenv(key, val)
+	}
+	got, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load of the dump: %v\n%s", err, dump)
+	}
+	if got.CopilotTokenGenerated {
+		t.Error("expected the dumped COPILOT_TOKEN to be used rather than generated again")
+	}
+	got.CopilotTokenGenerated = want.CopilotTokenGenerated
+	if !reflect.DeepEqual(got, want) {
+		wv, gv := reflect.ValueOf(*want), reflect.ValueOf(*got)
+		for i := 0; i < wv.NumField(); i++ {
+			if !reflect.DeepEqual(wv.Field(i).Interface(), gv.Field(i).Interface()) {
+				t.Errorf("%s: expected %#v, got %#v", wv.Type().Field(i).Name, wv.Field(i).Interface(), gv.Field(i).Interface())
+			}
+		}
+	}
+}
+
+func TestDumpConfigCommand(t *testing.T) {
+	bin := buildServer(t)
+	dir := t.TempDir()
+	run := func(args ...string) (string, int) {
+		t.Helper()
+		cmd := exec.Command(bin, append([]string{"dump-config"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = []string{"HOME=" + t.TempDir(), "PATH=" + os.Getenv("PATH"),
+			"OAUTH_TOKEN_SEARCH_PATHS=$COPILOT_OAUTH_TOKEN", "COPILOT_OAUTH_TOKEN=gho_dumpsecret", "COPILOT_TOKEN=client-secret", "DEFAULT_MODEL=gpt-4o"}
+		out, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return string(out), exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatalf("failed to run dump-config: %v", err)
+		}
+		return string(out), 0
+	}
+
+	out, code := run()
+	if code != 0 || !strings.Contains(out, "DEFAULT_MODEL=gpt-4o") || !strings.Contains(out, "COPILOT_TOKEN=<hidden>") {
+		t.Fatalf("expected the configuration on stdout, got %d:\n%s", code, out)
+	}
+	if strings.Contains(out, "gho_dumpsecret") || strings.Contains(out, "client-secret") {
+		t.Errorf("expected secrets to be hidden:\n%s", out)
+	}
+
+	path := filepath.Join(dir, "out.env")
+	if out, code := run("--output", path); code != 0 {
+		t.Fatalf("expected --output to succeed, got %d:\n%s", code, out)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), "DEFAULT_MODEL=gpt-4o") {
+		t.Fatalf("expected the configuration in %s, got %q (%v)", path, data, err)
+	}
+	if err := os.WriteFile(path, []byte("keep"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if out, code := run("--output", path); code != 1 || !strings.Contains(out, "already exists") {
+		t.Errorf("expected an existing file to be refused, got %d:\n%s", code, out)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "keep" {
+		t.Errorf("expected the existing file to be kept, got %q", data)
+	}
+	if out, code := run("--output", path, "--force"); code != 0 {
+		t.Fatalf("expected --force to overwrite the file, got %d:\n%s", code, out)
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "DEFAULT_MODEL=gpt-4o") {
+		t.Errorf("expected the file to be overwritten, got %q", data)
+	}
+	if _, code := run("extra"); code != 2 {
+		t.Errorf("expected a usage error for extra arguments, got %d", code)
+	}
+}
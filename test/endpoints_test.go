@@ -0,0 +1,97 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+// newNamedUpstream starts a mock Copilot API that appends name to *hits on every request
+// and answers with status.
+func newNamedUpstream(t *testing.T, name string, status int, hits *[]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits = append(*hits, name)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// postChat sends a minimal chat completion request through handler.
+func postChat(handler http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestEndpointsRoundRobin(t *testing.T) {
+	var hits []string
+	a := newNamedUpstream(t, "a", http.StatusOK, &hits)
+	b := newNamedUpstream(t, "b", http.StatusOK, &hits)
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotEndpoints: []string{a.URL, b.URL}}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	for i := 0; i < 4; i++ {
+		if rr := postChat(handler, "test-token"); rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d", i, rr.Code)
+		}
+	}
+	want := []string{"a", "b", "a", "b"}
+	if strings.Join(hits, ",") != strings.Join(want, ",") {
+		t.Errorf("expected requests to alternate %v, got %v", want, hits)
+	}
+}
+
+func TestEndpointsSkipOpenCircuit(t *testing.T) {
+	var hits []string
+	bad := newNamedUpstream(t, "bad", http.StatusInternalServerError, &hits)
+	good := newNamedUpstream(t, "good", http.StatusOK, &hits)
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotEndpoints: []string{bad.URL, good.URL}}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	// Alternate until the failing endpoint's circuit opens
+	for i := 0; i < 10; i++ {
+		postChat(handler, "test-token")
+	}
+	hits = nil
+	for i := 0; i < 4; i++ {
+		if rr := postChat(handler, "test-token"); rr.Code != http.StatusOK {
+			t.Fatalf("expected healthy endpoint to serve request, got %d", rr.Code)
+		}
+	}
+	for _, h := range hits {
+		if h != "good" {
+			t.Fatalf("expected open endpoint to be skipped, got hits %v", hits)
+		}
+	}
+}
+
+func TestEndpointsAllOpen(t *testing.T) {
+	var hits []string
+	bad := newNamedUpstream(t, "bad", http.StatusBadGateway, &hits)
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotEndpoints: []string{bad.URL}}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	for i := 0; i < 5; i++ {
+		postChat(handler, "test-token")
+	}
+	if rr := postChat(handler, "test-token"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when all endpoints are open, got %d", rr.Code)
+	}
+	if len(hits) != 5 {
+		t.Errorf("expected no upstream call once the circuit is open, got %d calls", len(hits))
+	}
+}
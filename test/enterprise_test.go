@@ -0,0 +1,55 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+const enterpriseAppsJSON = `{
+	"github.example.com:Iv1.enterprise": {"user": "ghe-user", "oauth_token": "ghe-oauth-token"}
+}`
+
+func TestEnterpriseTokenFromConfig(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	if err := os.WriteFile(filepath.Join(copilotDir, "apps.json"), []byte(enterpriseAppsJSON), 0o600); err != nil {
+		t.Fatalf("failed to write apps.json: %v", err)
+	}
+	// Unset env var to force file lookup; t.Setenv restores it afterwards
+	t.Setenv("COPILOT_OAUTH_TOKEN", "")
+	os.Unsetenv("COPILOT_OAUTH_TOKEN")
+	t.Setenv("GITHUB_ENTERPRISE_URL", "https://github.example.com")
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CopilotOAuthToken != "ghe-oauth-token" {
+		t.Errorf("expected enterprise OAuth token, got %q", cfg.CopilotOAuthToken)
+	}
+	if host := cfg.GitHubHost(); host != "github.example.com" {
+		t.Errorf("expected enterprise host, got %q", host)
+	}
+}
+
+func TestEnterpriseTokenManager(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	if err := os.WriteFile(filepath.Join(copilotDir, "apps.json"), []byte(enterpriseAppsJSON), 0o600); err != nil {
+		t.Fatalf("failed to write apps.json: %v", err)
+	}
+
+	if tm, err := copilot.NewTokenManager(context.Background()); err == nil {
+		tm.Close()
+		t.Fatal("expected github.com lookup to ignore the enterprise token")
+	}
+
+	tm, err := copilot.NewTokenManager(context.Background(), copilot.WithGitHubHost("github.example.com"))
+	if err != nil {
+		t.Fatalf("expected enterprise token to be loaded: %v", err)
+	}
+	tm.Close()
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestFilesProxy(t *testing.T) {
+	var gotMethod, gotPath, gotPurpose, gotFilename, gotContent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.RequestURI()
+		if r.Method == http.MethodPost {
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "bad multipart body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			content, _ := io.ReadAll(file)
+			gotPurpose, gotFilename, gotContent = r.FormValue("purpose"), header.Filename, string(content)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"file-abc123","object":"file","filename":"data.jsonl","purpose":"fine-tune"}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, MaxRequestBodyBytes: 10, MaxFileUploadBytes: 1 << 20}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	upload := func(content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		_ = mw.WriteField("purpose", "fine-tune")
+		part, _ := mw.CreateFormFile("file", "data.jsonl")
+		_, _ = part.Write([]byte(content))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/files", &body)
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("upload", func(t *testing.T) {
+		// Larger than MaxRequestBodyBytes, which does not apply to uploads
+		content := `{"prompt":"hi","completion":"hello"}`
+		rr := upload(content)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if gotPath != "/files" || gotContent != content || gotFilename != "data.jsonl" || gotPurpose != "fine-tune" {
+			t.Errorf("expected the file and purpose relayed to /files, got path %s, file %q (%q), purpose %q", gotPath, gotFilename, gotContent, gotPurpose)
+		}
+		var file map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &file); err != nil || file["id"] != "file-abc123" {
+			t.Errorf("expected the upstream file object, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("upload too large", func(t *testing.T) {
+		gotPath = ""
+		if rr := upload(strings.Repeat("x", 2<<20)); rr.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status 413, got %d", rr.Code)
+		}
+		if gotPath != "" {
+			t.Errorf("expected no upstream request, got %s", gotPath)
+		}
+	})
+
+	tests := []struct {
+		method, target, wantPath string
+		wantStatus               int
+	}{
+		{http.MethodGet, "/v1/files?purpose=fine-tune", "/files?purpose=fine-tune", http.StatusOK},
+		{http.MethodGet, "/v1/files/file-abc123", "/files/file-abc123", http.StatusOK},
+		{http.MethodDelete, "/v1/files/file-abc123", "/files/file-abc123", http.StatusOK},
+		{http.MethodDelete, "/v1/files", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			gotMethod, gotPath = "", ""
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if gotPath != tt.wantPath || (tt.wantPath != "" && gotMethod != tt.method) {
+				t.Errorf("expected %s %s upstream, got %s %s", tt.method, tt.wantPath, gotMethod, gotPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+//go:build gcpsm
+
+package test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"copilot-api/pkg/config"
+)
+
+// fakeSecretManager is a Secret Manager gRPC server holding secret payloads by version
+// name. Unknown versions are not found and names containing "forbidden" are denied.
+type fakeSecretManager struct {
+	secretmanagerpb.UnimplementedSecretManagerServiceServer
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func (f *fakeSecretManager) AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest) (*secretmanagerpb.AccessSecretVersionResponse, error) {
+	if strings.Contains(req.GetName(), "forbidden") {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.secrets[req.GetName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "secret version %s not found", req.GetName())
+	}
+	return &secretmanagerpb.AccessSecretVersionResponse{
+		Name:    req.GetName(),
+		Payload: &secretmanagerpb.SecretPayload{Data: []byte(data)},
+	}, nil
+}
+
+func (f *fakeSecretManager) set(name, data string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[name] = data
+}
+
+// startFakeSecretManager serves a fakeSecretManager and points the Secret Manager client
+// at it.
+func startFakeSecretManager(t *testing.T) *fakeSecretManager {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	fake := &fakeSecretManager{secrets: make(map[string]string)}
+	srv := grpc.NewServer()
+	secretmanagerpb.RegisterSecretManagerServiceServer(srv, fake)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	t.Setenv("SECRET_MANAGER_EMULATOR_HOST", lis.Addr().String())
+	return fake
+}
+
+func TestGCPSecretOAuthToken(t *testing.T) {
+	fake := startFakeSecretManager(t)
+	fake.set("projects/p/secrets/copilot/versions/latest", "secret-token\n")
+	home, _ := clearOAuthTokenEnv(t)
+	writeTokenSource(t, filepath.Join(home, ".config", "github-copilot", "apps.json"), `{"github.com:Iv1.a": {"oauth_token": "apps-token"}}`)
+
+	tests := []struct {
+		name   string
+		secret string
+		want   string
+	}{
+		{"secret before files", "projects/p/secrets/copilot", "secret-token"},
+		{"not found falls back", "projects/p/secrets/missing", "apps-token"},
+		{"access denied falls back", "projects/p/secrets/forbidden", "apps-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GCP_SECRET_NAME", tt.secret)
+			if got := loadOAuthToken(t); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	// The environment variables still come first
+	t.Setenv("GCP_SECRET_NAME", "projects/p/secrets/copilot")
+	t.Setenv("GITHUB_TOKEN", "env-token")
+	if got := loadOAuthToken(t); got != "env-token" {
+		t.Errorf("expected GITHUB_TOKEN to win over the secret, got %q", got)
+	}
+}
+
+func TestGCPSecretRefetch(t *testing.T) {
+	fake := startFakeSecretManager(t)
+	fake.set("projects/p/secrets/copilot/versions/latest", "first-token")
+
+	token, err := config.FetchGCPSecret(context.Background(), "projects/p/secrets/copilot")
+	if err != nil || token != "first-token" {
+		t.Fatalf("expected first-token, got %q, %v", token, err)
+	}
+	// A SIGHUP reload fetches the latest version again
+	fake.set("projects/p/secrets/copilot/versions/latest", "rotated-token")
+	token, err = config.FetchGCPSecret(context.Background(), "projects/p/secrets/copilot")
+	if err != nil || token != "rotated-token" {
+		t.Fatalf("expected rotated-token, got %q, %v", token, err)
+	}
+	if _, err := config.FetchGCPSecret(context.Background(), "projects/p/secrets/missing"); err == nil || !strings.Contains(err.Error(), "NotFound") {
+		t.Errorf("expected NotFound for a missing secret, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build !gcpsm
+
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+func TestGCPSecretWithoutBuildTag(t *testing.T) {
+	home, _ := clearOAuthTokenEnv(t)
+	writeTokenSource(t, filepath.Join(home, ".config", "github-copilot", "apps.json"), `{"github.com:Iv1.a": {"oauth_token": "apps-token"}}`)
+	t.Setenv("GCP_SECRET_NAME", "projects/p/secrets/copilot-oauth-token")
+
+	paths := config.DefaultOAuthTokenSearchPaths()
+	if len(paths) < 3 || paths[2] != config.SearchGCPSecret {
+		t.Fatalf("expected the secret to be searched right after the environment variables, got %v", paths)
+	}
+	// Without Secret Manager support the search goes on with the next source
+	if got := loadOAuthToken(t); got != "apps-token" {
+		t.Errorf("expected the apps.json token, got %q", got)
+	}
+	_, err := config.FetchGCPSecret(context.Background(), "copilot-oauth-token")
+	if err == nil || !strings.Contains(err.Error(), "GOOGLE_CLOUD_PROJECT") {
+		t.Errorf("expected a bare secret name to need GOOGLE_CLOUD_PROJECT, got %v", err)
+	}
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "p")
+	_, err = config.FetchGCPSecret(context.Background(), "copilot-oauth-token")
+	if err == nil || !strings.Contains(err.Error(), "projects/p/secrets/copilot-oauth-token/versions/latest") || !strings.Contains(err.Error(), "gcpsm") {
+		t.Errorf("expected the latest version to be requested and the build tag named, got %v", err)
+	}
+}
+
+func TestSetOAuthToken(t *testing.T) {
+	var mu sync.Mutex
+	var auth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"token":"copilot-token","expires_at":4102444800}`))
+	}))
+	defer srv.Close()
+
+	tm := newTestTokenManager(t, copilot.WithAuthURL(srv.URL))
+	tm.SetOAuthToken("rotated-oauth-token")
+	if err := tm.ForceRefresh(context.Background()); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(auth) != 1 || auth[0] != "token rotated-oauth-token" {
+		t.Errorf("expected the refresh to use the new OAuth token, got %v", auth)
+	}
+}
@@ -4,42 +4,39 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"context"
 	"copilot-api/internal/api"
 	"copilot-api/internal/copilot"
 	"copilot-api/pkg/config"
-	"time"
 )
 
 func TestHealthzEndpoint(t *testing.T) {
 	cfg := &config.Config{}
-	// Provide a dummy TokenManager and dummy ModelsCache for testing
-	dummyTokenManager, _ := copilot.NewTokenManager(context.Background())
-	dummyModelsCache, _ := copilot.NewModelsCache(context.Background(), "dummy", 1*time.Hour)
-	handler := api.NewRouter(cfg, dummyTokenManager, dummyModelsCache)
+	handler := api.NewRouter(cfg, newTestTokenManager(t), newTestModelsCache(t, testModelsJSON))
 
 	tests := []struct {
 		name           string
 		method         string
 		target         string
 		wantStatusCode int
-		wantBody       map[string]string
+		wantBody       map[string]interface{}
 	}{
 		{
 			name:           "GET healthz",
 			method:         http.MethodGet,
 			target:         "/healthz",
 			wantStatusCode: http.StatusOK,
-			wantBody:       map[string]string{"status": "ok"},
+			wantBody:       map[string]interface{}{"status": "ok", "token_valid": true, "models_count": float64(3)},
 		},
 		{
 			name:           "POST healthz (should still work)",
 			method:         http.MethodPost,
 			target:         "/healthz",
 			wantStatusCode: http.StatusOK,
-			wantBody:       map[string]string{"status": "ok"},
+			wantBody:       map[string]interface{}{"status": "ok"},
 		},
 		{
 			name:           "GET unknown route",
@@ -61,16 +58,124 @@ func TestHealthzEndpoint(t *testing.T) {
 			}
 
 			if tt.wantBody != nil {
-				var got map[string]string
+				var got map[string]interface{}
 				if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
 					t.Fatalf("failed to unmarshal response: %v", err)
 				}
 				for k, v := range tt.wantBody {
 					if got[k] != v {
-						t.Errorf("expected body[%q]=%q, got %q", k, v, got[k])
+						t.Errorf("expected body[%q]=%v, got %v", k, v, got[k])
 					}
 				}
 			}
 		})
 	}
 }
+
+func TestHealthzStatus(t *testing.T) {
+	// Token refreshes fail, so an expired token stays expired
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	healthz := func(tm *copilot.TokenManager, mc *copilot.ModelsCache) (int, map[string]interface{}) {
+		rr := httptest.NewRecorder()
+		api.NewRouter(&config.Config{}, tm, mc).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return rr.Code, body
+	}
+
+	t.Run("ok", func(t *testing.T) {
+		code, body := healthz(newTestTokenManager(t), newTestModelsCache(t, testModelsJSON))
+		if code != http.StatusOK || body["status"] != "ok" || body["token_valid"] != true {
+			t.Errorf("expected 200 ok with a valid token, got %d %v", code, body)
+		}
+		if _, err := time.Parse(time.RFC3339, body["token_expires_at"].(string)); err != nil {
+			t.Errorf("expected RFC3339 token_expires_at, got %v", body["token_expires_at"])
+		}
+		if age, ok := body["models_age_secs"].(float64); !ok || age < 0 {
+			t.Errorf("expected models_age_secs, got %v", body["models_age_secs"])
+		}
+		if _, ok := body["uptime_secs"].(float64); !ok {
+			t.Errorf("expected uptime_secs, got %v", body["uptime_secs"])
+		}
+	})
+
+	t.Run("ok without models", func(t *testing.T) {
+		code, body := healthz(newTestTokenManager(t), nil)
+		if code != http.StatusOK || body["status"] != "ok" || body["models_count"] != float64(0) || body["models_age_secs"] != nil {
+			t.Errorf("expected 200 ok with no models, got %d %v", code, body)
+		}
+	})
+
+	t.Run("degraded", func(t *testing.T) {
+		copilotDir := setTestConfigHome(t)
+		writeTestApps(t, copilotDir)
+		writeTestToken(t, copilotDir, "expired-token", -time.Hour)
+		tm := startTestTokenManager(t, copilot.WithAuthURL(failing.URL))
+
+		code, body := healthz(tm, nil)
+		if code != http.StatusOK || body["status"] != "degraded" || body["token_valid"] != false {
+			t.Errorf("expected 200 degraded with an expired token, got %d %v", code, body)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		code, body := healthz(nil, nil)
+		if code != http.StatusServiceUnavailable || body["status"] != "error" {
+			t.Errorf("expected 503 error without a token manager, got %d %v", code, body)
+		}
+	})
+}
+
+func TestReadyzStatus(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	readyz := func(tm *copilot.TokenManager, opts ...api.RouterOption) (int, string) {
+		rr := httptest.NewRecorder()
+		api.NewRouter(&config.Config{}, tm, nil, opts...).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body map[string]string
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return rr.Code, body["status"]
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		if code, status := readyz(newTestTokenManager(t)); code != http.StatusOK || status != "ready" {
+			t.Errorf("expected 200 ready with a valid token, got %d %q", code, status)
+		}
+	})
+
+	t.Run("degraded", func(t *testing.T) {
+		copilotDir := setTestConfigHome(t)
+		writeTestApps(t, copilotDir)
+		writeTestToken(t, copilotDir, "expired-token", -time.Hour)
+		tm := startTestTokenManager(t, copilot.WithAuthURL(failing.URL))
+
+		if code, status := readyz(tm); code != http.StatusServiceUnavailable || status != "degraded" {
+			t.Errorf("expected 503 degraded with an expired token, got %d %q", code, status)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		if code, status := readyz(nil); code != http.StatusServiceUnavailable || status != "error" {
+			t.Errorf("expected 503 error without a token, got %d %q", code, status)
+		}
+	})
+
+	t.Run("shutting down", func(t *testing.T) {
+		var shuttingDown atomic.Bool
+		shuttingDown.Store(true)
+		if code, status := readyz(newTestTokenManager(t), api.WithShutdownFlag(&shuttingDown)); code != http.StatusServiceUnavailable || status != "shutting_down" {
+			t.Errorf("expected 503 shutting_down, got %d %q", code, status)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+// setTestConfigHome points the user's config directory at a temporary directory and
+// returns the github-copilot directory inside it.
+func setTestConfigHome(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	var copilotDir string
+	if runtime.GOOS == "windows" {
+		t.Setenv("LOCALAPPDATA", dir)
+		copilotDir = filepath.Join(dir, "github-copilot")
+	} else {
+		t.Setenv("HOME", dir)
+		copilotDir = filepath.Join(dir, ".config", "github-copilot")
+	}
+	if err := os.MkdirAll(copilotDir, 0o755); err != nil {
+		t.Fatalf("failed to create config dir: %v", err)
+	}
+	return copilotDir
+}
+
+// writeTestToken writes a Copilot token.json into copilotDir that expires after ttl.
+func writeTestToken(t *testing.T, copilotDir, token string, ttl time.Duration) {
+	t.Helper()
+	data, _ := json.Marshal(copilot.CopilotToken{
+		Token:     token,
+		ExpiresAt: float64(time.Now().Add(ttl).Unix()),
+	})
+	if err := os.WriteFile(filepath.Join(copilotDir, "token.json"), data, 0o600); err != nil {
+		t.Fatalf("failed to write token.json: %v", err)
+	}
+}
+
+// writeTestApps writes an apps.json holding a github.com OAuth token into copilotDir.
+func writeTestApps(t *testing.T, copilotDir string) {
+	t.Helper()
+	apps := `{"github.com:Iv1.test": {"user": "tester", "oauth_token": "test-oauth-token"}}`
+	if err := os.WriteFile(filepath.Join(copilotDir, "apps.json"), []byte(apps), 0o600); err != nil {
+		t.Fatalf("failed to write apps.json: %v", err)
+	}
+}
+
+// newTestTokenManager returns a TokenManager backed by a temporary config directory
+// holding an OAuth token and a valid Copilot token, so no GitHub call is needed.
+func newTestTokenManager(t *testing.T, opts ...copilot.TokenManagerOption) *copilot.TokenManager {
+	t.Helper()
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "test-copilot-token", time.Hour)
+	return startTestTokenManager(t, opts...)
+}
+
+// startTestTokenManager creates a TokenManager for the already prepared config directory.
+func startTestTokenManager(t *testing.T, opts ...copilot.TokenManagerOption) *copilot.TokenManager {
+	t.Helper()
+	tm, err := copilot.NewTokenManager(context.Background(), opts...)
+	if err != nil {
+		t.Fatalf("failed to create token manager: %v", err)
+	}
+	t.Cleanup(tm.Close)
+	return tm
+}
+
+// newTokenServer starts a mock Copilot token endpoint issuing tokens valid for ttl.
+// Each response carries a distinct token; the returned counter reports how many were issued.
+func newTokenServer(t *testing.T, ttl time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(copilot.CopilotToken{
+			Token:     "refreshed-token-" + strconv.Itoa(int(n)),
+			ExpiresAt: float64(time.Now().Add(ttl).Unix()),
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// testModelsJSON is a small GitHub Models catalog response used to seed caches.
+const testModelsJSON = `[
+	{"id": "openai/gpt-4o", "name": "OpenAI GPT-4o", "publisher": "OpenAI", "capabilities": ["streaming", "tool-calling"], "supported_input_modalities": ["text", "image"], "limits": {"max_input_tokens": 131072, "max_output_tokens": 16384}},
+	{"id": "openai/gpt-4o-mini", "name": "OpenAI GPT-4o mini", "publisher": "OpenAI", "capabilities": ["streaming"], "supported_input_modalities": ["text"], "limits": {"max_input_tokens": 131072, "max_output_tokens": 4096}},
+	{"id": "meta/llama-3.3-70b-instruct", "name": "Llama-3.3-70B-Instruct", "publisher": "Meta", "capabilities": ["streaming"], "supported_input_modalities": ["text"], "limits": {"max_input_tokens": 128000, "max_output_tokens": 4096}}
+]`
+
+// newTestModelsCache returns a ModelsCache seeded from a mock catalog serving modelsJSON,
+// closed when the test finishes.
+func newTestModelsCache(t *testing.T, modelsJSON string) *copilot.ModelsCache {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(modelsJSON))
+	}))
+	t.Cleanup(srv.Close)
+	cache, err := copilot.NewModelsCache(context.Background(), "test-token", time.Hour, copilot.WithModelsURL(srv.URL))
+	if err != nil {
+		t.Fatalf("failed to create models cache: %v", err)
+	}
+	t.Cleanup(cache.Close)
+	return cache
+}
+
+// itoa formats an integer for building JSON fixtures.
+func itoa(i int64) string { return strconv.FormatInt(i, 10) }
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestHostsJSONWrittenOnRefresh(t *testing.T) {
+	// Tokens that are already expired force a refresh on every GetToken
+	tokenSrv, refreshes := newTokenServer(t, -time.Hour)
+
+	var userCalls atomic.Int32
+	userSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userCalls.Add(1)
+		if r.Header.Get("Authorization") != "token test-oauth-token" {
+			t.Errorf("expected the OAuth token on the user lookup, got %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"login":"octocat","id":1}`))
+	}))
+	defer userSrv.Close()
+
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	hostsFile := filepath.Join(t.TempDir(), "hosts.json")
+	tm := startTestTokenManager(t,
+		copilot.WithAuthURL(tokenSrv.URL),
+		copilot.WithUserURL(userSrv.URL),
+		copilot.WithHostsJSON(hostsFile),
+	)
+
+	for i := 0; i < 3; i++ {
+		if _, err := tm.GetToken(context.Background()); err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+	}
+	if refreshes.Load() < 3 {
+		t.Fatalf("expected at least 3 token refreshes, got %d", refreshes.Load())
+	}
+	if userCalls.Load() != 1 {
+		t.Errorf("expected the username to be fetched once, got %d calls", userCalls.Load())
+	}
+
+	data, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatalf("hosts.json not written: %v", err)
+	}
+	var hosts map[string]struct {
+		OAuthToken string `json:"oauth_token"`
+		User       string `json:"user"`
+	}
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		t.Fatalf("invalid hosts.json: %v", err)
+	}
+	entry, ok := hosts["github.com"]
+	if len(hosts) != 1 || !ok {
+		t.Fatalf("expected a single github.com entry, got %s", data)
+	}
+	if entry.OAuthToken != "test-oauth-token" || entry.User != "octocat" {
+		t.Errorf("unexpected hosts.json entry: %s", data)
+	}
+}
@@ -0,0 +1,61 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestIntegrationIdPerKey(t *testing.T) {
+	var gotID string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotID = r.Header.Get("Copilot-Integration-Id")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		CopilotToken:   "primary-token",
+		CopilotBaseURL: upstream.URL,
+		APIKeys: []config.APIKey{
+			{Label: "team-a", Token: "team-a-token"},
+			{Label: "team-b", Token: "team-b-token"},
+		},
+		IntegrationIdPerKey: map[string]string{"team-a": "team-a-integration"},
+	}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	tests := []struct {
+		name   string
+		token  string
+		wantID string
+	}{
+		{name: "labeled key with mapping", token: "team-a-token", wantID: "team-a-integration"},
+		{name: "labeled key without mapping", token: "team-b-token", wantID: "vscode-chat"},
+		{name: "primary token", token: "primary-token", wantID: "vscode-chat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotID = ""
+			body := `{"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if gotID != tt.wantID {
+				t.Errorf("expected Copilot-Integration-Id %q, got %q", tt.wantID, gotID)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestJSONModeSystemPrompt(t *testing.T) {
+	var upstreamMessages []interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []interface{} `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		upstreamMessages = body.Messages
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	models := newTestModelsCache(t, `[
+		{"id": "openai/gpt-4o", "name": "OpenAI GPT-4o", "capabilities": ["streaming", "json-mode"]},
+		{"id": "openai/gpt-4o-mini", "name": "OpenAI GPT-4o mini", "capabilities": ["streaming"]}
+	]`)
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), models)
+
+	user := map[string]interface{}{"role": "user", "content": "list three colors"}
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantMessages []interface{}
+	}{
+		{
+			name:         "capable model is unchanged",
+			body:         `{"model":"gpt-4o","response_format":{"type":"json_object"},"messages":[{"role":"user","content":"list three colors"}]}`,
+			wantStatus:   http.StatusOK,
+			wantMessages: []interface{}{user},
+		},
+		{
+			name:       "incapable model gets a system prompt",
+			body:       `{"model":"gpt-4o-mini","response_format":{"type":"json_object"},"messages":[{"role":"user","content":"list three colors"}]}`,
+			wantStatus: http.StatusOK,
+			wantMessages: []interface{}{
+				map[string]interface{}{"role": "system", "content": "Respond with valid JSON only"},
+				user,
+			},
+		},
+		{
+			name:       "existing system prompt is extended",
+			body:       `{"model":"gpt-4o-mini","response_format":{"type":"json_object"},"messages":[{"role":"system","content":"You are terse."},{"role":"user","content":"list three colors"}]}`,
+			wantStatus: http.StatusOK,
+			wantMessages: []interface{}{
+				map[string]interface{}{"role": "system", "content": "You are terse.\n\nRespond with valid JSON only"},
+				user,
+			},
+		},
+		{
+			name:         "text format is unchanged",
+			body:         `{"model":"gpt-4o-mini","response_format":{"type":"text"},"messages":[{"role":"user","content":"list three colors"}]}`,
+			wantStatus:   http.StatusOK,
+			wantMessages: []interface{}{user},
+		},
+		{
+			name:       "unknown type is rejected",
+			body:       `{"model":"gpt-4o-mini","response_format":{"type":"xml"},"messages":[{"role":"user","content":"list three colors"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "json_schema requires a schema",
+			body:       `{"model":"gpt-4o-mini","response_format":{"type":"json_schema"},"messages":[{"role":"user","content":"list three colors"}]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamMessages = nil
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantMessages != nil && !reflect.DeepEqual(upstreamMessages, tt.wantMessages) {
+				t.Errorf("expected upstream messages %v, got %v", tt.wantMessages, upstreamMessages)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestLegacyCopilotConfig(t *testing.T) {
+	for _, migrate := range []bool{false, true} {
+		name := "warn"
+		if migrate {
+			name = "migrate"
+		}
+		t.Run(name, func(t *testing.T) {
+			copilotDir := setTestConfigHome(t)
+			home, _ := os.UserHomeDir()
+			legacyDir := filepath.Join(home, ".copilot")
+			if err := os.MkdirAll(legacyDir, 0o755); err != nil {
+				t.Fatalf("failed to create legacy config dir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(legacyDir, "config.json"), []byte(`{"github.token": "gho_legacy"}`), 0o600); err != nil {
+				t.Fatalf("failed to write legacy config: %v", err)
+			}
+
+			var gotAuth string
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAuth = r.Header.Get("Authorization")
+				_ = json.NewEncoder(w).Encode(copilot.CopilotToken{Token: "copilot-token", ExpiresAt: float64(time.Now().Add(time.Hour).Unix())})
+			}))
+			defer tokenServer.Close()
+
+			buf := captureLog(t)
+			tm := startTestTokenManager(t, copilot.WithAuthURL(tokenServer.URL), copilot.WithMigrateOldConfig(migrate))
+			if _, err := tm.GetToken(context.Background()); err != nil {
+				t.Fatalf("failed to get token: %v", err)
+			}
+			if gotAuth != "token gho_legacy" {
+				t.Errorf("expected the legacy OAuth token to be used, got %q", gotAuth)
+			}
+
+			hosts, err := os.ReadFile(filepath.Join(copilotDir, "hosts.json"))
+			if !migrate {
+				if !strings.Contains(buf.String(), "old Copilot CLI config") {
+					t.Errorf("expected a migration warning, got %q", buf.String())
+				}
+				if err == nil {
+					t.Errorf("expected no hosts.json without migration, got %s", hosts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected hosts.json to be written: %v", err)
+			}
+			var entries map[string]struct {
+				OAuthToken string `json:"oauth_token"`
+			}
+			if err := json.Unmarshal(hosts, &entries); err != nil || entries["github.com"].OAuthToken != "gho_legacy" {
+				t.Errorf("expected the legacy token in hosts.json, got %s", hosts)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// newLicenseTokenManager returns a TokenManager whose first token comes from a mock
+// token endpoint answering with the given fields besides token and expires_at.
+func newLicenseTokenManager(t *testing.T, fields string) *copilot.TokenManager {
+	t.Helper()
+	writeTestApps(t, setTestConfigHome(t))
+	expiresAt := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body := `{"token":"tier-token","expires_at":` + expiresAt
+		if fields != "" {
+			body += "," + fields
+		}
+		_, _ = w.Write([]byte(body + "}"))
+	}))
+	t.Cleanup(srv.Close)
+	tm := startTestTokenManager(t, copilot.WithAuthURL(srv.URL))
+	if _, err := tm.GetToken(context.Background()); err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+	return tm
+}
+
+func TestLicenseTierDetection(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   string
+	}{
+		{"no license fields", ``, ""},
+		{"individual subscriber", `"sku":"monthly_subscriber","individual":true`, copilot.LicenseIndividual},
+		{"free plan", `"sku":"free_limited_copilot"`, copilot.LicenseIndividual},
+		{"individual flag only", `"individual":true`, copilot.LicenseIndividual},
+		{"business seat", `"sku":"copilot_for_business_seat","individual":false`, copilot.LicenseBusiness},
+		{"organization seat without sku", `"individual":false`, copilot.LicenseBusiness},
+		{"enterprise seat", `"sku":"copilot_enterprise_seat","individual":false`, copilot.LicenseEnterprise},
+		{"enterprise trial", `"sku":"copilot_for_business_seat","enterprise_trial":true`, copilot.LicenseEnterprise},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := newLicenseTokenManager(t, tt.fields)
+			if got := tm.LicenseTier(); got != tt.want {
+				t.Errorf("expected tier %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLicenseTierPersisted(t *testing.T) {
+	tm := newLicenseTokenManager(t, `"sku":"copilot_enterprise_seat","chat_enabled":true,"tracking_id":"abc"`)
+	tm.Close()
+
+	// A new manager reads the saved token.json instead of asking the token endpoint
+	restarted := startTestTokenManager(t)
+	if got := restarted.LicenseTier(); got != copilot.LicenseEnterprise {
+		t.Errorf("expected the tier to survive a restart, got %q", got)
+	}
+}
+
+func TestEnterpriseOnlyModels(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	post := func(handler http.Handler, path, model string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(
+			`{"model":"`+model+`","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	cfg := &config.Config{CopilotToken: "test-token", AdminToken: "admin-token", CopilotBaseURL: upstream.URL, EnterpriseOnlyModels: []string{"o1"}}
+
+	individual := api.NewRouter(cfg, newLicenseTokenManager(t, `"sku":"monthly_subscriber"`), nil)
+	for _, path := range []string{"/v1/chat/completions", "/v1/messages"} {
+		rr := post(individual, path, "o1")
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400 for an enterprise-only model, got %d: %s", path, rr.Code, rr.Body.String())
+		}
+		if !strings.Contains(rr.Body.String(), "Business or Enterprise license") {
+			t.Errorf("%s: expected the license to be named in the error, got %s", path, rr.Body.String())
+		}
+		if rr := post(individual, path, "gpt-4o"); rr.Code != http.StatusOK {
+			t.Errorf("%s: expected other models to be allowed, got %d: %s", path, rr.Code, rr.Body.String())
+		}
+	}
+
+	business := api.NewRouter(cfg, newLicenseTokenManager(t, `"sku":"copilot_for_business_seat"`), nil)
+	if rr := post(business, "/v1/chat/completions", "o1"); rr.Code != http.StatusOK {
+		t.Errorf("expected a business license to use o1, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/token/status", nil)
+	req.Header.Set("Authorization", "Bearer admin-token")
+	rr := httptest.NewRecorder()
+	business.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected token status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var status struct {
+		Valid       bool   `json:"valid"`
+		LicenseTier string `json:"license_tier"`
+		ExpiresAt   string `json:"expires_at"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+		t.Fatalf("invalid token status: %v", err)
+	}
+	if !status.Valid || status.LicenseTier != copilot.LicenseBusiness || status.ExpiresAt == "" {
+		t.Errorf("expected a valid business token with an expiry, got %+v", status)
+	}
+}
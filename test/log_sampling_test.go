@@ -0,0 +1,59 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestLogSampling(t *testing.T) {
+	buf := captureLog(t)
+	cfg := &config.Config{Debug: true, LogSampleRate: 0.5}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	for i := 0; i < 1000; i++ {
+		// /healthz answers 200 without authentication; /v1/files requires it
+		for _, path := range []string{"/healthz", "/v1/files"} {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+	}
+
+	successes := strings.Count(buf.String(), "GET /healthz 200")
+	errors := strings.Count(buf.String(), "GET /v1/files 401")
+	if successes < 450 || successes > 550 {
+		t.Errorf("expected about 500 of 1000 successful requests to be logged, got %d", successes)
+	}
+	if errors != 1000 {
+		t.Errorf("expected all 1000 error responses to be logged, got %d", errors)
+	}
+}
+
+func TestLogSamplingSlowRequests(t *testing.T) {
+	buf := captureLog(t)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer upstream.Close()
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, Debug: true, LogSampleRate: 0, SlowRequestThreshold: 20 * time.Millisecond}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	rr := postChatBody(handler, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if strings.Contains(buf.String(), "GET /healthz") {
+		t.Errorf("expected a fast request not to be logged with a sample rate of 0, got %q", buf.String())
+	}
+	if !strings.Contains(buf.String(), "POST /v1/chat/completions 200") {
+		t.Errorf("expected the slow request to be logged, got %q", buf.String())
+	}
+}
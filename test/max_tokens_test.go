@@ -0,0 +1,144 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestMaxTokensPerKey(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		CopilotToken:    "test-token",
+		CopilotBaseURL:  upstream.URL,
+		APIKeys:         []config.APIKey{{Label: "team-a", Token: "token-a"}},
+		MaxTokensPerKey: map[string]int{"team-a": 100},
+	}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	tests := []struct {
+		name       string
+		target     string
+		token      string
+		maxTokens  string // "" omits max_tokens
+		wantTokens float64
+		wantHeader string
+	}{
+		{"below limit", "/v1/chat/completions", "token-a", "50", 50, "100"},
+		{"at limit", "/v1/chat/completions", "token-a", "100", 100, "100"},
+		{"above limit", "/v1/chat/completions", "token-a", "101", 100, "100"},
+		{"missing", "/v1/chat/completions", "token-a", "", 100, "100"},
+		{"anthropic above limit", "/v1/messages", "token-a", "500", 100, "100"},
+		{"key without limit", "/v1/chat/completions", "test-token", "5000", 5000, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]`
+			if tt.maxTokens != "" {
+				body += `,"max_tokens":` + tt.maxTokens
+			}
+			body += "}"
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if upstreamBody["max_tokens"] != tt.wantTokens {
+				t.Errorf("expected max_tokens %v upstream, got %v", tt.wantTokens, upstreamBody["max_tokens"])
+			}
+			if got := rr.Header().Get("X-Max-Tokens-Limit"); got != tt.wantHeader {
+				t.Errorf("expected X-Max-Tokens-Limit %q, got %q", tt.wantHeader, got)
+			}
+		})
+	}
+}
+
+func TestModelMaxTokensClamp(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cache := newTestModelsCache(t, testModelsJSON)
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), cache)
+	// Without a models cache no model is known, and unknown models are not refused
+	uncached := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		target     string
+		model      string
+		maxTokens  string
+		wantTokens float64
+		wantHeader string
+	}{
+		{"above model limit", handler, "/v1/chat/completions", "gpt-4o-mini", "100000", 4096, "100000"},
+		{"at model limit", handler, "/v1/chat/completions", "openai/gpt-4o-mini", "4096", 4096, ""},
+		{"below model limit", handler, "/v1/chat/completions", "gpt-4o", "8000", 8000, ""},
+		{"anthropic above model limit", handler, "/v1/messages", "gpt-4o", "20000", 16384, "20000"},
+		{"unknown model", uncached, "/v1/chat/completions", "gpt-4o-mini", "100000", 100000, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"model":"` + tt.model + `","max_tokens":` + tt.maxTokens + `,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if upstreamBody["max_tokens"] != tt.wantTokens {
+				t.Errorf("expected max_tokens %v upstream, got %v", tt.wantTokens, upstreamBody["max_tokens"])
+			}
+			if got := rr.Header().Get("X-Max-Tokens-Clamped"); got != tt.wantHeader {
+				t.Errorf("expected X-Max-Tokens-Clamped %q, got %q", tt.wantHeader, got)
+			}
+		})
+	}
+}
+
+func TestMaxTokensFile(t *testing.T) {
+	setTestConfigHome(t)
+	t.Setenv("COPILOT_OAUTH_TOKEN", "test-oauth-token")
+	path := filepath.Join(t.TempDir(), "max_tokens.json")
+	if err := os.WriteFile(path, []byte(`{"team-a": 1024, "team-b": 256}`), 0o600); err != nil {
+		t.Fatalf("failed to write limits: %v", err)
+	}
+	t.Setenv("MAX_TOKENS_FILE", path)
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.MaxTokensPerKey["team-a"] != 1024 || cfg.MaxTokensPerKey["team-b"] != 256 {
+		t.Errorf("unexpected limits: %v", cfg.MaxTokensPerKey)
+	}
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestPostOnlyEndpointsRejectOtherMethods(t *testing.T) {
+	var upstreamCalls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls.Add(1)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	paths := []string{"/v1/chat/completions", "/v1/chat/completions/count_tokens", "/v1/embeddings", "/v1/messages", "/v1/messages/count_tokens"}
+	for _, path := range paths {
+		for _, method := range []string{http.MethodGet, http.MethodDelete} {
+			req := httptest.NewRequest(method, path, nil)
+			req.Header.Set("Authorization", "Bearer test-token")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusMethodNotAllowed {
+				t.Errorf("%s %s: expected 405, got %d: %s", method, path, rr.Code, rr.Body.String())
+				continue
+			}
+			if allow := rr.Header().Get("Allow"); allow != http.MethodPost {
+				t.Errorf("%s %s: expected Allow: POST, got %q", method, path, allow)
+			}
+			var body struct {
+				Error struct {
+					Type string `json:"type"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Error.Type != "invalid_request_error" {
+				t.Errorf("%s %s: expected an OpenAI-format error, got %s", method, path, rr.Body.String())
+			}
+		}
+	}
+	if n := upstreamCalls.Load(); n != 0 {
+		t.Errorf("expected no upstream requests, got %d", n)
+	}
+}
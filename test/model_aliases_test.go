@@ -0,0 +1,148 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+func TestValidateModelAliases(t *testing.T) {
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+
+	aliases := map[string]string{
+		"gpt-4":   "openai/gpt-4o-mini",
+		"fast":    "gpt-4o-mini",
+		"llama":   "meta/llama-9",
+		"premium": "nonexistent-copilot-model",
+	}
+
+	buf := captureLog(t)
+	cache, err := copilot.NewModelsCache(context.Background(), "test-token", time.Hour,
+		copilot.WithModelsURL(catalog.URL), copilot.WithModelAliases(aliases))
+	if err != nil {
+		t.Fatalf("failed to create models cache: %v", err)
+	}
+	defer cache.Close()
+
+	// The refresh at startup warns about every unknown alias target
+	logged := buf.String()
+	for _, target := range []string{"meta/llama-9", "nonexistent-copilot-model"} {
+		if !strings.Contains(logged, "Warning") || !strings.Contains(logged, target) {
+			t.Errorf("expected a warning for alias target %s, got %q", target, logged)
+		}
+	}
+	if strings.Contains(logged, "gpt-4o-mini") {
+		t.Errorf("expected no warning for known alias targets, got %q", logged)
+	}
+
+	errs := copilot.ValidateModelAliases(context.Background(), aliases, cache)
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if !strings.Contains(errs[0].Error(), `"llama"`) || !strings.Contains(errs[1].Error(), `"premium"`) {
+		t.Errorf("expected errors for llama and premium in order, got %v", errs)
+	}
+
+	buf.Reset()
+	if _, err := cache.ForceRefresh(context.Background()); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "nonexistent-copilot-model") {
+		t.Errorf("expected warnings to be logged again after a refresh, got %q", buf.String())
+	}
+}
+
+func TestModelAliasFileReload(t *testing.T) {
+	var mu sync.Mutex
+	var upstreamModel string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		upstreamModel = body.Model
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "aliases.json")
+	if err := os.WriteFile(path, []byte(`{"fast": "gpt-4o-mini"}`), 0o600); err != nil {
+		t.Fatalf("failed to write aliases: %v", err)
+	}
+	t.Setenv("MODEL_ALIASES_FILE", path)
+	t.Setenv("COPILOT_OAUTH_TOKEN", "test-oauth-token")
+	setTestConfigHome(t)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.CopilotToken, cfg.CopilotBaseURL = "test-token", upstream.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	aliases := copilot.NewAtomicAliasMap(cfg.ModelAliases)
+	watching := make(chan struct{})
+	go func() {
+		copilot.WatchAliasFile(ctx, cfg.ModelAliasFile, aliases)
+		close(watching)
+	}()
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithModelAliases(aliases))
+
+	sentModel := func() string {
+		rr := postChatBody(handler, `{"model":"fast","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return upstreamModel
+	}
+	if got := sentModel(); got != "gpt-4o-mini" {
+		t.Fatalf("expected the alias from the file to apply, got %q", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"fast": "gpt-4.1-nano", "smart": "gpt-4o"}`), 0o600); err != nil {
+		t.Fatalf("failed to update aliases: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for aliases.Get("fast") != "gpt-4.1-nano" && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if got := sentModel(); got != "gpt-4.1-nano" {
+		t.Fatalf("expected the updated alias within a second, got %q", got)
+	}
+
+	// An invalid file keeps the aliases last loaded
+	buf := captureLog(t)
+	if err := os.WriteFile(path, []byte(`{"fast": `), 0o600); err != nil {
+		t.Fatalf("failed to update aliases: %v", err)
+	}
+	// Give the watcher time to see the change, then stop it so the log can be read
+	time.Sleep(time.Second)
+	cancel()
+	<-watching
+	if !strings.Contains(buf.String(), "keeping the current model aliases") {
+		t.Errorf("expected the invalid file to be logged, got %q", buf.String())
+	}
+	if got := aliases.Get("smart"); got != "gpt-4o" {
+		t.Errorf("expected the previous aliases to be kept, got smart=%q", got)
+	}
+}
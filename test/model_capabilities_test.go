@@ -0,0 +1,82 @@
+package test
+
+import (
+	"slices"
+	"testing"
+
+	"copilot-api/internal/copilot"
+)
+
+// capabilityModelsJSON is a catalog with a different set of capabilities per model.
+const capabilityModelsJSON = `[
+	{"id":"openai/gpt-4o","name":"GPT-4o","supported_input_modalities":["text","image"],"capabilities":["streaming","tool-calling","json-mode"],"limits":{"max_input_tokens":131072}},
+	{"id":"openai/o1-mini","name":"o1-mini","supported_input_modalities":["text"],"capabilities":["streaming"],"limits":{"max_input_tokens":128000}},
+	{"id":"meta/llama","name":"Llama","supported_input_modalities":["text"],"capabilities":["tool-calling"],"limits":{"max_input_tokens":8192}},
+	{"id":"other/plain","name":"Plain","supported_input_modalities":["text"],"limits":{"max_input_tokens":100000}}
+]`
+
+func TestGetModelsByCapability(t *testing.T) {
+	cache := newTestModelsCache(t, capabilityModelsJSON)
+
+	ids := func(models []copilot.Model) []string {
+		ids := make([]string, len(models))
+		for i, m := range models {
+			ids[i] = m.ID
+		}
+		return ids
+	}
+	tests := []struct {
+		name string
+		caps []string
+		all  []string
+		any  []string
+	}{
+		{"no capabilities", nil, []string{"openai/gpt-4o", "openai/o1-mini", "meta/llama", "other/plain"}, []string{}},
+		{"vision", []string{copilot.CapabilityVision}, []string{"openai/gpt-4o"}, []string{"openai/gpt-4o"}},
+		{"streaming", []string{copilot.CapabilityStreaming}, []string{"openai/gpt-4o", "openai/o1-mini"}, []string{"openai/gpt-4o", "openai/o1-mini"}},
+		{"json mode", []string{copilot.CapabilityJSONMode}, []string{"openai/gpt-4o"}, []string{"openai/gpt-4o"}},
+		// 100000 tokens is not over the threshold
+		{"long context", []string{copilot.CapabilityLongContext}, []string{"openai/gpt-4o", "openai/o1-mini"}, []string{"openai/gpt-4o", "openai/o1-mini"}},
+		{"streaming and function calling", []string{copilot.CapabilityStreaming, copilot.CapabilityFunctionCalling},
+			[]string{"openai/gpt-4o"}, []string{"openai/gpt-4o", "openai/o1-mini", "meta/llama"}},
+		{"function calling and long context", []string{copilot.CapabilityFunctionCalling, copilot.CapabilityLongContext},
+			[]string{"openai/gpt-4o"}, []string{"openai/gpt-4o", "openai/o1-mini", "meta/llama"}},
+		{"vision and function calling without long context", []string{copilot.CapabilityVision, copilot.CapabilityFunctionCalling},
+			[]string{"openai/gpt-4o"}, []string{"openai/gpt-4o", "meta/llama"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all, err := cache.GetModelsByCapability(tt.caps...)
+			if err != nil {
+				t.Fatalf("GetModelsByCapability: %v", err)
+			}
+			if got := ids(all); !slices.Equal(got, tt.all) {
+				t.Errorf("expected %v with all capabilities, got %v", tt.all, got)
+			}
+			anyOf, err := cache.GetModelsByAnyCapability(tt.caps...)
+			if err != nil {
+				t.Fatalf("GetModelsByAnyCapability: %v", err)
+			}
+			if got := ids(anyOf); !slices.Equal(got, tt.any) {
+				t.Errorf("expected %v with any capability, got %v", tt.any, got)
+			}
+		})
+	}
+
+	// A filter matching nothing returns an empty slice, not nil
+	none, err := cache.GetModelsByCapability(copilot.CapabilityVision, copilot.CapabilityLongContext, copilot.CapabilityJSONMode, "streaming", "function_calling")
+	if err != nil || len(none) != 1 {
+		t.Fatalf("expected only gpt-4o to have every capability, got %v (%v)", ids(none), err)
+	}
+	empty, err := newTestModelsCache(t, `[{"id":"other/plain","name":"Plain"}]`).GetModelsByCapability(copilot.CapabilityVision)
+	if err != nil || empty == nil || len(empty) != 0 {
+		t.Errorf("expected an empty non-nil slice, got %#v (%v)", empty, err)
+	}
+
+	if _, err := cache.GetModelsByCapability("telepathy"); err == nil {
+		t.Error("expected an error for an unknown capability")
+	}
+	if _, err := cache.GetModelsByAnyCapability(copilot.CapabilityVision, "telepathy"); err == nil {
+		t.Error("expected an error for an unknown capability")
+	}
+}
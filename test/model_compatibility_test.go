@@ -0,0 +1,75 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestModelCompatibility(t *testing.T) {
+	cache := newTestModelsCache(t, `[
+		{"id": "openai/gpt-4o", "capabilities": ["streaming", "tool-calling"], "supported_input_modalities": ["text", "image"], "supported_output_modalities": ["text"], "limits": {"max_input_tokens": 131072, "max_output_tokens": 16384}},
+		{"id": "openai/gpt-4o-mini", "capabilities": ["streaming"], "supported_input_modalities": ["text"], "limits": {"max_input_tokens": 131072, "max_output_tokens": 4096}},
+		{"id": "openai/text-embedding-3-small", "supported_input_modalities": ["text"], "supported_output_modalities": ["embeddings"], "limits": {"max_input_tokens": 8191}}
+	]`)
+	defer cache.Close()
+	cfg := &config.Config{CopilotToken: "test-token"}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), cache)
+
+	type compatibility struct {
+		ModelID                 string `json:"model_id"`
+		SupportsStreaming       bool   `json:"supports_streaming"`
+		SupportsFunctionCalling bool   `json:"supports_function_calling"`
+		SupportsVision          bool   `json:"supports_vision"`
+		SupportsJSONMode        bool   `json:"supports_json_mode"`
+		AnthropicCompatible     bool   `json:"anthropic_compatible"`
+		MaxContextTokens        int    `json:"max_context_tokens"`
+		MaxOutputTokens         int    `json:"max_output_tokens"`
+	}
+	tests := []struct {
+		id   string
+		want compatibility
+	}{
+		{"openai/gpt-4o", compatibility{"openai/gpt-4o", true, true, true, true, true, 131072, 16384}},
+		{"gpt-4o-mini", compatibility{"openai/gpt-4o-mini", true, false, false, true, true, 131072, 4096}},
+		{"text-embedding-3-small", compatibility{"openai/text-embedding-3-small", false, false, false, false, false, 8191, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			// No Authorization header: the endpoint is public like /v1/models
+			req := httptest.NewRequest(http.MethodGet, "/v1/models/"+tt.id+"/compatibility", nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var got compatibility
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	for path, want := range map[string]int{
+		"/v1/models/gpt-5/compatibility": http.StatusNotFound,
+		// Other paths under /v1/models/ still need a token
+		"/v1/models/gpt-4o":         http.StatusUnauthorized,
+		"/v1/models/cache":          http.StatusUnauthorized,
+		"/v1/models/search":         http.StatusOK,
+		"/v1/models//compatibility": http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s: expected status %d, got %d: %s", path, want, rr.Code, rr.Body.String())
+		}
+	}
+}
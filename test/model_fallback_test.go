@@ -0,0 +1,81 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestModelFallback(t *testing.T) {
+	var upstreamModel interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		upstreamModel = body["model"]
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+	modelsCache := newTestModelsCache(t, testModelsJSON)
+
+	tests := []struct {
+		name          string
+		fallback      string
+		aliases       map[string]string
+		target        string
+		model         string
+		wantStatus    int
+		wantUpstream  string
+		wantFallback  bool
+		wantErrorText string
+	}{
+		{name: "known model", target: "/v1/chat/completions", model: "gpt-4o-mini", wantStatus: http.StatusOK, wantUpstream: "gpt-4o-mini"},
+		{name: "alias of a known model", aliases: map[string]string{"gpt-4": "gpt-4o-mini"}, target: "/v1/chat/completions", model: "gpt-4", wantStatus: http.StatusOK, wantUpstream: "gpt-4o-mini"},
+		{name: "unknown model with fallback", fallback: "gpt-4o-mini", target: "/v1/chat/completions", model: "gpt-9", wantStatus: http.StatusOK, wantUpstream: "gpt-4o-mini", wantFallback: true},
+		{name: "unknown anthropic model with fallback", fallback: "gpt-4o-mini", target: "/v1/messages", model: "claude-9", wantStatus: http.StatusOK, wantUpstream: "gpt-4o-mini", wantFallback: true},
+		{name: "unknown model without fallback", target: "/v1/chat/completions", model: "gpt-9", wantStatus: http.StatusBadRequest, wantErrorText: "openai/gpt-4o-mini"},
+		{name: "unknown anthropic model without fallback", target: "/v1/messages", model: "claude-9", wantStatus: http.StatusBadRequest, wantErrorText: "meta/llama-3.3-70b-instruct"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamModel = nil
+			cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, FallbackModel: tt.fallback, ModelAliases: tt.aliases}
+			handler := api.NewRouter(cfg, newTestTokenManager(t), modelsCache)
+
+			body := `{"model":"` + tt.model + `","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantErrorText != "" {
+				if upstreamModel != nil {
+					t.Errorf("expected no upstream request, got one for %v", upstreamModel)
+				}
+				if !strings.Contains(rr.Body.String(), tt.wantErrorText) {
+					t.Errorf("expected the error to list %s, got %s", tt.wantErrorText, rr.Body.String())
+				}
+				return
+			}
+			if upstreamModel != tt.wantUpstream {
+				t.Errorf("expected model %s upstream, got %v", tt.wantUpstream, upstreamModel)
+			}
+			if got := rr.Header().Get("X-Model-Fallback") == "true"; got != tt.wantFallback {
+				t.Errorf("expected X-Model-Fallback %v, got %q", tt.wantFallback, rr.Header().Get("X-Model-Fallback"))
+			}
+			if tt.wantFallback && rr.Header().Get("X-Original-Model") != tt.model {
+				t.Errorf("expected X-Original-Model %s, got %q", tt.model, rr.Header().Get("X-Original-Model"))
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestModelsAcceptNegotiation(t *testing.T) {
+	handler := api.NewRouter(&config.Config{}, nil, newTestModelsCache(t, testModelsJSON))
+
+	tests := []struct {
+		name           string
+		accept         string
+		wantStatusCode int
+	}{
+		{name: "no accept header", accept: "", wantStatusCode: http.StatusOK},
+		{name: "application/json", accept: "application/json", wantStatusCode: http.StatusOK},
+		{name: "json:api alias", accept: "application/vnd.api+json", wantStatusCode: http.StatusOK},
+		{name: "wildcard", accept: "*/*", wantStatusCode: http.StatusOK},
+		{name: "application wildcard", accept: "application/*", wantStatusCode: http.StatusOK},
+		{name: "json among others", accept: "text/html, application/json;q=0.9", wantStatusCode: http.StatusOK},
+		{name: "text/plain only", accept: "text/plain", wantStatusCode: http.StatusNotAcceptable},
+		{name: "json explicitly refused", accept: "application/json;q=0, text/plain", wantStatusCode: http.StatusNotAcceptable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatusCode {
+				t.Fatalf("expected status %d, got %d", tt.wantStatusCode, rr.Code)
+			}
+			if rr.Code == http.StatusOK {
+				if ct := rr.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+					t.Errorf("expected JSON content type with charset, got %q", ct)
+				}
+			}
+		})
+	}
+}
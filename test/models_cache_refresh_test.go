@@ -0,0 +1,198 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+func TestModelsCacheRefreshEndpoint(t *testing.T) {
+	var calls atomic.Int32
+	var failing atomic.Bool
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			http.Error(w, "catalog down", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+
+	cache, err := copilot.NewModelsCache(context.Background(), "test-token", time.Hour, copilot.WithModelsURL(catalog.URL))
+	if err != nil {
+		t.Fatalf("failed to create models cache: %v", err)
+	}
+	cfg := &config.Config{CopilotToken: "client-token", AdminToken: "admin-token"}
+	handler := api.NewRouter(cfg, nil, cache)
+
+	send := func(token, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("requires admin token", func(t *testing.T) {
+		if rr := send("", "/v1/models/cache"); rr.Code != http.StatusUnauthorized {
+			t.Errorf("expected 401 without token, got %d", rr.Code)
+		}
+		if rr := send("client-token", "/v1/models/cache"); rr.Code != http.StatusForbidden {
+			t.Errorf("expected 403 for client token, got %d", rr.Code)
+		}
+	})
+
+	t.Run("synchronous refresh", func(t *testing.T) {
+		calls.Store(0)
+		rr := send("admin-token", "/v1/models/cache")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var got struct {
+			ModelsCount int    `json:"models_count"`
+			LastFetch   string `json:"last_fetch"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		if got.ModelsCount != 3 {
+			t.Errorf("expected 3 models, got %d", got.ModelsCount)
+		}
+		if _, err := time.Parse(time.RFC3339, got.LastFetch); err != nil {
+			t.Errorf("last_fetch is not RFC3339: %q", got.LastFetch)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("expected one catalog fetch, got %d", calls.Load())
+		}
+	})
+
+	t.Run("background refresh", func(t *testing.T) {
+		calls.Store(0)
+		rr := send("admin-token", "/v1/models/cache?background=true")
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d", rr.Code)
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for calls.Load() == 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("expected background catalog fetch, got %d", calls.Load())
+		}
+	})
+
+	t.Run("refresh failure", func(t *testing.T) {
+		failing.Store(true)
+		defer failing.Store(false)
+		rr := send("admin-token", "/v1/models/cache")
+		if rr.Code != http.StatusBadGateway {
+			t.Fatalf("expected 502, got %d", rr.Code)
+		}
+		var got map[string]string
+		if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil || got["error"] == "" {
+			t.Errorf("expected error detail, got %q", rr.Body.String())
+		}
+	})
+}
+
+func TestModelsCacheForceRefresh(t *testing.T) {
+	var fetches atomic.Int32
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+	cache, err := copilot.NewModelsCache(context.Background(), "test-token", time.Hour, copilot.WithModelsURL(catalog.URL))
+	if err != nil {
+		t.Fatalf("failed to create models cache: %v", err)
+	}
+	defer cache.Close()
+
+	count, err := cache.ForceRefresh(context.Background())
+	if err != nil {
+		t.Fatalf("ForceRefresh: %v", err)
+	}
+	var seeded []map[string]interface{}
+	_ = json.Unmarshal([]byte(testModelsJSON), &seeded)
+	if count != len(seeded) {
+		t.Errorf("expected ForceRefresh to report %d models, got %d", len(seeded), count)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("expected ForceRefresh to fetch despite a fresh cache, got %d fetches", n)
+	}
+
+	if _, err := cache.GetModelsWithForce(context.Background(), false); err != nil {
+		t.Fatalf("GetModelsWithForce: %v", err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("expected a fresh cache to be served without fetching, got %d fetches", n)
+	}
+	data, err := cache.GetModelsWithForce(context.Background(), true)
+	if err != nil {
+		t.Fatalf("GetModelsWithForce: %v", err)
+	}
+	if n := fetches.Load(); n != 3 {
+		t.Errorf("expected force to bypass the TTL, got %d fetches", n)
+	}
+	if string(data) != testModelsJSON {
+		t.Errorf("expected the refetched models JSON, got %s", data)
+	}
+}
+
+func TestModelsCacheBackgroundRefresh(t *testing.T) {
+	var calls atomic.Int32
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+
+	const ttl = time.Second
+	cache, err := copilot.NewModelsCache(context.Background(), "test-token", ttl, copilot.WithModelsURL(catalog.URL))
+	if err != nil {
+		t.Fatalf("failed to create models cache: %v", err)
+	}
+	fetched := cache.LastFetch()
+
+	// With no requests at all, the list is refetched once 90% of the TTL has passed
+	time.Sleep(time.Until(fetched.Add(ttl * 97 / 100)))
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected a background refresh before the TTL expired, got %d fetches", n)
+	}
+	if since := cache.LastFetch().Sub(fetched); since < ttl*9/10 || since >= ttl {
+		t.Errorf("expected the refresh at 90%% of the TTL, got %v after the first fetch", since)
+	}
+
+	cache.Close()
+	n := calls.Load()
+	time.Sleep(ttl)
+	if calls.Load() != n {
+		t.Error("expected no refreshes after Close")
+	}
+}
+
+func TestEmptyModelsCacheBackgroundRefresh(t *testing.T) {
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+
+	// A cache whose startup fetch failed is filled without waiting for a request
+	cache := copilot.NewEmptyModelsCache("test-token", time.Second, copilot.WithModelsURL(catalog.URL))
+	defer cache.Close()
+	time.Sleep(300 * time.Millisecond)
+	if n := cache.ModelCount(); n != 3 {
+		t.Errorf("expected the background refresh to fill the cache, got %d models", n)
+	}
+}
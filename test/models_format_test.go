@@ -0,0 +1,88 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestModelsOpenAIFormat(t *testing.T) {
+	handler := api.NewRouter(&config.Config{}, nil, newTestModelsCache(t, testModelsJSON))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	// Mirrors the fields the OpenAI SDKs require on Model objects
+	var list struct {
+		Object *string `json:"object"`
+		Data   []struct {
+			ID      *string `json:"id"`
+			Object  *string `json:"object"`
+			Created *int64  `json:"created"`
+			OwnedBy *string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if list.Object == nil || *list.Object != "list" {
+		t.Fatalf("expected object \"list\", got %s", rr.Body.String())
+	}
+	if len(list.Data) != 3 {
+		t.Fatalf("expected 3 models, got %d", len(list.Data))
+	}
+	for _, m := range list.Data {
+		if m.ID == nil || m.Object == nil || m.Created == nil || m.OwnedBy == nil {
+			t.Fatalf("model is missing a required field: %s", rr.Body.String())
+		}
+		if *m.Object != "model" {
+			t.Errorf("expected object \"model\", got %q", *m.Object)
+		}
+		if time.Since(time.Unix(*m.Created, 0)) > time.Minute {
+			t.Errorf("expected created to be a recent Unix timestamp, got %d", *m.Created)
+		}
+	}
+	if first := list.Data[0]; *first.ID != "openai/gpt-4o" || *first.OwnedBy != "OpenAI" {
+		t.Errorf("expected openai/gpt-4o owned by OpenAI, got %s owned by %s", *first.ID, *first.OwnedBy)
+	}
+}
+
+func TestModelsAnthropicFormat(t *testing.T) {
+	handler := api.NewRouter(&config.Config{}, nil, newTestModelsCache(t, testModelsJSON))
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("Accept", "application/vnd.anthropic+json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var list struct {
+		Models []struct {
+			Type        string `json:"type"`
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+			CreatedAt   string `json:"created_at"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(list.Models) != 3 {
+		t.Fatalf("expected 3 models, got %s", rr.Body.String())
+	}
+	m := list.Models[0]
+	if m.Type != "model" || m.ID != "openai/gpt-4o" || m.DisplayName != "OpenAI GPT-4o" {
+		t.Errorf("unexpected model entry: %+v", m)
+	}
+	if _, err := time.Parse(time.RFC3339, m.CreatedAt); err != nil {
+		t.Errorf("expected an RFC 3339 created_at, got %q", m.CreatedAt)
+	}
+}
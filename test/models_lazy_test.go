@@ -0,0 +1,68 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+func TestModelsUnavailableAtStartup(t *testing.T) {
+	var available atomic.Bool
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available.Load() {
+			http.Error(w, "catalog down", http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+
+	getModels := func(handler http.Handler) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+		return rr
+	}
+	cfg := &config.Config{CopilotToken: "test-token"}
+
+	t.Run("nil cache", func(t *testing.T) {
+		rr := getModels(api.NewRouter(cfg, nil, nil))
+		if rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status 503, got %d", rr.Code)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body["error"] != "models unavailable at startup" {
+			t.Errorf("expected JSON error body, got %q", rr.Body.String())
+		}
+	})
+
+	cache := copilot.NewEmptyModelsCache("test-token", time.Hour, copilot.WithModelsURL(catalog.URL))
+	t.Cleanup(cache.Close)
+	handler := api.NewRouter(cfg, nil, cache)
+
+	t.Run("empty cache with catalog down", func(t *testing.T) {
+		if rr := getModels(handler); rr.Code != http.StatusServiceUnavailable {
+			t.Fatalf("expected status 503, got %d", rr.Code)
+		}
+	})
+
+	t.Run("empty cache fetches on demand", func(t *testing.T) {
+		available.Store(true)
+		rr := getModels(handler)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var models struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &models); err != nil || len(models.Data) != 3 {
+			t.Errorf("expected 3 models, got %d (%v)", len(models.Data), err)
+		}
+	})
+}
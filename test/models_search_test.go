@@ -0,0 +1,90 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+const searchModelsJSON = `[
+	{"id": "openai/gpt-4o", "name": "OpenAI GPT-4o", "publisher": "OpenAI", "capabilities": ["streaming", "tool-calling"], "supported_input_modalities": ["text", "image"]},
+	{"id": "openai/gpt-4o-mini", "name": "OpenAI GPT-4o mini", "publisher": "OpenAI", "capabilities": ["streaming", "tool-calling"], "supported_input_modalities": ["text", "image"]},
+	{"id": "openai/o1", "name": "OpenAI o1", "publisher": "OpenAI", "capabilities": ["tool-calling"], "supported_input_modalities": ["text"]},
+	{"id": "meta/llama-3.3-70b-instruct", "name": "Llama-3.3-70B-Instruct", "publisher": "Meta", "capabilities": ["streaming"], "supported_input_modalities": ["text"]},
+	{"id": "meta/llama-3.2-90b-vision-instruct", "name": "Llama-3.2-90B-Vision-Instruct", "publisher": "Meta", "capabilities": ["streaming"], "supported_input_modalities": ["text", "image"]},
+	{"id": "mistral-ai/mistral-large-2411", "name": "Mistral Large 24.11", "publisher": "Mistral AI", "capabilities": ["streaming", "tool-calling"], "supported_input_modalities": ["text"]},
+	{"id": "mistral-ai/codestral-2501", "name": "Codestral 25.01", "publisher": "Mistral AI", "capabilities": ["streaming"], "supported_input_modalities": ["text"]},
+	{"id": "microsoft/phi-4", "name": "Phi-4", "publisher": "Microsoft", "capabilities": ["streaming"], "supported_input_modalities": ["text"]},
+	{"id": "microsoft/phi-4-multimodal-instruct", "name": "Phi-4-multimodal-instruct", "publisher": "Microsoft", "capabilities": ["streaming"], "supported_input_modalities": ["text", "image", "audio"]},
+	{"id": "deepseek/deepseek-r1", "name": "DeepSeek-R1", "capabilities": [], "supported_input_modalities": ["text"]}
+]`
+
+func TestModelsSearch(t *testing.T) {
+	cache := newTestModelsCache(t, searchModelsJSON)
+	defer cache.Close()
+	cfg := &config.Config{CopilotToken: "test-token"}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), cache)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"no filters", "", []string{"openai/gpt-4o", "openai/gpt-4o-mini", "openai/o1", "meta/llama-3.3-70b-instruct", "meta/llama-3.2-90b-vision-instruct", "mistral-ai/mistral-large-2411", "mistral-ai/codestral-2501", "microsoft/phi-4", "microsoft/phi-4-multimodal-instruct", "deepseek/deepseek-r1"}},
+		{"id substring", "q=phi-4", []string{"microsoft/phi-4", "microsoft/phi-4-multimodal-instruct"}},
+		{"name substring ignoring case", "q=CODESTRAL", []string{"mistral-ai/codestral-2501"}},
+		{"name only", "q=large%2024", []string{"mistral-ai/mistral-large-2411"}},
+		{"vision", "capability=vision", []string{"openai/gpt-4o", "openai/gpt-4o-mini", "meta/llama-3.2-90b-vision-instruct", "microsoft/phi-4-multimodal-instruct"}},
+		{"function calling", "capability=function_calling", []string{"openai/gpt-4o", "openai/gpt-4o-mini", "openai/o1", "mistral-ai/mistral-large-2411"}},
+		{"streaming", "capability=streaming", []string{"openai/gpt-4o", "openai/gpt-4o-mini", "meta/llama-3.3-70b-instruct", "meta/llama-3.2-90b-vision-instruct", "mistral-ai/mistral-large-2411", "mistral-ai/codestral-2501", "microsoft/phi-4", "microsoft/phi-4-multimodal-instruct"}},
+		{"vendor", "vendor=meta", []string{"meta/llama-3.3-70b-instruct", "meta/llama-3.2-90b-vision-instruct"}},
+		{"vendor without publisher", "vendor=deepseek", []string{"deepseek/deepseek-r1"}},
+		{"vendor and capability", "vendor=OpenAI&capability=vision", []string{"openai/gpt-4o", "openai/gpt-4o-mini"}},
+		{"query and capability", "q=llama&capability=vision", []string{"meta/llama-3.2-90b-vision-instruct"}},
+		{"all filters", "q=mini&capability=function_calling&vendor=openai", []string{"openai/gpt-4o-mini"}},
+		{"no match", "q=gpt&vendor=meta", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No Authorization header: the search endpoint is exempt by default
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models/search?"+tt.query, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var resp struct {
+				Object string `json:"object"`
+				Data   []struct {
+					ID     string `json:"id"`
+					Object string `json:"object"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if resp.Object != "list" {
+				t.Errorf("expected an OpenAI list, got object %q", resp.Object)
+			}
+			got := []string{}
+			for _, m := range resp.Data {
+				got = append(got, m.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("unknown capability", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models/search?capability=telepathy", nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+}
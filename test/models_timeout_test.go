@@ -0,0 +1,47 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+func TestModelsFetchTimeout(t *testing.T) {
+	const fetchTimeout = 200 * time.Millisecond
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Much slower than the fetch timeout, but give up once the client does
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+
+	cache := copilot.NewEmptyModelsCache("test-token", time.Hour,
+		copilot.WithModelsURL(catalog.URL),
+		copilot.WithModelsConfig(copilot.ModelsConfig{FetchTimeout: fetchTimeout, RefreshTimeout: time.Second}),
+	)
+	defer cache.Close()
+	handler := api.NewRouter(&config.Config{CopilotToken: "test-token"}, nil, cache)
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	elapsed := time.Since(start)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("expected Retry-After 10, got %q", got)
+	}
+	if elapsed > fetchTimeout+500*time.Millisecond {
+		t.Errorf("expected the request to give up after about %v, took %v", fetchTimeout, elapsed)
+	}
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestMultipleNEmulation(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if _, ok := body["n"]; ok {
+			http.Error(w, "n is not supported", http.StatusBadRequest)
+			return
+		}
+		call := calls.Add(1)
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"answer %d\"}}]}\n\n", call)
+			fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"chatcmpl-1","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"answer %d"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`, call)
+	}))
+	defer upstream.Close()
+
+	send := func(emulate bool, body string) *httptest.ResponseRecorder {
+		calls.Store(0)
+		cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, EmulateMultipleN: emulate}
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		api.NewRouter(cfg, newTestTokenManager(t), nil).ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("not supported", func(t *testing.T) {
+		rr := send(false, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"n":2}`)
+		var resp struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		if rr.Code != http.StatusBadRequest || resp.Error.Message != "n > 1 not supported" {
+			t.Errorf("expected 400 n > 1 not supported, got %d: %s", rr.Code, rr.Body.String())
+		}
+		if calls.Load() != 0 {
+			t.Errorf("expected no upstream requests, got %d", calls.Load())
+		}
+	})
+
+	t.Run("merged", func(t *testing.T) {
+		rr := send(true, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"n":2}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Choices []struct {
+				Index   int `json:"index"`
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+			Usage map[string]float64 `json:"usage"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid JSON response: %v", err)
+		}
+		if len(resp.Choices) != 2 {
+			t.Fatalf("expected 2 choices, got %d: %s", len(resp.Choices), rr.Body.String())
+		}
+		if resp.Choices[0].Index != 0 || resp.Choices[1].Index != 1 {
+			t.Errorf("expected choice indexes 0 and 1, got %d and %d", resp.Choices[0].Index, resp.Choices[1].Index)
+		}
+		if resp.Choices[0].Message.Content == resp.Choices[1].Message.Content {
+			t.Errorf("expected two distinct choices, got %q twice", resp.Choices[0].Message.Content)
+		}
+		if resp.Usage["completion_tokens"] != 4 || resp.Usage["total_tokens"] != 9 {
+			t.Errorf("expected summed usage, got %v", resp.Usage)
+		}
+	})
+
+	t.Run("streamed", func(t *testing.T) {
+		rr := send(true, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"n":2,"stream":true}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, `"index":0`) || !strings.Contains(body, `"index":1`) {
+			t.Errorf("expected events for choices 0 and 1, got %q", body)
+		}
+		if strings.Count(body, "[DONE]") != 1 || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+			t.Errorf("expected a single final [DONE], got %q", body)
+		}
+	})
+}
+
+func TestMultipleNStreamedWithinUpstreamConcurrency(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"id\":\"chatcmpl-1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"hi\"}}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, EmulateMultipleN: true, MaxUpstreamConcurrency: 2}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+	send := func(n int) <-chan *httptest.ResponseRecorder {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			done <- postChatBody(handler, fmt.Sprintf(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}],"n":%d,"stream":true}`, n))
+		}()
+		return done
+	}
+	wait := func(done <-chan *httptest.ResponseRecorder) *httptest.ResponseRecorder {
+		select {
+		case rr := <-done:
+			return rr
+		case <-time.After(5 * time.Second):
+			t.Fatal("the streamed request did not complete")
+			return nil
+		}
+	}
+
+	// More streams than slots could never all start
+	if rr := wait(send(3)); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "MAX_UPSTREAM_CONCURRENCY") {
+		t.Errorf("expected 400 naming MAX_UPSTREAM_CONCURRENCY for n above the limit, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("expected no upstream requests for n above the limit, got %d", n)
+	}
+
+	// Concurrent fan-outs take their slots together instead of each holding some
+	var pending []<-chan *httptest.ResponseRecorder
+	for i := 0; i < 4; i++ {
+		pending = append(pending, send(2))
+	}
+	for _, done := range pending {
+		if rr := wait(done); rr.Code != http.StatusOK || !strings.HasSuffix(rr.Body.String(), "data: [DONE]\n\n") {
+			t.Errorf("expected a complete stream, got %d: %s", rr.Code, rr.Body.String())
+		}
+	}
+}
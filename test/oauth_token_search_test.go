@@ -0,0 +1,116 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"copilot-api/pkg/config"
+)
+
+// clearOAuthTokenEnv points the home and config directories at empty temp dirs and
+// unsets every environment variable the OAuth token search reads.
+func clearOAuthTokenEnv(t *testing.T) (home, xdg string) {
+	t.Helper()
+	home, xdg = t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	for _, key := range []string{"COPILOT_OAUTH_TOKEN", "COPILOT_OAUTH_TOKENS", "COPILOT_OAUTH_TOKEN_FILE", "GITHUB_TOKEN", "GH_CONFIG_DIR", "OAUTH_TOKEN_SEARCH_PATHS", "GCP_SECRET_NAME"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	return home, xdg
+}
+
+func writeTokenSource(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func loadOAuthToken(t *testing.T) string {
+	t.Helper()
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return cfg.CopilotOAuthToken
+}
+
+func TestOAuthTokenDefaultSearchOrder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the default search paths use LOCALAPPDATA on Windows")
+	}
+	home, xdg := clearOAuthTokenEnv(t)
+	// gh would otherwise read hosts.yml from XDG_CONFIG_HOME/gh
+	t.Setenv("GH_CONFIG_DIR", filepath.Join(home, ".config", "gh"))
+
+	tokenFile := filepath.Join(t.TempDir(), "oauth-token")
+	xdgApps := filepath.Join(xdg, "github-copilot", "apps.json")
+	homeApps := filepath.Join(home, ".config", "github-copilot", "apps.json")
+	homeHosts := filepath.Join(home, ".config", "github-copilot", "hosts.json")
+	ghHosts := filepath.Join(home, ".config", "gh", "hosts.yml")
+
+	writeTokenSource(t, tokenFile, "file-token\n")
+	writeTokenSource(t, xdgApps, `{"github.com:Iv1.a": {"oauth_token": "xdg-apps-token"}}`)
+	writeTokenSource(t, homeApps, `{"github.com:Iv1.a": {"oauth_token": "home-apps-token"}}`)
+	writeTokenSource(t, homeHosts, `{"github.com": {"oauth_token": "home-hosts-token"}}`)
+	writeTokenSource(t, ghHosts, "github.com:\n    user: octocat\n    oauth_token: gh-cli-token\n")
+	t.Setenv("COPILOT_OAUTH_TOKEN", "copilot-env-token")
+	t.Setenv("GITHUB_TOKEN", "github-env-token")
+	t.Setenv("COPILOT_OAUTH_TOKEN_FILE", tokenFile)
+
+	// Each source is removed once checked, exposing the next one in priority order
+	steps := []struct {
+		want   string
+		remove func()
+	}{
+		{"copilot-env-token", func() { os.Unsetenv("COPILOT_OAUTH_TOKEN") }},
+		{"github-env-token", func() { os.Unsetenv("GITHUB_TOKEN") }},
+		{"file-token", func() { os.Unsetenv("COPILOT_OAUTH_TOKEN_FILE") }},
+		{"xdg-apps-token", func() { os.Remove(xdgApps) }},
+		{"home-apps-token", func() { os.Remove(homeApps) }},
+		{"home-hosts-token", func() { os.Remove(homeHosts) }},
+		{"gh-cli-token", func() { os.Remove(ghHosts) }},
+	}
+	for _, step := range steps {
+		if got := loadOAuthToken(t); got != step.want {
+			t.Fatalf("expected %q, got %q", step.want, got)
+		}
+		step.remove()
+	}
+}
+
+func TestOAuthTokenSearchPathsOverride(t *testing.T) {
+	home, _ := clearOAuthTokenEnv(t)
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "token")
+	apps := filepath.Join(dir, "apps.json")
+	writeTokenSource(t, plain, "  plain-file-token  ")
+	writeTokenSource(t, apps, `{"github.com:Iv1.a": {"oauth_token": "apps-token"}}`)
+	// A default location that is not part of the override must be ignored
+	writeTokenSource(t, filepath.Join(home, ".config", "github-copilot", "apps.json"), `{"github.com": {"oauth_token": "default-token"}}`)
+	t.Setenv("GITHUB_TOKEN", "github-env-token")
+
+	sep := string(os.PathListSeparator)
+	t.Setenv("OAUTH_TOKEN_SEARCH_PATHS", strings.Join([]string{filepath.Join(dir, "missing"), apps, "$GITHUB_TOKEN", plain}, sep))
+	if got := loadOAuthToken(t); got != "apps-token" {
+		t.Errorf("expected the first existing search path to win, got %q", got)
+	}
+
+	t.Setenv("OAUTH_TOKEN_SEARCH_PATHS", strings.Join([]string{plain, "$GITHUB_TOKEN"}, sep))
+	if got := loadOAuthToken(t); got != "plain-file-token" {
+		t.Errorf("expected the trimmed plain token file, got %q", got)
+	}
+
+	t.Setenv("OAUTH_TOKEN_SEARCH_PATHS", filepath.Join(dir, "missing"))
+	if got := loadOAuthToken(t); got != "" {
+		t.Errorf("expected no token, got %q", got)
+	}
+}
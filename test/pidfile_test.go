@@ -0,0 +1,63 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"copilot-api/internal/pidfile"
+)
+
+func TestPIDFileWriteReadRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "go-copilot-api.pid")
+
+	if err := pidfile.Write(path); err != nil {
+		t.Fatalf("failed to write PID file: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("PID file not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0644 && os.PathSeparator == '/' {
+		t.Errorf("expected permissions 0644, got %o", perm)
+	}
+	pid, err := pidfile.Read(path)
+	if err != nil || pid != os.Getpid() {
+		t.Fatalf("expected PID %d, got %d (err %v)", os.Getpid(), pid, err)
+	}
+
+	if err := pidfile.Remove(path); err != nil {
+		t.Fatalf("failed to remove PID file: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected PID file to be removed, stat err = %v", err)
+	}
+}
+
+func TestPIDFileOverwritesStaleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stale.pid")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())), 0644); err != nil {
+		t.Fatalf("failed to write stale PID file: %v", err)
+	}
+	if err := pidfile.Write(path); err != nil {
+		t.Fatalf("expected existing PID file to be overwritten, got %v", err)
+	}
+	if pid, _ := pidfile.Read(path); pid != os.Getpid() {
+		t.Errorf("expected PID %d, got %d", os.Getpid(), pid)
+	}
+}
+
+func TestPIDFileRemoveKeepsForeignPID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "foreign.pid")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())), 0644); err != nil {
+		t.Fatalf("failed to write PID file: %v", err)
+	}
+	if err := pidfile.Remove(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected PID file of another process to be kept: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+const providersModelsJSON = `[
+	{"id": "openai/gpt-4o", "name": "OpenAI GPT-4o", "publisher": "OpenAI"},
+	{"id": "meta/llama-3.3-70b-instruct", "name": "Llama-3.3-70B-Instruct", "publisher": "Meta"},
+	{"id": "openai/gpt-4o-mini", "name": "OpenAI GPT-4o mini", "publisher": "OpenAI"},
+	{"id": "mistral-ai/mistral-large-2411", "name": "Mistral Large 24.11", "publisher": "Mistral AI"},
+	{"id": "deepseek/deepseek-r1", "name": "DeepSeek-R1"}
+]`
+
+func TestModelsCacheGetByProvider(t *testing.T) {
+	cache := newTestModelsCache(t, providersModelsJSON)
+
+	got := make(map[string][]string)
+	for provider, models := range cache.GetByProvider() {
+		for _, m := range models {
+			got[provider] = append(got[provider], m.ID)
+		}
+	}
+	want := map[string][]string{
+		"OpenAI":     {"openai/gpt-4o", "openai/gpt-4o-mini"},
+		"Meta":       {"meta/llama-3.3-70b-instruct"},
+		"Mistral AI": {"mistral-ai/mistral-large-2411"},
+		"deepseek":   {"deepseek/deepseek-r1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected providers %v, got %v", want, got)
+	}
+}
+
+func TestProvidersEndpoints(t *testing.T) {
+	cache := newTestModelsCache(t, providersModelsJSON)
+	cfg := &config.Config{CopilotToken: "test-token"}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), cache)
+
+	get := func(t *testing.T, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		// No Authorization header: the provider endpoints are exempt by default
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	t.Run("list", func(t *testing.T) {
+		rr := get(t, "/v1/providers")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Providers []struct {
+				Name   string   `json:"name"`
+				Models []string `json:"models"`
+			} `json:"providers"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		var names []string
+		for _, p := range resp.Providers {
+			names = append(names, p.Name)
+		}
+		if want := []string{"Meta", "Mistral AI", "OpenAI", "deepseek"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("expected providers %v, got %v", want, names)
+		}
+		if got := resp.Providers[2].Models; !reflect.DeepEqual(got, []string{"openai/gpt-4o", "openai/gpt-4o-mini"}) {
+			t.Errorf("unexpected OpenAI models %v", got)
+		}
+	})
+
+	for _, tt := range []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantModels []string
+	}{
+		{"exact name", "/v1/providers/OpenAI/models", http.StatusOK, []string{"openai/gpt-4o", "openai/gpt-4o-mini"}},
+		{"case-insensitive", "/v1/providers/openai/models", http.StatusOK, []string{"openai/gpt-4o", "openai/gpt-4o-mini"}},
+		{"escaped space", "/v1/providers/mistral%20ai/models", http.StatusOK, []string{"mistral-ai/mistral-large-2411"}},
+		{"unknown provider", "/v1/providers/acme/models", http.StatusNotFound, nil},
+		{"missing models suffix", "/v1/providers/openai", http.StatusNotFound, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := get(t, tt.path)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Data []struct {
+					ID string `json:"id"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			var ids []string
+			for _, m := range resp.Data {
+				ids = append(ids, m.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantModels) {
+				t.Errorf("expected models %v, got %v", tt.wantModels, ids)
+			}
+		})
+	}
+}
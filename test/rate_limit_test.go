@@ -0,0 +1,49 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestRateLimiterEvictsLeastRecentlySeenIP(t *testing.T) {
+	cfg := &config.Config{
+		CopilotToken:       "test-token",
+		RateLimitPerMinute: 1,
+		RateLimitBurst:     1,
+		RateLimiterMaxIPs:  2,
+	}
+	handler := api.NewRouter(cfg, nil, nil)
+
+	limited := func(ip string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		req.RemoteAddr = ip + ":1234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code == http.StatusTooManyRequests
+	}
+
+	// Each IP gets one request; a limited IP is still tracked, an allowed repeat was evicted
+	steps := []struct {
+		ip          string
+		wantLimited bool
+	}{
+		{"10.0.0.1", false},
+		{"10.0.0.2", false},
+		{"10.0.0.1", true},  // tracked; 10.0.0.2 is now least recently seen
+		{"10.0.0.3", false}, // evicts 10.0.0.2
+		{"10.0.0.1", true},  // still tracked; 10.0.0.3 is now least recently seen
+		{"10.0.0.2", false}, // forgotten, so allowed again; evicts 10.0.0.3
+		{"10.0.0.3", false}, // forgotten; evicts 10.0.0.1
+		{"10.0.0.2", true},
+		{"10.0.0.1", false},
+	}
+	for i, s := range steps {
+		if got := limited(s.ip); got != s.wantLimited {
+			t.Fatalf("step %d (%s): expected limited=%v, got %v", i+1, s.ip, s.wantLimited, got)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"copilot-api/internal/copilot"
+)
+
+// newCountingCatalog starts a mock models catalog serving the value of body and counting
+// the requests it answers.
+func newCountingCatalog(t *testing.T, body *atomic.Value) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// newRedisModelsCache returns a RedisModelsCache of the Redis server addr fetching from
+// the catalog at catalogURL.
+func newRedisModelsCache(t *testing.T, addr, catalogURL string, ttl time.Duration) *copilot.RedisModelsCache {
+	t.Helper()
+	r, err := copilot.NewRedisModelsCache(context.Background(), addr, "test-token", ttl, copilot.WithModelsURL(catalogURL))
+	if err != nil {
+		t.Fatalf("NewRedisModelsCache: %v", err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+func TestRedisModelsCacheSharedBetweenInstances(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var body atomic.Value
+	body.Store(testModelsJSON)
+	catalog, calls := newCountingCatalog(t, &body)
+
+	// Two instances, each with its own connection, in front of one Redis server
+	var caches []*copilot.ModelsCache
+	for i := 0; i < 2; i++ {
+		l2 := newRedisModelsCache(t, mr.Addr(), catalog.URL, time.Hour)
+		cache, err := copilot.NewModelsCache(context.Background(), "test-token", time.Hour, copilot.WithRedisCache(l2))
+		if err != nil {
+			t.Fatalf("NewModelsCache: %v", err)
+		}
+		t.Cleanup(cache.Close)
+		caches = append(caches, cache)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the second instance to use the list in Redis, got %d catalog fetches", n)
+	}
+	for i, cache := range caches {
+		if n := cache.ModelCount(); n != 3 {
+			t.Errorf("instance %d: expected 3 models, got %d", i, n)
+		}
+	}
+	if ttl := mr.TTL("copilot-api:models"); ttl != time.Hour {
+		t.Errorf("expected the list to be stored for the TTL, got %v", ttl)
+	}
+
+	// A forced refresh fetches upstream and replaces the shared list
+	body.Store(`[{"id": "openai/gpt-4o", "name": "OpenAI GPT-4o"}]`)
+	if n, err := caches[0].ForceRefresh(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected a forced refresh to fetch the new list, got %d models and %v", n, err)
+	}
+	if n, err := caches[1].ForceRefresh(context.Background()); err != nil || n != 1 {
+		t.Fatalf("expected the new list on the other instance too, got %d models and %v", n, err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected a catalog fetch per forced refresh, got %d in total", n)
+	}
+}
+
+func TestRedisModelsCacheMissAndOutage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var body atomic.Value
+	body.Store(testModelsJSON)
+	catalog, calls := newCountingCatalog(t, &body)
+	r := newRedisModelsCache(t, mr.Addr(), catalog.URL, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.GetModels(ctx); err != nil {
+			t.Fatalf("GetModels: %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected one catalog fetch while the list is stored, got %d", n)
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if _, err := r.GetModels(ctx); err != nil {
+		t.Fatalf("GetModels after expiry: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected an expired list to be fetched again, got %d fetches", n)
+	}
+
+	// Without Redis the list still comes from upstream
+	mr.Close()
+	data, err := r.GetModels(ctx)
+	if err != nil || len(data) == 0 {
+		t.Errorf("expected the catalog to be used while Redis is down, got %d bytes and %v", len(data), err)
+	}
+}
+
+func TestRedisModelsCacheFiles(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var body atomic.Value
+	body.Store(testModelsJSON)
+	catalog, calls := newCountingCatalog(t, &body)
+	r := newRedisModelsCache(t, mr.Addr(), catalog.URL, time.Hour)
+
+	path := filepath.Join(t.TempDir(), "models.json")
+	if err := r.SaveToFile(path); err == nil {
+		t.Error("expected an error saving an empty cache")
+	}
+	if err := os.WriteFile(path, []byte(testModelsJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadFromFile(path); err != nil {
+		t.Fatalf("LoadFromFile: %v", err)
+	}
+	if data, err := r.GetModels(context.Background()); err != nil || string(data) != testModelsJSON {
+		t.Errorf("expected the loaded list, got %v", err)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("expected no catalog fetch after loading a file, got %d", n)
+	}
+
+	saved := filepath.Join(t.TempDir(), "saved.json")
+	if err := r.SaveToFile(saved); err != nil {
+		t.Fatalf("SaveToFile: %v", err)
+	}
+	if data, _ := os.ReadFile(saved); string(data) != testModelsJSON {
+		t.Errorf("expected the stored list to be saved, got %s", data)
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadFromFile(path); err == nil {
+		t.Error("expected invalid models JSON to be rejected")
+	}
+}
+
+func TestNewRedisModelsCacheUnreachable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := copilot.NewRedisModelsCache(ctx, "redis://"+addr+"/0", "test-token", time.Hour); !errors.Is(err, copilot.ErrRedisUnreachable) {
+		t.Errorf("expected ErrRedisUnreachable for an unreachable Redis server, got %v", err)
+	}
+	if _, err := copilot.NewRedisModelsCache(ctx, "redis://"+addr+"/x", "test-token", time.Hour); err == nil || errors.Is(err, copilot.ErrRedisUnreachable) {
+		t.Errorf("expected an invalid Redis URL to be a different error, got %v", err)
+	}
+}
+
+// newSlowCatalog starts a mock models catalog that answers after delay, counting the
+// requests it gets.
+func newSlowCatalog(t *testing.T, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRedisModelsCacheFillLock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	catalog, calls := newSlowCatalog(t, 300*time.Millisecond)
+
+	// Instances missing the list at once leave the fetch to the one holding the lock
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		r := newRedisModelsCache(t, mr.Addr(), catalog.URL, time.Hour)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if data, err := r.GetModels(context.Background()); err != nil || string(data) != testModelsJSON {
+				t.Errorf("GetModels: %q, %v", data, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected one catalog fetch between the instances, got %d", n)
+	}
+	if mr.Exists("copilot-api:models:lock") {
+		t.Error("expected the fill lock to be released after the fetch")
+	}
+
+	// A lock left behind by another instance does not hold up a fetch once it is gone
+	mr.FlushAll()
+	if err := mr.Set("copilot-api:models:lock", "other"); err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(200*time.Millisecond, func() { mr.Del("copilot-api:models:lock") })
+	r := newRedisModelsCache(t, mr.Addr(), catalog.URL, time.Hour)
+	start := time.Now()
+	if _, err := r.GetModels(context.Background()); err != nil {
+		t.Fatalf("GetModels: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected the waiting instance to fetch the list itself, got %d fetches", n)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the wait to end with the lock, took %v", elapsed)
+	}
+}
+
+func TestRedisModelsCacheFetchSurvivesCallerCancel(t *testing.T) {
+	mr := miniredis.RunT(t)
+	catalog, calls := newSlowCatalog(t, 300*time.Millisecond)
+	r := newRedisModelsCache(t, mr.Addr(), catalog.URL, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := r.GetModels(ctx)
+		first <- err
+	}()
+	for calls.Load() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	second := make(chan error, 1)
+	go func() {
+		_, err := r.GetModels(context.Background())
+		second <- err
+	}()
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the canceled caller to return, got %v", err)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("expected the shared fetch to finish for the other caller, got %v", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected one catalog fetch, got %d", n)
+	}
+	if got, err := mr.Get("copilot-api:models"); err != nil || got != testModelsJSON {
+		t.Errorf("expected the list to be stored in Redis, got %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestResponseCache(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-cached","choices":[]}`))
+	}))
+	defer upstream.Close()
+	tm := newTestTokenManager(t)
+
+	newHandler := func(ttl time.Duration) http.Handler {
+		cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, ResponseCacheSize: 10, ResponseCacheTTL: ttl}
+		return api.NewRouter(cfg, tm, nil)
+	}
+	send := func(handler http.Handler, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	const deterministic = `{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"faq"}]}`
+
+	t.Run("cache hit", func(t *testing.T) {
+		calls.Store(0)
+		handler := newHandler(time.Minute)
+		if rr := send(handler, deterministic); rr.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("expected first response to be a miss, got X-Cache %q", rr.Header().Get("X-Cache"))
+		}
+		rr := send(handler, deterministic)
+		if rr.Header().Get("X-Cache") != "HIT" {
+			t.Errorf("expected X-Cache HIT, got %q", rr.Header().Get("X-Cache"))
+		}
+		if rr.Header().Get("Age") == "" {
+			t.Error("expected Age header on cache hit")
+		}
+		if !strings.Contains(rr.Body.String(), "chatcmpl-cached") {
+			t.Errorf("unexpected cached body %q", rr.Body.String())
+		}
+		if calls.Load() != 1 {
+			t.Errorf("expected one upstream call, got %d", calls.Load())
+		}
+	})
+
+	t.Run("ttl eviction", func(t *testing.T) {
+		calls.Store(0)
+		handler := newHandler(50 * time.Millisecond)
+		send(handler, deterministic)
+		time.Sleep(100 * time.Millisecond)
+		if rr := send(handler, deterministic); rr.Header().Get("X-Cache") == "HIT" {
+			t.Error("expected expired entry to be evicted")
+		}
+		if calls.Load() != 2 {
+			t.Errorf("expected two upstream calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("streaming requests are never cached", func(t *testing.T) {
+		calls.Store(0)
+		handler := newHandler(time.Minute)
+		body := `{"model":"gpt-4o","temperature":0,"stream":true,"messages":[{"role":"user","content":"faq"}]}`
+		send(handler, body)
+		if rr := send(handler, body); rr.Header().Get("X-Cache") != "" {
+			t.Errorf("expected no cache header for streaming, got %q", rr.Header().Get("X-Cache"))
+		}
+		if calls.Load() != 2 {
+			t.Errorf("expected two upstream calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("seed is part of the key", func(t *testing.T) {
+		calls.Store(0)
+		handler := newHandler(time.Minute)
+		send(handler, `{"model":"gpt-4o","temperature":0,"seed":1,"messages":[{"role":"user","content":"faq"}]}`)
+		if rr := send(handler, `{"model":"gpt-4o","temperature":0,"seed":2,"messages":[{"role":"user","content":"faq"}]}`); rr.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("expected a different seed to miss, got X-Cache %q", rr.Header().Get("X-Cache"))
+		}
+		if rr := send(handler, `{"model":"gpt-4o","temperature":0,"seed":2,"messages":[{"role":"user","content":"faq"}]}`); rr.Header().Get("X-Cache") != "HIT" {
+			t.Errorf("expected the same seed to hit, got X-Cache %q", rr.Header().Get("X-Cache"))
+		}
+		if calls.Load() != 2 {
+			t.Errorf("expected two upstream calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("tools are part of the key", func(t *testing.T) {
+		calls.Store(0)
+		handler := newHandler(time.Minute)
+		withTools := `{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"faq"}],` +
+			`"tools":[{"type":"function","function":{"name":"lookup","parameters":{"type":"object","properties":{"q":{"type":"string"}}}}}]}`
+		if rr := send(handler, deterministic); rr.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("expected the request without tools to miss, got X-Cache %q", rr.Header().Get("X-Cache"))
+		}
+		if rr := send(handler, withTools); rr.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("expected the request with tools to miss, got X-Cache %q", rr.Header().Get("X-Cache"))
+		}
+		if calls.Load() != 2 {
+			t.Errorf("expected two upstream calls, got %d", calls.Load())
+		}
+	})
+
+	t.Run("non-zero temperature is not cached", func(t *testing.T) {
+		calls.Store(0)
+		handler := newHandler(time.Minute)
+		body := `{"model":"gpt-4o","temperature":0.7,"messages":[{"role":"user","content":"faq"}]}`
+		send(handler, body)
+		send(handler, body)
+		if calls.Load() != 2 {
+			t.Errorf("expected two upstream calls, got %d", calls.Load())
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestUpstreamRetryResendsBody(t *testing.T) {
+	var bodies []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		if len(bodies) < 3 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, UpstreamMaxAttempts: 3}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+	}{
+		{"chat completions", "/v1/chat/completions", `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`},
+		{"embeddings", "/v1/embeddings", `{"model":"text-embedding-3-small","input":"hi"}`},
+		{"messages", "/v1/messages", `{"model":"gpt-4o","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bodies = nil
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200 after retries, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if len(bodies) != 3 {
+				t.Fatalf("expected 3 upstream attempts, got %d", len(bodies))
+			}
+			for i, b := range bodies {
+				if b == "" || b != bodies[0] {
+					t.Errorf("attempt %d sent body %q, expected %q", i+1, b, bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestUpstreamRetryGivesUp(t *testing.T) {
+	var attempts int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, UpstreamMaxAttempts: 2}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	if rr := postChat(handler, "test-token"); rr.Code != http.StatusBadGateway {
+		t.Errorf("expected last upstream status 502, got %d", rr.Code)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
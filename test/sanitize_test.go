@@ -0,0 +1,77 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestSanitizeRequestBody(t *testing.T) {
+	var upstreamBody map[string]interface{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&upstreamBody)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cache := newTestModelsCache(t, testModelsJSON)
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, DefaultMaxTokens: 8192}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), cache)
+	uncached := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	const messages = `"messages":[{"role":"user","content":"hi"}]`
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		body     string
+		want     map[string]interface{} // expected upstream fields, nil for absent
+		wantCode int
+	}{
+		{"default max_tokens for unknown limit", uncached, `{"model":"gpt-4o",` + messages + `}`,
+			map[string]interface{}{"max_tokens": 8192.0}, http.StatusOK},
+		{"default max_tokens lowered to model limit", handler, `{"model":"gpt-4o-mini",` + messages + `}`,
+			map[string]interface{}{"max_tokens": 4096.0}, http.StatusOK},
+		{"max_tokens kept", handler, `{"model":"gpt-4o","max_tokens":100,` + messages + `}`,
+			map[string]interface{}{"max_tokens": 100.0}, http.StatusOK},
+		{"max_completion_tokens kept", handler, `{"model":"gpt-4o","max_completion_tokens":100,` + messages + `}`,
+			map[string]interface{}{"max_tokens": nil, "max_completion_tokens": 100.0}, http.StatusOK},
+		{"null fields dropped", handler, `{"model":"gpt-4o","max_tokens":10,"user":null,"stop":null,` + messages + `}`,
+			map[string]interface{}{"user": nil, "stop": nil, "max_tokens": 10.0}, http.StatusOK},
+		{"null max_tokens replaced", handler, `{"model":"gpt-4o","max_tokens":null,"tools":null,` + messages + `}`,
+			map[string]interface{}{"max_tokens": 8192.0, "tools": nil}, http.StatusOK},
+		{"empty messages", handler, `{"model":"gpt-4o","messages":[]}`, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstreamBody = nil
+			rr := postChatBody(tt.handler, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				if upstreamBody != nil {
+					t.Error("expected the request not to be forwarded")
+				}
+				if !strings.Contains(rr.Body.String(), `"param":"messages"`) {
+					t.Errorf("expected messages to be named in the error, got %s", rr.Body.String())
+				}
+				return
+			}
+			for field, want := range tt.want {
+				got, ok := upstreamBody[field]
+				if want == nil && ok {
+					t.Errorf("expected %s to be removed, got %v", field, got)
+				} else if want != nil && got != want {
+					t.Errorf("expected %s %v upstream, got %v", field, want, got)
+				}
+			}
+		})
+	}
+}
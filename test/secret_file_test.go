@@ -0,0 +1,85 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"copilot-api/pkg/config"
+)
+
+// unsetEnv clears key for the duration of the test.
+func unsetEnv(t *testing.T, key string) {
+	t.Helper()
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+// writeSecret writes value to a file in a temporary directory and returns its path.
+func writeSecret(t *testing.T, value string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+	return path
+}
+
+func TestSecretFiles(t *testing.T) {
+	setTestConfigHome(t)
+	for _, key := range []string{"COPILOT_TOKEN", "COPILOT_OAUTH_TOKEN", "ADMIN_TOKEN", "API_KEYS"} {
+		unsetEnv(t, key)
+	}
+	t.Setenv("COPILOT_TOKEN_FILE", writeSecret(t, "file-token\n"))
+	t.Setenv("COPILOT_OAUTH_TOKEN_FILE", writeSecret(t, "  file-oauth-token  "))
+	t.Setenv("ADMIN_TOKEN_FILE", writeSecret(t, "file-admin-token\n"))
+	t.Setenv("API_KEYS_FILE", writeSecret(t, "team-a:token-a\n"))
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.CopilotToken != "file-token" {
+		t.Errorf("expected CopilotToken from file, got %q", cfg.CopilotToken)
+	}
+	if cfg.CopilotOAuthToken != "file-oauth-token" {
+		t.Errorf("expected CopilotOAuthToken from file, got %q", cfg.CopilotOAuthToken)
+	}
+	if cfg.AdminToken != "file-admin-token" {
+		t.Errorf("expected AdminToken from file, got %q", cfg.AdminToken)
+	}
+	if len(cfg.APIKeys) != 1 || cfg.APIKeys[0].Label != "team-a" || cfg.APIKeys[0].Token != "token-a" {
+		t.Errorf("expected API keys from file, got %+v", cfg.APIKeys)
+	}
+}
+
+func TestSecretEnvTakesPriority(t *testing.T) {
+	setTestConfigHome(t)
+	t.Setenv("COPILOT_OAUTH_TOKEN", "test-oauth-token")
+	t.Setenv("COPILOT_TOKEN", "env-token")
+	t.Setenv("COPILOT_TOKEN_FILE", writeSecret(t, "file-token"))
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.CopilotToken != "env-token" {
+		t.Errorf("expected COPILOT_TOKEN to take priority, got %q", cfg.CopilotToken)
+	}
+}
+
+func TestSecretFileUnreadable(t *testing.T) {
+	setTestConfigHome(t)
+	t.Setenv("COPILOT_OAUTH_TOKEN", "test-oauth-token")
+	unsetEnv(t, "ADMIN_TOKEN")
+	t.Setenv("ADMIN_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	_, err := config.Load()
+	if err == nil {
+		t.Fatal("expected an error for an unreadable secret file")
+	}
+	if !strings.Contains(err.Error(), "ADMIN_TOKEN_FILE") {
+		t.Errorf("expected error to name ADMIN_TOKEN_FILE, got %q", err)
+	}
+}
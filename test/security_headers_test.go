@@ -0,0 +1,74 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	get := func(cfg *config.Config, path string) http.Header {
+		handler := api.NewRouter(cfg, nil, nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Header()
+	}
+	want := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"X-XSS-Protection":        "1; mode=block",
+		"Referrer-Policy":         "strict-origin-when-cross-origin",
+		"Content-Security-Policy": config.DefaultCSP,
+	}
+
+	cfg := &config.Config{CopilotToken: "test-token", CSP: config.DefaultCSP}
+	// Rejected requests carry the headers too
+	for _, path := range []string{"/healthz", "/v1/chat/completions"} {
+		h := get(cfg, path)
+		for name, value := range want {
+			if got := h.Get(name); got != value {
+				t.Errorf("%s: expected %s %q, got %q", path, name, value, got)
+			}
+		}
+		if got := h.Get("Strict-Transport-Security"); got != "" {
+			t.Errorf("%s: expected no HSTS without TLS, got %q", path, got)
+		}
+	}
+
+	tlsCfg := &config.Config{CopilotToken: "test-token", CSP: "default-src 'self'", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}
+	h := get(tlsCfg, "/healthz")
+	if got := h.Get("Strict-Transport-Security"); got != "max-age=31536000" {
+		t.Errorf("expected HSTS with TLS, got %q", got)
+	}
+	if got := h.Get("Content-Security-Policy"); got != "default-src 'self'" {
+		t.Errorf("expected the configured CSP, got %q", got)
+	}
+
+	if got := get(&config.Config{CopilotToken: "test-token"}, "/healthz").Get("Content-Security-Policy"); got != "" {
+		t.Errorf("expected no CSP when it is empty, got %q", got)
+	}
+}
+
+func TestCSPConfig(t *testing.T) {
+	setTestConfigHome(t)
+	t.Setenv("COPILOT_OAUTH_TOKEN", "test-oauth-token")
+	unsetEnv(t, "CSP_POLICY")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CSP != config.DefaultCSP {
+		t.Errorf("expected the default CSP, got %q", cfg.CSP)
+	}
+
+	t.Setenv("CSP_POLICY", "default-src 'self'")
+	if cfg, err = config.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.CSP != "default-src 'self'" {
+		t.Errorf("expected CSP_POLICY to be used, got %q", cfg.CSP)
+	}
+}
@@ -0,0 +1,52 @@
+package test
+
+import (
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPreShutdownDelay(t *testing.T) {
+	bin := buildServer(t)
+	// /readyz needs a Copilot token to answer 200
+	tokenServer, _ := newTokenServer(t, time.Hour)
+	cmd, port, lines := startServerBinary(t, bin, "PRE_SHUTDOWN_DELAY=1s", "SHUTDOWN_TIMEOUT=5s", "GITHUB_AUTH_URL="+tokenServer.URL)
+	waitForLog(t, lines, "Starting server")
+
+	readyz := func() int {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/readyz")
+		if err != nil {
+			t.Fatalf("GET /readyz: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("expected /readyz to answer 200 before shutdown, got %d", code)
+	}
+
+	signaled := time.Now()
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	waitForLog(t, lines, "Shutdown signal received")
+	// The server keeps serving during the delay, reporting that it is shutting down
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected /readyz to answer 503 during the pre-shutdown delay, got %d", code)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected a clean exit, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the server did not shut down")
+	}
+	if elapsed := time.Since(signaled); elapsed < time.Second {
+		t.Errorf("expected shutdown to wait for the 1s pre-shutdown delay, took %v", elapsed)
+	}
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+// routingModelsJSON lists two gpt-4o family models with small and large context windows
+// and a model of another family with a larger one.
+const routingModelsJSON = `[
+	{"id": "openai/gpt-4o-mini", "name": "OpenAI GPT-4o mini", "publisher": "OpenAI", "limits": {"max_input_tokens": 1000, "max_output_tokens": 4096}},
+	{"id": "openai/gpt-4o", "name": "OpenAI GPT-4o", "publisher": "OpenAI", "limits": {"max_input_tokens": 5000, "max_output_tokens": 4096}},
+	{"id": "meta/llama-3.3-70b-instruct", "name": "Llama-3.3-70B-Instruct", "publisher": "Meta", "limits": {"max_input_tokens": 100000, "max_output_tokens": 4096}}
+]`
+
+func TestSmartRouting(t *testing.T) {
+	upstream := newMockCopilot(t, "test-copilot-token")
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, EnableSmartRouting: true}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), newTestModelsCache(t, routingModelsJSON))
+
+	tests := []struct {
+		name      string
+		model     string
+		words     int
+		wantCode  int
+		wantModel string
+	}{
+		{"short conversation uses the small model", "openai/gpt-4o", 100, http.StatusOK, "openai/gpt-4o-mini"},
+		{"long conversation uses the large model", "openai/gpt-4o-mini", 2000, http.StatusOK, "openai/gpt-4o"},
+		{"publisher prefix is optional", "gpt-4o", 2000, http.StatusOK, "openai/gpt-4o"},
+		{"too long for the family", "openai/gpt-4o", 8000, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := strings.Repeat("hello ", tt.words)
+			body, _ := json.Marshal(map[string]interface{}{
+				"model":    tt.model,
+				"messages": []map[string]string{{"role": "user", "content": content}},
+			})
+			sent := len(upstream.bodies)
+			rr := postChatBody(handler, string(body))
+
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				if !strings.Contains(rr.Body.String(), "context window") {
+					t.Errorf("expected an error about the context window, got %s", rr.Body.String())
+				}
+				return
+			}
+			if got := rr.Header().Get("X-Routed-Model"); got != tt.wantModel {
+				t.Errorf("expected X-Routed-Model %q, got %q", tt.wantModel, got)
+			}
+			if len(upstream.bodies) != sent+1 || upstream.bodies[sent]["model"] != tt.wantModel {
+				t.Errorf("expected the request to be sent for %s", tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestSmartRoutingDisabled(t *testing.T) {
+	upstream := newMockCopilot(t, "test-copilot-token")
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), newTestModelsCache(t, routingModelsJSON))
+
+	rr := postChatBody(handler, `{"model":"openai/gpt-4o","messages":[{"role":"user","content":"hi"}]}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("X-Routed-Model"); got != "" {
+		t.Errorf("expected no routing without ENABLE_SMART_ROUTING, got X-Routed-Model %q", got)
+	}
+	if upstream.bodies[0]["model"] != "openai/gpt-4o" {
+		t.Errorf("expected the requested model to be sent, got %v", upstream.bodies[0]["model"])
+	}
+}
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestSSEResponseDetection(t *testing.T) {
+	const sseBody = "data: {\"id\":\"chatcmpl-1\",\"choices\":[]}\n\ndata: [DONE]\n\n"
+	const jsonBody = `{"id":"chatcmpl-1","model":"gpt-4o","choices":[{"finish_reason":"stop"}]}`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStream  bool
+	}{
+		{"event-stream with charset", "text/event-stream; charset=utf-8", sseBody, true},
+		{"event-stream without charset", "text/event-stream", sseBody, true},
+		{"wrong content type with SSE body", "text/plain; charset=utf-8", sseBody, true},
+		{"missing content type with SSE body", "", sseBody, true},
+		{"plain JSON body", "application/json", jsonBody, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType == "" {
+					// Stop net/http from sniffing a content type
+					w.Header()["Content-Type"] = nil
+				} else {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer upstream.Close()
+
+			cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+			handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"gpt-4o","max_tokens":10,"messages":[{"role":"user","content":"hi"}]}`))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rr.Code)
+			}
+			if tt.wantStream {
+				if rr.Body.String() != sseBody {
+					t.Errorf("expected the event stream to be passed through, got %q", rr.Body.String())
+				}
+				if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" {
+					t.Errorf("expected Content-Type text/event-stream, got %q", ct)
+				}
+				return
+			}
+			var resp map[string]interface{}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp["type"] != "message" {
+				t.Errorf("expected a converted Anthropic message, got %q", rr.Body.String())
+			}
+		})
+	}
+}
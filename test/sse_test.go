@@ -0,0 +1,102 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"copilot-api/internal/sse"
+)
+
+func TestSSERoundtrip(t *testing.T) {
+	events := []sse.Event{
+		{Data: `{"choices":[]}`},
+		{Data: ""},
+		{Type: "error", Data: `{"error":{"message":"boom"}}`},
+		{ID: "42", Data: "first line\nsecond line\n\nfourth line"},
+		{ID: "7", Type: "ping", Data: "x"},
+		{Data: "[DONE]"},
+	}
+	var buf bytes.Buffer
+	w := sse.NewWriter(&buf)
+	for _, e := range events {
+		if err := w.WriteEvent(e); err != nil {
+			t.Fatalf("WriteEvent: %v", err)
+		}
+	}
+
+	scanner := sse.NewScanner(&buf)
+	var got []sse.Event
+	for scanner.Scan() {
+		got = append(got, scanner.Event())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(got) != len(events) {
+		t.Fatalf("expected %d events, got %d: %+v", len(events), len(got), got)
+	}
+	for i := range events {
+		if got[i] != events[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, events[i], got[i])
+		}
+	}
+}
+
+func TestSSEScannerFields(t *testing.T) {
+	stream := ": keep-alive\r\n" +
+		"\r\n" +
+		"retry: 3000\r\n" +
+		"id: 1\r\n" +
+		"event: message\r\n" +
+		"data:no space\r\n" +
+		"data: two\r\n" +
+		"\r\n" +
+		"event: empty\n" +
+		"\n" +
+		"data: cut off"
+	scanner := sse.NewScanner(strings.NewReader(stream))
+
+	if !scanner.Scan() {
+		t.Fatalf("expected an event, err %v", scanner.Err())
+	}
+	want := sse.Event{ID: "1", Type: "message", Data: "no space\ntwo"}
+	if got := scanner.Event(); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := scanner.Retry(); got != 3*time.Second {
+		t.Errorf("expected retry 3s, got %v", got)
+	}
+
+	// The event without data is skipped and the last one is dispatched despite the missing blank line
+	if !scanner.Scan() {
+		t.Fatalf("expected the cut off event, err %v", scanner.Err())
+	}
+	if got := scanner.Event(); got != (sse.Event{Data: "cut off"}) {
+		t.Errorf("expected the cut off event, got %+v", got)
+	}
+	if scanner.Scan() {
+		t.Errorf("expected the end of the stream, got %+v", scanner.Event())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Errorf("expected no error at the end of the stream, got %v", err)
+	}
+}
+
+func TestSSEScannerMaxEventSize(t *testing.T) {
+	stream := "data: small\n\ndata: " + strings.Repeat("x", 100) + "\n\ndata: after\n\n"
+	scanner := sse.NewScanner(strings.NewReader(stream))
+	scanner.SetMaxEventSize(64)
+
+	if !scanner.Scan() || scanner.Event().Data != "small" {
+		t.Fatalf("expected the small event first, got %+v (err %v)", scanner.Event(), scanner.Err())
+	}
+	if scanner.Scan() {
+		t.Fatalf("expected the oversized event to stop the scanner, got %+v", scanner.Event())
+	}
+	if err := scanner.Err(); !errors.Is(err, sse.ErrEventTooLarge) {
+		t.Errorf("expected ErrEventTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// deadPID is above the largest PID Linux can assign, so no process has it.
+const deadPID = 1<<22 + 1
+
+func TestStaleLockCleanup(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "test-copilot-token", time.Hour)
+	startTestTokenManager(t)
+	lockPath := filepath.Join(copilotDir, "token.json.lock")
+
+	writeLock := func(t *testing.T, pid int, age time.Duration) {
+		t.Helper()
+		if err := os.WriteFile(lockPath, []byte(strconv.Itoa(pid)), 0o600); err != nil {
+			t.Fatalf("failed to write lock file: %v", err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(lockPath, modTime, modTime); err != nil {
+			t.Fatalf("failed to age lock file: %v", err)
+		}
+	}
+	waitRemoved := func(t *testing.T) bool {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+			if _, err := os.Stat(lockPath); os.IsNotExist(err) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("crashed owner", func(t *testing.T) {
+		writeLock(t, deadPID, 0)
+		if !waitRemoved(t) {
+			t.Error("expected the lock of a process that no longer runs to be removed")
+		}
+	})
+
+	t.Run("expired lock of a running owner", func(t *testing.T) {
+		writeLock(t, os.Getpid(), 10*time.Minute)
+		if !waitRemoved(t) {
+			t.Error("expected a lock older than 300 seconds to be removed")
+		}
+	})
+
+	t.Run("young lock of a running owner", func(t *testing.T) {
+		writeLock(t, os.Getpid(), 0)
+		time.Sleep(2500 * time.Millisecond)
+		if _, err := os.Stat(lockPath); err != nil {
+			t.Errorf("expected the lock of a running process to be kept: %v", err)
+		}
+		_ = os.Remove(lockPath)
+	})
+}
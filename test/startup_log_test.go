@@ -0,0 +1,101 @@
+package test
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServerBinary runs bin on a free port with a Copilot OAuth token and env, with
+// every GitHub and Copilot URL pointing at a closed port. It returns the process, the
+// port and the lines the server logs; the process is killed when the test ends.
+func startServerBinary(t *testing.T, bin string, env ...string) (*exec.Cmd, string, <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	l.Close()
+
+	cmd := exec.Command(bin)
+	cmd.Dir = t.TempDir()
+	cmd.Env = append([]string{
+		"HOME=" + t.TempDir(),
+		"PATH=" + os.Getenv("PATH"),
+		"OAUTH_TOKEN_SEARCH_PATHS=$COPILOT_OAUTH_TOKEN",
+		"COPILOT_OAUTH_TOKEN=gho_startupsecret1234",
+		"COPILOT_SERVER_PORT=" + port,
+		// Nothing listens on port 1, so no request leaves the machine
+		"GITHUB_AUTH_URL=http://127.0.0.1:1",
+		"COPILOT_BASE_URL=http://127.0.0.1:1",
+		"USE_COPILOT_MODELS_ENDPOINT=true",
+	}, env...)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start the server: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	lines := make(chan string, 100)
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	return cmd, port, lines
+}
+
+// waitForLog returns the first line of lines containing substr.
+func waitForLog(t *testing.T, lines <-chan string, substr string) string {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatalf("the server exited without logging %q", substr)
+			}
+			if strings.Contains(line, substr) {
+				return line
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q in the server log", substr)
+		}
+	}
+}
+
+func TestStartupLogSummary(t *testing.T) {
+	bin := buildServer(t)
+	_, port, lines := startServerBinary(t, bin, "ADMIN_TOKEN=admin-secret-efgh")
+	summary := waitForLog(t, lines, "Starting server")
+
+	for _, want := range []string{
+		"INFO Starting server", "addr=:" + port, "tls=false", `default_model="not set"`, "cors_origins=",
+		"auth_mode=single", "token_source=env", `copilot_token="<not set>"`, "admin_token=<set>", "oauth_token=<set>",
+		"models_cache_ttl=6h0m0s", "upstream_max_attempts=", "upstream_tls_handshake_timeout=", "shutdown_timeout=30s",
+		"features.debug=false", "features.copilot_models_endpoint=true", "features.metrics=false",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("expected %q in the startup log:\n%s", want, summary)
+		}
+	}
+	for _, secret := range []string{"gho_startupsecret1234", "admin-secret-efgh"} {
+		if strings.Contains(summary, secret) {
+			t.Errorf("expected %s not to be logged:\n%s", secret, summary)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestAdminStats(t *testing.T) {
+	// The upstream answers after the number of milliseconds given as the message content
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Model == "broken" {
+			http.Error(w, "upstream failure", http.StatusBadRequest)
+			return
+		}
+		ms, _ := strconv.Atoi(body.Messages[0].Content)
+		time.Sleep(time.Duration(ms) * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", AdminToken: "admin-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	send := func(model string, ms int) {
+		body := fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":"%d"}]}`, model, ms)
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Latencies of 1ms to 100ms, so the p95 is 95ms. Ten workers keep the number of
+	// upstream connections, and with it the dialing overhead, small.
+	var wg sync.WaitGroup
+	for w := 0; w < 10; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for ms := w + 1; ms <= 100; ms += 10 {
+				send("gpt-4o", ms)
+			}
+		}(w)
+	}
+	wg.Wait()
+	for i := 0; i < 3; i++ {
+		send("broken", 0)
+	}
+
+	getStats := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := getStats("test-token"); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the admin token, got %d", rr.Code)
+	}
+	rr := getStats("admin-token")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	var stats struct {
+		Models map[string]struct {
+			Requests  int64              `json:"requests"`
+			Errors    int64              `json:"errors"`
+			LatencyMs map[string]float64 `json:"latency_ms"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	gpt := stats.Models["gpt-4o"]
+	if gpt.Requests != 100 || gpt.Errors != 0 {
+		t.Errorf("expected 100 requests without errors, got %d requests and %d errors", gpt.Requests, gpt.Errors)
+	}
+	if p95 := gpt.LatencyMs["p95"]; math.Abs(p95-95)/95 > 0.05 {
+		t.Errorf("expected p95 within 5%% of 95ms, got %.2fms", p95)
+	}
+	if p50, p99 := gpt.LatencyMs["p50"], gpt.LatencyMs["p99"]; p50 >= gpt.LatencyMs["p95"] || p99 < gpt.LatencyMs["p95"] {
+		t.Errorf("expected p50 < p95 <= p99, got %v", gpt.LatencyMs)
+	}
+	if broken := stats.Models["broken"]; broken.Requests != 3 || broken.Errors != 3 {
+		t.Errorf("expected 3 failed requests for broken, got %+v", broken)
+	}
+}
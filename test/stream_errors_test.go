@@ -0,0 +1,80 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+const streamAuthError = `data: {"error":{"code":"unauthorized","message":"token expired"}}` + "\n\n"
+
+// newStreamingCopilot starts a mock Copilot API answering chat requests with an event
+// stream. Requests with the token rejectToken get stream as the body.
+func newStreamingCopilot(t *testing.T, rejectToken, stream string) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		mu.Lock()
+		tokens = append(tokens, auth)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		if auth == "Bearer "+rejectToken {
+			_, _ = io.WriteString(w, stream)
+			return
+		}
+		_, _ = io.WriteString(w, `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hello"}}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), tokens...)
+	}
+}
+
+func TestStreamAuthErrorRetriesWithFreshToken(t *testing.T) {
+	upstream, tokens := newStreamingCopilot(t, "test-copilot-token", streamAuthError+"data: [DONE]\n\n")
+	tokenSrv, _ := newTokenServer(t, time.Hour)
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t, copilot.WithAuthURL(tokenSrv.URL)), nil)
+
+	rr := postChatBody(handler, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "error") || !strings.Contains(rr.Body.String(), "Hello") {
+		t.Errorf("expected only the stream of the retried request, got %q", rr.Body.String())
+	}
+	got := tokens()
+	if len(got) != 2 || got[0] != "Bearer test-copilot-token" || got[1] != "Bearer refreshed-token-1" {
+		t.Errorf("expected a retry with the refreshed token, got %v", got)
+	}
+}
+
+func TestStreamAuthErrorAfterContent(t *testing.T) {
+	stream := `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hel"}}]}` + "\n\n" + streamAuthError
+	upstream, tokens := newStreamingCopilot(t, "test-copilot-token", stream)
+	tokenSrv, issued := newTokenServer(t, time.Hour)
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t, copilot.WithAuthURL(tokenSrv.URL)), nil)
+
+	rr := postChatBody(handler, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+
+	if !strings.Contains(rr.Body.String(), "\nevent: error\n"+streamAuthError) {
+		t.Errorf("expected the error to be forwarded as an error event, got %q", rr.Body.String())
+	}
+	if n := len(tokens()); n != 1 || issued.Load() != 0 {
+		t.Errorf("expected no retry after content was sent, got %d requests and %d refreshes", n, issued.Load())
+	}
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+// flushCounter records how often the handler flushes the response.
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushes atomic.Int32
+}
+
+func (f *flushCounter) Flush() {
+	f.flushes.Add(1)
+	f.ResponseRecorder.Flush()
+}
+
+// streamChat streams a 20-byte response one byte every 10ms through a router built from cfg
+// and returns the number of flushes the client saw.
+func streamChat(t *testing.T, cfg *config.Config) int32 {
+	t.Helper()
+	const payload = "data: 0123456789abc\n"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i := 0; i < len(payload); i++ {
+			_, _ = w.Write([]byte{payload[i]})
+			w.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer upstream.Close()
+
+	cfg.CopilotToken = "test-token"
+	cfg.CopilotBaseURL = upstream.URL
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"stream":true,"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	rr := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(rr, req)
+
+	if got := rr.Body.String(); got != payload {
+		t.Fatalf("expected streamed body %q, got %q", payload, got)
+	}
+	return rr.flushes.Load()
+}
+
+func TestStreamFlushInterval(t *testing.T) {
+	perWrite := streamChat(t, &config.Config{})
+	batched := streamChat(t, &config.Config{StreamFlushInterval: 100 * time.Millisecond})
+
+	// The stream lasts about 200ms, so a 100ms interval flushes a handful of times
+	if batched > 5 {
+		t.Errorf("expected batched flushes with a 100ms interval, got %d", batched)
+	}
+	if perWrite <= batched {
+		t.Errorf("expected fewer flushes with an interval (%d) than without (%d)", batched, perWrite)
+	}
+}
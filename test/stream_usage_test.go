@@ -0,0 +1,97 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestStreamIncludeUsage(t *testing.T) {
+	const chunks = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":" world"},"finish_reason":"stop"}]}
+
+`
+	const upstreamUsage = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1700000000,"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":11,"completion_tokens":22,"total_tokens":33}}
+
+`
+	tests := []struct {
+		name          string
+		streamOptions string
+		upstreamBody  string
+		wantUsage     map[string]float64
+	}{
+		{"requested", `,"stream_options":{"include_usage":true}`, chunks + "data: [DONE]\n\n", map[string]float64{"completion_tokens": 2}},
+		{"not requested", ``, chunks + "data: [DONE]\n\n", nil},
+		{"explicitly off", `,"stream_options":{"include_usage":false}`, chunks + "data: [DONE]\n\n", nil},
+		{"sent by upstream", `,"stream_options":{"include_usage":true}`, chunks + upstreamUsage + "data: [DONE]\n\n", map[string]float64{"prompt_tokens": 11, "completion_tokens": 22, "total_tokens": 33}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = w.Write([]byte(tt.upstreamBody))
+			}))
+			defer upstream.Close()
+
+			cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+			handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+			body := `{"model":"gpt-4o","stream":true` + tt.streamOptions + `,"messages":[{"role":"user","content":"hi"}]}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			var usages []map[string]float64
+			var last string
+			scanner := bufio.NewScanner(rr.Body)
+			for scanner.Scan() {
+				data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+				if !ok {
+					continue
+				}
+				last = data
+				var chunk struct {
+					ID    string             `json:"id"`
+					Usage map[string]float64 `json:"usage"`
+				}
+				if data != "[DONE]" && json.Unmarshal([]byte(data), &chunk) == nil && chunk.Usage != nil {
+					if chunk.ID != "chatcmpl-1" {
+						t.Errorf("expected the usage chunk to carry the completion id, got %q", chunk.ID)
+					}
+					usages = append(usages, chunk.Usage)
+				}
+			}
+			if last != "[DONE]" {
+				t.Errorf("expected the stream to end with [DONE], got %q", last)
+			}
+
+			if tt.wantUsage == nil {
+				if len(usages) != 0 {
+					t.Errorf("expected no usage chunk, got %v", usages)
+				}
+				return
+			}
+			if len(usages) != 1 {
+				t.Fatalf("expected exactly one usage chunk, got %d in %q", len(usages), rr.Body.String())
+			}
+			usage := usages[0]
+			for k, want := range tt.wantUsage {
+				if usage[k] != want {
+					t.Errorf("expected %s %v, got %v", k, want, usage[k])
+				}
+			}
+			if usage["prompt_tokens"] <= 0 || usage["total_tokens"] != usage["prompt_tokens"]+usage["completion_tokens"] {
+				t.Errorf("inconsistent usage %v", usage)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestSensitiveHeadersStripped(t *testing.T) {
+	var upstreamHeader http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHeader = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		CopilotToken:         "test-token",
+		CopilotBaseURL:       upstream.URL,
+		StripUpstreamHeaders: []string{"x-internal-trace"},
+	}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Auth-Token", "secret")
+	req.Header.Set("X-Internal-Trace", "abc123")
+	req.Header.Set("X-Request-Id", "req-1")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	for _, h := range []string{"Cookie", "X-Auth-Token", "X-Internal-Trace"} {
+		if v := upstreamHeader.Get(h); v != "" {
+			t.Errorf("expected %s to be stripped, got %q upstream", h, v)
+		}
+	}
+	if got := upstreamHeader.Get("X-Request-Id"); got != "req-1" {
+		t.Errorf("expected other headers to be forwarded, got X-Request-Id %q", got)
+	}
+}
+
+func TestTraceContextHeadersForwarded(t *testing.T) {
+	var upstreamHeader http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHeader = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		CopilotToken:   "test-token",
+		CopilotBaseURL: upstream.URL,
+		// Trace headers are forwarded even when configured to be stripped
+		StripUpstreamHeaders: []string{"traceparent", "x-b3-traceid"},
+	}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	traceHeaders := map[string]string{
+		"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"tracestate":   "vendor=value",
+		"X-B3-TraceId": "463ac35c9f6413ad",
+		"X-B3-SpanId":  "a2fb4a1d1a96d312",
+		"X-B3-Sampled": "1",
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range traceHeaders {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+
+	for k, want := range traceHeaders {
+		if got := upstreamHeader.Get(k); got != want {
+			t.Errorf("expected %s %q upstream, got %q", k, want, got)
+		}
+	}
+}
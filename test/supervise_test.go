@@ -0,0 +1,87 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestSupervisedGoroutineRestartsAfterPanic(t *testing.T) {
+	buf := captureLog(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs, ticks atomic.Int32
+	var restartedAt atomic.Int64
+	started := time.Now()
+	var wg sync.WaitGroup
+	copilot.SupervisedGoroutine(ctx, "test loop", func(ctx context.Context) {
+		if runs.Add(1) == 1 {
+			var m map[string]interface{}
+			_ = m["token"].(string) // an unexpected type panics
+		}
+		restartedAt.CompareAndSwap(0, int64(time.Since(started)))
+		for {
+			ticks.Add(1)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}, &wg)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ticks.Load() < 5 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ticks.Load() < 5 {
+		t.Fatalf("expected the loop to keep running after the panic, got %d runs and %d ticks", runs.Load(), ticks.Load())
+	}
+	if runs.Load() != 2 {
+		t.Errorf("expected one restart, got %d runs", runs.Load())
+	}
+	if d := time.Duration(restartedAt.Load()); d < time.Second {
+		t.Errorf("expected the restart to wait for the 1s backoff, restarted after %v", d)
+	}
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the supervisor to stop once the context is cancelled")
+	}
+	// The supervisor has stopped logging, so the buffer can be read
+	if out := buf.String(); !strings.Contains(out, "background goroutine panicked") || !strings.Contains(out, "test loop") {
+		t.Errorf("expected the panic to be logged, got %q", out)
+	}
+}
+
+func TestSupervisedGoroutineStopsWhenCancelled(t *testing.T) {
+	captureLog(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs atomic.Int32
+	var wg sync.WaitGroup
+	copilot.SupervisedGoroutine(ctx, "failing loop", func(context.Context) {
+		runs.Add(1)
+		panic("always fails")
+	}, &wg)
+
+	// Cancelling during the backoff ends the supervisor without another run
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	wg.Wait()
+	if n := runs.Load(); n != 1 {
+		t.Errorf("expected a single run before cancellation, got %d", n)
+	}
+}
@@ -0,0 +1,58 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestCorruptTokenFileKeepsValidToken(t *testing.T) {
+	srv, refreshes := newTokenServer(t, time.Hour)
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "test-copilot-token", time.Hour)
+	tm := startTestTokenManager(t, copilot.WithAuthURL(srv.URL))
+	tokenFile := filepath.Join(copilotDir, "token.json")
+
+	// The watcher compares modification times in whole seconds
+	time.Sleep(1100 * time.Millisecond)
+
+	// Valid JSON without a token is ignored but left in place
+	if err := os.WriteFile(tokenFile, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("failed to write token.json: %v", err)
+	}
+	time.Sleep(2500 * time.Millisecond)
+	if _, err := os.Stat(tokenFile); err != nil {
+		t.Errorf("expected token.json without a token to be kept: %v", err)
+	}
+
+	// A truncated file is deleted
+	if err := os.WriteFile(tokenFile, []byte(`{"token":"trunc`), 0o600); err != nil {
+		t.Fatalf("failed to write token.json: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(tokenFile); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err := os.Stat(tokenFile); !os.IsNotExist(err) {
+		t.Errorf("expected corrupt token.json to be deleted")
+	}
+
+	token, err := tm.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if token != "test-copilot-token" {
+		t.Errorf("expected the in-memory token to be preserved, got %q", token)
+	}
+	if refreshes.Load() != 0 {
+		t.Errorf("expected no refresh, got %d", refreshes.Load())
+	}
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestTokenManagerCreatesTokenDirectory(t *testing.T) {
+	// A fresh machine: the config directory does not exist yet
+	home := t.TempDir()
+	copilotDir := filepath.Join(home, ".config", "github-copilot")
+	if runtime.GOOS == "windows" {
+		t.Setenv("LOCALAPPDATA", home)
+		copilotDir = filepath.Join(home, "github-copilot")
+	} else {
+		t.Setenv("HOME", home)
+	}
+
+	tokenServer, _ := newTokenServer(t, time.Hour)
+	tm := startTestTokenManager(t,
+		copilot.WithOAuthToken("test-oauth-token"),
+		copilot.WithAuthURL(tokenServer.URL),
+	)
+
+	token, err := tm.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("expected refresh to succeed, got %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(copilotDir, "token.json"))
+	if err != nil {
+		t.Fatalf("expected token.json to be saved: %v", err)
+	}
+	var saved copilot.CopilotToken
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Token != token {
+		t.Errorf("expected saved token %q, got %q (%v)", token, saved.Token, err)
+	}
+}
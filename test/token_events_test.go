@@ -0,0 +1,84 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestTokenRefreshEvents(t *testing.T) {
+	tokenSrv, _ := newTokenServer(t, time.Hour)
+	tm := newTestTokenManager(t, copilot.WithAuthURL(tokenSrv.URL))
+	events, err := tm.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := tm.ForceRefresh(context.Background()); err != nil {
+		t.Fatalf("ForceRefresh: %v", err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Error != nil || ev.Token != "refreshed-token-1" {
+			t.Errorf("expected an event for refreshed-token-1, got %+v", ev)
+		}
+		if until := time.Until(ev.ExpiresAt); until < 59*time.Minute || until > time.Hour {
+			t.Errorf("expected the event to carry the token expiry, got %v", ev.ExpiresAt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received after a refresh")
+	}
+}
+
+func TestTokenRefreshEventsFailure(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	tm := newTestTokenManager(t, copilot.WithAuthURL(failing.URL))
+	events, err := tm.Subscribe()
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := tm.ForceRefresh(context.Background()); err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	select {
+	case ev := <-events:
+		if ev.Error == nil || ev.Token != "" {
+			t.Errorf("expected an event carrying the refresh error, got %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event received after a failed refresh")
+	}
+}
+
+func TestTokenRefreshSubscriberLimit(t *testing.T) {
+	tm := newTestTokenManager(t)
+	var channels []<-chan copilot.TokenRefreshEvent
+	for i := 0; i < 16; i++ {
+		ch, err := tm.Subscribe()
+		if err != nil {
+			t.Fatalf("subscriber %d: %v", i+1, err)
+		}
+		channels = append(channels, ch)
+	}
+	if _, err := tm.Subscribe(); err == nil {
+		t.Error("expected the 17th subscriber to be rejected")
+	}
+
+	tm.Close()
+	for i, ch := range channels {
+		if _, ok := <-ch; ok {
+			t.Errorf("expected channel %d to be closed by Close", i+1)
+		}
+	}
+	if _, err := tm.Subscribe(); err == nil {
+		t.Error("expected Subscribe to fail after Close")
+	}
+}
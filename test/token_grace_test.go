@@ -0,0 +1,69 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestTokenExpiryGraceZero(t *testing.T) {
+	srv, calls := newTokenServer(t, time.Hour)
+
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "short-lived-token", 2*time.Second)
+	tm := startTestTokenManager(t,
+		copilot.WithAuthURL(srv.URL),
+		copilot.WithPrewarm(0),
+		copilot.WithExpiryGrace(0),
+	)
+
+	// Without a grace period the token is used right up to its expiry
+	token, err := tm.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if token != "short-lived-token" || calls.Load() != 0 {
+		t.Fatalf("expected cached token before expiry, got %q after %d refreshes", token, calls.Load())
+	}
+
+	time.Sleep(2100 * time.Millisecond)
+	token, err = tm.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if token == "short-lived-token" || calls.Load() == 0 {
+		t.Errorf("expected token to be refreshed at expiry, got %q", token)
+	}
+}
+
+func TestTokenExpiryGraceWidensPrewarm(t *testing.T) {
+	srv, calls := newTokenServer(t, time.Hour)
+
+	// 200s left is inside a 300s grace period, even with pre-warm disabled
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "expiring-token", 200*time.Second)
+	tm := startTestTokenManager(t,
+		copilot.WithAuthURL(srv.URL),
+		copilot.WithPrewarm(0),
+		copilot.WithExpiryGrace(300*time.Second),
+	)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one background refresh, got %d", calls.Load())
+	}
+	token, err := tm.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if token != "refreshed-token-1" {
+		t.Errorf("expected pre-warmed token, got %q", token)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build !windows
+
+package test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+// holdTokenLock takes the token refresh lock in copilotDir the way another process
+// refreshing the token would, until the test ends.
+func holdTokenLock(t *testing.T, copilotDir string) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(copilotDir, "token.json.lock"), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		t.Fatalf("failed to open lock file: %v", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	// A running owner keeps the lock from being removed as stale
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+		t.Fatalf("failed to write lock file: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+}
+
+func TestTokenRefreshCancelledWhileWaitingForLock(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "test-copilot-token", time.Hour)
+	tokenSrv, calls := newTokenServer(t, time.Hour)
+	tm := startTestTokenManager(t, copilot.WithAuthURL(tokenSrv.URL))
+	holdTokenLock(t, copilotDir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- tm.ForceRefresh(ctx) }()
+	time.Sleep(1500 * time.Millisecond)
+
+	cancel()
+	cancelled := time.Now()
+	select {
+	case err := <-done:
+		if elapsed := time.Since(cancelled); elapsed > 100*time.Millisecond {
+			t.Errorf("expected the refresh to return within 100ms of cancellation, took %v", elapsed)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("refresh kept waiting for the lock after cancellation")
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("expected no token requests while the lock was held, got %d", n)
+	}
+}
+
+func TestTokenManagerCloseWhileWaitingForLock(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	// Inside the pre-warm window, so the background loop refreshes at once
+	writeTestToken(t, copilotDir, "test-copilot-token", 3*time.Minute)
+	holdTokenLock(t, copilotDir)
+	tokenSrv, calls := newTokenServer(t, time.Hour)
+	tm := startTestTokenManager(t, copilot.WithAuthURL(tokenSrv.URL))
+	time.Sleep(300 * time.Millisecond)
+
+	start := time.Now()
+	tm.Close()
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected Close to return within 100ms during a lock retry, took %v", elapsed)
+	}
+	if n := calls.Load(); n != 0 {
+		t.Errorf("expected no token requests while the lock was held, got %d", n)
+	}
+}
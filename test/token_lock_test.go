@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestTokenLockReacquire(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "test-copilot-token", time.Hour)
+	tokenSrv, calls := newTokenServer(t, time.Hour)
+	tm := startTestTokenManager(t, copilot.WithAuthURL(tokenSrv.URL))
+	lockPath := filepath.Join(copilotDir, "token.json.lock")
+
+	for i := 1; i <= 3; i++ {
+		start := time.Now()
+		if err := tm.ForceRefresh(context.Background()); err != nil {
+			t.Fatalf("refresh %d: %v", i, err)
+		}
+		// Waiting for a held lock takes at least a second per attempt
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("refresh %d waited %v for the lock; it was not released", i, elapsed)
+		}
+		if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+			t.Errorf("expected the lock file to be removed after refresh %d, got %v", i, err)
+		}
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("expected 3 token requests, got %d", n)
+	}
+	token, _ := tm.GetToken(context.Background())
+	if token != "refreshed-token-3" {
+		t.Errorf("expected the last refreshed token, got %q", token)
+	}
+}
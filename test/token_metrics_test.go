@@ -0,0 +1,104 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestTokenRefreshMetrics(t *testing.T) {
+	// Tokens that are already expired force a refresh on every GetToken
+	srv, _ := newTokenServer(t, -time.Hour)
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	registry := prometheus.NewRegistry()
+	tm := startTestTokenManager(t,
+		copilot.WithAuthURL(srv.URL),
+		copilot.WithPrewarm(0),
+		copilot.WithExpiryGrace(0),
+		copilot.WithMetrics(registry),
+	)
+
+	for i := 0; i < 3; i++ {
+		if _, err := tm.GetToken(context.Background()); err != nil {
+			t.Fatalf("GetToken failed: %v", err)
+		}
+	}
+
+	values := gatherTokenMetrics(t, registry)
+	// The background loop may refresh too, so count at least the explicit refreshes
+	if values["refresh_success"] < 3 {
+		t.Errorf("expected at least 3 successful refreshes, got %v", values["refresh_success"])
+	}
+	if values["refresh_failure"] != 0 {
+		t.Errorf("expected no failed refreshes, got %v", values["refresh_failure"])
+	}
+	if values["lock_waits"] != values["refresh_success"] {
+		t.Errorf("expected one lock wait per refresh, got %v for %v refreshes", values["lock_waits"], values["refresh_success"])
+	}
+	if values["expiry"] > -3500 || values["expiry"] < -3700 {
+		t.Errorf("expected expiry about an hour in the past, got %v", values["expiry"])
+	}
+}
+
+func TestTokenRefreshFailureMetric(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	registry := prometheus.NewRegistry()
+	tm := startTestTokenManager(t, copilot.WithAuthURL(srv.URL), copilot.WithMetrics(registry))
+
+	if _, err := tm.GetToken(context.Background()); err == nil {
+		t.Fatal("expected GetToken to fail")
+	}
+
+	expected := `
+# HELP copilot_token_expiry_seconds Seconds until the current Copilot token expires; negative once expired, 0 without a token.
+# TYPE copilot_token_expiry_seconds gauge
+copilot_token_expiry_seconds 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "copilot_token_expiry_seconds"); err != nil {
+		t.Error(err)
+	}
+	if n, err := testutil.GatherAndCount(registry, "copilot_token_refresh_total"); err != nil || n != 2 {
+		t.Errorf("expected success and failure series, got %d (%v)", n, err)
+	}
+	if values := gatherTokenMetrics(t, registry); values["refresh_failure"] < 1 || values["refresh_success"] != 0 {
+		t.Errorf("expected only failed refreshes, got %v", values)
+	}
+}
+
+// gatherTokenMetrics returns the token metric values in registry: refresh counts as
+// refresh_<result>, the expiry gauge as expiry, and the lock wait samples as lock_waits.
+func gatherTokenMetrics(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	values := map[string]float64{}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			switch mf.GetName() {
+			case "copilot_token_refresh_total":
+				values["refresh_"+m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+			case "copilot_token_expiry_seconds":
+				values["expiry"] = m.GetGauge().GetValue()
+			case "copilot_token_lock_wait_seconds":
+				values["lock_waits"] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return values
+}
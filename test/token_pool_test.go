@@ -0,0 +1,236 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+// newSeatTokenServer starts a mock Copilot token endpoint issuing "copilot-<oauth>" for
+// each OAuth token and rejecting the OAuth tokens in rejected with 401.
+func newSeatTokenServer(t *testing.T, rejected ...string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oauth := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		for _, bad := range rejected {
+			if oauth == bad {
+				http.Error(w, "bad credentials", http.StatusUnauthorized)
+				return
+			}
+		}
+		_ = json.NewEncoder(w).Encode(copilot.CopilotToken{
+			Token:     "copilot-" + oauth,
+			ExpiresAt: float64(time.Now().Add(time.Hour).Unix()),
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// seatCalls counts the token endpoint requests of each OAuth token.
+type seatCalls struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *seatCalls) get(oauth string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[oauth]
+}
+
+// newCountingSeatTokenServer is newSeatTokenServer, counting the requests of each OAuth
+// token.
+func newCountingSeatTokenServer(t *testing.T, rejected ...string) (*httptest.Server, *seatCalls) {
+	t.Helper()
+	seats := newSeatTokenServer(t, rejected...)
+	calls := &seatCalls{counts: make(map[string]int)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.mu.Lock()
+		calls.counts[strings.TrimPrefix(r.Header.Get("Authorization"), "token ")]++
+		calls.mu.Unlock()
+		seats.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func newTestTokenPool(t *testing.T, oauthTokens []string, opts ...copilot.TokenManagerOption) *copilot.TokenPool {
+	t.Helper()
+	pool, err := copilot.NewTokenPool(context.Background(), oauthTokens, opts...)
+	if err != nil {
+		t.Fatalf("failed to create token pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func TestTokenPoolRoundRobin(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	srv := newSeatTokenServer(t)
+	pool := newTestTokenPool(t, []string{"alice", "bob", "carol"}, copilot.WithAuthURL(srv.URL))
+
+	var got []string
+	for i := 0; i < 6; i++ {
+		token, err := pool.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken: %v", err)
+		}
+		got = append(got, token)
+	}
+	want := "copilot-alice copilot-bob copilot-carol copilot-alice copilot-bob copilot-carol"
+	if strings.Join(got, " ") != want {
+		t.Errorf("expected tokens %q, got %q", want, strings.Join(got, " "))
+	}
+
+	// Every seat keeps its token in its own file, and token.json is left alone
+	files, _ := filepath.Glob(filepath.Join(copilotDir, "token-*.json"))
+	if len(files) != 3 {
+		t.Errorf("expected a token file per seat, got %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(copilotDir, "token.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no token.json, got %v", err)
+	}
+}
+
+func TestTokenPoolSkipsRejectedSeat(t *testing.T) {
+	setTestConfigHome(t)
+	srv := newSeatTokenServer(t, "revoked")
+	pool := newTestTokenPool(t, []string{"alice", "revoked", "carol"}, copilot.WithAuthURL(srv.URL))
+
+	counts := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		token, err := pool.GetToken(context.Background())
+		if err != nil {
+			t.Fatalf("GetToken: %v", err)
+		}
+		counts[token]++
+	}
+	if counts["copilot-alice"] != 2 || counts["copilot-carol"] != 4 {
+		t.Errorf("expected the rejected seat's turns to go to the next seat, got %v", counts)
+	}
+
+	all := newTestTokenPool(t, []string{"revoked"}, copilot.WithAuthURL(srv.URL))
+	if _, err := all.GetToken(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected a 401 error once every seat is rejected, got %v", err)
+	}
+}
+
+func TestTokenPoolRejectedSeatCooldown(t *testing.T) {
+	setTestConfigHome(t)
+	srv, calls := newCountingSeatTokenServer(t, "revoked")
+	pool := newTestTokenPool(t, []string{"alice", "revoked"}, copilot.WithAuthURL(srv.URL))
+
+	getTokens := func() {
+		for i := 0; i < 6; i++ {
+			if token, err := pool.GetToken(context.Background()); err != nil || token != "copilot-alice" {
+				t.Fatalf("expected alice's token, got %q and %v", token, err)
+			}
+		}
+	}
+	getTokens()
+	before := calls.get("revoked")
+	if before == 0 {
+		t.Fatal("expected the rejected seat to be tried")
+	}
+	getTokens()
+	if n := calls.get("revoked") - before; n != 0 {
+		t.Errorf("expected the rejected seat to be skipped after it was rejected, got %d more token requests", n)
+	}
+}
+
+func TestTokenPoolRefreshTokenOfOneSeat(t *testing.T) {
+	setTestConfigHome(t)
+	srv, calls := newCountingSeatTokenServer(t)
+	pool := newTestTokenPool(t, []string{"alice", "bob"}, copilot.WithAuthURL(srv.URL))
+	for i := 0; i < 2; i++ {
+		if _, err := pool.GetToken(context.Background()); err != nil {
+			t.Fatalf("GetToken: %v", err)
+		}
+	}
+
+	alice, bob := calls.get("alice"), calls.get("bob")
+	if err := pool.RefreshToken(context.Background(), "copilot-alice"); err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if calls.get("alice") != alice+1 || calls.get("bob") != bob {
+		t.Errorf("expected only alice's seat to be refreshed, got %d and %d more requests", calls.get("alice")-alice, calls.get("bob")-bob)
+	}
+
+	// A token no seat holds any more is left alone
+	if err := pool.RefreshToken(context.Background(), "copilot-unknown"); err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if calls.get("alice") != alice+1 || calls.get("bob") != bob {
+		t.Error("expected no refresh for a token no seat holds")
+	}
+}
+
+func TestTokenPoolMetricsPerSeat(t *testing.T) {
+	setTestConfigHome(t)
+	srv := newSeatTokenServer(t)
+	registry := prometheus.NewRegistry()
+	pool := newTestTokenPool(t, []string{"alice", "bob"}, copilot.WithAuthURL(srv.URL), copilot.WithMetrics(registry))
+	if _, err := pool.GetToken(context.Background()); err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	seats := make(map[string]bool)
+	for _, family := range families {
+		if family.GetName() != "copilot_token_expiry_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "seat" {
+					seats[label.GetValue()] = true
+				}
+			}
+		}
+	}
+	if !seats["0"] || !seats["1"] {
+		t.Errorf("expected expiry gauges for seats 0 and 1, got %v", seats)
+	}
+}
+
+func TestOAuthTokensConfig(t *testing.T) {
+	home, _ := clearOAuthTokenEnv(t)
+	t.Setenv("COPILOT_TOKEN", "test-token")
+
+	t.Setenv("COPILOT_OAUTH_TOKENS", " alice, bob ,,carol")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(cfg.CopilotOAuthTokens, ",") != "alice,bob,carol" || cfg.CopilotOAuthToken != "alice" {
+		t.Errorf("expected three OAuth tokens starting with alice, got %q and %q", cfg.CopilotOAuthTokens, cfg.CopilotOAuthToken)
+	}
+
+	// Without COPILOT_OAUTH_TOKENS every account of apps.json is a seat
+	os.Unsetenv("COPILOT_OAUTH_TOKENS")
+	writeTokenSource(t, filepath.Join(home, ".config", "github-copilot", "apps.json"),
+		`{"github.com:Iv1.b": {"oauth_token": "second"}, "github.com:Iv1.a": {"oauth_token": "first"}, "github.com:Iv1.c": {"oauth_token": "first"}}`)
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(cfg.CopilotOAuthTokens, ",") != "first,second" || cfg.CopilotOAuthToken != "first" {
+		t.Errorf("expected the distinct apps.json tokens, got %q and %q", cfg.CopilotOAuthTokens, cfg.CopilotOAuthToken)
+	}
+}
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestTokenPrewarm(t *testing.T) {
+	srv, calls := newTokenServer(t, time.Hour)
+
+	// The cached token is still usable but expires inside the pre-warm window.
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "expiring-token", 200*time.Second)
+	tm := startTestTokenManager(t, copilot.WithAuthURL(srv.URL), copilot.WithPrewarm(10*time.Minute))
+
+	deadline := time.Now().Add(3 * time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected one background refresh, got %d", calls.Load())
+	}
+
+	start := time.Now()
+	token, err := tm.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if token != "refreshed-token-1" {
+		t.Errorf("expected pre-warmed token, got %q", token)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("GetToken took %v, expected no refresh latency", elapsed)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("GetToken triggered an extra refresh: %d calls", calls.Load())
+	}
+}
+
+func TestTokenPrewarmOutsideWindow(t *testing.T) {
+	srv, calls := newTokenServer(t, time.Hour)
+	tm := newTestTokenManager(t, copilot.WithAuthURL(srv.URL), copilot.WithPrewarm(5*time.Minute))
+
+	token, err := tm.GetToken(context.Background())
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if token != "test-copilot-token" {
+		t.Errorf("expected cached token, got %q", token)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if calls.Load() != 0 {
+		t.Errorf("expected no refresh for a token outside the pre-warm window, got %d", calls.Load())
+	}
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/copilot"
+)
+
+func TestConcurrentGetTokenRefreshesOnce(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	// A valid token keeps the background loop asleep during the test
+	writeTestToken(t, copilotDir, "test-copilot-token", time.Hour)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		// Slow enough for every caller to arrive during the refresh
+		time.Sleep(100 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(copilot.CopilotToken{
+			Token:     "refreshed-token-" + strconv.Itoa(int(n)),
+			ExpiresAt: float64(time.Now().Add(time.Hour).Unix()),
+		})
+	}))
+	defer srv.Close()
+	tm := startTestTokenManager(t, copilot.WithAuthURL(srv.URL))
+
+	tokenFile := filepath.Join(copilotDir, "token.json")
+	for cycle := 1; cycle <= 2; cycle++ {
+		// Expire the token through the file, as another process would
+		writeTestToken(t, copilotDir, "expired-token", -time.Minute)
+		mtime := time.Now().Add(time.Duration(cycle) * time.Minute)
+		if err := os.Chtimes(tokenFile, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Until(tm.TokenExpiry()) > 0 && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if time.Until(tm.TokenExpiry()) > 0 {
+			t.Fatalf("cycle %d: the expired token was not loaded", cycle)
+		}
+
+		var wg sync.WaitGroup
+		tokens := make([]string, 10)
+		for i := range tokens {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				token, err := tm.GetToken(context.Background())
+				if err != nil {
+					t.Errorf("GetToken: %v", err)
+				}
+				tokens[i] = token
+			}()
+		}
+		wg.Wait()
+		if n := calls.Load(); n != int32(cycle) {
+			t.Fatalf("cycle %d: expected one token request per refresh cycle, got %d in total", cycle, n)
+		}
+		want := "refreshed-token-" + strconv.Itoa(cycle)
+		for i, token := range tokens {
+			if token != want {
+				t.Errorf("cycle %d: caller %d got %q, want %q", cycle, i, token, want)
+			}
+		}
+	}
+}
+
+// countBackgroundRefreshes counts the token requests a TokenManager without a token
+// makes in d against a token endpoint answering with status.
+func countBackgroundRefreshes(t *testing.T, status int, d time.Duration, opts ...copilot.TokenManagerOption) int32 {
+	t.Helper()
+	writeTestApps(t, setTestConfigHome(t))
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, http.StatusText(status), status)
+	}))
+	defer srv.Close()
+	tm := startTestTokenManager(t, append(opts, copilot.WithAuthURL(srv.URL))...)
+	time.Sleep(d)
+	tm.Close()
+	return calls.Load()
+}
+
+func TestBackgroundRefreshBacksOff(t *testing.T) {
+	// The default backoff waits at least 30 seconds after a failure
+	if n := countBackgroundRefreshes(t, http.StatusServiceUnavailable, time.Second); n != 1 {
+		t.Errorf("expected a single attempt in the first second, got %d", n)
+	}
+
+	// Attempts at 0, 100-110ms, 300-320ms and 700-740ms, then after 1.5s at most
+	fast := copilot.WithRetryConfig(copilot.RetryConfig{
+		RefreshInitialDelay: 100 * time.Millisecond,
+		RefreshMaxDelay:     time.Second,
+	})
+	if n := countBackgroundRefreshes(t, http.StatusServiceUnavailable, time.Second, fast); n != 4 {
+		t.Errorf("expected 4 attempts with doubling delays, got %d", n)
+	}
+
+	// A rejected OAuth token waits the longest delay straight away
+	if n := countBackgroundRefreshes(t, http.StatusUnauthorized, 800*time.Millisecond, fast); n != 1 {
+		t.Errorf("expected a single attempt after a 401, got %d", n)
+	}
+}
+
+func TestSharedRefreshSurvivesCallerCancel(t *testing.T) {
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	writeTestToken(t, copilotDir, "expired-token", -time.Minute)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(300 * time.Millisecond)
+		_ = json.NewEncoder(w).Encode(copilot.CopilotToken{
+			Token:     "refreshed-token",
+			ExpiresAt: float64(time.Now().Add(time.Hour).Unix()),
+		})
+	}))
+	defer srv.Close()
+	tm := startTestTokenManager(t, copilot.WithAuthURL(srv.URL))
+
+	// The first caller starts the refresh and goes away while it runs
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := tm.GetToken(ctx)
+		first <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancelled caller to get context.Canceled, got %v", err)
+	}
+
+	token, err := tm.GetToken(context.Background())
+	if err != nil || token != "refreshed-token" {
+		t.Errorf("expected the shared refresh to complete, got %q and %v", token, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a single token request, got %d", n)
+	}
+}
@@ -0,0 +1,144 @@
+package test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+// pipeListener hands the server end of net.Pipe connections to an http.Server.
+type pipeListener struct {
+	conns chan net.Conn
+	once  sync.Once
+	done  chan struct{}
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+// dial returns the client end of a new connection to the server.
+func (l *pipeListener) dial() net.Conn {
+	client, server := net.Pipe()
+	l.conns <- server
+	return client
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+// startTunnelProxy serves a router built from cfg over a pipeListener.
+func startTunnelProxy(t *testing.T, cfg *config.Config) *pipeListener {
+	t.Helper()
+	ln := newPipeListener()
+	srv := &http.Server{Handler: api.NewRouter(cfg, newTestTokenManager(t), nil)}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return ln
+}
+
+// connect sends a CONNECT request for target on conn and returns the response.
+func connect(t *testing.T, conn net.Conn, target, token string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	go func() {
+		_, _ = io.WriteString(conn, "CONNECT /tunnel/"+target+" HTTP/1.1\r\nHost: "+target+"\r\nAuthorization: Bearer "+token+"\r\n\r\n")
+	}()
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		t.Fatalf("reading CONNECT response: %v", err)
+	}
+	return resp, br
+}
+
+func TestTunnel(t *testing.T) {
+	// An echo server stands in for the Copilot API
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		for {
+			conn, err := upstream.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	target := upstream.Addr().String()
+	ln := startTunnelProxy(t, &config.Config{CopilotToken: "test-token", CopilotBaseURL: "http://" + target, EnableTunnel: true})
+
+	t.Run("established", func(t *testing.T) {
+		conn := ln.dial()
+		defer conn.Close()
+		resp, br := connect(t, conn, target, "test-token")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", resp.StatusCode)
+		}
+
+		go func() { _, _ = io.WriteString(conn, "ping through the tunnel") }()
+		got := make([]byte, len("ping through the tunnel"))
+		if _, err := io.ReadFull(br, got); err != nil {
+			t.Fatalf("reading echoed bytes: %v", err)
+		}
+		if string(got) != "ping through the tunnel" {
+			t.Errorf("expected the bytes to be echoed through the tunnel, got %q", got)
+		}
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		conn := ln.dial()
+		defer conn.Close()
+		resp, _ := connect(t, conn, target, "wrong-token")
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("expected status 403, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("other host", func(t *testing.T) {
+		conn := ln.dial()
+		defer conn.Close()
+		resp, _ := connect(t, conn, "example.com:443", "test-token")
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusForbidden || !strings.Contains(string(body), "Copilot API") {
+			t.Errorf("expected tunnels to other hosts to be refused, got %d: %s", resp.StatusCode, body)
+		}
+	})
+}
+
+func TestTunnelDisabled(t *testing.T) {
+	ln := startTunnelProxy(t, &config.Config{CopilotToken: "test-token", CopilotBaseURL: "https://api.githubcopilot.com"})
+	conn := ln.dial()
+	defer conn.Close()
+	resp, _ := connect(t, conn, "api.githubcopilot.com:443", "test-token")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected status 404 without ENABLE_TUNNEL, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,90 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+func TestUpstreamRateLimitHeaders(t *testing.T) {
+	remaining := []string{"42", "17", "30"}
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Remaining", remaining[calls%len(remaining)])
+		w.Header().Set("X-RateLimit-Reset", "1767225600")
+		w.Header().Set("X-RateLimit-Resource", "chat")
+		w.Header().Set("Content-Type", "application/json")
+		calls++
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	send := func(t *testing.T, handler http.Handler) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("Authorization", "Bearer test-token")
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr
+	}
+
+	t.Run("forwarded", func(t *testing.T) {
+		calls = 0
+		registry := prometheus.NewRegistry()
+		cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, ForwardRateLimitHeaders: true}
+		handler := api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithMetrics(registry))
+
+		rr := send(t, handler)
+		for header, want := range map[string]string{
+			"X-RateLimit-Limit":     "100",
+			"X-RateLimit-Remaining": "42",
+			"X-RateLimit-Reset":     "1767225600",
+			"X-RateLimit-Resource":  "chat",
+		} {
+			if got := rr.Header().Get(header); got != want {
+				t.Errorf("expected %s %q, got %q", header, want, got)
+			}
+		}
+		send(t, handler)
+		send(t, handler)
+
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatalf("gather metrics: %v", err)
+		}
+		var found bool
+		for _, mf := range families {
+			if mf.GetName() != "copilot_upstream_rate_limit_remaining" {
+				continue
+			}
+			found = true
+			if got := mf.GetMetric()[0].GetGauge().GetValue(); got != 17 {
+				t.Errorf("expected the gauge to hold the minimum remaining 17, got %v", got)
+			}
+		}
+		if !found {
+			t.Error("copilot_upstream_rate_limit_remaining was not registered")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+		handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+		rr := send(t, handler)
+		if got := rr.Header().Get("X-RateLimit-Remaining"); got != "" {
+			t.Errorf("expected X-RateLimit-Remaining to be dropped, got %q", got)
+		}
+	})
+}
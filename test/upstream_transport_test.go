@@ -0,0 +1,176 @@
+package test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+func TestUpstreamTransportSettings(t *testing.T) {
+	tr := copilot.NewUpstreamTransport(&config.Config{
+		UpstreamMaxIdleConns:          50,
+		UpstreamMaxIdleConnsPerHost:   20,
+		UpstreamIdleConnTimeout:       30 * time.Second,
+		UpstreamTLSHandshakeTimeout:   5 * time.Second,
+		UpstreamExpectContinueTimeout: 2 * time.Second,
+	})
+	if tr.MaxIdleConns != 50 || tr.MaxIdleConnsPerHost != 20 {
+		t.Errorf("expected idle limits 50/20, got %d/%d", tr.MaxIdleConns, tr.MaxIdleConnsPerHost)
+	}
+	if tr.IdleConnTimeout != 30*time.Second || tr.TLSHandshakeTimeout != 5*time.Second || tr.ExpectContinueTimeout != 2*time.Second {
+		t.Errorf("unexpected timeouts: %v %v %v", tr.IdleConnTimeout, tr.TLSHandshakeTimeout, tr.ExpectContinueTimeout)
+	}
+
+	tr = copilot.NewUpstreamTransport(&config.Config{})
+	if tr.MaxIdleConnsPerHost != 100 {
+		t.Errorf("expected 100 idle connections per host by default, got %d", tr.MaxIdleConnsPerHost)
+	}
+}
+
+func TestUpstreamConnectionReuse(t *testing.T) {
+	const concurrency = 10
+
+	var conns atomic.Int32
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hold each request so concurrent requests need their own connection
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	upstream.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	upstream.Start()
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	burst := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`))
+				req.Header.Set("Authorization", "Bearer test-token")
+				req.Header.Set("Content-Type", "application/json")
+				rr := httptest.NewRecorder()
+				handler.ServeHTTP(rr, req)
+				if rr.Code != http.StatusOK {
+					t.Errorf("expected status 200, got %d", rr.Code)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	burst()
+	opened := conns.Load()
+	burst()
+	if got := conns.Load(); got != opened {
+		t.Errorf("expected the second burst to reuse %d idle connections, but %d new ones were opened", opened, got-opened)
+	}
+}
+
+func TestMaxUpstreamConcurrency(t *testing.T) {
+	const limit = 3
+
+	var conns, inFlight, maxInFlight atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for m := maxInFlight.Load(); n > m && !maxInFlight.CompareAndSwap(m, n); m = maxInFlight.Load() {
+		}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	upstream.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	upstream.Start()
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, MaxUpstreamConcurrency: limit}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2*limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rr := postChatBody(handler, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`); rr.Code != http.StatusOK {
+				t.Errorf("expected status 200, got %d", rr.Code)
+			}
+		}()
+	}
+	time.Sleep(200 * time.Millisecond)
+	if n, c := inFlight.Load(), conns.Load(); n != limit || c != limit {
+		t.Errorf("expected %d requests on %d connections while the rest queue, got %d requests on %d connections", limit, limit, n, c)
+	}
+	close(release)
+	wg.Wait()
+	if m := maxInFlight.Load(); m != limit {
+		t.Errorf("expected at most %d requests in flight, got %d", limit, m)
+	}
+}
+
+func TestMaxUpstreamConcurrencyHeldByStreams(t *testing.T) {
+	var requests atomic.Int32
+	endStream := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\n"))
+			w.(http.Flusher).Flush()
+			<-endStream
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, MaxUpstreamConcurrency: 1}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		postChatBody(handler, `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	}()
+	for requests.Load() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- postChatBody(handler, `{"model":"gpt-4o","messages":[{"role":"user","content":"hi"}]}`).Code
+	}()
+	select {
+	case <-done:
+		t.Fatal("expected the request to wait while the stream holds the only slot")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(endStream)
+	<-streamDone
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("expected status 200 once the stream ended, got %d", code)
+	}
+}
@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/pkg/config"
+)
+
+// newUsageServer starts a mock GitHub API serving the Copilot usage of the acme
+// organization; the counter reports how often it was requested.
+func newUsageServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orgs/acme/copilot/usage" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		calls.Add(1)
+		now := time.Now().UTC()
+		wantSince := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+		if r.Header.Get("Authorization") != "token test-oauth-token" || r.URL.Query().Get("since") != wantSince {
+			http.Error(w, `{"message":"Bad request"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"day":"2024-06-01","total_suggestions_count":1000,"total_acceptances_count":800}]`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func getUpstreamUsage(handler http.Handler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/admin/upstream-usage", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestUpstreamUsage(t *testing.T) {
+	github, calls := newUsageServer(t)
+	cfg := &config.Config{CopilotToken: "test-token", AdminToken: "admin-token", GitHubAPIURL: github.URL, GitHubOrg: "acme", UsageCacheTTL: 200 * time.Millisecond}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	if rr := getUpstreamUsage(handler, "test-token"); rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the admin token, got %d", rr.Code)
+	}
+
+	for i, wantCache := range []string{"MISS", "HIT"} {
+		rr := getUpstreamUsage(handler, "admin-token")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("request %d: expected status 200, got %d: %s", i, rr.Code, rr.Body.String())
+		}
+		if got := rr.Header().Get("X-Cache"); got != wantCache {
+			t.Errorf("request %d: expected X-Cache %s, got %q", i, wantCache, got)
+		}
+		if want := `[{"day":"2024-06-01","total_suggestions_count":1000,"total_acceptances_count":800}]`; rr.Body.String() != want {
+			t.Errorf("request %d: expected the GitHub response as is, got %s", i, rr.Body.String())
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected one GitHub request while cached, got %d", calls.Load())
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if rr := getUpstreamUsage(handler, "admin-token"); rr.Header().Get("X-Cache") != "MISS" || calls.Load() != 2 {
+		t.Errorf("expected an expired cache to be refetched, got X-Cache %q after %d requests", rr.Header().Get("X-Cache"), calls.Load())
+	}
+
+	cfg.GitHubOrg = "other"
+	if rr := getUpstreamUsage(api.NewRouter(cfg, newTestTokenManager(t), nil), "admin-token"); rr.Code != http.StatusBadGateway {
+		t.Errorf("expected 502 for a GitHub error, got %d: %s", rr.Code, rr.Body.String())
+	}
+	cfg.GitHubOrg = ""
+	if rr := getUpstreamUsage(api.NewRouter(cfg, newTestTokenManager(t), nil), "admin-token"); rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without GITHUB_ORG, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestUpstreamUsageBackgroundRefresh(t *testing.T) {
+	github, calls := newUsageServer(t)
+	cfg := &config.Config{CopilotToken: "test-token", AdminToken: "admin-token", GitHubAPIURL: github.URL, GitHubOrg: "acme", UsageCacheTTL: 200 * time.Millisecond}
+	usage := api.NewUpstreamUsage(cfg, newTestTokenManager(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	usage.Start(ctx)
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil, api.WithUpstreamUsage(usage))
+
+	// The first refresh runs at start and the next ones before the cache expires
+	time.Sleep(500 * time.Millisecond)
+	if n := calls.Load(); n < 3 {
+		t.Fatalf("expected background refreshes, got %d GitHub requests", n)
+	}
+	rr := getUpstreamUsage(handler, "admin-token")
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("expected the refreshed usage to be served from the cache, got %d with X-Cache %q", rr.Code, rr.Header().Get("X-Cache"))
+	}
+}
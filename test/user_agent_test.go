@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"copilot-api/internal/api"
+	"copilot-api/internal/copilot"
+	"copilot-api/pkg/config"
+)
+
+const testUserAgent = "go-copilot-api-test/9.9"
+
+func TestUserAgentProxy(t *testing.T) {
+	var gotUA string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{CopilotToken: "test-token", CopilotBaseURL: upstream.URL, UserAgent: testUserAgent}
+	handler := api.NewRouter(cfg, newTestTokenManager(t), nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"input":"hi"}`))
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "client-agent")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotUA != testUserAgent {
+		t.Errorf("expected User-Agent %q upstream, got %q", testUserAgent, gotUA)
+	}
+}
+
+func TestUserAgentTokenRefresh(t *testing.T) {
+	var gotUA, gotPlugin string
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		gotPlugin = r.Header.Get("Editor-Plugin-Version")
+		_, _ = w.Write([]byte(`{"token":"fresh","expires_at":` + itoa(time.Now().Add(time.Hour).Unix()) + `}`))
+	}))
+	defer auth.Close()
+
+	copilotDir := setTestConfigHome(t)
+	writeTestApps(t, copilotDir)
+	tm := startTestTokenManager(t, copilot.WithAuthURL(auth.URL), copilot.WithUserAgent(testUserAgent))
+	if _, err := tm.GetToken(context.Background()); err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if gotUA != testUserAgent {
+		t.Errorf("expected User-Agent %q on token refresh, got %q", testUserAgent, gotUA)
+	}
+	if !strings.Contains(gotPlugin, testUserAgent) {
+		t.Errorf("expected Editor-Plugin-Version to include %q, got %q", testUserAgent, gotPlugin)
+	}
+}
+
+func TestUserAgentModelsFetch(t *testing.T) {
+	var gotUA string
+	catalog := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte(testModelsJSON))
+	}))
+	defer catalog.Close()
+
+	_, err := copilot.NewModelsCache(context.Background(), "test-token", time.Hour,
+		copilot.WithModelsURL(catalog.URL), copilot.WithModelsUserAgent(testUserAgent))
+	if err != nil {
+		t.Fatalf("failed to create models cache: %v", err)
+	}
+	if gotUA != testUserAgent {
+		t.Errorf("expected User-Agent %q on models fetch, got %q", testUserAgent, gotUA)
+	}
+}